@@ -0,0 +1,550 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server   ServerConfig
+	Database DatabaseConfig
+	Token    TokenConfig
+	Password PasswordConfig
+	Mail     MailConfig
+	Verify   VerifyConfig
+	Lockout  LockoutConfig
+	RBAC     RBACConfig
+	MFA      MFAConfig
+	WebAuthn WebAuthnConfig
+	OAuth    OAuthConfig
+}
+
+type ServerConfig struct {
+	Addr string
+}
+
+type DatabaseConfig struct {
+	// Driver is "mysql", "postgres" or "sqlite".
+	Driver       string
+	User         string
+	Password     string
+	PasswordFile string
+	Host         string
+	// Port 0 is the default port of the driver.
+	Port int
+	// Name is the database, or the file path for SQLite. ":memory:" keeps
+	// an SQLite database in memory until the process exits.
+	Name string
+	// SSLMode is the sslmode of PostgreSQL connections, e.g. "disable" or
+	// "verify-full". It is left to the driver when empty.
+	SSLMode string
+	// ReadTimeout and WriteTimeout bound a single lookup or change of a
+	// user, within the time the request has. Zero only stops it with the
+	// request.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+type TokenConfig struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// KeysDir holds the PEM signing keys, see helper.LoadKeyRing. When it is
+	// empty an ephemeral key is generated on every start.
+	KeysDir string
+}
+
+type PasswordConfig struct {
+	MinLength int
+	// ResetURL is the page linked from reset emails.
+	ResetURL string
+	ResetTTL time.Duration
+	// Hasher is "argon2id" or "bcrypt". Hashes of the other algorithm still
+	// verify and are upgraded on the next login.
+	Hasher            string
+	Argon2Memory      int
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+}
+
+type VerifyConfig struct {
+	// URL is the verification endpoint linked from the email.
+	URL string
+	// ChangeEmailURL is the endpoint linked from the email that confirms a
+	// new address.
+	ChangeEmailURL string
+	TTL            time.Duration
+	ResendInterval time.Duration
+	// AllowUnverifiedLogin lets users log in before they verify. It is on by
+	// default so accounts created before verification existed keep working.
+	AllowUnverifiedLogin bool
+}
+
+type LockoutConfig struct {
+	// Store is "sql" to share lockouts between instances or "memory".
+	Store         string
+	MaxFailures   int
+	IPMaxFailures int
+	Window        time.Duration
+	BaseDuration  time.Duration
+	MaxDuration   time.Duration
+}
+
+type RBACConfig struct {
+	// AdminEmail names an existing account that is given the admin role
+	// when the start creates that role. It is how the first administrator is
+	// created.
+	AdminEmail string
+}
+
+type MFAConfig struct {
+	// Issuer is the name authenticator apps show next to the account.
+	Issuer string
+	// ChallengeTTL is how long the second login step may take.
+	ChallengeTTL time.Duration
+}
+
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are bound to. It must be the host of the
+	// origins or a parent domain of it.
+	RPID   string
+	RPName string
+	// Origins is a comma separated list of web origins allowed to use
+	// passkeys, e.g. "https://app.example.com".
+	Origins string
+	Timeout time.Duration
+}
+
+// OriginList splits Origins.
+func (w WebAuthnConfig) OriginList() []string {
+	var origins []string
+	for _, origin := range strings.Split(w.Origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+type OAuthConfig struct {
+	// CodeTTL is how long an authorization code may wait to be exchanged.
+	CodeTTL time.Duration
+	// Issuer is the public base URL of this service, OpenID Connect clients
+	// compare it with the iss claim of id tokens.
+	Issuer string
+	// AuthorizationURL is the frontend page that handles authorization
+	// requests and calls /oauth/authorize.
+	AuthorizationURL string
+}
+
+type MailConfig struct {
+	// Driver is "log" or "file".
+	Driver string
+	From   string
+	// Dir is where the file driver writes messages.
+	Dir string
+}
+
+// binding ties one setting to its file key, environment variable and flag.
+type binding struct {
+	key    string
+	env    string
+	usage  string
+	target interface{}
+}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr: ":3000",
+		},
+		Database: DatabaseConfig{
+			Driver: "mysql",
+			User:   "root",
+			Host:   "localhost",
+			Name:   "intern_sekolahmu",
+			// Lookups and single row writes, anything slower is stuck.
+			ReadTimeout:  time.Second * 5,
+			WriteTimeout: time.Second * 10,
+		},
+		Token: TokenConfig{
+			AccessTTL:  time.Minute * 15,
+			RefreshTTL: time.Hour * 24 * 30,
+		},
+		Password: PasswordConfig{
+			MinLength: 8,
+			ResetURL:  "http://localhost:3000/password/reset",
+			ResetTTL:  time.Minute * 30,
+			Hasher:    "argon2id",
+			// 64 MiB
+			Argon2Memory:      64 * 1024,
+			Argon2Iterations:  3,
+			Argon2Parallelism: 2,
+			BcryptCost:        12,
+		},
+		Mail: MailConfig{
+			Driver: "log",
+			From:   "no-reply@localhost",
+			Dir:    "mail",
+		},
+		Verify: VerifyConfig{
+			URL:                  "http://localhost:3000/verify-email",
+			ChangeEmailURL:       "http://localhost:3000/me/email/confirm",
+			TTL:                  time.Hour * 24,
+			ResendInterval:       time.Minute,
+			AllowUnverifiedLogin: true,
+		},
+		Lockout: LockoutConfig{
+			Store:         "sql",
+			MaxFailures:   5,
+			IPMaxFailures: 50,
+			Window:        time.Minute * 15,
+			BaseDuration:  time.Minute,
+			MaxDuration:   time.Hour,
+		},
+		MFA: MFAConfig{
+			Issuer:       "api-auth",
+			ChallengeTTL: time.Minute * 5,
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    "localhost",
+			RPName:  "api-auth",
+			Origins: "http://localhost:3000",
+			Timeout: time.Minute * 5,
+		},
+		OAuth: OAuthConfig{
+			CodeTTL:          time.Minute,
+			Issuer:           "http://localhost:3000",
+			AuthorizationURL: "http://localhost:3000/oauth/authorize",
+		},
+	}
+}
+
+func (c *Config) bindings() []binding {
+	return []binding{
+		{"server.addr", "APP_SERVER_ADDR", "HTTP listen address", &c.Server.Addr},
+		{"database.driver", "APP_DB_DRIVER", "database driver, mysql, postgres or sqlite", &c.Database.Driver},
+		{"database.user", "APP_DB_USER", "database user", &c.Database.User},
+		{"database.password", "APP_DB_PASSWORD", "database password, prefer database.password_file", &c.Database.Password},
+		{"database.password_file", "APP_DB_PASSWORD_FILE", "file holding the database password", &c.Database.PasswordFile},
+		{"database.host", "APP_DB_HOST", "database host", &c.Database.Host},
+		{"database.port", "APP_DB_PORT", "database port", &c.Database.Port},
+		{"database.name", "APP_DB_NAME", "database name, or file for sqlite", &c.Database.Name},
+		{"database.ssl_mode", "APP_DB_SSL_MODE", "sslmode of postgres connections", &c.Database.SSLMode},
+		{"database.read_timeout", "APP_DB_READ_TIMEOUT", "timeout of a user lookup, 0 for none", &c.Database.ReadTimeout},
+		{"database.write_timeout", "APP_DB_WRITE_TIMEOUT", "timeout of a user change, 0 for none", &c.Database.WriteTimeout},
+		{"token.access_ttl", "APP_TOKEN_ACCESS_TTL", "access token lifetime", &c.Token.AccessTTL},
+		{"token.refresh_ttl", "APP_TOKEN_REFRESH_TTL", "refresh token lifetime", &c.Token.RefreshTTL},
+		{"token.keys_dir", "APP_TOKEN_KEYS_DIR", "directory with PEM signing keys", &c.Token.KeysDir},
+		{"password.min_length", "APP_PASSWORD_MIN_LENGTH", "minimum password length", &c.Password.MinLength},
+		{"password.reset_url", "APP_PASSWORD_RESET_URL", "page linked from password reset emails", &c.Password.ResetURL},
+		{"password.reset_ttl", "APP_PASSWORD_RESET_TTL", "password reset token lifetime", &c.Password.ResetTTL},
+		{"password.hasher", "APP_PASSWORD_HASHER", "password hashing algorithm, argon2id or bcrypt", &c.Password.Hasher},
+		{"password.argon2_memory", "APP_PASSWORD_ARGON2_MEMORY", "argon2id memory in KiB", &c.Password.Argon2Memory},
+		{"password.argon2_iterations", "APP_PASSWORD_ARGON2_ITERATIONS", "argon2id iterations", &c.Password.Argon2Iterations},
+		{"password.argon2_parallelism", "APP_PASSWORD_ARGON2_PARALLELISM", "argon2id lanes", &c.Password.Argon2Parallelism},
+		{"password.bcrypt_cost", "APP_PASSWORD_BCRYPT_COST", "bcrypt cost", &c.Password.BcryptCost},
+		{"mail.driver", "APP_MAIL_DRIVER", "mail driver, log or file", &c.Mail.Driver},
+		{"mail.from", "APP_MAIL_FROM", "sender address", &c.Mail.From},
+		{"mail.dir", "APP_MAIL_DIR", "output directory of the file mail driver", &c.Mail.Dir},
+		{"verify.url", "APP_VERIFY_URL", "email verification endpoint linked from emails", &c.Verify.URL},
+		{"verify.change_email_url", "APP_VERIFY_CHANGE_EMAIL_URL", "email change confirmation endpoint linked from emails", &c.Verify.ChangeEmailURL},
+		{"verify.ttl", "APP_VERIFY_TTL", "verification link lifetime", &c.Verify.TTL},
+		{"verify.resend_interval", "APP_VERIFY_RESEND_INTERVAL", "minimum time between verification emails", &c.Verify.ResendInterval},
+		{"verify.allow_unverified_login", "APP_VERIFY_ALLOW_UNVERIFIED_LOGIN", "let unverified users log in", &c.Verify.AllowUnverifiedLogin},
+		{"lockout.store", "APP_LOCKOUT_STORE", "login attempt store, sql or memory", &c.Lockout.Store},
+		{"lockout.max_failures", "APP_LOCKOUT_MAX_FAILURES", "failed logins per account before a lockout, 0 disables", &c.Lockout.MaxFailures},
+		{"lockout.ip_max_failures", "APP_LOCKOUT_IP_MAX_FAILURES", "failed logins per client address before a lockout, 0 disables", &c.Lockout.IPMaxFailures},
+		{"lockout.window", "APP_LOCKOUT_WINDOW", "window in which failures are counted", &c.Lockout.Window},
+		{"lockout.base_duration", "APP_LOCKOUT_BASE_DURATION", "length of the first lockout", &c.Lockout.BaseDuration},
+		{"lockout.max_duration", "APP_LOCKOUT_MAX_DURATION", "upper bound of the lockout backoff", &c.Lockout.MaxDuration},
+		{"mfa.issuer", "APP_MFA_ISSUER", "issuer shown in authenticator apps", &c.MFA.Issuer},
+		{"mfa.challenge_ttl", "APP_MFA_CHALLENGE_TTL", "time allowed for the second login step", &c.MFA.ChallengeTTL},
+		{"webauthn.rp_id", "APP_WEBAUTHN_RP_ID", "domain passkeys are bound to", &c.WebAuthn.RPID},
+		{"webauthn.rp_name", "APP_WEBAUTHN_RP_NAME", "name shown when creating a passkey", &c.WebAuthn.RPName},
+		{"webauthn.origins", "APP_WEBAUTHN_ORIGINS", "comma separated origins allowed to use passkeys", &c.WebAuthn.Origins},
+		{"webauthn.timeout", "APP_WEBAUTHN_TIMEOUT", "time allowed for a passkey ceremony", &c.WebAuthn.Timeout},
+		{"oauth.code_ttl", "APP_OAUTH_CODE_TTL", "authorization code lifetime", &c.OAuth.CodeTTL},
+		{"oauth.issuer", "APP_OAUTH_ISSUER", "public base URL used as OpenID Connect issuer", &c.OAuth.Issuer},
+		{"oauth.authorization_url", "APP_OAUTH_AUTHORIZATION_URL", "frontend page handling authorization requests", &c.OAuth.AuthorizationURL},
+		{"rbac.admin_email", "APP_RBAC_ADMIN_EMAIL", "account given the admin role when it is created", &c.RBAC.AdminEmail},
+	}
+}
+
+func (b binding) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(b.key)
+}
+
+func (b binding) set(value string) error {
+	var err error
+	switch target := b.target.(type) {
+	case *string:
+		*target = value
+	case *int:
+		*target, err = strconv.Atoi(value)
+	case *time.Duration:
+		*target, err = time.ParseDuration(value)
+	case *bool:
+		*target, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: invalid value %q", b.key, value)
+	}
+	return nil
+}
+
+// Load builds the configuration from defaults, command line flags, the
+// config file and environment variables. Later sources win, so the
+// precedence is env, then file, then flags. The file is taken from -config or
+// APP_CONFIG_FILE and may be YAML or TOML depending on its extension.
+func Load(args []string) (*Config, error) {
+	c := Default()
+	bindings := c.bindings()
+
+	fs := flag.NewFlagSet("api-auth", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("APP_CONFIG_FILE"), "path to a YAML or TOML config file")
+	flagValues := map[string]*string{}
+	for _, b := range bindings {
+		flagValues[b.flagName()] = fs.String(b.flagName(), "", b.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	fs.Visit(func(f *flag.Flag) {
+		for _, b := range bindings {
+			if b.flagName() == f.Name {
+				if err := b.set(*flagValues[f.Name]); err != nil {
+					errs = append(errs, err.Error())
+				}
+			}
+		}
+	})
+
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bindings {
+			if value, ok := values[b.key]; ok {
+				if err := b.set(value); err != nil {
+					errs = append(errs, err.Error())
+				}
+				delete(values, b.key)
+			}
+		}
+		for key := range values {
+			errs = append(errs, fmt.Sprintf("%s: unknown setting", key))
+		}
+	}
+
+	for _, b := range bindings {
+		if value, ok := os.LookupEnv(b.env); ok {
+			if err := b.set(value); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if c.Database.PasswordFile != "" {
+		secret, err := os.ReadFile(c.Database.PasswordFile)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("database.password_file: %s", err.Error()))
+		} else {
+			c.Database.Password = strings.TrimRight(string(secret), "\r\n")
+		}
+	}
+
+	errs = append(errs, c.validate()...)
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, errors.New("invalid configuration: " + strings.Join(errs, "; "))
+	}
+	return c, nil
+}
+
+func (c *Config) validate() []string {
+	var errs []string
+	if c.Server.Addr == "" {
+		errs = append(errs, "server.addr: must not be empty")
+	}
+	switch c.Database.Driver {
+	case "mysql", "postgres":
+		if c.Database.Host == "" {
+			errs = append(errs, "database.host: must not be empty")
+		}
+		if c.Database.Port < 0 || c.Database.Port > 65535 {
+			errs = append(errs, "database.port: must be between 1 and 65535, or 0 for the default")
+		}
+	case "sqlite":
+	default:
+		errs = append(errs, "database.driver: must be mysql, postgres or sqlite")
+	}
+	if c.Database.Name == "" {
+		errs = append(errs, "database.name: must not be empty")
+	}
+	if c.Database.ReadTimeout < 0 || c.Database.WriteTimeout < 0 {
+		errs = append(errs, "database.read_timeout: timeouts must not be negative")
+	}
+	if c.Token.AccessTTL <= 0 {
+		errs = append(errs, "token.access_ttl: must be positive")
+	}
+	if c.Token.RefreshTTL <= c.Token.AccessTTL {
+		errs = append(errs, "token.refresh_ttl: must be longer than token.access_ttl")
+	}
+	if c.Password.MinLength < 8 {
+		errs = append(errs, "password.min_length: must be at least 8")
+	}
+	if c.Password.ResetURL == "" {
+		errs = append(errs, "password.reset_url: must not be empty")
+	}
+	if c.Password.ResetTTL <= 0 {
+		errs = append(errs, "password.reset_ttl: must be positive")
+	}
+	switch c.Password.Hasher {
+	case "argon2id":
+		if c.Password.Argon2Iterations < 1 {
+			errs = append(errs, "password.argon2_iterations: must be at least 1")
+		}
+		if c.Password.Argon2Parallelism < 1 || c.Password.Argon2Parallelism > 255 {
+			errs = append(errs, "password.argon2_parallelism: must be between 1 and 255")
+		}
+		if c.Password.Argon2Memory < 8*c.Password.Argon2Parallelism || c.Password.Argon2Memory < 19*1024 {
+			errs = append(errs, "password.argon2_memory: must be at least 19456 KiB")
+		}
+	case "bcrypt":
+		if c.Password.BcryptCost < 10 || c.Password.BcryptCost > 31 {
+			errs = append(errs, "password.bcrypt_cost: must be between 10 and 31")
+		}
+	default:
+		errs = append(errs, "password.hasher: must be argon2id or bcrypt")
+	}
+	if c.Verify.URL == "" {
+		errs = append(errs, "verify.url: must not be empty")
+	}
+	if c.Verify.ChangeEmailURL == "" {
+		errs = append(errs, "verify.change_email_url: must not be empty")
+	}
+	if c.Verify.TTL <= 0 {
+		errs = append(errs, "verify.ttl: must be positive")
+	}
+	if c.Verify.ResendInterval < 0 {
+		errs = append(errs, "verify.resend_interval: must not be negative")
+	}
+	if c.Lockout.Store != "sql" && c.Lockout.Store != "memory" {
+		errs = append(errs, "lockout.store: must be sql or memory")
+	}
+	if c.Lockout.MaxFailures < 0 || c.Lockout.IPMaxFailures < 0 {
+		errs = append(errs, "lockout.max_failures: must not be negative")
+	}
+	if c.Lockout.Window <= 0 {
+		errs = append(errs, "lockout.window: must be positive")
+	}
+	if c.Lockout.BaseDuration <= 0 || c.Lockout.MaxDuration < c.Lockout.BaseDuration {
+		errs = append(errs, "lockout.max_duration: must be at least lockout.base_duration, which must be positive")
+	}
+	if c.MFA.Issuer == "" || strings.Contains(c.MFA.Issuer, ":") {
+		errs = append(errs, "mfa.issuer: must not be empty or contain a colon")
+	}
+	if c.MFA.ChallengeTTL <= 0 {
+		errs = append(errs, "mfa.challenge_ttl: must be positive")
+	}
+	if c.WebAuthn.RPID == "" || strings.ContainsAny(c.WebAuthn.RPID, ":/") {
+		errs = append(errs, "webauthn.rp_id: must be a domain")
+	}
+	if c.WebAuthn.RPName == "" {
+		errs = append(errs, "webauthn.rp_name: must not be empty")
+	}
+	if len(c.WebAuthn.OriginList()) == 0 {
+		errs = append(errs, "webauthn.origins: must list at least one origin")
+	}
+	for _, origin := range c.WebAuthn.OriginList() {
+		if u, err := url.Parse(origin); err != nil || u.Host == "" || !secureScheme(u) {
+			errs = append(errs, "webauthn.origins: "+origin+" must use https")
+		}
+	}
+	if c.WebAuthn.Timeout <= 0 {
+		errs = append(errs, "webauthn.timeout: must be positive")
+	}
+	if c.OAuth.CodeTTL <= 0 || c.OAuth.CodeTTL > time.Minute*10 {
+		errs = append(errs, "oauth.code_ttl: must be positive and at most 10m")
+	}
+	if issuer, err := url.Parse(c.OAuth.Issuer); err != nil || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" || strings.HasSuffix(c.OAuth.Issuer, "/") {
+		errs = append(errs, "oauth.issuer: must be a URL without query, fragment or trailing slash")
+	} else if !secureScheme(issuer) {
+		errs = append(errs, "oauth.issuer: must use https")
+	}
+	if c.OAuth.AuthorizationURL == "" {
+		errs = append(errs, "oauth.authorization_url: must not be empty")
+	}
+	if c.Mail.Driver != "log" && c.Mail.Driver != "file" {
+		errs = append(errs, "mail.driver: must be log or file")
+	}
+	return errs
+}
+
+// secureScheme allows plain http only for the loopback host, where nothing
+// can listen in. The host is compared after parsing, a prefix check would let
+// http://localhost.evil.com and http://localhost@evil.com through.
+func secureScheme(u *url.URL) bool {
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return false
+}
+
+// readFile flattens a config file into "section.key" strings so it can go
+// through the same parsing as flags and env vars.
+func readFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &tree)
+	case ".toml":
+		err = toml.Unmarshal(raw, &tree)
+	default:
+		return nil, fmt.Errorf("%s: config file must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	values := map[string]string{}
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, values map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(key, nested, values)
+			continue
+		}
+		values[key] = fmt.Sprint(value)
+	}
+}
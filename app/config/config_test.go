@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  addr: ":4000"
+database:
+  host: file-host
+  name: file-db
+token:
+  access_ttl: 5m
+`)
+
+	t.Setenv("APP_DB_HOST", "env-host")
+
+	cfg, err := Load([]string{"-config", file, "-database-host", "flag-host", "-database-name", "flag-db", "-database-user", "flag-user"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "env-host", cfg.Database.Host)
+	assert.Equal(t, "file-db", cfg.Database.Name)
+	assert.Equal(t, "flag-user", cfg.Database.User)
+	assert.Equal(t, ":4000", cfg.Server.Addr)
+	assert.Equal(t, 5*time.Minute, cfg.Token.AccessTTL)
+}
+
+func TestLoad_TOMLAndSecretFile(t *testing.T) {
+	secret := writeFile(t, "db-password", "s3cret\n")
+	file := writeFile(t, "config.toml", `
+[database]
+port = 3307
+password_file = "`+secret+`"
+
+[password]
+min_length = 12
+`)
+	t.Setenv("APP_CONFIG_FILE", file)
+
+	cfg, err := Load(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3307, cfg.Database.Port)
+	assert.Equal(t, "s3cret", cfg.Database.Password)
+	assert.Equal(t, 12, cfg.Password.MinLength)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		file string
+	}{
+		{name: "bad_duration", args: []string{"-token-access-ttl", "soon"}},
+		{name: "refresh_shorter_than_access", args: []string{"-token-access-ttl", "2h", "-token-refresh-ttl", "1h"}},
+		{name: "weak_password_policy", args: []string{"-password-min-length", "4"}},
+		{name: "unknown_hasher", args: []string{"-password-hasher", "md5"}},
+		{name: "weak_argon2", args: []string{"-password-argon2-memory", "1024"}},
+		{name: "weak_bcrypt", args: []string{"-password-hasher", "bcrypt", "-password-bcrypt-cost", "4"}},
+		{name: "insecure_webauthn_origin", args: []string{"-webauthn-origins", "http://example.com"}},
+		{name: "localhost_subdomain_origin", args: []string{"-webauthn-origins", "http://localhost.evil.com"}},
+		{name: "localhost_userinfo_origin", args: []string{"-webauthn-origins", "http://localhost@evil.com"}},
+		{name: "long_oauth_code_ttl", args: []string{"-oauth-code-ttl", "1h"}},
+		{name: "insecure_oauth_issuer", args: []string{"-oauth-issuer", "http://auth.example.com"}},
+		{name: "localhost_subdomain_issuer", args: []string{"-oauth-issuer", "http://localhost.evil.com"}},
+		{name: "localhost_userinfo_issuer", args: []string{"-oauth-issuer", "http://localhost@evil.com"}},
+		{name: "oauth_issuer_trailing_slash", args: []string{"-oauth-issuer", "https://auth.example.com/"}},
+		{name: "missing_secret_file", args: []string{"-database-password-file", "/does/not/exist"}},
+		{name: "unknown_driver", args: []string{"-database-driver", "oracle"}},
+		{name: "missing_host", args: []string{"-database-driver", "postgres", "-database-host", ""}},
+		{name: "negative_db_timeout", args: []string{"-database-write-timeout", "-1s"}},
+		{name: "unknown_file_key", file: "databse:\n  host: typo\n"},
+		{name: "unknown_flag", args: []string{"-nope"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+			if test.file != "" {
+				args = append(args, "-config", writeFile(t, "config.yml", test.file))
+			}
+
+			cfg, err := Load(args)
+
+			assert.Nil(t, cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoad_SQLiteNeedsNoHost(t *testing.T) {
+	cfg, err := Load([]string{"-database-driver", "sqlite", "-database-host", "", "-database-name", ":memory:"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite", cfg.Database.Driver)
+}
+
+func TestLoad_LoopbackAllowsHTTP(t *testing.T) {
+	for _, origin := range []string{"http://localhost:3000", "http://127.0.0.1:3000", "http://[::1]:3000"} {
+		cfg, err := Load([]string{"-webauthn-origins", origin, "-oauth-issuer", origin})
+
+		assert.NoError(t, err, origin)
+		assert.Equal(t, origin, cfg.OAuth.Issuer)
+	}
+}
+
+func TestDataSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      DatabaseConfig
+		expected string
+	}{
+		{
+			name:     "mysql",
+			cfg:      DatabaseConfig{Driver: "mysql", User: "root", Password: "pw", Host: "db", Name: "auth"},
+			expected: "root:pw@(db:3306)/auth?charset=utf8&parseTime=True&loc=Local",
+		},
+		{
+			name:     "postgres",
+			cfg:      DatabaseConfig{Driver: "postgres", User: "auth", Password: "p@ss word", Host: "db", Port: 5433, Name: "auth", SSLMode: "disable"},
+			expected: "postgres://auth:p%40ss%20word@db:5433/auth?sslmode=disable",
+		},
+		{
+			name:     "postgres_default_port",
+			cfg:      DatabaseConfig{Driver: "postgres", User: "auth", Host: "db", Name: "auth"},
+			expected: "postgres://auth:@db:5432/auth",
+		},
+		{
+			name:     "sqlite",
+			cfg:      DatabaseConfig{Driver: "sqlite", Name: "/var/lib/auth.db"},
+			expected: "/var/lib/auth.db?_busy_timeout=5000",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, dataSource(test.cfg))
+		})
+	}
+}
+
+func TestSetupDatabase_SQLite(t *testing.T) {
+	db := SetupDatabase(DatabaseConfig{Driver: "sqlite", Name: ":memory:"})
+	defer db.Close()
+
+	assert.Equal(t, "sqlite3", db.Dialect().GetName())
+	assert.NoError(t, db.DB().Ping())
+}
@@ -1,25 +1,67 @@
-package config
-
-import (
-	"fmt"
-
-	"github.com/jinzhu/gorm"
-	_ "github.com/jinzhu/gorm/dialects/mysql"
-)
-
-func SetupMysql() *gorm.DB {
-	USER := "root"
-	PASS := ""
-	HOST := "localhost"
-	// PORT := "3306"
-	DBNAME := "intern_sekolahmu"
-
-	URL := fmt.Sprintf("%s:%s@(%s)/%s?charset=utf8&parseTime=True&loc=Local", USER, PASS, HOST, DBNAME)
-
-	db, err := gorm.Open("mysql", URL)
-
-	if err != nil {
-		panic(err.Error())
-	}
-	return db
-}
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// dialects maps the drivers of the config to the names gorm knows them by.
+var dialects = map[string]string{
+	"mysql":    "mysql",
+	"postgres": "postgres",
+	"sqlite":   "sqlite3",
+}
+
+var defaultPorts = map[string]int{
+	"mysql":    3306,
+	"postgres": 5432,
+}
+
+// SetupDatabase connects to the database of cfg.Driver.
+func SetupDatabase(cfg DatabaseConfig) *gorm.DB {
+	db, err := gorm.Open(dialects[cfg.Driver], dataSource(cfg))
+
+	if err != nil {
+		panic(err.Error())
+	}
+	if cfg.Driver == "sqlite" {
+		// SQLite has a single writer, and every connection to :memory: would
+		// open a database of its own.
+		db.DB().SetMaxOpenConns(1)
+	}
+	return db
+}
+
+func dataSource(cfg DatabaseConfig) string {
+	port := cfg.Port
+	if port == 0 {
+		port = defaultPorts[cfg.Driver]
+	}
+
+	switch cfg.Driver {
+	case "postgres":
+		query := url.Values{}
+		if cfg.SSLMode != "" {
+			query.Set("sslmode", cfg.SSLMode)
+		}
+		source := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+			Path:     "/" + cfg.Name,
+			RawQuery: query.Encode(),
+		}
+		return source.String()
+	case "sqlite":
+		return cfg.Name + "?_busy_timeout=5000"
+	default:
+		return fmt.Sprintf("%s:%s@(%s:%d)/%s?charset=utf8&parseTime=True&loc=Local", cfg.User, cfg.Password, cfg.Host, port, cfg.Name)
+	}
+}
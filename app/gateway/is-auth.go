@@ -1,36 +1,122 @@
-package gateway
-
-// import (
-// 	"fmt"
-// 	"net/http"
-// 	"strings"
-
-// 	"github.com/gin-gonic/gin"
-// 	"github.com/golang-jwt/jwt/v4"
-// )
-
-// func IsAuthMiddleware() gin.HandlerFunc {
-// 	return func(c *gin.Context) {
-// 		authHeader := c.GetHeader("Authorization")
-// 		if authHeader == "" {
-// 			c.JSON(http.StatusUnauthorized, gin.H{
-// 				"error" : "No header authorization",
-// 			})
-// 			return
-// 		}
-
-// 		token := 	c.Query("token")
-
-// 		bearerToken := c.Request.Header.Get("Authorization")
-// 		if len(strings.Split(bearerToken, " ")) == 2 {
-// 			token = strings.Split(bearerToken, " ")[1]
-// 		}
-
-// 		token1, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-// 			if _, ok := token1.Method.(*jwt.SigningMethodHMAC); !ok {
-// 				return nil, fmt.Errorf("Unexpected signin method %v", token1.Header["alg"])
-// 			}
-// 			return secret, nil
-// 		})
-// 	}
-// }
+package gateway
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const principalKey = "principal"
+
+// APIKeyResolver turns an API key into the principal it acts as.
+type APIKeyResolver interface {
+	ResolveAPIKey(ctx context.Context, key string) (*domains.Principal, error)
+}
+
+// IsAuthMiddleware accepts a Bearer token or, when apiKeys is not nil, an
+// "ApiKey" header. Both end up as the principal of the request.
+func IsAuthMiddleware(revocations repository.RevocationRepositoryInterface, apiKeys APIKeyResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			AbortWithProblem(c, http.StatusUnauthorized, "missing_token", "No header authorization")
+			return
+		}
+
+		bearerToken := strings.Split(authHeader, " ")
+		if len(bearerToken) == 2 && apiKeys != nil && strings.EqualFold(bearerToken[0], "ApiKey") {
+			principal, err := apiKeys.ResolveAPIKey(c.Request.Context(), bearerToken[1])
+			if err != nil {
+				AbortWithError(c, err)
+				return
+			}
+			c.Set(principalKey, principal)
+			c.Next()
+			return
+		}
+		if len(bearerToken) != 2 || !strings.EqualFold(bearerToken[0], "Bearer") {
+			AbortWithProblem(c, http.StatusUnauthorized, "invalid_token", "Authorization header must be a Bearer token")
+			return
+		}
+
+		claims, err := helper.ValidateAccessToken(c.Request.Context(), bearerToken[1], revocations)
+		if err != nil {
+			AbortWithProblem(c, http.StatusUnauthorized, "invalid_token", err.Error())
+			return
+		}
+		// Tokens of clients acting on their own behalf have no user to act
+		// as.
+		if !claims.Authorized || claims.ID == "" {
+			AbortWithProblem(c, http.StatusUnauthorized, "invalid_token", "Invalid token!")
+			return
+		}
+
+		authTime := claims.IssuedAt.Time
+		if claims.AuthTime != nil {
+			authTime = claims.AuthTime.Time
+		}
+		c.Set(principalKey, &domains.Principal{
+			ID:        claims.ID,
+			Email:     claims.Email,
+			TokenID:   claims.RegisteredClaims.ID,
+			ExpiresAt: claims.ExpiresAt.Time,
+			Roles:     claims.Roles,
+			ClientID:  claims.ClientID,
+			Scope:     claims.Scope,
+			AuthTime:  authTime,
+		})
+		c.Next()
+	}
+}
+
+// FirstPartyOnly rejects tokens issued to OAuth clients. It guards the
+// account routes, which third-party apps must not reach with the access the
+// user delegated to them. It must run after IsAuthMiddleware.
+func FirstPartyOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+			return
+		}
+		if principal.ClientID != "" {
+			AbortWithProblem(c, http.StatusForbidden, "oauth_token_not_allowed", "Token was issued to an OAuth client!")
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly rejects API keys. It guards the routes that act on the
+// account itself, like changing the password or creating more keys, which
+// need a logged in user. It must run after IsAuthMiddleware.
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+			return
+		}
+		if principal.APIKeyID != "" {
+			AbortWithProblem(c, http.StatusForbidden, "api_key_not_allowed", "API keys cannot be used here!")
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentPrincipal returns the caller set by IsAuthMiddleware, or false when
+// the route is not behind the middleware.
+func CurrentPrincipal(c *gin.Context) (*domains.Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*domains.Principal)
+	return principal, ok
+}
@@ -0,0 +1,233 @@
+package gateway
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/logic"
+	"api-auth/services/repository"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var revocations = repository.NewMemoryRevocationRepository()
+
+func SetRouter() *gin.Engine {
+	r := gin.Default()
+	r.GET("/private", IsAuthMiddleware(revocations, nil), func(c *gin.Context) {
+		principal, _ := CurrentPrincipal(c)
+		c.String(http.StatusOK, principal.ID+" "+principal.Email)
+	})
+	return r
+}
+
+func TestSuccessIsAuthMiddleware(t *testing.T) {
+	token, err := helper.GenerateJWT("uuid", "kale@gmail.com")
+	assert.NoError(t, err)
+
+	r := SetRouter()
+	req, err := http.NewRequest("GET", "/private", nil)
+	if err != nil {
+		t.Fatalf("Couldn't create request: %v\n", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	res, _ := ioutil.ReadAll(w.Body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "uuid kale@gmail.com", string(res))
+}
+
+func signToken(method jwt.SigningMethod, key interface{}, kid string, claims helper.Claims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	tokenString, _ := token.SignedString(key)
+	return tokenString
+}
+
+func TestFailIsAuthMiddleware(t *testing.T) {
+	active := helper.CurrentKeyRing().Active()
+	valid := func(exp time.Time) helper.Claims {
+		return helper.Claims{
+			Authorized: true,
+			ID:         "uuid",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti",
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(exp),
+			},
+		}
+	}
+
+	expiredToken := signToken(active.Method, active.Private, active.ID, valid(time.Now().Add(-time.Minute)))
+
+	otherKey, _ := helper.GenerateSigningKey(active.Method.Alg(), helper.KeyActive)
+	wrongKeyToken := signToken(active.Method, otherKey.Private, active.ID, valid(time.Now().Add(time.Hour)))
+	unknownKidToken := signToken(otherKey.Method, otherKey.Private, otherKey.ID, valid(time.Now().Add(time.Hour)))
+
+	noExpiryToken := signToken(active.Method, active.Private, active.ID, helper.Claims{Authorized: true, ID: "uuid"})
+
+	// HS256 keyed with the public key must not be accepted in place of ES256.
+	hmacToken := signToken(jwt.SigningMethodHS256, []byte(active.ID), active.ID, valid(time.Now().Add(time.Hour)))
+
+	noneAlgToken := signToken(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, active.ID, valid(time.Now().Add(time.Hour)))
+
+	tests := map[string]string{
+		"no_header":   "",
+		"not_bearer":  "Basic dXNlcjpwYXNz",
+		"expired":     "Bearer " + expiredToken,
+		"wrong_key":   "Bearer " + wrongKeyToken,
+		"unknown_kid": "Bearer " + unknownKidToken,
+		"hmac_alg":    "Bearer " + hmacToken,
+		"no_expiry":   "Bearer " + noExpiryToken,
+		"none_alg":    "Bearer " + noneAlgToken,
+		"garbage":     "Bearer not.a.token",
+	}
+
+	r := SetRouter()
+	for name, header := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/private", nil)
+			if err != nil {
+				t.Fatalf("Couldn't create request: %v\n", err)
+			}
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
+
+func TestRevokedIsAuthMiddleware(t *testing.T) {
+	r := SetRouter()
+	request := func(token string) int {
+		req, err := http.NewRequest("GET", "/private", nil)
+		if err != nil {
+			t.Fatalf("Couldn't create request: %v\n", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("revoked_jti", func(t *testing.T) {
+		token, _ := helper.GenerateJWT("uuid_logout", "kale@gmail.com")
+		claims, err := helper.ParseJWT(token)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, request(token))
+
+		revocations.RevokeToken(context.Background(), claims.RegisteredClaims.ID, claims.ExpiresAt.Time)
+
+		assert.Equal(t, http.StatusUnauthorized, request(token))
+	})
+
+	t.Run("revoked_user_tokens", func(t *testing.T) {
+		token, _ := helper.GenerateJWT("uuid_deleted", "kale@gmail.com")
+		other, _ := helper.GenerateJWT("uuid_other", "other@gmail.com")
+
+		revocations.RevokeUserTokens(context.Background(), "uuid_deleted", time.Now().Add(time.Second))
+
+		assert.Equal(t, http.StatusUnauthorized, request(token))
+		assert.Equal(t, http.StatusOK, request(other))
+	})
+}
+
+func TestFirstPartyOnly(t *testing.T) {
+	r := gin.Default()
+	r.GET("/account", IsAuthMiddleware(revocations, nil), FirstPartyOnly(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(token string) int {
+		req, err := http.NewRequest("GET", "/account", nil)
+		if err != nil {
+			t.Fatalf("Couldn't create request: %v\n", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	token, _ := helper.GenerateJWT("uuid", "kale@gmail.com")
+	oauthToken, _ := helper.GenerateOAuthJWT("uuid", "kale@gmail.com", "spa_client", "openid")
+
+	assert.Equal(t, http.StatusOK, request(token))
+	assert.Equal(t, http.StatusForbidden, request(oauthToken))
+}
+
+func TestClientTokenIsAuthMiddleware(t *testing.T) {
+	token, _ := helper.GenerateOAuthJWT("", "", "service_client", "reports:read")
+
+	req, err := http.NewRequest("GET", "/private", nil)
+	if err != nil {
+		t.Fatalf("Couldn't create request: %v\n", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	SetRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+type fakeAPIKeys map[string]*domains.Principal
+
+func (keys fakeAPIKeys) ResolveAPIKey(ctx context.Context, key string) (*domains.Principal, error) {
+	principal, ok := keys[key]
+	if !ok {
+		return nil, &logic.Error{Kind: logic.KindUnauthorized, Code: "invalid_api_key", Message: "Invalid API key!"}
+	}
+	return principal, nil
+}
+
+func TestAPIKeyIsAuthMiddleware(t *testing.T) {
+	apiKeys := fakeAPIKeys{
+		"ak_user.secret": {ID: "uuid", Email: "kale@gmail.com", APIKeyID: "key_uuid", Scope: "users:read"},
+	}
+	r := gin.Default()
+	r.GET("/private", IsAuthMiddleware(revocations, apiKeys), func(c *gin.Context) {
+		principal, _ := CurrentPrincipal(c)
+		c.String(http.StatusOK, principal.ID+" "+principal.APIKeyID)
+	})
+	r.GET("/account", IsAuthMiddleware(revocations, apiKeys), SessionOnly(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/no-keys", IsAuthMiddleware(revocations, nil), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path, header string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatalf("Couldn't create request: %v\n", err)
+		}
+		req.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	token, _ := helper.GenerateJWT("uuid", "kale@gmail.com")
+
+	w := request("/private", "ApiKey ak_user.secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "uuid key_uuid", w.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, request("/private", "ApiKey ak_user.wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("/no-keys", "ApiKey ak_user.secret").Code)
+	assert.Equal(t, http.StatusForbidden, request("/account", "ApiKey ak_user.secret").Code)
+	assert.Equal(t, http.StatusOK, request("/account", "Bearer "+token).Code)
+}
@@ -0,0 +1,71 @@
+package gateway
+
+import (
+	"api-auth/services/repository"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorizer guards routes by permission. The roles come from the token of
+// the principal, the permissions of those roles are looked up on every
+// request so that revoking a grant takes effect immediately. Tokens naming a
+// role that was taken away or deleted are revoked, so a name always means
+// the role the token was issued with.
+type Authorizer struct {
+	roles repository.RoleRepositoryInterface
+}
+
+func NewAuthorizer(roles repository.RoleRepositoryInterface) *Authorizer {
+	return &Authorizer{
+		roles: roles,
+	}
+}
+
+// RequirePermission must run after IsAuthMiddleware.
+func (a *Authorizer) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+			return
+		}
+
+		// API keys are limited to their scopes. Those of service accounts
+		// have no roles, their scopes are all they are granted.
+		if principal.APIKeyID != "" {
+			if !hasScope(principal.Scope, permission) {
+				AbortWithProblem(c, http.StatusForbidden, "insufficient_scope", "API key lacks scope "+permission+"!")
+				return
+			}
+			if principal.ID == "" {
+				c.Next()
+				return
+			}
+		}
+
+		granted, err := a.roles.RolePermissions(c.Request.Context(), principal.Roles)
+		if err != nil {
+			AbortWithError(c, err)
+			return
+		}
+		for _, name := range granted {
+			if name == permission {
+				c.Next()
+				return
+			}
+		}
+
+		AbortWithProblem(c, http.StatusForbidden, "permission_required", "Permission "+permission+" required!")
+	}
+}
+
+func hasScope(scope, name string) bool {
+	for _, granted := range strings.Fields(scope) {
+		if granted == name {
+			return true
+		}
+	}
+	return false
+}
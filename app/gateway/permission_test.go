@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"api-auth/app/helper"
+	mokz "api-auth/mock"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRequirePermission(t *testing.T) {
+	roles := &mokz.RoleRepositoryMock{Mock: mock.Mock{}}
+	roles.Mock.On("RolePermissions", mock.Anything, []string{"admin"}).Return([]string{"roles:manage", "users:delete"}, nil)
+	roles.Mock.On("RolePermissions", mock.Anything, []string{"support"}).Return([]string{"users:read"}, nil)
+	roles.Mock.On("RolePermissions", mock.Anything, []string(nil)).Return([]string{}, nil)
+	roles.Mock.On("RolePermissions", mock.Anything, []string{"broken"}).Return(nil, errors.New(""))
+	guard := NewAuthorizer(roles)
+
+	r := gin.Default()
+	r.DELETE("/user", IsAuthMiddleware(revocations, nil), guard.RequirePermission("users:delete"), func(c *gin.Context) {
+		c.String(http.StatusOK, "deleted")
+	})
+	r.DELETE("/unguarded", guard.RequirePermission("users:delete"), func(c *gin.Context) {
+		c.String(http.StatusOK, "deleted")
+	})
+
+	tests := []struct {
+		name  string
+		roles []string
+		code  int
+	}{
+		{name: "granted", roles: []string{"admin"}, code: http.StatusOK},
+		{name: "other_permission", roles: []string{"support"}, code: http.StatusForbidden},
+		{name: "no_roles", roles: nil, code: http.StatusForbidden},
+		{name: "lookup_failed", roles: []string{"broken"}, code: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, _ := helper.GenerateJWT("uuid", "kale@gmail.com", test.roles...)
+			req, _ := http.NewRequest("DELETE", "/user", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.code, w.Code)
+		})
+	}
+
+	t.Run("no_principal", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/unguarded", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("api_keys", func(t *testing.T) {
+		apiKeys := fakeAPIKeys{
+			"ak_scoped":     {ID: "uuid", APIKeyID: "key_uuid", Roles: []string{"admin"}, Scope: "users:delete"},
+			"ak_unscoped":   {ID: "uuid", APIKeyID: "key_uuid", Roles: []string{"admin"}, Scope: "users:read"},
+			"ak_role_lost":  {ID: "uuid", APIKeyID: "key_uuid", Roles: []string{"support"}, Scope: "users:delete"},
+			"ak_service":    {APIKeyID: "service_key", ServiceAccount: "batch-jobs", Scope: "users:delete"},
+			"ak_service_ro": {APIKeyID: "service_key", ServiceAccount: "batch-jobs", Scope: "users:read"},
+		}
+		r.DELETE("/keyed", IsAuthMiddleware(revocations, apiKeys), guard.RequirePermission("users:delete"), func(c *gin.Context) {
+			c.String(http.StatusOK, "deleted")
+		})
+
+		codes := map[string]int{
+			"ak_scoped":     http.StatusOK,
+			"ak_unscoped":   http.StatusForbidden,
+			"ak_role_lost":  http.StatusForbidden,
+			"ak_service":    http.StatusOK,
+			"ak_service_ro": http.StatusForbidden,
+		}
+		for key, code := range codes {
+			req, _ := http.NewRequest("DELETE", "/keyed", nil)
+			req.Header.Set("Authorization", "ApiKey "+key)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, code, w.Code, key)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"api-auth/domains"
+	"api-auth/services/logic"
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const problemContentType = "application/problem+json"
+
+// kindStatus maps the kinds of logic errors to HTTP statuses.
+var kindStatus = map[logic.ErrorKind]int{
+	logic.KindInternal:     http.StatusInternalServerError,
+	logic.KindValidation:   http.StatusBadRequest,
+	logic.KindUnauthorized: http.StatusUnauthorized,
+	logic.KindForbidden:    http.StatusForbidden,
+	logic.KindNotFound:     http.StatusNotFound,
+	logic.KindConflict:     http.StatusConflict,
+	logic.KindRateLimited:  http.StatusTooManyRequests,
+}
+
+// AbortWithProblem ends the request with a problem response.
+func AbortWithProblem(c *gin.Context, status int, code, detail string) {
+	abortWithProblem(c, &domains.Problem{Status: status, Code: code, Detail: detail})
+}
+
+// AbortWithError ends the request with a problem response describing err.
+// The status and code follow from the kind of the error, see logic.AsError.
+// Errors without a kind are internal, they are logged and answered with 500
+// and a generic detail.
+func AbortWithError(c *gin.Context, err error) {
+	problem := &domains.Problem{}
+
+	var locked *logic.LockedError
+	var oauthErr *logic.OAuthError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		problem.Status = http.StatusTooManyRequests
+		problem.Code = "login_locked"
+	case errors.As(err, &oauthErr):
+		problem.Status = http.StatusBadRequest
+		problem.Code = oauthErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		problem.Status = http.StatusGatewayTimeout
+		problem.Code = "timeout"
+	case errors.Is(err, context.Canceled):
+		// The client is gone, the status only shows up in the logs.
+		problem.Status = http.StatusServiceUnavailable
+		problem.Code = "canceled"
+	default:
+		domainErr := logic.AsError(err)
+		if domainErr.Kind == logic.KindInternal {
+			log.Printf("%s %s: %s", c.Request.Method, c.Request.URL.Path, err.Error())
+			err = domainErr
+		}
+		problem.Status = kindStatus[domainErr.Kind]
+		problem.Code = domainErr.Code
+		problem.InvalidParams = invalidParams(domainErr.Fields)
+	}
+	problem.Detail = err.Error()
+	abortWithProblem(c, problem)
+}
+
+// AbortWithBindError ends the request with a 400 problem response for an
+// error of binding the request, naming the fields that failed validation.
+func AbortWithBindError(c *gin.Context, err error) {
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		AbortWithProblem(c, http.StatusBadRequest, statusCode(http.StatusBadRequest), err.Error())
+		return
+	}
+	fields := map[string]string{}
+	for _, field := range invalid {
+		fields[lowerFirst(field.Field())] = "Failed on the " + field.Tag() + " rule!"
+	}
+	abortWithProblem(c, &domains.Problem{
+		Status:        http.StatusBadRequest,
+		Code:          logic.ErrValidation.Code,
+		Detail:        logic.ErrValidation.Message,
+		InvalidParams: invalidParams(fields),
+	})
+}
+
+func abortWithProblem(c *gin.Context, problem *domains.Problem) {
+	problem.Type = "about:blank"
+	problem.Title = http.StatusText(problem.Status)
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// statusCode turns a status into a code, e.g. 404 into "not_found".
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+func invalidParams(fields map[string]string) []domains.InvalidParam {
+	params := make([]domains.InvalidParam, 0, len(fields))
+	for name, reason := range fields {
+		params = append(params, domains.InvalidParam{Name: name, Reason: reason})
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return params
+}
+
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
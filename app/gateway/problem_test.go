@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"api-auth/domains"
+	"api-auth/services/logic"
+	"api-auth/services/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbortWithError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+		params []domains.InvalidParam
+	}{
+		{name: "not_found", err: logic.ErrNotFound, status: http.StatusNotFound, code: "not_found"},
+		{name: "conflict", err: logic.ErrEmailTaken, status: http.StatusConflict, code: "email_taken"},
+		{name: "unauthorized", err: logic.ErrInvalidCredentials, status: http.StatusUnauthorized, code: "invalid_credentials"},
+		{name: "forbidden", err: logic.ErrWrongPassword, status: http.StatusForbidden, code: "wrong_password"},
+		{name: "wrapped", err: fmt.Errorf("deleting: %w", logic.ErrNotFound), status: http.StatusNotFound, code: "not_found"},
+		{
+			name: "validation",
+			err: &logic.Error{
+				Kind:    logic.KindValidation,
+				Code:    "validation_failed",
+				Message: "Invalid input!",
+				Fields:  map[string]string{"password": "Too short!", "email": "Missing @!"},
+			},
+			status: http.StatusBadRequest,
+			code:   "validation_failed",
+			params: []domains.InvalidParam{{Name: "email", Reason: "Missing @!"}, {Name: "password", Reason: "Too short!"}},
+		},
+		{name: "oauth", err: &logic.OAuthError{Code: "invalid_scope"}, status: http.StatusBadRequest, code: "invalid_scope"},
+		{name: "rate_limited", err: logic.ErrRateLimited, status: http.StatusTooManyRequests, code: "rate_limited"},
+		{name: "repository_not_found", err: fmt.Errorf("revoking: %w", repository.ErrNotFound), status: http.StatusNotFound, code: "not_found"},
+		{name: "repository_conflict", err: fmt.Errorf("confirming: %w", repository.ErrConflict), status: http.StatusConflict, code: "conflict"},
+		{name: "plain", err: errors.New("Cannot fetch users!"), status: http.StatusInternalServerError, code: "internal_error", detail: "Something went wrong!"},
+		{name: "timeout", err: context.DeadlineExceeded, status: http.StatusGatewayTimeout, code: "timeout"},
+		{name: "canceled", err: fmt.Errorf("fetching users: %w", context.Canceled), status: http.StatusServiceUnavailable, code: "canceled"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/users", nil)
+
+			AbortWithError(c, test.err)
+
+			detail := test.detail
+			if detail == "" {
+				detail = test.err.Error()
+			}
+
+			var problem domains.Problem
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, test.status, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.True(t, c.IsAborted())
+			assert.Equal(t, domains.Problem{
+				Type:          "about:blank",
+				Title:         http.StatusText(test.status),
+				Status:        test.status,
+				Detail:        detail,
+				Code:          test.code,
+				InvalidParams: test.params,
+			}, problem)
+		})
+	}
+
+	t.Run("locked", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		AbortWithError(c, &logic.LockedError{RetryAfter: 90*time.Second + time.Millisecond})
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "91", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), `"code":"login_locked"`)
+	})
+}
+
+func TestAbortWithBindError(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	AbortWithBindError(c, errors.New("EOF"))
+
+	var problem domains.Problem
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "bad_request", problem.Code)
+	assert.Equal(t, "EOF", problem.Detail)
+}
@@ -1,33 +1,198 @@
 package helper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
 )
 
-func GenerateJWT(id string, email string) (string, error) {
-	var mySigningKey = []byte("secretkey")
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
+var AccessTokenTTL = time.Minute * 15
 
-	claims["authorized"] = true
-	claims["id"] = id
-	claims["email"] = email
-	claims["exp"] = time.Now().Add(time.Hour * 3).Unix()
+var MinPasswordLength = 8
 
-	tokenString, err := token.SignedString(mySigningKey)
+// SetTokenTTL overrides the lifetimes of issued access and refresh tokens.
+func SetTokenTTL(access, refresh time.Duration) {
+	AccessTokenTTL = access
+	RefreshTokenTTL = refresh
+}
+
+// SetPasswordPolicy overrides the minimum length enforced by PasswordRequired.
+func SetPasswordPolicy(minLength int) {
+	MinPasswordLength = minLength
+}
+
+type Claims struct {
+	Authorized bool   `json:"authorized"`
+	ID         string `json:"id"`
+	Email      string `json:"email"`
+	// Roles are the names of the roles the user had when the token was
+	// issued.
+	Roles []string `json:"roles,omitempty"`
+	// ClientID and Scope are set on tokens issued to OAuth clients.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// AuthTime is when the user logged in, refreshed tokens keep it.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// IDTokenClaims are the claims of an OpenID Connect id_token. The profile
+// and email claims are left empty when the client was not granted the
+// matching scope.
+type IDTokenClaims struct {
+	Nonce           string           `json:"nonce,omitempty"`
+	AuthTime        *jwt.NumericDate `json:"auth_time,omitempty"`
+	AuthorizedParty string           `json:"azp,omitempty"`
+	Name            string           `json:"name,omitempty"`
+	Email           string           `json:"email,omitempty"`
+	EmailVerified   *bool            `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func GenerateJWT(id string, email string, roles ...string) (string, error) {
+	return GenerateSessionJWT(id, email, time.Now(), roles...)
+}
+
+// GenerateSessionJWT is GenerateJWT for a session the user logged in to at
+// authTime, which is earlier than now once the session was refreshed.
+func GenerateSessionJWT(id string, email string, authTime time.Time, roles ...string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Authorized: true,
+		ID:         id,
+		Email:      email,
+		Roles:      roles,
+		AuthTime:   jwt.NewNumericDate(authTime),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	}
+	return SignToken(claims)
+}
+
+// GenerateOAuthJWT issues an access token to an OAuth client. It carries no
+// roles, the client only gets the scopes the user consented to. Tokens of
+// the client_credentials grant have no user and use the client as subject.
+func GenerateOAuthJWT(userId, email, clientId, scope string) (string, error) {
+	now := time.Now()
+	subject := userId
+	if subject == "" {
+		subject = clientId
+	}
+	claims := Claims{
+		Authorized: userId != "",
+		ID:         userId,
+		Email:      email,
+		ClientID:   clientId,
+		Scope:      scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	}
+	return SignToken(claims)
+}
+
+// SignToken signs any claims with the active key of the ring.
+func SignToken(claims jwt.Claims) (string, error) {
+	key := keyRing.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
-		fmt.Errorf("Something Went Wrong: %s", err.Error())
 		return "", err
 	}
 	return tokenString, nil
 }
 
+// VerifyToken checks a token signed by SignToken and decodes it into claims.
+// The kid header must name a key on the ring and the alg must be the one that
+// key signs with.
+func VerifyToken(tokenString string, claims jwt.Claims) error {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{
+		jwt.SigningMethodRS256.Alg(),
+		jwt.SigningMethodES256.Alg(),
+		jwt.SigningMethodEdDSA.Alg(),
+	}))
+
+	token, err := parser.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key := keyRing.Key(kid)
+		if key == nil {
+			return nil, fmt.Errorf("Unknown signing key %q", kid)
+		}
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("Unexpected signing method %v", t.Header["alg"])
+		}
+		return key.Private.Public(), nil
+	})
+	if err != nil || !token.Valid {
+		return errors.New("Invalid token!")
+	}
+	return nil
+}
+
+// ParseJWT verifies an access token issued to a user by GenerateJWT or
+// GenerateOAuthJWT. It must carry an expiry that has not passed yet.
+func ParseJWT(tokenString string) (*Claims, error) {
+	claims, err := ParseAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.Authorized || claims.ID == "" {
+		return nil, errors.New("Invalid token!")
+	}
+	return claims, nil
+}
+
+// ParseAccessToken is ParseJWT that also accepts the tokens of OAuth clients
+// acting on their own behalf, which have no user.
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := VerifyToken(tokenString, claims); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("Token has no expiry!")
+	}
+	if claims.RegisteredClaims.ID == "" || claims.IssuedAt == nil {
+		return nil, errors.New("Invalid token!")
+	}
+	if claims.ID == "" && claims.ClientID == "" {
+		return nil, errors.New("Invalid token!")
+	}
+	return claims, nil
+}
+
+// RevocationChecker is the part of the revocation store that token
+// validation needs.
+type RevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) bool
+}
+
+// ValidateAccessToken is the check every presented access token goes
+// through, for the auth middleware as well as for token introspection.
+func ValidateAccessToken(ctx context.Context, tokenString string, revocations RevocationChecker) (*Claims, error) {
+	claims, err := ParseAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if revocations.IsTokenRevoked(ctx, claims.RegisteredClaims.ID, claims.ID, claims.IssuedAt.Time) {
+		return nil, errors.New("Token has been revoked!")
+	}
+	return claims, nil
+}
+
 func EmailRequired(email string) error {
 	if !strings.Contains(email, "@") || !strings.Contains(email, ".") {
 		return errors.New("Email does'n contains @ or .")
@@ -36,8 +201,8 @@ func EmailRequired(email string) error {
 }
 
 func PasswordRequired(pass, confPass string) error {
-	if len(pass) < 8 {
-		return errors.New("Password must be greater than 8 characters!")
+	if len(pass) < MinPasswordLength {
+		return fmt.Errorf("Password must be greater than %d characters!", MinPasswordLength)
 	}
 	if strings.Compare(pass, confPass) != 0 {
 		return errors.New("Password not match!")
@@ -45,21 +210,20 @@ func PasswordRequired(pass, confPass string) error {
 	return nil
 }
 
+// CheckPasswordHash verifies a password against a hash of any supported
+// algorithm.
 func CheckPasswordHash(passEntered, passHashed string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(passHashed), []byte(passEntered))
-
-	if err != nil {
+	hasher := hasherFor(passHashed)
+	if hasher == nil {
 		return errors.New("Invalid password!")
 	}
-
-	return nil
+	return hasher.Verify(passEntered, passHashed)
 }
 
-func PasswordHashing(pw string) string {
-	pen, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.MinCost)
-
+func PasswordHashing(pw string) (string, error) {
+	hash, err := passwordHasher.Hash(pw)
 	if err != nil {
-		return "Cannot hash password"
+		return "", errors.New("Cannot hash password!")
 	}
-	return string(pen)
+	return hash, nil
 }
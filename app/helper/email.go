@@ -0,0 +1,28 @@
+package helper
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// NormalizeEmail validates an email and returns the form it is stored and
+// looked up in: trimmed, case folded and with the domain in its ASCII form,
+// so " Kale@Bücher.de" and "kale@xn--bcher-kva.de" are the same account.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if err := EmailRequired(email); err != nil {
+		return "", err
+	}
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], email[at+1:]
+	if local == "" || domain == "" {
+		return "", errors.New("Email needs a name and a domain around @!")
+	}
+	domain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", errors.New("Email domain is invalid!")
+	}
+	return strings.ToLower(local) + "@" + strings.ToLower(domain), nil
+}
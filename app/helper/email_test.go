@@ -0,0 +1,36 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		expected string
+	}{
+		{name: "unchanged", email: "kale@gmail.com", expected: "kale@gmail.com"},
+		{name: "trimmed", email: "  kale@gmail.com\n", expected: "kale@gmail.com"},
+		{name: "case_folded", email: "Kale.Leo@GMail.COM", expected: "kale.leo@gmail.com"},
+		{name: "idn_domain", email: "kale@Bücher.de", expected: "kale@xn--bcher-kva.de"},
+		{name: "punycode_domain", email: "kale@XN--BCHER-KVA.de", expected: "kale@xn--bcher-kva.de"},
+		{name: "unicode_name", email: "Ünal@gmail.com", expected: "ünal@gmail.com"},
+		{name: "last_at_splits", email: `"a@b"@gmail.com`, expected: `"a@b"@gmail.com`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			email, err := NormalizeEmail(test.email)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, email)
+		})
+	}
+
+	for _, invalid := range []string{"", "kale", "kale@gmail", "@gmail.com", "kale.leo@", "kale@exa_mple.com", "kale@-gmail.com"} {
+		_, err := NormalizeEmail(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,344 @@
+package helper
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+type KeyStatus string
+
+const (
+	// KeyActive signs new tokens. There is exactly one active key.
+	KeyActive KeyStatus = "active"
+	// KeyNext is published in the JWKS ahead of time so verifiers have it
+	// cached before it starts signing.
+	KeyNext KeyStatus = "next"
+	// KeyRetired no longer signs but still verifies tokens issued before
+	// the rotation until they expire.
+	KeyRetired KeyStatus = "retired"
+)
+
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Status  KeyStatus
+}
+
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys []*SigningKey
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var keyRing = mustEphemeralKeyRing()
+
+// SetKeyRing replaces the key ring used by GenerateJWT and ParseJWT.
+func SetKeyRing(ring *KeyRing) {
+	keyRing = ring
+}
+
+func CurrentKeyRing() *KeyRing {
+	return keyRing
+}
+
+// mustEphemeralKeyRing is the fallback when no keys are configured. Tokens
+// signed with it do not survive a restart.
+func mustEphemeralKeyRing() *KeyRing {
+	key, err := GenerateSigningKey(jwt.SigningMethodES256.Alg(), KeyActive)
+	if err != nil {
+		panic(err.Error())
+	}
+	ring, err := NewKeyRing(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ring
+}
+
+func NewKeyRing(keys ...*SigningKey) (*KeyRing, error) {
+	ring := &KeyRing{keys: keys}
+	if err := ring.validate(); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+func (kr *KeyRing) validate() error {
+	active := 0
+	seen := map[string]bool{}
+	for _, key := range kr.keys {
+		if seen[key.ID] {
+			return fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		seen[key.ID] = true
+		if key.Status == KeyActive {
+			active++
+		}
+	}
+	if active != 1 {
+		return fmt.Errorf("key ring needs exactly one active key, got %d", active)
+	}
+	return nil
+}
+
+func (kr *KeyRing) Active() *SigningKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for _, key := range kr.keys {
+		if key.Status == KeyActive {
+			return key
+		}
+	}
+	return nil
+}
+
+// Key looks up any key that may verify a token, whatever its status.
+func (kr *KeyRing) Key(kid string) *SigningKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for _, key := range kr.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// Add puts a new key on the ring. Only next or retired keys can be added;
+// use Rotate to change which key signs.
+func (kr *KeyRing) Add(key *SigningKey) error {
+	if key.Status == KeyActive {
+		return errors.New("cannot add an active key, add it as next and rotate")
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	for _, existing := range kr.keys {
+		if existing.ID == key.ID {
+			return fmt.Errorf("duplicate key id %q", key.ID)
+		}
+	}
+	kr.keys = append(kr.keys, key)
+	return nil
+}
+
+// Rotate promotes the next key to active and retires the current one.
+func (kr *KeyRing) Rotate() error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	var next *SigningKey
+	for _, key := range kr.keys {
+		if key.Status == KeyNext {
+			next = key
+			break
+		}
+	}
+	if next == nil {
+		return errors.New("no next key to rotate to")
+	}
+	for _, key := range kr.keys {
+		if key.Status == KeyActive {
+			key.Status = KeyRetired
+		}
+	}
+	next.Status = KeyActive
+	return nil
+}
+
+// Remove drops a retired key once no token signed by it can still be valid.
+func (kr *KeyRing) Remove(kid string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	for i, key := range kr.keys {
+		if key.ID != kid {
+			continue
+		}
+		if key.Status == KeyActive {
+			return errors.New("cannot remove the active key")
+		}
+		kr.keys = append(kr.keys[:i], kr.keys[i+1:]...)
+		return nil
+	}
+	return fmt.Errorf("unknown key id %q", kid)
+}
+
+// JWKS returns the public half of every key on the ring.
+func (kr *KeyRing) JWKS() JWKS {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range kr.keys {
+		jwk, err := publicJWK(key)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// Algorithms returns the signing algorithms of the keys on the ring, in the
+// order the keys were added.
+func (kr *KeyRing) Algorithms() []string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	var algs []string
+	seen := map[string]bool{}
+	for _, key := range kr.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+func publicJWK(key *SigningKey) (JWK, error) {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+	enc := base64.RawURLEncoding
+
+	switch pub := key.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = enc.EncodeToString(pub.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = enc.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = enc.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = enc.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", pub)
+	}
+	return jwk, nil
+}
+
+// GenerateSigningKey creates a fresh key for RS256, ES256 or EdDSA.
+func GenerateSigningKey(alg string, status KeyStatus) (*SigningKey, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(uuid.New().String(), private, status)
+}
+
+func newSigningKey(kid string, private crypto.Signer, status KeyStatus) (*SigningKey, error) {
+	key := &SigningKey{ID: kid, Private: private, Status: status}
+
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("key %q: only P-256 is supported for ES256", kid)
+		}
+		key.Method = jwt.SigningMethodES256
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", kid, private)
+	}
+	return key, nil
+}
+
+// LoadKeyRing reads PKCS#8 PEM private keys from dir. Files are named
+// "<status>-<kid>.pem", e.g. "active-2022-09.pem" or "next-2022-10.pem".
+func LoadKeyRing(dir string) (*KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*SigningKey
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".pem")
+		parts := strings.SplitN(name, "-", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s: file name must be <status>-<kid>.pem", path)
+		}
+		status := KeyStatus(parts[0])
+		if status != KeyActive && status != KeyNext && status != KeyRetired {
+			return nil, fmt.Errorf("%s: unknown key status %q", path, parts[0])
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(raw)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM block found", path)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+		private, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%s: key cannot sign", path)
+		}
+		key, err := newSigningKey(parts[1], private, status)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyRing(keys...)
+}
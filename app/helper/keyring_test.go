@@ -0,0 +1,111 @@
+package helper
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyRing_SignAndVerify(t *testing.T) {
+	defer SetKeyRing(CurrentKeyRing())
+
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := GenerateSigningKey(alg, KeyActive)
+			assert.NoError(t, err)
+			ring, err := NewKeyRing(key)
+			assert.NoError(t, err)
+			SetKeyRing(ring)
+
+			token, err := GenerateJWT("uuid", "kale@gmail.com")
+			assert.NoError(t, err)
+
+			parsed, _ := jwt.Parse(token, nil)
+			assert.Equal(t, key.ID, parsed.Header["kid"])
+			assert.Equal(t, alg, parsed.Header["alg"])
+
+			claims, err := ParseJWT(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "uuid", claims.ID)
+
+			jwks := ring.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, key.ID, jwks.Keys[0].Kid)
+			assert.Equal(t, alg, jwks.Keys[0].Alg)
+		})
+	}
+}
+
+func TestKeyRing_Rotate(t *testing.T) {
+	defer SetKeyRing(CurrentKeyRing())
+
+	active, _ := GenerateSigningKey("ES256", KeyActive)
+	next, _ := GenerateSigningKey("EdDSA", KeyNext)
+	ring, err := NewKeyRing(active, next)
+	assert.NoError(t, err)
+	SetKeyRing(ring)
+
+	oldToken, _ := GenerateJWT("uuid", "kale@gmail.com")
+	assert.Len(t, ring.JWKS().Keys, 2)
+
+	assert.NoError(t, ring.Rotate())
+	assert.Equal(t, next.ID, ring.Active().ID)
+	assert.Equal(t, KeyRetired, ring.Key(active.ID).Status)
+
+	newToken, _ := GenerateJWT("uuid", "kale@gmail.com")
+	parsed, _ := jwt.Parse(newToken, nil)
+	assert.Equal(t, next.ID, parsed.Header["kid"])
+
+	// Tokens signed before the rotation stay valid while the key is retired.
+	_, err = ParseJWT(oldToken)
+	assert.NoError(t, err)
+
+	assert.Error(t, ring.Rotate())
+	assert.Error(t, ring.Remove(next.ID))
+	assert.NoError(t, ring.Remove(active.ID))
+
+	_, err = ParseJWT(oldToken)
+	assert.Error(t, err)
+}
+
+func TestNewKeyRing_Invalid(t *testing.T) {
+	first, _ := GenerateSigningKey("ES256", KeyActive)
+	second, _ := GenerateSigningKey("ES256", KeyActive)
+
+	_, err := NewKeyRing()
+	assert.Error(t, err)
+	_, err = NewKeyRing(first, second)
+	assert.Error(t, err)
+
+	_, err = GenerateSigningKey("HS256", KeyActive)
+	assert.Error(t, err)
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, key *SigningKey) {
+		der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+		assert.NoError(t, err)
+		raw := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), raw, 0600))
+	}
+
+	active, _ := GenerateSigningKey("RS256", KeyActive)
+	next, _ := GenerateSigningKey("ES256", KeyNext)
+	write("active-2022-09.pem", active)
+	write("next-2022-10.pem", next)
+
+	ring, err := LoadKeyRing(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "2022-09", ring.Active().ID)
+	assert.Equal(t, KeyNext, ring.Key("2022-10").Status)
+
+	write("bogus.pem", next)
+	_, err = LoadKeyRing(dir)
+	assert.Error(t, err)
+}
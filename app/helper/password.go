@@ -0,0 +1,202 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher turns passwords into self-describing encoded hashes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify checks a password against a hash produced by this hasher.
+	Verify(password, encoded string) error
+	// NeedsRehash reports whether encoded was produced by another algorithm
+	// or with weaker parameters than the hasher currently uses.
+	NeedsRehash(encoded string) bool
+}
+
+type Argon2Params struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the second recommended option of RFC 9106
+// with a smaller lane count.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var passwordHasher PasswordHasher = NewArgon2idHasher(DefaultArgon2Params)
+
+// dummyHash is made by the current hasher on first use, an argon2id hash
+// with the default parameters unless another hasher is set.
+type dummyHash struct {
+	once    sync.Once
+	encoded string
+}
+
+var dummyPasswordHash = &dummyHash{}
+
+// SetPasswordHasher replaces the hasher used for new passwords. Hashes of
+// every supported algorithm keep verifying.
+func SetPasswordHasher(hasher PasswordHasher) {
+	passwordHasher = hasher
+	dummyPasswordHash = &dummyHash{}
+}
+
+// CheckDummyPassword verifies a password against a fixed hash of the current
+// hasher and always fails. Logins of unknown accounts call it so that they
+// take as long as a wrong password, and the timing does not tell which
+// accounts exist.
+func CheckDummyPassword(password string) error {
+	dummy := dummyPasswordHash
+	dummy.once.Do(func() {
+		dummy.encoded, _ = passwordHasher.Hash("dummy password")
+	})
+	if err := CheckPasswordHash(password, dummy.encoded); err != nil {
+		return err
+	}
+	return errors.New("Invalid password!")
+}
+
+type Argon2idHasher struct {
+	Params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	return &Argon2idHasher{Params: params}
+}
+
+// Hash encodes in the PHC string format,
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Params.Memory, h.Params.Iterations, h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify uses the parameters stored in the hash, not the hasher's own.
+func (h *Argon2idHasher) Verify(password, encoded string) error {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return errors.New("Invalid password!")
+	}
+	return nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory < h.Params.Memory ||
+		params.Iterations < h.Params.Iterations ||
+		params.Parallelism < h.Params.Parallelism ||
+		params.SaltLength < h.Params.SaltLength ||
+		params.KeyLength < h.Params.KeyLength
+}
+
+func decodeArgon2id(encoded string) (*Argon2Params, []byte, []byte, error) {
+	invalid := errors.New("Invalid password hash!")
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return nil, nil, nil, invalid
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, invalid
+	}
+	params := &Argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, invalid
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, nil, nil, invalid
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, invalid
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, invalid
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(password, encoded string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+		return errors.New("Invalid password!")
+	}
+	return nil
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.Cost
+}
+
+// hasherFor picks the algorithm an encoded hash was made with.
+func hasherFor(encoded string) PasswordHasher {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return &Argon2idHasher{}
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return &BcryptHasher{}
+	}
+	return nil
+}
+
+// PasswordNeedsRehash reports whether a stored hash should be replaced by
+// one from the current hasher, which can only be done once the password is
+// known, i.e. after a successful login.
+func PasswordNeedsRehash(encoded string) bool {
+	return passwordHasher.NeedsRehash(encoded)
+}
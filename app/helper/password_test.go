@@ -0,0 +1,71 @@
+package helper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2Params)
+
+	hash, err := hasher.Hash("password")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	other, _ := hasher.Hash("password")
+	assert.NotEqual(t, hash, other)
+
+	assert.NoError(t, hasher.Verify("password", hash))
+	assert.Error(t, hasher.Verify("wrong_password", hash))
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	stronger := NewArgon2idHasher(Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	assert.True(t, stronger.NeedsRehash(hash))
+	// Verification follows the parameters in the hash.
+	assert.NoError(t, stronger.Verify("password", hash))
+
+	for _, broken := range []string{
+		"",
+		"Cannot hash password",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+	} {
+		assert.Error(t, hasher.Verify("password", broken), broken)
+		assert.True(t, hasher.NeedsRehash(broken), broken)
+	}
+}
+
+func TestCheckPasswordHash_Algorithms(t *testing.T) {
+	defer SetPasswordHasher(passwordHasher)
+	SetPasswordHasher(NewArgon2idHasher(testArgon2Params))
+
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	assert.NoError(t, CheckPasswordHash("password", string(legacy)))
+	assert.Error(t, CheckPasswordHash("wrong_password", string(legacy)))
+	assert.True(t, PasswordNeedsRehash(string(legacy)))
+
+	hash, err := PasswordHashing("password")
+	assert.NoError(t, err)
+	assert.NoError(t, CheckPasswordHash("password", hash))
+	assert.False(t, PasswordNeedsRehash(hash))
+
+	assert.Error(t, CheckPasswordHash("Cannot hash password", "Cannot hash password"))
+
+	assert.Error(t, CheckDummyPassword("password"))
+	assert.Error(t, CheckDummyPassword("dummy password"))
+	assert.True(t, strings.HasPrefix(dummyPasswordHash.encoded, "$argon2id$"))
+
+	SetPasswordHasher(NewBcryptHasher(bcrypt.MinCost + 1))
+	assert.Error(t, CheckDummyPassword("password"))
+	assert.True(t, strings.HasPrefix(dummyPasswordHash.encoded, "$2a$"))
+	assert.True(t, PasswordNeedsRehash(string(legacy)))
+	assert.True(t, PasswordNeedsRehash(hash))
+	assert.NoError(t, CheckPasswordHash("password", hash))
+}
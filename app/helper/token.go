@@ -0,0 +1,79 @@
+package helper
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+var RefreshTokenTTL = time.Hour * 24 * 30
+
+// GenerateOpaqueToken returns a random url-safe token for the client together
+// with the hash that should be persisted in its place.
+func GenerateOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix starts every API key so that leaked keys are easy to spot.
+const APIKeyPrefix = "ak_"
+
+// GenerateAPIKey returns a new API key of the form ak_<lookup>.<secret>. The
+// lookup part is stored in plain to find the key, only a salted hash of the
+// secret is kept.
+func GenerateAPIKey() (key, lookup, secret string, err error) {
+	l := make([]byte, 8)
+	s := make([]byte, 32)
+	if _, err = rand.Read(l); err != nil {
+		return "", "", "", err
+	}
+	if _, err = rand.Read(s); err != nil {
+		return "", "", "", err
+	}
+	lookup = hex.EncodeToString(l)
+	secret = base64.RawURLEncoding.EncodeToString(s)
+	return APIKeyPrefix + lookup + "." + secret, lookup, secret, nil
+}
+
+// SplitAPIKey returns the lookup and secret parts of an API key.
+func SplitAPIKey(key string) (lookup, secret string, ok bool) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return "", "", false
+	}
+	lookup, secret, ok = strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), ".")
+	if !ok || len(lookup) != 16 || secret == "" {
+		return "", "", false
+	}
+	return lookup, secret, true
+}
+
+// GenerateSalt returns a random hex salt for HashAPIKeySecret.
+func GenerateSalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashAPIKeySecret hashes the secret of an API key with its salt. The
+// secrets are random, so a keyed hash is enough and keeps the check cheap
+// on every request.
+func HashAPIKeySecret(salt, secret string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,101 @@
+package helper
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The SHA1 vectors of RFC 6238 appendix B, truncated to six digits.
+func TestTOTPCode_RFC6238(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+	now := time.Now()
+
+	code, _ := TOTPCode(secret, TOTPStep(now))
+	step, ok := ValidateTOTP(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now), step)
+
+	// One period of drift either way is fine, two is not.
+	_, ok = ValidateTOTP(secret, code, now.Add(30*time.Second))
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(secret, code, now.Add(-30*time.Second))
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(secret, code, now.Add(90*time.Second))
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP("not base32!", "123456", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("api-auth", "kale@gmail.com", "JBSWY3DPEHPK3PXP"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/api-auth:kale@gmail.com", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "api-auth", uri.Query().Get("issuer"))
+
+	png, err := QRCodePNG(uri.String())
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(png), "\x89PNG"))
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, 11)
+	assert.Equal(t, "-", code[5:6])
+
+	assert.Equal(t, code, NormalizeRecoveryCode(strings.ToUpper(strings.Replace(code, "-", "", 1))))
+	assert.Equal(t, code, NormalizeRecoveryCode(" "+code+" "))
+}
+
+func TestMFAChallengeToken(t *testing.T) {
+	token, err := GenerateMFAChallengeToken("uuid", time.Minute)
+	assert.NoError(t, err)
+
+	claims, err := ParseMFAChallengeToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid", claims.Subject)
+
+	// A challenge is not an access token and the other way round.
+	_, err = ParseJWT(token)
+	assert.Error(t, err)
+	access, _ := GenerateJWT("uuid", "kale@gmail.com")
+	_, err = ParseMFAChallengeToken(access)
+	assert.Error(t, err)
+	verify, _ := GenerateVerificationToken("uuid", "kale@gmail.com", time.Minute)
+	_, err = ParseMFAChallengeToken(verify)
+	assert.Error(t, err)
+
+	expired, _ := GenerateMFAChallengeToken("uuid", -time.Minute)
+	_, err = ParseMFAChallengeToken(expired)
+	assert.Error(t, err)
+}
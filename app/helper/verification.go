@@ -0,0 +1,80 @@
+package helper
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	purposeVerifyEmail = "verify_email"
+	purposeChangeEmail = "change_email"
+)
+
+// VerificationClaims is the payload of the link mailed after registration.
+// It names the address being verified so the link dies if the email changes.
+type VerificationClaims struct {
+	Purpose string `json:"purpose"`
+	Email   string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+func GenerateVerificationToken(userId, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return SignToken(VerificationClaims{
+		Purpose: purposeVerifyEmail,
+		Email:   email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+}
+
+func ParseVerificationToken(tokenString string) (*VerificationClaims, error) {
+	claims := &VerificationClaims{}
+	if err := VerifyToken(tokenString, claims); err != nil {
+		return nil, errors.New("Invalid or expired verification link!")
+	}
+	if claims.Purpose != purposeVerifyEmail || claims.Subject == "" || claims.ExpiresAt == nil {
+		return nil, errors.New("Invalid or expired verification link!")
+	}
+	return claims, nil
+}
+
+// EmailChangeClaims is the payload of the link mailed to a new address. It
+// names the address being replaced so the link dies if the email changes
+// in the meantime.
+type EmailChangeClaims struct {
+	Purpose       string `json:"purpose"`
+	Email         string `json:"email"`
+	PreviousEmail string `json:"previousEmail"`
+	jwt.RegisteredClaims
+}
+
+func GenerateEmailChangeToken(userId, previousEmail, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return SignToken(EmailChangeClaims{
+		Purpose:       purposeChangeEmail,
+		Email:         email,
+		PreviousEmail: previousEmail,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+}
+
+func ParseEmailChangeToken(tokenString string) (*EmailChangeClaims, error) {
+	claims := &EmailChangeClaims{}
+	if err := VerifyToken(tokenString, claims); err != nil {
+		return nil, errors.New("Invalid or expired confirmation link!")
+	}
+	if claims.Purpose != purposeChangeEmail || claims.Subject == "" || claims.Email == "" || claims.PreviousEmail == "" || claims.ExpiresAt == nil {
+		return nil, errors.New("Invalid or expired confirmation link!")
+	}
+	return claims, nil
+}
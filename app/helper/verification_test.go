@@ -0,0 +1,30 @@
+package helper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailChangeToken(t *testing.T) {
+	token, err := GenerateEmailChangeToken("uuid", "kale@gmail.com", "leo@gmail.com", time.Minute)
+	assert.NoError(t, err)
+
+	claims, err := ParseEmailChangeToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid", claims.Subject)
+	assert.Equal(t, "kale@gmail.com", claims.PreviousEmail)
+	assert.Equal(t, "leo@gmail.com", claims.Email)
+
+	// A verification link cannot change the email and the other way round.
+	verify, _ := GenerateVerificationToken("uuid", "leo@gmail.com", time.Minute)
+	_, err = ParseEmailChangeToken(verify)
+	assert.Error(t, err)
+	_, err = ParseVerificationToken(token)
+	assert.Error(t, err)
+
+	expired, _ := GenerateEmailChangeToken("uuid", "kale@gmail.com", "leo@gmail.com", -time.Minute)
+	_, err = ParseEmailChangeToken(expired)
+	assert.Error(t, err)
+}
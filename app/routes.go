@@ -1,28 +1,99 @@
-package app
-
-import (
-	"api-auth/controllers"
-	"api-auth/services/logic"
-
-	"github.com/gin-gonic/gin"
-	"github.com/jinzhu/gorm"
-)
-
-func Routes(db *gorm.DB, uc logic.UserUsecaseInterface) *gin.Engine {
-
-	c := controllers.NewInitController(uc)
-
-	r := gin.Default()
-	r.Use(func(c *gin.Context) {
-		c.Set("db", db)
-	})
-
-	r.POST("/login", c.Login)
-	r.POST("/register", c.Register)
-	r.POST("/change-password", c.ChangePassword)
-	r.GET("/users", c.AllUsers)
-	r.GET("/user/:userId", c.SingleUser)
-	r.DELETE("/user", c.DeleteUser)
-
-	return r
-}
+package app
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/app/helper"
+	"api-auth/controllers"
+	"api-auth/services/logic"
+	"api-auth/services/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+func Routes(db *gorm.DB, uc logic.UserUsecaseInterface, rc logic.RoleUsecaseInterface, oc logic.OAuthUsecaseInterface, ac logic.APIKeyUsecaseInterface, revocations repository.RevocationRepositoryInterface, roles repository.RoleRepositoryInterface) *gin.Engine {
+
+	c := controllers.NewInitController(uc)
+	rolec := controllers.NewRoleController(rc)
+	oauthc := controllers.NewOAuthController(oc)
+	apikeyc := controllers.NewAPIKeyController(ac)
+	guard := gateway.NewAuthorizer(roles)
+	kc := controllers.NewKeyController(helper.CurrentKeyRing())
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("db", db)
+	})
+
+	r.POST("/login", c.Login)
+	r.POST("/login/mfa", c.MFALogin)
+	r.POST("/login/mfa/webauthn/begin", c.BeginWebAuthnMFA)
+	r.POST("/login/mfa/webauthn/finish", c.FinishWebAuthnMFA)
+	r.POST("/webauthn/login/begin", c.BeginWebAuthnLogin)
+	r.POST("/webauthn/login/finish", c.FinishWebAuthnLogin)
+	r.POST("/register", c.Register)
+	r.POST("/token/refresh", c.RefreshToken)
+	r.POST("/password/forgot", c.ForgotPassword)
+	r.POST("/password/reset", c.ResetPassword)
+	r.GET("/verify-email", c.VerifyEmail)
+	r.POST("/verify-email/resend", c.ResendVerification)
+	r.GET("/me/email/confirm", c.ConfirmEmailChange)
+	r.GET("/.well-known/jwks.json", kc.JWKS)
+	r.GET("/.well-known/openid-configuration", oauthc.Discovery)
+	r.POST("/oauth/token", oauthc.Token)
+	r.POST("/oauth/introspect", oauthc.Introspect)
+	r.POST("/oauth/revoke", oauthc.Revoke)
+
+	// Access tokens of OAuth clients are only good for the userinfo endpoint.
+	userinfo := r.Group("/userinfo", gateway.IsAuthMiddleware(revocations, nil))
+	userinfo.GET("", oauthc.UserInfo)
+	userinfo.POST("", oauthc.UserInfo)
+
+	auth := r.Group("/", gateway.IsAuthMiddleware(revocations, ac), gateway.FirstPartyOnly())
+	auth.GET("/users", guard.RequirePermission(logic.PermissionUsersRead), c.AllUsers)
+	auth.GET("/user/:userId", guard.RequirePermission(logic.PermissionUsersRead), c.SingleUser)
+	auth.DELETE("/user", guard.RequirePermission(logic.PermissionUsersDelete), c.DeleteUser)
+	auth.POST("/admin/unlock", guard.RequirePermission(logic.PermissionUsersUnlock), c.Unlock)
+	auth.GET("/admin/clients", guard.RequirePermission(logic.PermissionClientsManage), oauthc.Clients)
+	auth.POST("/admin/clients", guard.RequirePermission(logic.PermissionClientsManage), oauthc.CreateClient)
+	auth.DELETE("/admin/clients/:clientId", guard.RequirePermission(logic.PermissionClientsManage), oauthc.DeleteClient)
+
+	// Routes acting on the account of the caller need a logged in user.
+	account := auth.Group("/", gateway.SessionOnly())
+	account.POST("/logout", c.Logout)
+	account.POST("/change-password", c.ChangePassword)
+	account.GET("/me", c.Me)
+	account.PATCH("/me", c.UpdateMe)
+	account.POST("/me/email", c.ChangeEmail)
+	account.POST("/mfa/totp/enroll", c.EnrollMFA)
+	account.POST("/mfa/totp/confirm", c.ConfirmMFA)
+	account.POST("/mfa/totp/disable", c.DisableMFA)
+	account.POST("/mfa/recovery-codes", c.RegenerateRecoveryCodes)
+	account.POST("/webauthn/register/begin", c.BeginWebAuthnRegistration)
+	account.POST("/webauthn/register/finish", c.FinishWebAuthnRegistration)
+	account.POST("/webauthn/reauth/begin", c.BeginWebAuthnReauth)
+	account.GET("/webauthn/credentials", c.WebAuthnCredentials)
+	account.DELETE("/webauthn/credentials/:credentialId", c.DeleteWebAuthnCredential)
+	account.GET("/oauth/authorize", oauthc.Authorize)
+	account.POST("/oauth/authorize", oauthc.Consent)
+	account.GET("/api-keys", apikeyc.APIKeys)
+	account.POST("/api-keys", apikeyc.CreateAPIKey)
+	account.DELETE("/api-keys/:keyId", apikeyc.RevokeAPIKey)
+	account.GET("/admin/api-keys", guard.RequirePermission(logic.PermissionAPIKeysManage), apikeyc.ServiceAPIKeys)
+	account.POST("/admin/api-keys", guard.RequirePermission(logic.PermissionAPIKeysManage), apikeyc.CreateServiceAPIKey)
+	account.DELETE("/admin/api-keys/:keyId", guard.RequirePermission(logic.PermissionAPIKeysManage), apikeyc.RevokeServiceAPIKey)
+
+	admin := auth.Group("/admin", guard.RequirePermission(logic.PermissionRolesManage))
+	admin.GET("/roles", rolec.Roles)
+	admin.POST("/roles", rolec.CreateRole)
+	admin.DELETE("/roles/:roleId", rolec.DeleteRole)
+	admin.POST("/roles/:roleId/permissions", rolec.GrantPermission)
+	admin.DELETE("/roles/:roleId/permissions/:permissionId", rolec.RevokePermission)
+	admin.GET("/permissions", rolec.Permissions)
+	admin.POST("/permissions", rolec.CreatePermission)
+	admin.GET("/users/:userId/roles", rolec.UserRoles)
+	admin.POST("/users/:userId/roles", rolec.AssignRole)
+	admin.DELETE("/users/:userId/roles/:roleId", rolec.UnassignRole)
+
+	return r
+}
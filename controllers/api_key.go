@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/domains"
+	"api-auth/services/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyController struct {
+	caseAPIKey logic.APIKeyUsecaseInterface
+}
+
+func NewAPIKeyController(caseAPIKey logic.APIKeyUsecaseInterface) *APIKeyController {
+	return &APIKeyController{
+		caseAPIKey: caseAPIKey,
+	}
+}
+
+func (kc *APIKeyController) APIKeys(c *gin.Context) {
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	keys, err := kc.caseAPIKey.GetAPIKeys(c.Request.Context(), principal)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"apiKeys": presentAPIKeys(keys),
+	})
+}
+
+func (kc *APIKeyController) CreateAPIKey(c *gin.Context) {
+	var inputKey domains.CreateAPIKey
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputKey); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	created, err := kc.caseAPIKey.CreateAPIKeyHandler(c.Request.Context(), principal, &inputKey)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	respondAPIKeyCreated(c, created)
+}
+
+func (kc *APIKeyController) RevokeAPIKey(c *gin.Context) {
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := kc.caseAPIKey.RevokeAPIKeyHandler(c.Request.Context(), principal, c.Param("keyId")); err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked!",
+	})
+}
+
+func (kc *APIKeyController) ServiceAPIKeys(c *gin.Context) {
+	keys, err := kc.caseAPIKey.GetServiceAPIKeys(c.Request.Context())
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"apiKeys": presentAPIKeys(keys),
+	})
+}
+
+func (kc *APIKeyController) CreateServiceAPIKey(c *gin.Context) {
+	var inputKey domains.CreateServiceAPIKey
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputKey); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	created, err := kc.caseAPIKey.CreateServiceAPIKeyHandler(c.Request.Context(), principal, &inputKey)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	respondAPIKeyCreated(c, created)
+}
+
+func (kc *APIKeyController) RevokeServiceAPIKey(c *gin.Context) {
+	if err := kc.caseAPIKey.RevokeServiceAPIKeyHandler(c.Request.Context(), c.Param("keyId")); err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked!",
+	})
+}
+
+func respondAPIKeyCreated(c *gin.Context, created *domains.APIKeyCreated) {
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key created, store it now, it is not shown again!",
+		"apiKey":  created,
+	})
+}
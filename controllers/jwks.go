@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"api-auth/app/helper"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyController struct {
+	keys *helper.KeyRing
+}
+
+func NewKeyController(keys *helper.KeyRing) *KeyController {
+	return &KeyController{
+		keys: keys,
+	}
+}
+
+func (kc *KeyController) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, kc.keys.JWKS())
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (ac *AuthController) MFALogin(c *gin.Context) {
+	var inputMFA domains.MFALogin
+
+	if err := c.ShouldBindJSON(&inputMFA); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	user, token, err := ac.caseUser.MFALoginHandler(c.Request.Context(), &inputMFA, c.ClientIP())
+	ac.respondLogin(c, user, token, err)
+}
+
+// respondLogin writes the result of a login step that issues tokens.
+func (ac *AuthController) respondLogin(c *gin.Context, user *repository.User, token *domains.Token, err error) {
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Login successfully!",
+		"token":        token.AccessToken,
+		"refreshToken": token.RefreshToken,
+		"user":         presentUser(user),
+	})
+}
+
+func (ac *AuthController) EnrollMFA(c *gin.Context) {
+	var inputReauth domains.Reauthentication
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputReauth); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	enrollment, err := ac.caseUser.EnrollMFAHandler(c.Request.Context(), principal, &inputReauth)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Scan the QR code and confirm with a code from your authenticator!",
+		"secret":  enrollment.Secret,
+		"uri":     enrollment.URI,
+		"qrCode":  enrollment.QRCode,
+	})
+}
+
+func (ac *AuthController) ConfirmMFA(c *gin.Context) {
+	var inputCode domains.MFACode
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputCode); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	codes, err := ac.caseUser.ConfirmMFAHandler(c.Request.Context(), principal, &inputCode)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "MFA enabled, store the recovery codes somewhere safe!",
+		"recoveryCodes": codes,
+	})
+}
+
+func (ac *AuthController) DisableMFA(c *gin.Context) {
+	var inputDisable domains.DisableMFA
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputDisable); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.DisableMFAHandler(c.Request.Context(), principal, &inputDisable)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "MFA disabled!",
+	})
+}
+
+func (ac *AuthController) RegenerateRecoveryCodes(c *gin.Context) {
+	var inputCode domains.MFACode
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputCode); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	codes, err := ac.caseUser.RegenerateRecoveryCodesHandler(c.Request.Context(), principal, &inputCode)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Recovery codes replaced!",
+		"recoveryCodes": codes,
+	})
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/domains"
+	"api-auth/services/logic"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthController struct {
+	caseOAuth logic.OAuthUsecaseInterface
+}
+
+func NewOAuthController(caseOAuth logic.OAuthUsecaseInterface) *OAuthController {
+	return &OAuthController{
+		caseOAuth: caseOAuth,
+	}
+}
+
+func (oc *OAuthController) Clients(c *gin.Context) {
+	clients, err := oc.caseOAuth.GetClients(c.Request.Context())
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"clients": presentOAuthClients(clients),
+	})
+}
+
+func (oc *OAuthController) CreateClient(c *gin.Context) {
+	var inputClient domains.CreateOAuthClient
+
+	if err := c.ShouldBindJSON(&inputClient); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	credentials, err := oc.caseOAuth.CreateClientHandler(c.Request.Context(), &inputClient)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Client registered, store the secret now, it is not shown again!",
+		"clientId":     credentials.ClientID,
+		"clientSecret": credentials.ClientSecret,
+	})
+}
+
+func (oc *OAuthController) DeleteClient(c *gin.Context) {
+	if err := oc.caseOAuth.DeleteClientHandler(c.Request.Context(), c.Param("clientId")); err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Client deleted!",
+	})
+}
+
+// Authorize is called by the frontend with the query of the authorization
+// request and the token of the logged in user. The response tells it to
+// either redirect to RedirectTo or show the consent screen.
+func (oc *OAuthController) Authorize(c *gin.Context) {
+	var inputAuthorize domains.AuthorizeRequest
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindQuery(&inputAuthorize); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	response, err := oc.caseOAuth.AuthorizeHandler(c.Request.Context(), principal, &inputAuthorize)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (oc *OAuthController) Consent(c *gin.Context) {
+	var inputConsent domains.AuthorizeConsent
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBind(&inputConsent); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	response, err := oc.caseOAuth.ConsentHandler(c.Request.Context(), principal, &inputConsent)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Token is the token endpoint of RFC 6749. Clients authenticate with HTTP
+// Basic or with client_id and client_secret in the form.
+func (oc *OAuthController) Token(c *gin.Context) {
+	var inputToken domains.TokenRequest
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	if err := c.ShouldBind(&inputToken); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": err.Error(),
+		})
+		return
+	}
+	basicClientAuth(c, &inputToken.ClientID, &inputToken.ClientSecret)
+
+	token, err := oc.caseOAuth.TokenHandler(c.Request.Context(), &inputToken)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// Introspect is the introspection endpoint of RFC 7662.
+func (oc *OAuthController) Introspect(c *gin.Context) {
+	var inputToken domains.TokenHint
+
+	c.Header("Cache-Control", "no-store")
+
+	if err := c.ShouldBind(&inputToken); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": err.Error(),
+		})
+		return
+	}
+	basicClientAuth(c, &inputToken.ClientID, &inputToken.ClientSecret)
+
+	introspection, err := oc.caseOAuth.IntrospectHandler(c.Request.Context(), &inputToken)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, introspection)
+}
+
+// Revoke is the revocation endpoint of RFC 7009. It answers 200 for tokens
+// it does not know as well.
+func (oc *OAuthController) Revoke(c *gin.Context) {
+	var inputToken domains.TokenHint
+
+	if err := c.ShouldBind(&inputToken); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": err.Error(),
+		})
+		return
+	}
+	basicClientAuth(c, &inputToken.ClientID, &inputToken.ClientSecret)
+
+	if err := oc.caseOAuth.RevokeHandler(c.Request.Context(), &inputToken); err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// basicClientAuth takes the client credentials from HTTP Basic
+// authentication when the request has it.
+func basicClientAuth(c *gin.Context, clientId, clientSecret *string) {
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		// The credentials are form encoded before going into the header.
+		*clientId, _ = url.QueryUnescape(id)
+		*clientSecret, _ = url.QueryUnescape(secret)
+	}
+}
+
+func (oc *OAuthController) Discovery(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, oc.caseOAuth.DiscoveryHandler())
+}
+
+// UserInfo is the userinfo endpoint of OpenID Connect, clients call it
+// with the access token they got from Token.
+func (oc *OAuthController) UserInfo(c *gin.Context) {
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	info, err := oc.caseOAuth.UserInfoHandler(c.Request.Context(), principal)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// oauthErrorResponse writes errors in the format of RFC 6749 section 5.2,
+// or RFC 6750 section 3.1 for the errors of bearer token requests. Other
+// errors are logged and reported as server_error without their message.
+func oauthErrorResponse(c *gin.Context, err error) {
+	var oauthErr *logic.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("%s %s: %s", c.Request.Method, c.Request.URL.Path, err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "server_error",
+			"error_description": logic.ErrInternal.Message,
+		})
+		return
+	}
+	status := http.StatusBadRequest
+	switch oauthErr.Code {
+	case "invalid_client":
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		status = http.StatusUnauthorized
+	case "invalid_token":
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		status = http.StatusUnauthorized
+	case "insufficient_scope":
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		status = http.StatusForbidden
+	}
+	c.JSON(status, gin.H{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+	})
+}
@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/app/helper"
+	"api-auth/domains"
+	mokz "api-auth/mock"
+	"api-auth/services/logic"
+	"api-auth/services/repository"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var oauthUsecase = &mokz.OAuthUsecaseMock{Mock: mock.Mock{}}
+var oauthController = NewOAuthController(oauthUsecase)
+
+func TestOAuthToken(t *testing.T) {
+	r := SetRouter()
+	r.POST("/oauth/token", oauthController.Token)
+
+	post := func(form url.Values, clientId, clientSecret string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if clientId != "" {
+			req.SetBasicAuth(url.QueryEscape(clientId), url.QueryEscape(clientSecret))
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success_basic_auth", func(t *testing.T) {
+		oauthUsecase.Mock.On("TokenHandler", mock.Anything, &domains.TokenRequest{
+			GrantType:    logic.GrantClientCredentials,
+			Scope:        "reports:read",
+			ClientID:     "service_client",
+			ClientSecret: "secret with spaces",
+		}).Return(&domains.OAuthToken{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 900, Scope: "reports:read"}, nil).Once()
+
+		w := post(url.Values{"grant_type": {logic.GrantClientCredentials}, "scope": {"reports:read"}}, "service_client", "secret with spaces")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{"access_token":"access","token_type":"Bearer","expires_in":900,"scope":"reports:read"}`, w.Body.String())
+	})
+
+	t.Run("missing_grant_type", func(t *testing.T) {
+		w := post(url.Values{}, "", "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_request"`)
+	})
+
+	t.Run("invalid_client", func(t *testing.T) {
+		oauthUsecase.Mock.On("TokenHandler", mock.Anything, mock.MatchedBy(func(input *domains.TokenRequest) bool {
+			return input.ClientID == "service_client" && input.ClientSecret == "wrong"
+		})).Return(nil, &logic.OAuthError{Code: "invalid_client", Description: "Invalid client credentials!"}).Once()
+
+		w := post(url.Values{"grant_type": {logic.GrantClientCredentials}}, "service_client", "wrong")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+		assert.JSONEq(t, `{"error":"invalid_client","error_description":"Invalid client credentials!"}`, w.Body.String())
+	})
+
+	t.Run("invalid_grant", func(t *testing.T) {
+		oauthUsecase.Mock.On("TokenHandler", mock.Anything, mock.MatchedBy(func(input *domains.TokenRequest) bool {
+			return input.Code == "used_code"
+		})).Return(nil, &logic.OAuthError{Code: "invalid_grant", Description: "Authorization code already used!"}).Once()
+
+		w := post(url.Values{"grant_type": {logic.GrantAuthorizationCode}, "code": {"used_code"}, "client_id": {"spa_client"}}, "", "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"invalid_grant","error_description":"Authorization code already used!"}`, w.Body.String())
+	})
+
+	t.Run("server_error", func(t *testing.T) {
+		oauthUsecase.Mock.On("TokenHandler", mock.Anything, mock.MatchedBy(func(input *domains.TokenRequest) bool {
+			return input.RefreshToken == "broken"
+		})).Return(nil, errors.New("Cannot create refresh token!")).Once()
+
+		w := post(url.Values{"grant_type": {logic.GrantRefreshToken}, "refresh_token": {"broken"}}, "", "")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"server_error"`)
+	})
+}
+
+func TestOAuthAuthorize(t *testing.T) {
+	r := SetRouter()
+	r.GET("/oauth/authorize", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), oauthController.Authorize)
+
+	token, _ := helper.GenerateJWT("authorize_uuid", "kale@gmail.com")
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"spa_client"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"scope":                 {"openid"},
+		"state":                 {"xyz"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+	}
+
+	oauthUsecase.Mock.On("AuthorizeHandler", mock.Anything, mock.MatchedBy(func(principal *domains.Principal) bool {
+		return principal.ID == "authorize_uuid"
+	}), &domains.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            "spa_client",
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "openid",
+		State:               "xyz",
+		CodeChallenge:       "challenge",
+		CodeChallengeMethod: "S256",
+	}).Return(&domains.AuthorizeResponse{ConsentRequired: true, ClientID: "spa_client", ClientName: "Frontend", Scopes: []string{"openid"}}, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/oauth/authorize?"+query.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"consentRequired":true,"clientId":"spa_client","clientName":"Frontend","scopes":["openid"]}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/oauth/authorize?"+query.Encode(), nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestOAuthUserInfo(t *testing.T) {
+	r := SetRouter()
+	r.GET("/userinfo", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), oauthController.UserInfo)
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		token, _ := helper.GenerateOAuthJWT("userinfo_uuid", "", "spa_client", "openid profile")
+		oauthUsecase.Mock.On("UserInfoHandler", mock.Anything, mock.MatchedBy(func(principal *domains.Principal) bool {
+			return principal.ID == "userinfo_uuid" && principal.Scope == "openid profile"
+		})).Return(&domains.UserInfo{Subject: "userinfo_uuid", Name: "kale"}, nil).Once()
+
+		w := request(token)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"sub":"userinfo_uuid","name":"kale"}`, w.Body.String())
+	})
+
+	t.Run("insufficient_scope", func(t *testing.T) {
+		token, _ := helper.GenerateJWT("first_party_uuid", "kale@gmail.com")
+		oauthUsecase.Mock.On("UserInfoHandler", mock.Anything, mock.MatchedBy(func(principal *domains.Principal) bool {
+			return principal.ID == "first_party_uuid"
+		})).Return(nil, &logic.OAuthError{Code: "insufficient_scope", Description: "The token was not granted the openid scope!"}).Once()
+
+		w := request(token)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+	})
+}
+
+func TestOAuthIntrospectAndRevoke(t *testing.T) {
+	r := SetRouter()
+	r.POST("/oauth/introspect", oauthController.Introspect)
+	r.POST("/oauth/revoke", oauthController.Revoke)
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("service_client", "service_secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("introspect", func(t *testing.T) {
+		oauthUsecase.Mock.On("IntrospectHandler", mock.Anything, &domains.TokenHint{
+			Token:        "some_token",
+			ClientID:     "service_client",
+			ClientSecret: "service_secret",
+		}).Return(&domains.Introspection{Active: false}, nil).Once()
+
+		w := post("/oauth/introspect", url.Values{"token": {"some_token"}})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"active":false}`, w.Body.String())
+	})
+
+	t.Run("introspect_without_token", func(t *testing.T) {
+		w := post("/oauth/introspect", url.Values{})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		oauthUsecase.Mock.On("RevokeHandler", mock.Anything, &domains.TokenHint{
+			Token:         "some_refresh",
+			TokenTypeHint: "refresh_token",
+			ClientID:      "service_client",
+			ClientSecret:  "service_secret",
+		}).Return(nil).Once()
+
+		w := post("/oauth/revoke", url.Values{"token": {"some_refresh"}, "token_type_hint": {"refresh_token"}})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"api-auth/domains"
+	"api-auth/services/repository"
+)
+
+// The present functions map persistence models to the types the API
+// responds with. Models are never written to a response directly, so a
+// column added to a table is not exposed until it is added here.
+
+func presentUser(user *repository.User) *domains.User {
+	if user == nil {
+		return nil
+	}
+	return &domains.User{
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		CreatedAt:       user.CreatedAt,
+	}
+}
+
+func presentUsers(users []repository.User) []domains.User {
+	presented := make([]domains.User, len(users))
+	for i := range users {
+		presented[i] = *presentUser(&users[i])
+	}
+	return presented
+}
+
+func presentRole(role *repository.Role) *domains.Role {
+	return &domains.Role{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: presentPermissions(role.Permissions),
+	}
+}
+
+func presentRoles(roles []repository.Role) []domains.Role {
+	presented := make([]domains.Role, len(roles))
+	for i := range roles {
+		presented[i] = *presentRole(&roles[i])
+	}
+	return presented
+}
+
+func presentPermission(permission *repository.Permission) *domains.Permission {
+	return &domains.Permission{
+		ID:          permission.ID,
+		Name:        permission.Name,
+		Description: permission.Description,
+	}
+}
+
+func presentPermissions(permissions []repository.Permission) []domains.Permission {
+	presented := make([]domains.Permission, len(permissions))
+	for i := range permissions {
+		presented[i] = *presentPermission(&permissions[i])
+	}
+	return presented
+}
+
+func presentWebAuthnCredential(credential *repository.WebAuthnCredential) *domains.WebAuthnCredential {
+	return &domains.WebAuthnCredential{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		Algorithm:  credential.Algorithm,
+		AAGUID:     credential.AAGUID,
+		Transports: credential.Transports,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
+
+func presentWebAuthnCredentials(credentials []repository.WebAuthnCredential) []domains.WebAuthnCredential {
+	presented := make([]domains.WebAuthnCredential, len(credentials))
+	for i := range credentials {
+		presented[i] = *presentWebAuthnCredential(&credentials[i])
+	}
+	return presented
+}
+
+func presentOAuthClients(clients []repository.OAuthClient) []domains.OAuthClient {
+	presented := make([]domains.OAuthClient, len(clients))
+	for i, client := range clients {
+		presented[i] = domains.OAuthClient{
+			ClientID:     client.ID,
+			Name:         client.Name,
+			RedirectURIs: client.RedirectURIs,
+			GrantTypes:   client.GrantTypes,
+			Scopes:       client.Scopes,
+			CreatedAt:    client.CreatedAt,
+		}
+	}
+	return presented
+}
+
+func presentAPIKeys(keys []repository.APIKey) []domains.APIKey {
+	presented := make([]domains.APIKey, len(keys))
+	for i, key := range keys {
+		presented[i] = domains.APIKey{
+			ID:             key.ID,
+			Lookup:         key.Lookup,
+			UserID:         key.UserID,
+			ServiceAccount: key.ServiceAccount,
+			Name:           key.Name,
+			Scopes:         key.Scopes,
+			ExpiresAt:      key.ExpiresAt,
+			LastUsedAt:     key.LastUsedAt,
+			CreatedAt:      key.CreatedAt,
+		}
+	}
+	return presented
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"api-auth/services/repository"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Secrets of the models must not make it into any of the presented types,
+// whatever the json tags of the models say.
+func TestPresentersHideSecrets(t *testing.T) {
+	now := time.Now()
+	presented := []interface{}{
+		presentUser(&repository.User{ID: "uuid", Email: "kale@gmail.com", Password: "secret_hash", VerificationSentAt: &now}),
+		presentUsers([]repository.User{{ID: "uuid", Password: "secret_hash"}}),
+		presentWebAuthnCredentials([]repository.WebAuthnCredential{{ID: "credential", UserID: "uuid", PublicKey: []byte("secret_key"), SignCount: 7}}),
+		presentOAuthClients([]repository.OAuthClient{{ID: "client", SecretHash: "secret_hash"}}),
+		presentAPIKeys([]repository.APIKey{{ID: "key", Salt: "secret_salt", SecretHash: "secret_hash", RevokedAt: &now}}),
+	}
+
+	for _, value := range presented {
+		body, err := json.Marshal(value)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(body), "secret")
+		assertNoPassword(t, body)
+		for _, field := range []string{"verificationSentAt", "publicKey", "signCount", "salt", "secretHash", "revokedAt"} {
+			assert.NotContains(t, strings.ToLower(string(body)), strings.ToLower(`"`+field+`"`))
+		}
+	}
+}
+
+func TestPresentUsersEmpty(t *testing.T) {
+	body, _ := json.Marshal(presentUsers(nil))
+
+	assert.Equal(t, "[]", string(body))
+}
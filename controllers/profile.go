@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/domains"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (ac *AuthController) Me(c *gin.Context) {
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	user, err := ac.caseUser.GetProfileHandler(c.Request.Context(), principal)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully fetch profile!",
+		"user":    presentUser(user),
+	})
+}
+
+func (ac *AuthController) UpdateMe(c *gin.Context) {
+	var inputProfile domains.UpdateProfile
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputProfile); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	user, err := ac.caseUser.UpdateProfileHandler(c.Request.Context(), principal, &inputProfile)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Profile updated!",
+		"user":    presentUser(user),
+	})
+}
+
+func (ac *AuthController) ChangeEmail(c *gin.Context) {
+	var inputChangeEmail domains.ChangeEmail
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputChangeEmail); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.ChangeEmailHandler(c.Request.Context(), principal, &inputChangeEmail)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Check the new email to confirm the change!",
+	})
+}
+
+func (ac *AuthController) ConfirmEmailChange(c *gin.Context) {
+	err := ac.caseUser.ConfirmEmailChangeHandler(c.Request.Context(), c.Query("token"))
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email changed!",
+	})
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/domains"
+	"api-auth/services/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleController struct {
+	caseRole logic.RoleUsecaseInterface
+}
+
+func NewRoleController(caseRole logic.RoleUsecaseInterface) *RoleController {
+	return &RoleController{
+		caseRole: caseRole,
+	}
+}
+
+func (rc *RoleController) Roles(c *gin.Context) {
+	roles, err := rc.caseRole.GetRoles(c.Request.Context())
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"roles": presentRoles(roles),
+	})
+}
+
+func (rc *RoleController) CreateRole(c *gin.Context) {
+	var inputRole domains.CreateRole
+
+	if err := c.ShouldBindJSON(&inputRole); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	role, err := rc.caseRole.CreateRoleHandler(c.Request.Context(), &inputRole)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Role created!",
+		"role":    presentRole(role),
+	})
+}
+
+func (rc *RoleController) DeleteRole(c *gin.Context) {
+	err := rc.caseRole.DeleteRoleHandler(c.Request.Context(), c.Param("roleId"))
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role deleted!",
+	})
+}
+
+func (rc *RoleController) Permissions(c *gin.Context) {
+	permissions, err := rc.caseRole.GetPermissions(c.Request.Context())
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"permissions": presentPermissions(permissions),
+	})
+}
+
+func (rc *RoleController) CreatePermission(c *gin.Context) {
+	var inputPermission domains.CreatePermission
+
+	if err := c.ShouldBindJSON(&inputPermission); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	permission, err := rc.caseRole.CreatePermissionHandler(c.Request.Context(), &inputPermission)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Permission created!",
+		"permission": presentPermission(permission),
+	})
+}
+
+func (rc *RoleController) GrantPermission(c *gin.Context) {
+	var inputGrant domains.GrantPermission
+
+	if err := c.ShouldBindJSON(&inputGrant); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := rc.caseRole.GrantPermissionHandler(c.Request.Context(), c.Param("roleId"), &inputGrant)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Permission granted!",
+	})
+}
+
+func (rc *RoleController) RevokePermission(c *gin.Context) {
+	err := rc.caseRole.RevokePermissionHandler(c.Request.Context(), c.Param("roleId"), c.Param("permissionId"))
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Permission revoked!",
+	})
+}
+
+func (rc *RoleController) UserRoles(c *gin.Context) {
+	roles, err := rc.caseRole.GetUserRolesHandler(c.Request.Context(), c.Param("userId"))
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"roles": roles,
+	})
+}
+
+func (rc *RoleController) AssignRole(c *gin.Context) {
+	var inputAssign domains.AssignRole
+
+	if err := c.ShouldBindJSON(&inputAssign); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := rc.caseRole.AssignRoleHandler(c.Request.Context(), c.Param("userId"), &inputAssign)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role assigned!",
+	})
+}
+
+func (rc *RoleController) UnassignRole(c *gin.Context) {
+	err := rc.caseRole.UnassignRoleHandler(c.Request.Context(), c.Param("userId"), c.Param("roleId"))
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role unassigned!",
+	})
+}
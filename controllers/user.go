@@ -1,144 +1,290 @@
-package controllers
-
-import (
-	"api-auth/domains"
-	"api-auth/services/logic"
-	"fmt"
-	"net/http"
-
-	"github.com/gin-gonic/gin"
-)
-
-type AuthController struct {
-	caseUser logic.UserUsecaseInterface
-}
-
-func NewInitController(caseUser logic.UserUsecaseInterface) *AuthController {
-	return &AuthController{
-		caseUser: caseUser,
-	}
-}
-
-func (ac *AuthController) Register(c *gin.Context) {
-	var inputRegis domains.Register
-
-	if err := c.ShouldBindJSON(&inputRegis); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	err := ac.caseUser.RegisterHandler(&inputRegis)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Successfully created user!",
-		"name":    inputRegis.Name,
-		"email":   inputRegis.Email,
-	})
-}
-
-func (ac *AuthController) Login(c *gin.Context) {
-	var inputLogin domains.Login
-
-	if err := c.ShouldBindJSON(&inputLogin); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	user, token, err := ac.caseUser.LoginHandler(&inputLogin)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successfully!",
-		"token":   token,
-		"user":    user,
-	})
-}
-
-func (ac *AuthController) ChangePassword(c *gin.Context) {
-	var inputChangePass domains.ChangePassword
-
-	if err := c.ShouldBindJSON(&inputChangePass); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-	}
-
-	err := ac.caseUser.ChangePasswordHandler(&inputChangePass)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Password changed!",
-	})
-}
-
-func (ac *AuthController) AllUsers(c *gin.Context) {
-	fmt.Println("Inner controller")
-	users, err := ac.caseUser.GetUsers()
-
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Successflly fetch all users",
-		"users":   users,
-	})
-}
-
-func (ac *AuthController) SingleUser(c *gin.Context) {
-	userId := c.Param("userId")
-	user, err := ac.caseUser.GetSingleUserHandler(userId)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Successflly fetch single user",
-		"user":    user,
-	})
-}
-
-func (ac *AuthController) DeleteUser(c *gin.Context) {
-	var inputId domains.UserId
-
-	err := c.ShouldBindJSON(&inputId)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-	}
- 
-	err = ac.caseUser.DeleteUserHandler(inputId.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Successflly delete user",
-	})
-}
+package controllers
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/domains"
+	"api-auth/services/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController struct {
+	caseUser logic.UserUsecaseInterface
+}
+
+func NewInitController(caseUser logic.UserUsecaseInterface) *AuthController {
+	return &AuthController{
+		caseUser: caseUser,
+	}
+}
+
+func (ac *AuthController) Register(c *gin.Context) {
+	var inputRegis domains.Register
+
+	if err := c.ShouldBindJSON(&inputRegis); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.RegisterHandler(c.Request.Context(), &inputRegis)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Successfully created user!",
+		"name":    inputRegis.Name,
+		"email":   inputRegis.Email,
+	})
+}
+
+func (ac *AuthController) Login(c *gin.Context) {
+	var inputLogin domains.Login
+
+	if err := c.ShouldBindJSON(&inputLogin); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	user, token, err := ac.caseUser.LoginHandler(c.Request.Context(), &inputLogin, c.ClientIP())
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	if token.MFAToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "MFA code required!",
+			"mfaRequired": true,
+			"mfaToken":    token.MFAToken,
+			"mfaMethods":  token.MFAMethods,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Login successfully!",
+		"token":        token.AccessToken,
+		"refreshToken": token.RefreshToken,
+		"user":         presentUser(user),
+	})
+}
+
+func (ac *AuthController) RefreshToken(c *gin.Context) {
+	var inputRefresh domains.RefreshToken
+
+	if err := c.ShouldBindJSON(&inputRefresh); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	token, err := ac.caseUser.RefreshHandler(c.Request.Context(), &inputRefresh)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Token refreshed!",
+		"token":        token.AccessToken,
+		"refreshToken": token.RefreshToken,
+	})
+}
+
+func (ac *AuthController) Logout(c *gin.Context) {
+	var inputLogout domains.RefreshToken
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+	// The refresh token is optional, an empty body only revokes the access token.
+	c.ShouldBindJSON(&inputLogout)
+
+	err := ac.caseUser.LogoutHandler(c.Request.Context(), principal, &inputLogout)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logout successfully!",
+	})
+}
+
+func (ac *AuthController) ChangePassword(c *gin.Context) {
+	var inputChangePass domains.ChangePassword
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputChangePass); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.ChangePasswordHandler(c.Request.Context(), principal, &inputChangePass)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed!",
+	})
+}
+
+func (ac *AuthController) ForgotPassword(c *gin.Context) {
+	var inputForgot domains.ForgotPassword
+
+	if err := c.ShouldBindJSON(&inputForgot); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.ForgotPasswordHandler(c.Request.Context(), &inputForgot)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the email is registered, a reset link has been sent!",
+	})
+}
+
+func (ac *AuthController) ResetPassword(c *gin.Context) {
+	var inputReset domains.ResetPassword
+
+	if err := c.ShouldBindJSON(&inputReset); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.ResetPasswordHandler(c.Request.Context(), &inputReset)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed!",
+	})
+}
+
+func (ac *AuthController) VerifyEmail(c *gin.Context) {
+	err := ac.caseUser.VerifyEmailHandler(c.Request.Context(), c.Query("token"))
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified!",
+	})
+}
+
+func (ac *AuthController) ResendVerification(c *gin.Context) {
+	var inputResend domains.ResendVerification
+
+	if err := c.ShouldBindJSON(&inputResend); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.ResendVerificationHandler(c.Request.Context(), &inputResend)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the email is registered and not verified, a new link has been sent!",
+	})
+}
+
+func (ac *AuthController) Unlock(c *gin.Context) {
+	var inputUnlock domains.Unlock
+
+	if err := c.ShouldBindJSON(&inputUnlock); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.UnlockHandler(c.Request.Context(), &inputUnlock)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Login unlocked!",
+	})
+}
+
+func (ac *AuthController) AllUsers(c *gin.Context) {
+	var query domains.UserQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	page, err := ac.caseUser.GetUsers(c.Request.Context(), &query)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	response := gin.H{
+		"message": "Successflly fetch all users",
+		"users":   presentUsers(page.Users),
+	}
+	if page.NextCursor != "" {
+		response["nextCursor"] = page.NextCursor
+	}
+	if page.PrevCursor != "" {
+		response["prevCursor"] = page.PrevCursor
+	}
+	if page.Total != nil {
+		response["total"] = *page.Total
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (ac *AuthController) SingleUser(c *gin.Context) {
+	userId := c.Param("userId")
+	user, err := ac.caseUser.GetSingleUserHandler(c.Request.Context(), userId)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successflly fetch single user",
+		"user":    presentUser(user),
+	})
+}
+
+func (ac *AuthController) DeleteUser(c *gin.Context) {
+	var inputId domains.UserId
+
+	err := c.ShouldBindJSON(&inputId)
+	if err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err = ac.caseUser.DeleteUserHandler(c.Request.Context(), inputId.ID)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successflly delete user",
+	})
+}
@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"api-auth/app/gateway"
+	"api-auth/app/helper"
 	"api-auth/domains"
 	mokz "api-auth/mock"
+	"api-auth/services/logic"
 	"api-auth/services/repository"
 	"bytes"
 	"encoding/json"
@@ -13,6 +16,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -27,6 +31,13 @@ func SetRouter() *gin.Engine {
 	return r
 }
 
+// assertNoPassword fails when a response leaks a password field, hashed or
+// not.
+func assertNoPassword(t *testing.T, body []byte) {
+	t.Helper()
+	assert.NotContains(t, strings.ToLower(string(body)), `"password`)
+}
+
 func TestSuccessRegister(t *testing.T) {
 	input := domains.Register{
 		Name:            "kale",
@@ -41,7 +52,7 @@ func TestSuccessRegister(t *testing.T) {
 	r := SetRouter()
 	r.POST("/register", userController.Register)
 
-	userUsecase.Mock.On("RegisterHandler", &input).Return(nil)
+	userUsecase.Mock.On("RegisterHandler", mock.Anything, &input).Return(nil)
 
 	jsonValue, _ := json.Marshal(input)
 	req, err := http.NewRequest("POST", "/register", bytes.NewBuffer(jsonValue))
@@ -88,7 +99,7 @@ func TestFailRegister(t *testing.T) {
 			PasswordConfirm: "passwords",
 		}
 
-		userUsecase.Mock.On("RegisterHandler", &input).Return(errors.New(""))
+		userUsecase.Mock.On("RegisterHandler", mock.Anything, &input).Return(logic.ErrValidation)
 
 		jsonValue, _ := json.Marshal(input)
 		req, err := http.NewRequest("POST", "/register", bytes.NewBuffer(jsonValue))
@@ -117,13 +128,18 @@ func TestSuccessLogin(t *testing.T) {
 		Password: "passwords",
 	}
 
-	mockResponse := `{"message":"Login successfully!","token":"valid_token","user":{"id":"%s","name":"%s","email":"%s","password":"%s"}}`
-	mockResponse = fmt.Sprintf(mockResponse, user.ID, user.Name, user.Email, user.Password)
+	token := &domains.Token{
+		AccessToken:  "valid_token",
+		RefreshToken: "valid_refresh_token",
+	}
+
+	mockResponse := `{"message":"Login successfully!","refreshToken":"valid_refresh_token","token":"valid_token","user":{"id":"%s","name":"%s","email":"%s","createdAt":"0001-01-01T00:00:00Z"}}`
+	mockResponse = fmt.Sprintf(mockResponse, user.ID, user.Name, user.Email)
 
 	r := SetRouter()
 	r.POST("/login", userController.Login)
 
-	userUsecase.Mock.On("LoginHandler", &input).Return(user, "valid_token", nil)
+	userUsecase.Mock.On("LoginHandler", mock.Anything, &input, mock.Anything).Return(user, token, nil)
 
 	jsonValue, _ := json.Marshal(input)
 	req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonValue))
@@ -137,6 +153,7 @@ func TestSuccessLogin(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, mockResponse, string(res))
+	assertNoPassword(t, res)
 }
 
 func TestFailLogin(t *testing.T) {
@@ -150,7 +167,7 @@ func TestFailLogin(t *testing.T) {
 			Email: "kale@gmail.com",
 		}
 
-		userUsecase.Mock.On("LoginHandler", &input).Return(nil, "", errors.New(""))
+		userUsecase.Mock.On("LoginHandler", mock.Anything, &input, mock.Anything).Return(nil, nil, logic.ErrValidation)
 
 		jsonValue, _ := json.Marshal(input)
 		req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonValue))
@@ -170,7 +187,7 @@ func TestFailLogin(t *testing.T) {
 			Password: "password",
 		}
 
-		userUsecase.Mock.On("LoginHandler", &input).Return(nil, "", errors.New(""))
+		userUsecase.Mock.On("LoginHandler", mock.Anything, &input, mock.Anything).Return(nil, nil, logic.ErrValidation)
 
 		jsonValue, _ := json.Marshal(input)
 		req, err := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonValue))
@@ -184,25 +201,125 @@ func TestFailLogin(t *testing.T) {
 	})
 }
 
+func TestSuccessRefreshToken(t *testing.T) {
+	input := domains.RefreshToken{
+		RefreshToken: "valid_refresh_token",
+	}
+	token := &domains.Token{
+		AccessToken:  "new_token",
+		RefreshToken: "new_refresh_token",
+	}
+
+	mockResponse := `{"message":"Token refreshed!","refreshToken":"new_refresh_token","token":"new_token"}`
+
+	r := SetRouter()
+	r.POST("/token/refresh", userController.RefreshToken)
+
+	userUsecase.Mock.On("RefreshHandler", mock.Anything, &input).Return(token, nil)
+
+	jsonValue, _ := json.Marshal(input)
+	req, err := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(jsonValue))
+	if err != nil {
+		t.Fatalf("Couldn't create request: %v\n", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	res, _ := ioutil.ReadAll(w.Body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, mockResponse, string(res))
+}
+
+func TestFailRefreshToken(t *testing.T) {
+	r := SetRouter()
+	r.POST("/token/refresh", userController.RefreshToken)
+
+	t.Run("fail_case1", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/token/refresh", bytes.NewBufferString(`{}`))
+		if err != nil {
+			t.Fatalf("Couldn't create request: %v\n", err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("fail_case2", func(t *testing.T) {
+		input := domains.RefreshToken{
+			RefreshToken: "reused_refresh_token",
+		}
+
+		userUsecase.Mock.On("RefreshHandler", mock.Anything, &input).Return(nil, &logic.Error{Kind: logic.KindUnauthorized, Code: "refresh_token_reused", Message: "Refresh token reused, please login again!"})
+
+		jsonValue, _ := json.Marshal(input)
+		req, err := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(jsonValue))
+		if err != nil {
+			t.Fatalf("Couldn't create request: %v\n", err)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	r := SetRouter()
+	r.POST("/logout", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), userController.Logout)
+
+	token, _ := helper.GenerateJWT("logout_uuid", "kale@gmail.com")
+	input := domains.RefreshToken{RefreshToken: "logout_refresh_token"}
+
+	userUsecase.Mock.On("LogoutHandler", mock.Anything, mock.MatchedBy(func(principal *domains.Principal) bool {
+		return principal.ID == "logout_uuid" && principal.TokenID != ""
+	}), &input).Return(nil).Once()
+
+	jsonValue, _ := json.Marshal(input)
+	req, err := http.NewRequest("POST", "/logout", bytes.NewBuffer(jsonValue))
+	if err != nil {
+		t.Fatalf("Couldn't create request: %v\n", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	res, _ := ioutil.ReadAll(w.Body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"message":"Logout successfully!"}`, string(res))
+
+	req, _ = http.NewRequest("POST", "/logout", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
 func TestSuccessChangePassword(t *testing.T) {
 	mockResponse := `{"message":"Password changed!"}`
 
 	input := domains.ChangePassword{
-		Email:           "kale@gmail.com",
+		CurrentPassword: "password",
 		NewPassword:     "passwords",
 		PasswordConfirm: "passwords",
 	}
 
 	r := SetRouter()
-	r.POST("/change-password", userController.ChangePassword)
+	r.POST("/change-password", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), userController.ChangePassword)
 
-	userUsecase.Mock.On("ChangePasswordHandler", &input).Return(nil)
+	token, _ := helper.GenerateJWT("change_uuid", "kale@gmail.com")
+	userUsecase.Mock.On("ChangePasswordHandler", mock.Anything, mock.MatchedBy(func(principal *domains.Principal) bool {
+		return principal.ID == "change_uuid"
+	}), &input).Return(nil)
 
 	jsonValue, _ := json.Marshal(input)
 	req, err := http.NewRequest("POST", "/change-password", bytes.NewBuffer(jsonValue))
 	if err != nil {
 		t.Fatalf("Couldn't create request: %v\n", err)
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
@@ -215,26 +332,90 @@ func TestSuccessChangePassword(t *testing.T) {
 func TestFailChangePassword(t *testing.T) {
 
 	input := domains.ChangePassword{
-		Email:           "kale@gmail.com",
+		CurrentPassword: "wrong_password",
 		NewPassword:     "passwor",
 		PasswordConfirm: "passwords",
 	}
 
 	r := SetRouter()
-	r.POST("/change-password", userController.ChangePassword)
+	r.POST("/change-password", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), userController.ChangePassword)
 
-	userUsecase.Mock.On("ChangePasswordHandler", &input).Return(errors.New(""))
+	token, _ := helper.GenerateJWT("change_fail_uuid", "kale@gmail.com")
+	userUsecase.Mock.On("ChangePasswordHandler", mock.Anything, mock.Anything, &input).Return(logic.ErrValidation)
 
 	jsonValue, _ := json.Marshal(input)
 	req, err := http.NewRequest("POST", "/change-password", bytes.NewBuffer(jsonValue))
 	if err != nil {
 		t.Fatalf("Couldn't create request: %v\n", err)
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("POST", "/change-password", bytes.NewBuffer(jsonValue))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestForgotPassword(t *testing.T) {
+	r := SetRouter()
+	r.POST("/password/forgot", userController.ForgotPassword)
+
+	input := domains.ForgotPassword{Email: "kale@gmail.com"}
+	userUsecase.Mock.On("ForgotPasswordHandler", mock.Anything, &input).Return(nil)
+
+	jsonValue, _ := json.Marshal(input)
+	req, err := http.NewRequest("POST", "/password/forgot", bytes.NewBuffer(jsonValue))
+	if err != nil {
+		t.Fatalf("Couldn't create request: %v\n", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestResetPassword(t *testing.T) {
+	r := SetRouter()
+	r.POST("/password/reset", userController.ResetPassword)
+
+	t.Run("success", func(t *testing.T) {
+		input := domains.ResetPassword{Token: "valid_reset_token", NewPassword: "password", PasswordConfirm: "password"}
+		userUsecase.Mock.On("ResetPasswordHandler", mock.Anything, &input).Return(nil)
+
+		jsonValue, _ := json.Marshal(input)
+		req, _ := http.NewRequest("POST", "/password/reset", bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid_token", func(t *testing.T) {
+		input := domains.ResetPassword{Token: "used_reset_token", NewPassword: "password", PasswordConfirm: "password"}
+		userUsecase.Mock.On("ResetPasswordHandler", mock.Anything, &input).Return(&logic.Error{Kind: logic.KindValidation, Code: "invalid_reset_token", Message: "Invalid or expired reset token!"})
+
+		jsonValue, _ := json.Marshal(input)
+		req, _ := http.NewRequest("POST", "/password/reset", bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("missing_token", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/password/reset", bytes.NewBufferString(`{"newPassword":"password"}`))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
+
 func TestSuccessDeleteUser(t *testing.T) {
 	mockResponse := `{"message":"Successflly delete user"}`
 	input := domains.UserId{
@@ -245,7 +426,7 @@ func TestSuccessDeleteUser(t *testing.T) {
 	r.GET("/user")
 	r.DELETE("/user", userController.DeleteUser)
 
-	userUsecase.Mock.On("DeleteUserHandler", input.ID).Return(nil)
+	userUsecase.Mock.On("DeleteUserHandler", mock.Anything, input.ID).Return(nil)
 
 	jsonValue, _ := json.Marshal(input)
 	req, err := http.NewRequest("DELETE", "/user", bytes.NewBuffer(jsonValue))
@@ -269,7 +450,7 @@ func TestFailDeleteUser(t *testing.T) {
 	r := SetRouter()
 	r.DELETE("/user", userController.DeleteUser)
 
-	userUsecase.Mock.On("DeleteUserHandler", input.ID).Return(errors.New(""))
+	userUsecase.Mock.On("DeleteUserHandler", mock.Anything, input.ID).Return(errors.New(""))
 
 	jsonValue, _ := json.Marshal(input)
 	req, err := http.NewRequest("DELETE", "/user", bytes.NewBuffer(jsonValue))
@@ -282,19 +463,45 @@ func TestFailDeleteUser(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
+func TestDeleteUserNotFound(t *testing.T) {
+	input := domains.UserId{
+		ID: "missing_uuid",
+	}
+
+	r := SetRouter()
+	r.DELETE("/user", userController.DeleteUser)
+
+	userUsecase.Mock.On("DeleteUserHandler", mock.Anything, input.ID).Return(logic.ErrNotFound).Once()
+
+	jsonValue, _ := json.Marshal(input)
+	req, err := http.NewRequest("DELETE", "/user", bytes.NewBuffer(jsonValue))
+	if err != nil {
+		t.Fatalf("Couldn't create request: %v\n", err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"code":"not_found","detail":"Not found!"}`, w.Body.String())
+}
+
 func TestFailGetAllUsers(t *testing.T) {
 	r := SetRouter()
 	r.GET("/users", userController.AllUsers)
-	userUsecase.Mock.On("GetUsers").Return(nil, errors.New("Error")).Once()
+	userUsecase.Mock.On("GetUsers", mock.Anything, &domains.UserQuery{Limit: 500}).Return(nil, errors.New("Cannot fetch users!")).Once()
 
-	req, err := http.NewRequest("GET", "/users", nil)
+	req, err := http.NewRequest("GET", "/users?limit=500", nil)
 	if err != nil {
 		t.Fatalf("Couldn't create request: %v\n", err)
 	}
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	// Failures of the database are not the client's fault and keep their
+	// message to the logs.
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"internal_error","detail":"Something went wrong!"}`, w.Body.String())
 }
 
 func TestSuccessGetAllUsers(t *testing.T) {
@@ -316,7 +523,7 @@ func TestSuccessGetAllUsers(t *testing.T) {
 
 	var usersString []string
 	for _, v := range users {
-		usersString = append(usersString, fmt.Sprintf(`{"id":"%s",`, v.ID), fmt.Sprintf(`"name":"%s",`, v.Name), fmt.Sprintf(`"email":"%s",`, v.Email), fmt.Sprintf(`"password":"%s"},`, v.Password))
+		usersString = append(usersString, fmt.Sprintf(`{"id":"%s",`, v.ID), fmt.Sprintf(`"name":"%s",`, v.Name), fmt.Sprintf(`"email":"%s",`, v.Email), `"createdAt":"0001-01-01T00:00:00Z"},`)
 	}
 
 	mockResponse := `{"message":"Successflly fetch all users","users":%s}`
@@ -327,7 +534,7 @@ func TestSuccessGetAllUsers(t *testing.T) {
 	r := SetRouter()
 	r.GET("/users", userController.AllUsers)
 
-	userUsecase.Mock.On("GetUsers").Return(users, nil).Once()
+	userUsecase.Mock.On("GetUsers", mock.Anything, &domains.UserQuery{}).Return(repository.UserPage{Users: users}, nil).Once()
 
 	req, err := http.NewRequest("GET", "/users", nil)
 	if err != nil {
@@ -342,10 +549,34 @@ func TestSuccessGetAllUsers(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, mockResponse, resManip)
+	assertNoPassword(t, res)
 }
 
 
 
+func TestGetAllUsersPage(t *testing.T) {
+	r := SetRouter()
+	r.GET("/users", userController.AllUsers)
+
+	total := 3
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	query := &domains.UserQuery{Limit: 1, Cursor: "abc", Email: "ka", Status: "verified", CreatedAfter: &after, Sort: "-email", Total: true}
+	userUsecase.Mock.On("GetUsers", mock.Anything, query).Return(repository.UserPage{Users: []repository.User{}, NextCursor: "next", PrevCursor: "prev", Total: &total}, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/users?limit=1&cursor=abc&email=ka&status=verified&createdAfter=2024-01-01T00:00:00Z&sort=-email&total=true", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"Successflly fetch all users","users":[],"nextCursor":"next","prevCursor":"prev","total":3}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/users?createdAfter=yesterday", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestSuccessGetSingleUser(t *testing.T) {
 	userId := domains.UserId{
 		ID: "valid_uuid",
@@ -361,10 +592,10 @@ func TestSuccessGetSingleUser(t *testing.T) {
 		Password: "password",
 	}
 
-	userUsecase.Mock.On("GetSingleUserHandler", userId.ID).Return(user, nil)
+	userUsecase.Mock.On("GetSingleUserHandler", mock.Anything, userId.ID).Return(user, nil)
 
-	mockResponse := `{"message":"Successflly fetch single user","user":{"id":"%s","name":"%s","email":"%s","password":"%s"}}`
-	mockResponse = fmt.Sprintf(mockResponse, user.ID, user.Name, user.Email, user.Password)
+	mockResponse := `{"message":"Successflly fetch single user","user":{"id":"%s","name":"%s","email":"%s","createdAt":"0001-01-01T00:00:00Z"}}`
+	mockResponse = fmt.Sprintf(mockResponse, user.ID, user.Name, user.Email)
 
 	jsonValue, _ := json.Marshal(userId)
 	req, err := http.NewRequest("GET", "/user/"+userId.ID, bytes.NewBuffer(jsonValue))
@@ -377,6 +608,7 @@ func TestSuccessGetSingleUser(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, string(res), mockResponse)
+	assertNoPassword(t, res)
 
 }
 
@@ -388,7 +620,7 @@ func TestFailGetSingleUser(t *testing.T) {
 	r := SetRouter()
 	r.GET("/user/:userId", userController.SingleUser)
 
-	userUsecase.Mock.On("GetSingleUserHandler", userId.ID).Return(nil, errors.New(""))
+	userUsecase.Mock.On("GetSingleUserHandler", mock.Anything, userId.ID).Return(nil, logic.ErrNotFound)
 
 	jsonValue, _ := json.Marshal(userId)
 	req, err := http.NewRequest("GET", "/user/"+userId.ID, bytes.NewBuffer(jsonValue))
@@ -401,3 +633,250 @@ func TestFailGetSingleUser(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 
 }
+
+func TestVerifyEmail(t *testing.T) {
+	r := SetRouter()
+	r.GET("/verify-email", userController.VerifyEmail)
+
+	userUsecase.Mock.On("VerifyEmailHandler", mock.Anything, "valid_link").Return(nil)
+	userUsecase.Mock.On("VerifyEmailHandler", mock.Anything, "expired_link").Return(&logic.Error{Kind: logic.KindValidation, Code: "invalid_verification_token", Message: "Invalid or expired verification link!"})
+
+	req, _ := http.NewRequest("GET", "/verify-email?token=valid_link", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/verify-email?token=expired_link", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestResendVerification(t *testing.T) {
+	r := SetRouter()
+	r.POST("/verify-email/resend", userController.ResendVerification)
+
+	input := domains.ResendVerification{Email: "failed@gmail.com"}
+	userUsecase.Mock.On("ResendVerificationHandler", mock.Anything, &input).Return(errors.New("")).Once()
+
+	jsonValue, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/verify-email/resend", bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestLockedLogin(t *testing.T) {
+	r := SetRouter()
+	r.POST("/login", userController.Login)
+
+	input := domains.Login{Email: "locked@gmail.com", Password: "password"}
+	userUsecase.Mock.On("LoginHandler", mock.Anything, &input, mock.Anything).Return(nil, nil, &logic.LockedError{RetryAfter: 90 * time.Second}).Once()
+
+	jsonValue, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "90", w.Header().Get("Retry-After"))
+}
+
+func TestMFARequiredLogin(t *testing.T) {
+	input := domains.Login{Email: "mfa@gmail.com", Password: "passwords"}
+	userUsecase.Mock.On("LoginHandler", mock.Anything, &input, mock.Anything).Return(&repository.User{ID: "uuid"}, &domains.Token{MFAToken: "challenge", MFAMethods: []string{"totp", "webauthn"}}, nil).Once()
+
+	r := SetRouter()
+	r.POST("/login", userController.Login)
+
+	jsonValue, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"MFA code required!","mfaRequired":true,"mfaToken":"challenge","mfaMethods":["totp","webauthn"]}`, w.Body.String())
+}
+
+func TestMFALogin(t *testing.T) {
+	r := SetRouter()
+	r.POST("/login/mfa", userController.MFALogin)
+
+	success := domains.MFALogin{MFAToken: "challenge", Code: "123456"}
+	userUsecase.Mock.On("MFALoginHandler", mock.Anything, &success, mock.Anything).Return(&repository.User{ID: "uuid", Password: "$argon2id$hash"}, &domains.Token{AccessToken: "valid_token", RefreshToken: "valid_refresh_token"}, nil).Once()
+	failed := domains.MFALogin{MFAToken: "challenge", Code: "000000"}
+	userUsecase.Mock.On("MFALoginHandler", mock.Anything, &failed, mock.Anything).Return(nil, nil, logic.ErrInvalidMFACode).Once()
+
+	jsonValue, _ := json.Marshal(success)
+	req, _ := http.NewRequest("POST", "/login/mfa", bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"token":"valid_token"`)
+	assertNoPassword(t, w.Body.Bytes())
+
+	jsonValue, _ = json.Marshal(failed)
+	req, _ = http.NewRequest("POST", "/login/mfa", bytes.NewBuffer(jsonValue))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestWebAuthnLogin(t *testing.T) {
+	r := SetRouter()
+	r.POST("/webauthn/login/begin", userController.BeginWebAuthnLogin)
+	r.POST("/webauthn/login/finish", userController.FinishWebAuthnLogin)
+
+	userUsecase.Mock.On("BeginWebAuthnLoginHandler", mock.Anything, mock.Anything).Return(&domains.WebAuthnOptions{SessionID: "session", PublicKey: gin.H{"challenge": "abc"}}, nil).Once()
+
+	req, _ := http.NewRequest("POST", "/webauthn/login/begin", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"sessionId":"session","publicKey":{"challenge":"abc"}}`, w.Body.String())
+
+	input := domains.WebAuthnLogin{
+		SessionID: "session",
+		Credential: domains.PublicKeyCredential{
+			ID:       "credential",
+			Type:     "public-key",
+			Response: domains.AuthenticatorResponse{ClientDataJSON: "e30"},
+		},
+	}
+	userUsecase.Mock.On("FinishWebAuthnLoginHandler", mock.Anything, &input, mock.Anything).Return(nil, nil, &logic.Error{Kind: logic.KindUnauthorized, Code: "unauthorized", Message: "Passkey not registered!"}).Once()
+
+	jsonValue, _ := json.Marshal(input)
+	req, _ = http.NewRequest("POST", "/webauthn/login/finish", bytes.NewBuffer(jsonValue))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Unauthorized","status":401,"code":"unauthorized","detail":"Passkey not registered!"}`, w.Body.String())
+}
+
+func TestMe(t *testing.T) {
+	r := SetRouter()
+	r.GET("/me", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), userController.Me)
+
+	user := &repository.User{ID: "me_uuid", Name: "kale", Email: "kale@gmail.com", Password: "hash"}
+	userUsecase.Mock.On("GetProfileHandler", mock.Anything, mock.MatchedBy(func(principal *domains.Principal) bool {
+		return principal.ID == "me_uuid"
+	})).Return(user, nil)
+
+	token, _ := helper.GenerateJWT("me_uuid", "kale@gmail.com")
+	req, _ := http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	res, _ := ioutil.ReadAll(w.Body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"message":"Successfully fetch profile!","user":{"id":"me_uuid","name":"kale","email":"kale@gmail.com","createdAt":"0001-01-01T00:00:00Z"}}`, string(res))
+	assertNoPassword(t, res)
+}
+
+func TestUpdateMe(t *testing.T) {
+	r := SetRouter()
+	r.PATCH("/me", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), userController.UpdateMe)
+
+	name := "Kale Leo"
+	userUsecase.Mock.On("UpdateProfileHandler", mock.Anything, mock.Anything, &domains.UpdateProfile{Name: &name}).
+		Return(&repository.User{ID: "update_uuid", Name: name, Email: "kale@gmail.com"}, nil)
+	userUsecase.Mock.On("UpdateProfileHandler", mock.Anything, mock.Anything, &domains.UpdateProfile{Name: new(string)}).
+		Return(nil, logic.ErrValidation)
+
+	token, _ := helper.GenerateJWT("update_uuid", "kale@gmail.com")
+	req, _ := http.NewRequest("PATCH", "/me", strings.NewReader(`{"name":"Kale Leo"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	res, _ := ioutil.ReadAll(w.Body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, string(res), `"name":"Kale Leo"`)
+
+	req, _ = http.NewRequest("PATCH", "/me", strings.NewReader(`{"name":""}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestChangeEmail(t *testing.T) {
+	r := SetRouter()
+	r.POST("/me/email", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), userController.ChangeEmail)
+
+	input := domains.ChangeEmail{NewEmail: "leo@gmail.com", CurrentPassword: "password"}
+	userUsecase.Mock.On("ChangeEmailHandler", mock.Anything, mock.Anything, &input).Return(nil).Once()
+	userUsecase.Mock.On("ChangeEmailHandler", mock.Anything, mock.Anything, &input).Return(logic.ErrEmailTaken).Once()
+
+	token, _ := helper.GenerateJWT("email_uuid", "kale@gmail.com")
+	jsonValue, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/me/email", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	req, _ = http.NewRequest("POST", "/me/email", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	r := SetRouter()
+	r.GET("/me/email/confirm", userController.ConfirmEmailChange)
+
+	userUsecase.Mock.On("ConfirmEmailChangeHandler", mock.Anything, "valid_link").Return(nil)
+	userUsecase.Mock.On("ConfirmEmailChangeHandler", mock.Anything, "expired_link").Return(&logic.Error{Kind: logic.KindValidation, Code: "invalid_email_change_token", Message: "Invalid or expired confirmation link!"})
+
+	req, _ := http.NewRequest("GET", "/me/email/confirm?token=valid_link", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("GET", "/me/email/confirm?token=expired_link", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDeleteWebAuthnCredential(t *testing.T) {
+	r := SetRouter()
+	r.DELETE("/webauthn/credentials/:credentialId", gateway.IsAuthMiddleware(repository.NewMemoryRevocationRepository(), nil), userController.DeleteWebAuthnCredential)
+
+	input := domains.Reauthentication{Password: "password", Code: "123456"}
+	userUsecase.Mock.On("DeleteWebAuthnCredentialHandler", mock.Anything, mock.Anything, "passkey_id", &input).Return(nil).Once()
+
+	token, _ := helper.GenerateJWT("passkey_uuid", "kale@gmail.com")
+	jsonValue, _ := json.Marshal(input)
+	req, _ := http.NewRequest("DELETE", "/webauthn/credentials/passkey_id", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Without the password a session alone cannot remove a passkey.
+	req, _ = http.NewRequest("DELETE", "/webauthn/credentials/passkey_id", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	userUsecase.Mock.AssertNumberOfCalls(t, "DeleteWebAuthnCredentialHandler", 1)
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"api-auth/app/gateway"
+	"api-auth/domains"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (ac *AuthController) BeginWebAuthnRegistration(c *gin.Context) {
+	var inputReauth domains.Reauthentication
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputReauth); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	options, err := ac.caseUser.BeginWebAuthnRegistrationHandler(c.Request.Context(), principal, &inputReauth)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (ac *AuthController) FinishWebAuthnRegistration(c *gin.Context) {
+	var inputRegistration domains.WebAuthnRegistration
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputRegistration); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	credential, err := ac.caseUser.FinishWebAuthnRegistrationHandler(c.Request.Context(), principal, &inputRegistration)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Passkey registered!",
+		"credential": presentWebAuthnCredential(credential),
+	})
+}
+
+func (ac *AuthController) BeginWebAuthnLogin(c *gin.Context) {
+	options, err := ac.caseUser.BeginWebAuthnLoginHandler(c.Request.Context())
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (ac *AuthController) FinishWebAuthnLogin(c *gin.Context) {
+	var inputLogin domains.WebAuthnLogin
+
+	if err := c.ShouldBindJSON(&inputLogin); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	user, token, err := ac.caseUser.FinishWebAuthnLoginHandler(c.Request.Context(), &inputLogin, c.ClientIP())
+	ac.respondLogin(c, user, token, err)
+}
+
+func (ac *AuthController) BeginWebAuthnMFA(c *gin.Context) {
+	var inputBegin domains.WebAuthnMFABegin
+
+	if err := c.ShouldBindJSON(&inputBegin); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	options, err := ac.caseUser.BeginWebAuthnMFAHandler(c.Request.Context(), &inputBegin)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (ac *AuthController) FinishWebAuthnMFA(c *gin.Context) {
+	var inputMFA domains.WebAuthnMFALogin
+
+	if err := c.ShouldBindJSON(&inputMFA); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	user, token, err := ac.caseUser.FinishWebAuthnMFAHandler(c.Request.Context(), &inputMFA, c.ClientIP())
+	ac.respondLogin(c, user, token, err)
+}
+
+func (ac *AuthController) WebAuthnCredentials(c *gin.Context) {
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	credentials, err := ac.caseUser.GetWebAuthnCredentialsHandler(c.Request.Context(), principal)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": presentWebAuthnCredentials(credentials),
+	})
+}
+
+func (ac *AuthController) BeginWebAuthnReauth(c *gin.Context) {
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	options, err := ac.caseUser.BeginWebAuthnReauthHandler(c.Request.Context(), principal)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+func (ac *AuthController) DeleteWebAuthnCredential(c *gin.Context) {
+	var inputReauth domains.Reauthentication
+
+	principal, ok := gateway.CurrentPrincipal(c)
+	if !ok {
+		gateway.AbortWithProblem(c, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	if err := c.ShouldBindJSON(&inputReauth); err != nil {
+		gateway.AbortWithBindError(c, err)
+		return
+	}
+
+	err := ac.caseUser.DeleteWebAuthnCredentialHandler(c.Request.Context(), principal, c.Param("credentialId"), &inputReauth)
+	if err != nil {
+		gateway.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Passkey deleted!",
+	})
+}
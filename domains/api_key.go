@@ -0,0 +1,39 @@
+package domains
+
+import "time"
+
+type CreateAPIKey struct {
+	Name string `json:"name" binding:"required"`
+	// Scopes are permission names, the key cannot be given any the creator
+	// does not have.
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type CreateServiceAPIKey struct {
+	CreateAPIKey
+	ServiceAccount string `json:"serviceAccount" binding:"required"`
+}
+
+// APIKey describes a key without its secret.
+type APIKey struct {
+	ID             string     `json:"id"`
+	Lookup         string     `json:"lookup"`
+	UserID         string     `json:"userId,omitempty"`
+	ServiceAccount string     `json:"serviceAccount,omitempty"`
+	Name           string     `json:"name"`
+	Scopes         string     `json:"scopes"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt     *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// APIKeyCreated is returned once when a key is created, the key cannot be
+// looked up later.
+type APIKeyCreated struct {
+	ID        string     `json:"id"`
+	Key       string     `json:"key"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
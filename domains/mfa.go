@@ -0,0 +1,34 @@
+package domains
+
+type MFALogin struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	// Code is a TOTP code or a recovery code.
+	Code string `json:"code" binding:"required"`
+}
+
+type MFACode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableMFA struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// Reauthentication confirms a change to the sign-in methods of an account.
+// Accounts with a second factor also need a code or a passkey.
+type Reauthentication struct {
+	Password string `json:"password" binding:"required"`
+	// Code is a TOTP code or a recovery code.
+	Code string `json:"code"`
+	// Passkey answers a challenge from /webauthn/reauth/begin.
+	Passkey *WebAuthnAssertion `json:"passkey"`
+}
+
+// MFAEnrollment is what an authenticator app needs to add the account. The
+// QR code is a PNG data URI of the otpauth URI.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qrCode"`
+}
@@ -0,0 +1,138 @@
+package domains
+
+import "time"
+
+type CreateOAuthClient struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirectUris"`
+	// GrantTypes are any of authorization_code, refresh_token and
+	// client_credentials.
+	GrantTypes []string `json:"grantTypes" binding:"required"`
+	Scopes     []string `json:"scopes"`
+	// Public clients, like single page and native apps, cannot keep a
+	// secret and get none.
+	Public bool `json:"public"`
+}
+
+// OAuthClient is a registered client, without its secret.
+type OAuthClient struct {
+	ClientID     string    `json:"clientId"`
+	Name         string    `json:"name"`
+	RedirectURIs string    `json:"redirectUris"`
+	GrantTypes   string    `json:"grantTypes"`
+	Scopes       string    `json:"scopes"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// OAuthClientCredentials is returned once when a client is registered, the
+// secret cannot be looked up later.
+type OAuthClientCredentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+// AuthorizeRequest holds the parameters of an authorization request. The
+// frontend passes them on from the query string it was opened with.
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `form:"nonce" json:"nonce"`
+}
+
+// AuthorizeConsent is the answer of the user to the consent screen.
+type AuthorizeConsent struct {
+	AuthorizeRequest
+	Approve bool `form:"approve" json:"approve"`
+}
+
+// AuthorizeResponse either sends the browser back to the client with
+// RedirectTo or asks the user to consent to the listed scopes first.
+type AuthorizeResponse struct {
+	RedirectTo      string   `json:"redirectTo,omitempty"`
+	ConsentRequired bool     `json:"consentRequired"`
+	ClientID        string   `json:"clientId,omitempty"`
+	ClientName      string   `json:"clientName,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+}
+
+// TokenRequest is the form posted to the token endpoint. The client
+// credentials may come from HTTP Basic authentication instead.
+type TokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OAuthToken is the token response of RFC 6749 section 5.1.
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// IDToken is issued when the openid scope was granted.
+	IDToken string `json:"id_token,omitempty"`
+}
+
+// TokenHint is the form of the introspection and revocation endpoints. Like
+// at the token endpoint the client may authenticate with HTTP Basic instead.
+type TokenHint struct {
+	Token string `form:"token" binding:"required"`
+	// TokenTypeHint is access_token or refresh_token, it only decides which
+	// kind of token is looked up first.
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// Introspection is the response of RFC 7662 section 2.2. Inactive tokens
+// carry nothing but Active.
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	TokenID   string   `json:"jti,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// UserInfo is the response of the OpenID Connect userinfo endpoint. Only
+// the claims of the granted scopes are set.
+type UserInfo struct {
+	Subject       string `json:"sub"`
+	Name          string `json:"name,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+// OpenIDConfiguration is the discovery document of OpenID Connect.
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
@@ -0,0 +1,18 @@
+package domains
+
+// Problem is an RFC 7807 problem details response. Code is an extension
+// member that stays the same when Detail is reworded, clients branch on it.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Code          string         `json:"code"`
+	InvalidParams []InvalidParam `json:"invalidParams,omitempty"`
+}
+
+// InvalidParam tells what is wrong with one input field.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,34 @@
+package domains
+
+type Role struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+}
+
+type Permission struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type CreateRole struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type CreatePermission struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// GrantPermission names the permission, e.g. "users:delete".
+type GrantPermission struct {
+	Permission string `json:"permission" binding:"required"`
+}
+
+// AssignRole names the role, e.g. "admin".
+type AssignRole struct {
+	Role string `json:"role" binding:"required"`
+}
@@ -1,24 +1,116 @@
-package domains
-
-type UserId struct {
-	ID     string `json:"id"`
-}
-
-type Login struct {
-	Name     string
-	Email    string
-	Password string
-}
-
-type Register struct {
-	Name            string
-	Email           string
-	Password        string
-	PasswordConfirm string
-}
-
-type ChangePassword struct {
-	Email           string
-	NewPassword     string `json:"newPassword"`
-	PasswordConfirm string `json:"passwordConfirm"`
-}
\ No newline at end of file
+package domains
+
+import "time"
+
+type UserId struct {
+	ID string `json:"id"`
+}
+
+type Login struct {
+	Name     string
+	Email    string
+	Password string
+}
+
+type Register struct {
+	Name            string
+	Email           string
+	Password        string
+	PasswordConfirm string
+}
+
+type ChangePassword struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	PasswordConfirm string `json:"passwordConfirm"`
+}
+
+// UpdateProfile changes the profile of the caller. Fields left out keep
+// their value.
+type UpdateProfile struct {
+	Name *string `json:"name"`
+}
+
+// ChangeEmail starts moving the account to another address, the change
+// happens once the new address confirms it.
+type ChangeEmail struct {
+	NewEmail        string `json:"newEmail"`
+	CurrentPassword string `json:"currentPassword"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email"`
+}
+
+type ResendVerification struct {
+	Email string `json:"email"`
+}
+
+type Unlock struct {
+	Email string `json:"email"`
+	IP    string `json:"ip"`
+}
+
+// UserQuery selects a page of users. Email and Name match by prefix, Sort
+// names a field and is descending when prefixed with "-". Total asks for the
+// number of matching users, which costs another query.
+type UserQuery struct {
+	Limit         int        `form:"limit"`
+	Cursor        string     `form:"cursor"`
+	Email         string     `form:"email"`
+	Name          string     `form:"name"`
+	Status        string     `form:"status"`
+	CreatedAfter  *time.Time `form:"createdAfter"`
+	CreatedBefore *time.Time `form:"createdBefore"`
+	Sort          string     `form:"sort"`
+	Total         bool       `form:"total"`
+}
+
+type ResetPassword struct {
+	Token           string `json:"token" binding:"required"`
+	NewPassword     string `json:"newPassword"`
+	PasswordConfirm string `json:"passwordConfirm"`
+}
+
+// User is a user as the API shows it, never with the password hash.
+type User struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+// Principal is the authenticated caller that the auth middleware stores in
+// the gin context.
+type Principal struct {
+	ID        string
+	Email     string
+	TokenID   string
+	ExpiresAt time.Time
+	Roles     []string
+	// ClientID is set when the token was issued to an OAuth client, Scope
+	// then holds what the user granted it.
+	ClientID string
+	Scope    string
+	// AuthTime is when the user logged in.
+	AuthTime time.Time
+	// APIKeyID is set when the caller used an API key, Scope then holds its
+	// scopes. Keys of service accounts have no ID but ServiceAccount.
+	APIKeyID       string
+	ServiceAccount string
+}
+
+// Token holds either the issued tokens or, when the user has MFA enabled,
+// only the challenge to exchange at /login/mfa and the methods it can be
+// completed with.
+type Token struct {
+	AccessToken  string   `json:"token"`
+	RefreshToken string   `json:"refreshToken"`
+	MFAToken     string   `json:"mfaToken,omitempty"`
+	MFAMethods   []string `json:"mfaMethods,omitempty"`
+}
+
+type RefreshToken struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
@@ -0,0 +1,70 @@
+package domains
+
+import "time"
+
+// PublicKeyCredential is a passkey response as browsers serialize it with
+// PublicKeyCredential.toJSON(), binary values are base64url.
+type PublicKeyCredential struct {
+	ID       string                `json:"id" binding:"required"`
+	RawID    string                `json:"rawId"`
+	Type     string                `json:"type" binding:"required"`
+	Response AuthenticatorResponse `json:"response" binding:"required"`
+}
+
+type AuthenticatorResponse struct {
+	ClientDataJSON string `json:"clientDataJSON" binding:"required"`
+	// AttestationObject is set when registering.
+	AttestationObject string `json:"attestationObject"`
+	// AuthenticatorData, Signature and UserHandle are set when logging in.
+	AuthenticatorData string   `json:"authenticatorData"`
+	Signature         string   `json:"signature"`
+	UserHandle        string   `json:"userHandle"`
+	Transports        []string `json:"transports"`
+}
+
+// WebAuthnCredential describes a registered passkey, without its key.
+type WebAuthnCredential struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Algorithm  int        `json:"algorithm"`
+	AAGUID     string     `json:"aaguid"`
+	Transports string     `json:"transports"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// WebAuthnOptions starts a ceremony. PublicKey goes to
+// navigator.credentials.create or get, SessionID is sent back with the
+// result.
+type WebAuthnOptions struct {
+	SessionID string      `json:"sessionId"`
+	PublicKey interface{} `json:"publicKey"`
+}
+
+type WebAuthnRegistration struct {
+	SessionID string `json:"sessionId" binding:"required"`
+	// Name is a label for telling passkeys apart, e.g. "Work laptop".
+	Name       string              `json:"name"`
+	Credential PublicKeyCredential `json:"credential" binding:"required"`
+}
+
+type WebAuthnLogin struct {
+	SessionID  string              `json:"sessionId" binding:"required"`
+	Credential PublicKeyCredential `json:"credential" binding:"required"`
+}
+
+// WebAuthnAssertion proves a passkey of the caller, see Reauthentication.
+type WebAuthnAssertion struct {
+	SessionID  string              `json:"sessionId" binding:"required"`
+	Credential PublicKeyCredential `json:"credential" binding:"required"`
+}
+
+type WebAuthnMFABegin struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+}
+
+type WebAuthnMFALogin struct {
+	MFAToken   string              `json:"mfaToken" binding:"required"`
+	SessionID  string              `json:"sessionId" binding:"required"`
+	Credential PublicKeyCredential `json:"credential" binding:"required"`
+}
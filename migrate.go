@@ -0,0 +1,86 @@
+package main
+
+import (
+	"api-auth/app/config"
+	"api-auth/services/repository/migrations"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+)
+
+const migrateUsage = `usage:
+  api-auth migrate up|down|status [config flags]
+  api-auth migrate create [-dir services/repository/migrations] <name>`
+
+// migrate runs the migrate subcommand. up applies the pending migrations,
+// down reverts the newest one and create adds empty migrations for every
+// dialect to the source tree, they are embedded on the next build.
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+		dir := fs.String("dir", "services/repository/migrations", "directory of the migrations")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New(migrateUsage)
+		}
+		paths, err := migrations.Create(*dir, fs.Arg(0))
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return err
+	}
+
+	if args[0] != "up" && args[0] != "down" && args[0] != "status" {
+		return errors.New(migrateUsage)
+	}
+	cfg, err := config.Load(args[1:])
+	if err != nil {
+		return err
+	}
+	db := config.SetupDatabase(cfg.Database)
+	defer db.Close()
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up()
+		for _, migration := range done {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		migration, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		return nil
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	}
+	return errors.New(migrateUsage)
+}
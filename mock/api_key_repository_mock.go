@@ -0,0 +1,63 @@
+package mock
+
+import (
+	repo "api-auth/services/repository"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type APIKeyRepositoryMock struct {
+	Mock mock.Mock
+}
+
+func (repository *APIKeyRepositoryMock) CreateAPIKey(ctx context.Context, key *repo.APIKey) error {
+	args := repository.Mock.Called(ctx, key)
+	if args.Get(0) != nil {
+		return errors.New("Cannot create API key!")
+	}
+	return nil
+}
+
+func (repository *APIKeyRepositoryMock) FindAPIKeyByLookup(ctx context.Context, lookup string) *repo.APIKey {
+	args := repository.Mock.Called(ctx, lookup)
+	if args.Get(0) == nil {
+		return nil
+	}
+	key := args.Get(0).(repo.APIKey)
+	return &key
+}
+
+func (repository *APIKeyRepositoryMock) UserAPIKeys(ctx context.Context, userId string) ([]repo.APIKey, error) {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot fetch API keys!")
+	}
+	return args.Get(0).([]repo.APIKey), nil
+}
+
+func (repository *APIKeyRepositoryMock) ServiceAPIKeys(ctx context.Context) ([]repo.APIKey, error) {
+	args := repository.Mock.Called(ctx)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot fetch API keys!")
+	}
+	return args.Get(0).([]repo.APIKey), nil
+}
+
+func (repository *APIKeyRepositoryMock) RevokeAPIKey(ctx context.Context, userId, keyId string) error {
+	args := repository.Mock.Called(ctx, userId, keyId)
+	if args.Get(0) != nil {
+		return errors.New("API key not found!")
+	}
+	return nil
+}
+
+func (repository *APIKeyRepositoryMock) TouchAPIKey(ctx context.Context, keyId string, usedAt time.Time) error {
+	args := repository.Mock.Called(ctx, keyId, usedAt)
+	if args.Get(0) != nil {
+		return errors.New("Cannot update API key!")
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package mock
+
+import (
+	"api-auth/services/mailer"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MailerMock struct {
+	Mock mock.Mock
+}
+
+func (m *MailerMock) Send(msg mailer.Message) error {
+	args := m.Mock.Called(msg)
+	return args.Error(0)
+}
@@ -0,0 +1,70 @@
+package mock
+
+import (
+	repo "api-auth/services/repository"
+	"context"
+	"errors"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MFARepositoryMock struct {
+	Mock mock.Mock
+}
+
+func (repository *MFARepositoryMock) FindMFASecret(ctx context.Context, userId string) *repo.MFASecret {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(0) == nil {
+		return nil
+	}
+	secret := args.Get(0).(repo.MFASecret)
+	return &secret
+}
+
+func (repository *MFARepositoryMock) SaveMFASecret(ctx context.Context, userId string, secret string) error {
+	args := repository.Mock.Called(ctx, userId, secret)
+	if args.Get(0) != nil {
+		return errors.New("MFA already enabled!")
+	}
+	return nil
+}
+
+func (repository *MFARepositoryMock) ConfirmMFASecret(ctx context.Context, userId string) error {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(0) != nil {
+		return errors.New("No pending MFA enrollment!")
+	}
+	return nil
+}
+
+func (repository *MFARepositoryMock) UseTOTPStep(ctx context.Context, userId string, step int64) error {
+	args := repository.Mock.Called(ctx, userId, step)
+	if args.Get(0) != nil {
+		return errors.New("MFA code already used!")
+	}
+	return nil
+}
+
+func (repository *MFARepositoryMock) DeleteMFA(ctx context.Context, userId string) error {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot disable MFA!")
+	}
+	return nil
+}
+
+func (repository *MFARepositoryMock) ReplaceRecoveryCodes(ctx context.Context, userId string, codeHashes []string) error {
+	args := repository.Mock.Called(ctx, userId, codeHashes)
+	if args.Get(0) != nil {
+		return errors.New("Cannot save recovery codes!")
+	}
+	return nil
+}
+
+func (repository *MFARepositoryMock) UseRecoveryCode(ctx context.Context, userId string, codeHash string) error {
+	args := repository.Mock.Called(ctx, userId, codeHash)
+	if args.Get(0) != nil {
+		return errors.New("Invalid MFA code!")
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package mock
+
+import (
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OAuthUsecaseMock struct {
+	Mock mock.Mock
+}
+
+func (usecase *OAuthUsecaseMock) GetClients(ctx context.Context) (clients []repository.OAuthClient, err error) {
+	args := usecase.Mock.Called(ctx)
+
+	if args.Get(0) != nil {
+		clients = args.Get(0).([]repository.OAuthClient)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *OAuthUsecaseMock) CreateClientHandler(ctx context.Context, input *domains.CreateOAuthClient) (credentials *domains.OAuthClientCredentials, err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	if args.Get(0) != nil {
+		credentials = args.Get(0).(*domains.OAuthClientCredentials)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *OAuthUsecaseMock) DeleteClientHandler(ctx context.Context, clientId string) (err error) {
+	args := usecase.Mock.Called(ctx, clientId)
+
+	return args.Error(0)
+}
+
+func (usecase *OAuthUsecaseMock) AuthorizeHandler(ctx context.Context, principal *domains.Principal, input *domains.AuthorizeRequest) (response *domains.AuthorizeResponse, err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		response = args.Get(0).(*domains.AuthorizeResponse)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *OAuthUsecaseMock) ConsentHandler(ctx context.Context, principal *domains.Principal, input *domains.AuthorizeConsent) (response *domains.AuthorizeResponse, err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		response = args.Get(0).(*domains.AuthorizeResponse)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *OAuthUsecaseMock) TokenHandler(ctx context.Context, input *domains.TokenRequest) (token *domains.OAuthToken, err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	if args.Get(0) != nil {
+		token = args.Get(0).(*domains.OAuthToken)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *OAuthUsecaseMock) DiscoveryHandler() *domains.OpenIDConfiguration {
+	args := usecase.Mock.Called()
+
+	return args.Get(0).(*domains.OpenIDConfiguration)
+}
+
+func (usecase *OAuthUsecaseMock) UserInfoHandler(ctx context.Context, principal *domains.Principal) (info *domains.UserInfo, err error) {
+	args := usecase.Mock.Called(ctx, principal)
+
+	if args.Get(0) != nil {
+		info = args.Get(0).(*domains.UserInfo)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *OAuthUsecaseMock) IntrospectHandler(ctx context.Context, input *domains.TokenHint) (introspection *domains.Introspection, err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	if args.Get(0) != nil {
+		introspection = args.Get(0).(*domains.Introspection)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *OAuthUsecaseMock) RevokeHandler(ctx context.Context, input *domains.TokenHint) (err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	return args.Error(0)
+}
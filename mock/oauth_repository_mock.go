@@ -0,0 +1,88 @@
+package mock
+
+import (
+	repo "api-auth/services/repository"
+	"context"
+	"errors"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OAuthRepositoryMock struct {
+	Mock mock.Mock
+}
+
+func (repository *OAuthRepositoryMock) CreateOAuthClient(ctx context.Context, client *repo.OAuthClient) error {
+	args := repository.Mock.Called(ctx, client)
+	if args.Get(0) != nil {
+		return errors.New("Cannot create client!")
+	}
+	return nil
+}
+
+func (repository *OAuthRepositoryMock) FindOAuthClient(ctx context.Context, clientId string) *repo.OAuthClient {
+	args := repository.Mock.Called(ctx, clientId)
+	if args.Get(0) == nil {
+		return nil
+	}
+	client := args.Get(0).(repo.OAuthClient)
+	return &client
+}
+
+func (repository *OAuthRepositoryMock) OAuthClients(ctx context.Context) ([]repo.OAuthClient, error) {
+	args := repository.Mock.Called(ctx)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot fetch clients!")
+	}
+	return args.Get(0).([]repo.OAuthClient), nil
+}
+
+func (repository *OAuthRepositoryMock) DeleteOAuthClient(ctx context.Context, clientId string) error {
+	args := repository.Mock.Called(ctx, clientId)
+	if args.Get(0) != nil {
+		return errors.New("Client not found!")
+	}
+	return nil
+}
+
+func (repository *OAuthRepositoryMock) CreateAuthorizationCode(ctx context.Context, code *repo.AuthorizationCode) error {
+	args := repository.Mock.Called(ctx, code)
+	if args.Get(0) != nil {
+		return errors.New("Cannot create authorization code!")
+	}
+	return nil
+}
+
+func (repository *OAuthRepositoryMock) FindAuthorizationCode(ctx context.Context, codeHash string) *repo.AuthorizationCode {
+	args := repository.Mock.Called(ctx, codeHash)
+	if args.Get(0) == nil {
+		return nil
+	}
+	code := args.Get(0).(repo.AuthorizationCode)
+	return &code
+}
+
+func (repository *OAuthRepositoryMock) UseAuthorizationCode(ctx context.Context, codeId string) error {
+	args := repository.Mock.Called(ctx, codeId)
+	if args.Get(0) != nil {
+		return errors.New("Authorization code already used!")
+	}
+	return nil
+}
+
+func (repository *OAuthRepositoryMock) FindOAuthConsent(ctx context.Context, userId, clientId string) *repo.OAuthConsent {
+	args := repository.Mock.Called(ctx, userId, clientId)
+	if args.Get(0) == nil {
+		return nil
+	}
+	consent := args.Get(0).(repo.OAuthConsent)
+	return &consent
+}
+
+func (repository *OAuthRepositoryMock) SaveOAuthConsent(ctx context.Context, userId, clientId, scope string) error {
+	args := repository.Mock.Called(ctx, userId, clientId, scope)
+	if args.Get(0) != nil {
+		return errors.New("Cannot save consent!")
+	}
+	return nil
+}
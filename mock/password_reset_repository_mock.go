@@ -0,0 +1,49 @@
+package mock
+
+import (
+	repo "api-auth/services/repository"
+	"context"
+	"errors"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type PasswordResetRepositoryMock struct {
+	Mock mock.Mock
+}
+
+func (repository *PasswordResetRepositoryMock) CreatePasswordResetToken(ctx context.Context, token *repo.PasswordResetToken) error {
+	args := repository.Mock.Called(ctx, token)
+	if args.Get(0) != nil {
+		return errors.New("Cannot create reset token!")
+	}
+	return nil
+}
+
+func (repository *PasswordResetRepositoryMock) FindPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*repo.PasswordResetToken, error) {
+	args := repository.Mock.Called(ctx, tokenHash)
+	if err, ok := args.Get(0).(error); ok {
+		return nil, err
+	}
+	if args.Get(0) == nil {
+		return nil, nil
+	}
+	token := args.Get(0).(repo.PasswordResetToken)
+	return &token, nil
+}
+
+func (repository *PasswordResetRepositoryMock) UsePasswordResetToken(ctx context.Context, tokenId string) error {
+	args := repository.Mock.Called(ctx, tokenId)
+	if args.Get(0) != nil {
+		return errors.New("Reset token already used!")
+	}
+	return nil
+}
+
+func (repository *PasswordResetRepositoryMock) InvalidateUserPasswordResetTokens(ctx context.Context, userId string) error {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot invalidate reset tokens!")
+	}
+	return nil
+}
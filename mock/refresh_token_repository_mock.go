@@ -0,0 +1,54 @@
+package mock
+
+import (
+	repo "api-auth/services/repository"
+	"context"
+	"errors"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type RefreshTokenRepositoryMock struct {
+	Mock mock.Mock
+}
+
+func (repository *RefreshTokenRepositoryMock) CreateRefreshToken(ctx context.Context, token *repo.RefreshToken) error {
+	args := repository.Mock.Called(ctx, token)
+	if args.Get(0) != nil {
+		return errors.New("Cannot create refresh token!")
+	}
+	return nil
+}
+
+func (repository *RefreshTokenRepositoryMock) FindRefreshTokenByHash(ctx context.Context, tokenHash string) *repo.RefreshToken {
+	args := repository.Mock.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil
+	}
+	token := args.Get(0).(repo.RefreshToken)
+	return &token
+}
+
+func (repository *RefreshTokenRepositoryMock) RotateRefreshToken(ctx context.Context, tokenId, replacedBy string) error {
+	args := repository.Mock.Called(ctx, tokenId, replacedBy)
+	if args.Get(0) != nil {
+		return errors.New("Refresh token already used!")
+	}
+	return nil
+}
+
+func (repository *RefreshTokenRepositoryMock) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	args := repository.Mock.Called(ctx, familyId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot revoke refresh tokens!")
+	}
+	return nil
+}
+
+func (repository *RefreshTokenRepositoryMock) RevokeUserRefreshTokens(ctx context.Context, userId string) error {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot revoke refresh tokens!")
+	}
+	return nil
+}
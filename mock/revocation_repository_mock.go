@@ -0,0 +1,34 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type RevocationRepositoryMock struct {
+	Mock mock.Mock
+}
+
+func (repository *RevocationRepositoryMock) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	args := repository.Mock.Called(ctx, jti, expiresAt)
+	if args.Get(0) != nil {
+		return errors.New("Cannot revoke token!")
+	}
+	return nil
+}
+
+func (repository *RevocationRepositoryMock) RevokeUserTokens(ctx context.Context, userId string, before time.Time) error {
+	args := repository.Mock.Called(ctx, userId, before)
+	if args.Get(0) != nil {
+		return errors.New("Cannot revoke user tokens!")
+	}
+	return nil
+}
+
+func (repository *RevocationRepositoryMock) IsTokenRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) bool {
+	args := repository.Mock.Called(ctx, jti, userId, issuedAt)
+	return args.Bool(0)
+}
@@ -0,0 +1,136 @@
+package mock
+
+import (
+	repo "api-auth/services/repository"
+	"context"
+	"errors"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type RoleRepositoryMock struct {
+	Mock mock.Mock
+}
+
+func (repository *RoleRepositoryMock) CreateRole(ctx context.Context, name, description string) (*repo.Role, error) {
+	args := repository.Mock.Called(ctx, name, description)
+	if args.Get(0) != nil {
+		return nil, errors.New("Cannot create role!")
+	}
+	return &repo.Role{ID: "uuid", Name: name, Description: description}, nil
+}
+
+func (repository *RoleRepositoryMock) FindRoleById(ctx context.Context, roleId string) *repo.Role {
+	args := repository.Mock.Called(ctx, roleId)
+	if args.Get(0) == nil {
+		return nil
+	}
+	role := args.Get(0).(repo.Role)
+	return &role
+}
+
+func (repository *RoleRepositoryMock) FindRoleByName(ctx context.Context, name string) *repo.Role {
+	args := repository.Mock.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil
+	}
+	role := args.Get(0).(repo.Role)
+	return &role
+}
+
+func (repository *RoleRepositoryMock) Roles(ctx context.Context) ([]repo.Role, error) {
+	args := repository.Mock.Called(ctx)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot fetch roles!")
+	}
+	return args.Get(0).([]repo.Role), nil
+}
+
+func (repository *RoleRepositoryMock) DeleteRole(ctx context.Context, roleId string) ([]string, error) {
+	args := repository.Mock.Called(ctx, roleId)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot delete role!")
+	}
+	return args.Get(0).([]string), nil
+}
+
+func (repository *RoleRepositoryMock) CreatePermission(ctx context.Context, name, description string) (*repo.Permission, error) {
+	args := repository.Mock.Called(ctx, name, description)
+	if args.Get(0) != nil {
+		return nil, errors.New("Cannot create permission!")
+	}
+	return &repo.Permission{ID: name + "_uuid", Name: name, Description: description}, nil
+}
+
+func (repository *RoleRepositoryMock) FindPermissionByName(ctx context.Context, name string) *repo.Permission {
+	args := repository.Mock.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil
+	}
+	permission := args.Get(0).(repo.Permission)
+	return &permission
+}
+
+func (repository *RoleRepositoryMock) Permissions(ctx context.Context) ([]repo.Permission, error) {
+	args := repository.Mock.Called(ctx)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot fetch permissions!")
+	}
+	return args.Get(0).([]repo.Permission), nil
+}
+
+func (repository *RoleRepositoryMock) GrantPermission(ctx context.Context, roleId, permissionId string) error {
+	args := repository.Mock.Called(ctx, roleId, permissionId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot grant permission!")
+	}
+	return nil
+}
+
+func (repository *RoleRepositoryMock) RevokePermission(ctx context.Context, roleId, permissionId string) error {
+	args := repository.Mock.Called(ctx, roleId, permissionId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot revoke permission!")
+	}
+	return nil
+}
+
+func (repository *RoleRepositoryMock) AssignRole(ctx context.Context, userId, roleId string) error {
+	args := repository.Mock.Called(ctx, userId, roleId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot assign role!")
+	}
+	return nil
+}
+
+func (repository *RoleRepositoryMock) UnassignRole(ctx context.Context, userId, roleId string) error {
+	args := repository.Mock.Called(ctx, userId, roleId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot unassign role!")
+	}
+	return nil
+}
+
+func (repository *RoleRepositoryMock) UnassignAllRoles(ctx context.Context, userId string) error {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(0) != nil {
+		return errors.New("Cannot unassign roles!")
+	}
+	return nil
+}
+
+func (repository *RoleRepositoryMock) UserRoles(ctx context.Context, userId string) ([]string, error) {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot fetch user roles!")
+	}
+	return args.Get(0).([]string), nil
+}
+
+func (repository *RoleRepositoryMock) RolePermissions(ctx context.Context, roleNames []string) ([]string, error) {
+	args := repository.Mock.Called(ctx, roleNames)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot fetch permissions!")
+	}
+	return args.Get(0).([]string), nil
+}
@@ -3,6 +3,7 @@ package mock
 import (
 	"api-auth/domains"
 	"api-auth/services/repository"
+	"context"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -11,30 +12,18 @@ type UserUsecaseMock struct {
 	Mock mock.Mock
 }
 
-func (usecase *UserUsecaseMock) GetUsers() (users []repository.User, err error) {
-	args := usecase.Mock.Called()
+func (usecase *UserUsecaseMock) GetUsers(ctx context.Context, query *domains.UserQuery) (*repository.UserPage, error) {
+	args := usecase.Mock.Called(ctx, query)
 
-	if rf, ok := args.Get(0).(func() []repository.User); ok {
-		users = rf()
-	} else {
-		if args.Get(0) != nil {
-			users = args.Get(0).([]repository.User)
-		} else {
-			users = nil
-		}
-	}
-
-	if rf, ok := args.Get(1).(func() error); ok {
-		err = rf()
-	} else {
-		err = args.Error(1)
+	if args.Get(0) != nil {
+		page := args.Get(0).(repository.UserPage)
+		return &page, args.Error(1)
 	}
-
-	return
+	return nil, args.Error(1)
 }
 
-func (usecase *UserUsecaseMock) RegisterHandler(input *domains.Register) (err error) {
-	args := usecase.Mock.Called(input)
+func (usecase *UserUsecaseMock) RegisterHandler(ctx context.Context, input *domains.Register) (err error) {
+	args := usecase.Mock.Called(ctx, input)
 
 	if args.Get(0) == nil {
 		err = nil
@@ -45,8 +34,8 @@ func (usecase *UserUsecaseMock) RegisterHandler(input *domains.Register) (err er
 	return
 }
 
-func (usecase *UserUsecaseMock) LoginHandler(input *domains.Login) (user *repository.User, token string, err error) {
-	args := usecase.Mock.Called(input)
+func (usecase *UserUsecaseMock) LoginHandler(ctx context.Context, input *domains.Login, clientIP string) (user *repository.User, token *domains.Token, err error) {
+	args := usecase.Mock.Called(ctx, input, clientIP)
 
 	if rf, ok := args.Get(0).(func(*domains.Login) *repository.User); ok {
 		user = rf(input)
@@ -56,10 +45,12 @@ func (usecase *UserUsecaseMock) LoginHandler(input *domains.Login) (user *reposi
 		}
 	}
 
-	if rf, ok := args.Get(1).(func(*domains.Login) string); ok {
+	if rf, ok := args.Get(1).(func(*domains.Login) *domains.Token); ok {
 		token = rf(input)
 	} else {
-		token = args.Get(1).(string)
+		if args.Get(1) != nil {
+			token = args.Get(1).(*domains.Token)
+		}
 	}
 
 	if rf, ok := args.Get(2).(func(*domains.Login) error); ok {
@@ -71,8 +62,259 @@ func (usecase *UserUsecaseMock) LoginHandler(input *domains.Login) (user *reposi
 	return
 }
 
-func (usecase *UserUsecaseMock) ChangePasswordHandler(input *domains.ChangePassword) (err error) {
-	args := usecase.Mock.Called(input)
+func (usecase *UserUsecaseMock) MFALoginHandler(ctx context.Context, input *domains.MFALogin, clientIP string) (user *repository.User, token *domains.Token, err error) {
+	args := usecase.Mock.Called(ctx, input, clientIP)
+
+	if args.Get(0) != nil {
+		user = args.Get(0).(*repository.User)
+	}
+	if args.Get(1) != nil {
+		token = args.Get(1).(*domains.Token)
+	}
+	err = args.Error(2)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) EnrollMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.Reauthentication) (enrollment *domains.MFAEnrollment, err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		enrollment = args.Get(0).(*domains.MFAEnrollment)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) ConfirmMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.MFACode) (codes []string, err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		codes = args.Get(0).([]string)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) DisableMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.DisableMFA) (err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) RegenerateRecoveryCodesHandler(ctx context.Context, principal *domains.Principal, input *domains.MFACode) (codes []string, err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		codes = args.Get(0).([]string)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) BeginWebAuthnRegistrationHandler(ctx context.Context, principal *domains.Principal, input *domains.Reauthentication) (options *domains.WebAuthnOptions, err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		options = args.Get(0).(*domains.WebAuthnOptions)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) FinishWebAuthnRegistrationHandler(ctx context.Context, principal *domains.Principal, input *domains.WebAuthnRegistration) (credential *repository.WebAuthnCredential, err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		credential = args.Get(0).(*repository.WebAuthnCredential)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) BeginWebAuthnLoginHandler(ctx context.Context) (options *domains.WebAuthnOptions, err error) {
+	args := usecase.Mock.Called(ctx)
+
+	if args.Get(0) != nil {
+		options = args.Get(0).(*domains.WebAuthnOptions)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) FinishWebAuthnLoginHandler(ctx context.Context, input *domains.WebAuthnLogin, clientIP string) (user *repository.User, token *domains.Token, err error) {
+	args := usecase.Mock.Called(ctx, input, clientIP)
+
+	if args.Get(0) != nil {
+		user = args.Get(0).(*repository.User)
+	}
+	if args.Get(1) != nil {
+		token = args.Get(1).(*domains.Token)
+	}
+	err = args.Error(2)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) BeginWebAuthnMFAHandler(ctx context.Context, input *domains.WebAuthnMFABegin) (options *domains.WebAuthnOptions, err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	if args.Get(0) != nil {
+		options = args.Get(0).(*domains.WebAuthnOptions)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) FinishWebAuthnMFAHandler(ctx context.Context, input *domains.WebAuthnMFALogin, clientIP string) (user *repository.User, token *domains.Token, err error) {
+	args := usecase.Mock.Called(ctx, input, clientIP)
+
+	if args.Get(0) != nil {
+		user = args.Get(0).(*repository.User)
+	}
+	if args.Get(1) != nil {
+		token = args.Get(1).(*domains.Token)
+	}
+	err = args.Error(2)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) GetWebAuthnCredentialsHandler(ctx context.Context, principal *domains.Principal) (credentials []repository.WebAuthnCredential, err error) {
+	args := usecase.Mock.Called(ctx, principal)
+
+	if args.Get(0) != nil {
+		credentials = args.Get(0).([]repository.WebAuthnCredential)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) DeleteWebAuthnCredentialHandler(ctx context.Context, principal *domains.Principal, credentialId string, input *domains.Reauthentication) error {
+	args := usecase.Mock.Called(ctx, principal, credentialId, input)
+
+	return args.Error(0)
+}
+
+func (usecase *UserUsecaseMock) BeginWebAuthnReauthHandler(ctx context.Context, principal *domains.Principal) (options *domains.WebAuthnOptions, err error) {
+	args := usecase.Mock.Called(ctx, principal)
+
+	if args.Get(0) != nil {
+		options = args.Get(0).(*domains.WebAuthnOptions)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) RefreshHandler(ctx context.Context, input *domains.RefreshToken) (token *domains.Token, err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	if rf, ok := args.Get(0).(func(*domains.RefreshToken) *domains.Token); ok {
+		token = rf(input)
+	} else {
+		if args.Get(0) != nil {
+			token = args.Get(0).(*domains.Token)
+		}
+	}
+
+	if rf, ok := args.Get(1).(func(*domains.RefreshToken) error); ok {
+		err = rf(input)
+	} else {
+		err = args.Error(1)
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) LogoutHandler(ctx context.Context, principal *domains.Principal, input *domains.RefreshToken) (err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) ChangePasswordHandler(ctx context.Context, principal *domains.Principal, input *domains.ChangePassword) (err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) ForgotPasswordHandler(ctx context.Context, input *domains.ForgotPassword) (err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) ResetPasswordHandler(ctx context.Context, input *domains.ResetPassword) (err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) VerifyEmailHandler(ctx context.Context, token string) (err error) {
+	args := usecase.Mock.Called(ctx, token)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) ResendVerificationHandler(ctx context.Context, input *domains.ResendVerification) (err error) {
+	args := usecase.Mock.Called(ctx, input)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) UnlockHandler(ctx context.Context, input *domains.Unlock) (err error) {
+	args := usecase.Mock.Called(ctx, input)
 
 	if args.Get(0) != nil {
 		err = args.Error(0)
@@ -83,8 +325,8 @@ func (usecase *UserUsecaseMock) ChangePasswordHandler(input *domains.ChangePassw
 	return
 }
 
-func (usecase *UserUsecaseMock) GetSingleUserHandler(userId string) (user *repository.User, err error) {
-	args := usecase.Mock.Called(userId)
+func (usecase *UserUsecaseMock) GetSingleUserHandler(ctx context.Context, userId string) (user *repository.User, err error) {
+	args := usecase.Mock.Called(ctx, userId)
 
 	if rf, ok := args.Get(0).(func(string) *repository.User); ok {
 		user = rf(userId)
@@ -103,8 +345,54 @@ func (usecase *UserUsecaseMock) GetSingleUserHandler(userId string) (user *repos
 	return
 }
 
-func (usecase *UserUsecaseMock) DeleteUserHandler(userId string) (err error) {
-	args := usecase.Mock.Called(userId)
+func (usecase *UserUsecaseMock) GetProfileHandler(ctx context.Context, principal *domains.Principal) (user *repository.User, err error) {
+	args := usecase.Mock.Called(ctx, principal)
+
+	if args.Get(0) != nil {
+		user = args.Get(0).(*repository.User)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) UpdateProfileHandler(ctx context.Context, principal *domains.Principal, input *domains.UpdateProfile) (user *repository.User, err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		user = args.Get(0).(*repository.User)
+	}
+	err = args.Error(1)
+
+	return
+}
+
+func (usecase *UserUsecaseMock) ChangeEmailHandler(ctx context.Context, principal *domains.Principal, input *domains.ChangeEmail) (err error) {
+	args := usecase.Mock.Called(ctx, principal, input)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) ConfirmEmailChangeHandler(ctx context.Context, token string) (err error) {
+	args := usecase.Mock.Called(ctx, token)
+
+	if args.Get(0) != nil {
+		err = args.Error(0)
+	} else {
+		err = nil
+	}
+
+	return
+}
+
+func (usecase *UserUsecaseMock) DeleteUserHandler(ctx context.Context, userId string) (err error) {
+	args := usecase.Mock.Called(ctx, userId)
 
 	if args.Get(0) != nil {
 		err = args.Error(0)
@@ -3,7 +3,9 @@ package mock
 import (
 	"api-auth/domains"
 	repo "api-auth/services/repository"
+	"context"
 	"errors"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -12,49 +14,111 @@ type UserRepositoryMock struct {
 	Mock mock.Mock
 }
 
-func (repository *UserRepositoryMock) FindByEmail(email string) *repo.User {
+func (repository *UserRepositoryMock) FindByEmail(ctx context.Context, email string) (*repo.User, error) {
 
-	args := repository.Mock.Called(email)
+	args := repository.Mock.Called(ctx, email)
+	if err, ok := args.Get(0).(error); ok {
+		return nil, err
+	}
 	if args.Get(0) == nil {
-		return nil
+		return nil, nil
 	}
 	user := args.Get(0).(repo.User)
-	return &user
+	return &user, nil
 }
 
-func (repository *UserRepositoryMock) FindById(userId string) *repo.User {
-	args := repository.Mock.Called(userId)
+func (repository *UserRepositoryMock) FindById(ctx context.Context, userId string) (*repo.User, error) {
+	args := repository.Mock.Called(ctx, userId)
+	if err, ok := args.Get(0).(error); ok {
+		return nil, err
+	}
 	if args.Get(0) == nil {
-		return nil
+		return nil, nil
 	}
 	user := args.Get(0).(repo.User)
-	return &user
+	return &user, nil
+}
+
+func (repository *UserRepositoryMock) CreateUser(ctx context.Context, input *domains.Register) (*repo.User, error) {
+	args := repository.Mock.Called(ctx, input)
+	if err, ok := args.Get(0).(error); ok {
+		return nil, err
+	}
+	return &repo.User{ID: "uuid", Name: input.Name, Email: input.Email, Password: input.Password}, nil
 }
 
-func (repository *UserRepositoryMock) CreateUser(input *domains.Register) error {
-	args := repository.Mock.Called(input)
+func (repository *UserRepositoryMock) VerifyEmail(ctx context.Context, userId string, email string) error {
+	args := repository.Mock.Called(ctx, userId, email)
 	if args.Get(0) != nil {
-		return errors.New("Cannot create user!")
+		return errors.New("User not found!")
+	}
+	return nil
+}
+
+func (repository *UserRepositoryMock) MarkVerificationSent(ctx context.Context, userId string, notBefore time.Time) error {
+	args := repository.Mock.Called(ctx, userId, notBefore)
+	if err, ok := args.Get(0).(error); ok {
+		return err
+	}
+	return nil
+}
+
+func (repository *UserRepositoryMock) MarkEmailChangeSent(ctx context.Context, userId string, notBefore time.Time) error {
+	args := repository.Mock.Called(ctx, userId, notBefore)
+	if err, ok := args.Get(0).(error); ok {
+		return err
+	}
+	return nil
+}
+
+func (repository *UserRepositoryMock) ClearEmailChangeSent(ctx context.Context, userId string) error {
+	args := repository.Mock.Called(ctx, userId)
+	if err, ok := args.Get(0).(error); ok {
+		return err
 	}
 	return nil
 }
 
-func (repository *UserRepositoryMock) DeleteUserById(userId string) error {
-	args := repository.Mock.Called(userId)
+func (repository *UserRepositoryMock) DeleteUserById(ctx context.Context, userId string) error {
+	args := repository.Mock.Called(ctx, userId)
 	if args.Get(0) != nil {
 		return errors.New("Cannot delete user!")
 	}
 	return nil
 }
 
-func (repository *UserRepositoryMock) UpdatePassword(input *domains.ChangePassword, userId string) error {
-	args := repository.Mock.Called(input, userId)
+func (repository *UserRepositoryMock) UpdatePassword(ctx context.Context, userId string, passwordHash string) error {
+	args := repository.Mock.Called(ctx, userId, passwordHash)
+	if args.Get(0) != nil {
+		return errors.New("Cannot update password!")
+	}
+	return nil
+}
+
+func (repository *UserRepositoryMock) RehashPassword(ctx context.Context, userId string, oldHash string, newHash string) error {
+	args := repository.Mock.Called(ctx, userId, oldHash, newHash)
 	if args.Get(0) != nil {
 		return errors.New("Cannot update password!")
 	}
 	return nil
 }
 
+func (repository *UserRepositoryMock) UpdateProfile(ctx context.Context, userId string, input *domains.UpdateProfile) error {
+	args := repository.Mock.Called(ctx, userId, input)
+	if args.Get(0) != nil {
+		return errors.New("Cannot update profile!")
+	}
+	return nil
+}
+
+func (repository *UserRepositoryMock) ChangeEmail(ctx context.Context, userId string, previousEmail string, email string) error {
+	args := repository.Mock.Called(ctx, userId, previousEmail, email)
+	if err, ok := args.Get(0).(error); ok {
+		return err
+	}
+	return nil
+}
+
 // func (repository *UserRepositoryMock) Users() (users []repo.User, err error) {
 // 	args := repository.Mock.Called()
 
@@ -75,13 +139,13 @@ func (repository *UserRepositoryMock) UpdatePassword(input *domains.ChangePasswo
 // 	return
 // }
 
-func (repository *UserRepositoryMock) Users() ([]repo.User, error) {
-	args := repository.Mock.Called()
+func (repository *UserRepositoryMock) Users(ctx context.Context, query *domains.UserQuery) (*repo.UserPage, error) {
+	args := repository.Mock.Called(ctx, query)
 
 	if args.Get(1) != nil {
-		return nil, errors.New("users not found")
+		return nil, errors.New("Cannot fetch users!")
 	} else {
-		users := args.Get(0).([]repo.User)
-		return users, nil
+		page := args.Get(0).(repo.UserPage)
+		return &page, nil
 	}
 }
@@ -0,0 +1,80 @@
+package mock
+
+import (
+	repo "api-auth/services/repository"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type WebAuthnRepositoryMock struct {
+	Mock mock.Mock
+}
+
+func (repository *WebAuthnRepositoryMock) CreateWebAuthnCredential(ctx context.Context, credential *repo.WebAuthnCredential) error {
+	args := repository.Mock.Called(ctx, credential)
+	if args.Get(0) != nil {
+		return errors.New("Cannot save passkey!")
+	}
+	return nil
+}
+
+func (repository *WebAuthnRepositoryMock) FindWebAuthnCredential(ctx context.Context, credentialId string) *repo.WebAuthnCredential {
+	args := repository.Mock.Called(ctx, credentialId)
+	if args.Get(0) == nil {
+		return nil
+	}
+	credential := args.Get(0).(repo.WebAuthnCredential)
+	return &credential
+}
+
+func (repository *WebAuthnRepositoryMock) UserWebAuthnCredentials(ctx context.Context, userId string) ([]repo.WebAuthnCredential, error) {
+	args := repository.Mock.Called(ctx, userId)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot fetch passkeys!")
+	}
+	return args.Get(0).([]repo.WebAuthnCredential), nil
+}
+
+func (repository *WebAuthnRepositoryMock) UpdateWebAuthnSignCount(ctx context.Context, credentialId string, oldCount, newCount uint32) error {
+	args := repository.Mock.Called(ctx, credentialId, oldCount, newCount)
+	if args.Get(0) != nil {
+		return errors.New("Passkey was used concurrently!")
+	}
+	return nil
+}
+
+func (repository *WebAuthnRepositoryMock) DeleteWebAuthnCredential(ctx context.Context, userId, credentialId string) error {
+	args := repository.Mock.Called(ctx, userId, credentialId)
+	if args.Get(0) != nil {
+		return errors.New("Passkey not found!")
+	}
+	return nil
+}
+
+// CreateWebAuthnSession returns a session with the given values and the id
+// the test set up as the first return value.
+func (repository *WebAuthnRepositoryMock) CreateWebAuthnSession(ctx context.Context, userId, challenge, purpose string, expiresAt time.Time) (*repo.WebAuthnSession, error) {
+	args := repository.Mock.Called(ctx, userId, challenge, purpose, expiresAt)
+	if args.Get(1) != nil {
+		return nil, errors.New("Cannot start passkey ceremony!")
+	}
+	return &repo.WebAuthnSession{
+		ID:        args.String(0),
+		UserID:    userId,
+		Challenge: challenge,
+		Purpose:   purpose,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (repository *WebAuthnRepositoryMock) UseWebAuthnSession(ctx context.Context, sessionId, purpose string) *repo.WebAuthnSession {
+	args := repository.Mock.Called(ctx, sessionId, purpose)
+	if args.Get(0) == nil {
+		return nil
+	}
+	session := args.Get(0).(repo.WebAuthnSession)
+	return &session
+}
@@ -1,20 +1,129 @@
-package main
-
-import (
-	"api-auth/app"
-	"api-auth/app/config"
-	"api-auth/services/logic"
-	"api-auth/services/repository"
-)
-
-
-func main() {
-	db := config.SetupMysql()
-	db.AutoMigrate(&repository.User{})
-	
-	userRepo := repository.NewUserRepository(db)
-	userCase := logic.NewUserUsecase(userRepo)
-
-	r := app.Routes(db, userCase)
-	r.Run(":3000")
-}
\ No newline at end of file
+package main
+
+import (
+	"api-auth/app"
+	"api-auth/app/config"
+	"api-auth/app/helper"
+	"api-auth/services/logic"
+	"api-auth/services/mailer"
+	"api-auth/services/repository"
+	"api-auth/services/repository/migrations"
+	"api-auth/services/webauthn"
+	"context"
+	"log"
+	"os"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(os.Args[2:]); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if cfg.Token.KeysDir != "" {
+		keys, err := helper.LoadKeyRing(cfg.Token.KeysDir)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		helper.SetKeyRing(keys)
+	} else {
+		log.Print("token.keys_dir is not set, using an ephemeral signing key")
+	}
+	helper.SetTokenTTL(cfg.Token.AccessTTL, cfg.Token.RefreshTTL)
+	helper.SetPasswordPolicy(cfg.Password.MinLength)
+	if cfg.Password.Hasher == "bcrypt" {
+		helper.SetPasswordHasher(helper.NewBcryptHasher(cfg.Password.BcryptCost))
+	} else {
+		params := helper.DefaultArgon2Params
+		params.Memory = uint32(cfg.Password.Argon2Memory)
+		params.Iterations = uint32(cfg.Password.Argon2Iterations)
+		params.Parallelism = uint8(cfg.Password.Argon2Parallelism)
+		helper.SetPasswordHasher(helper.NewArgon2idHasher(params))
+	}
+
+	db := config.SetupDatabase(cfg.Database)
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if cfg.Database.Driver == "sqlite" && cfg.Database.Name == ":memory:" {
+		// Nothing else can reach a database in memory to migrate it.
+		if _, err := migrator.Up(); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	if err := migrator.Check(); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	timeouts := repository.Timeouts{
+		Read:  cfg.Database.ReadTimeout,
+		Write: cfg.Database.WriteTimeout,
+	}
+	userRepo := repository.NewUserRepository(db, timeouts)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db, timeouts)
+	revocationRepo := repository.NewRevocationRepository(db, timeouts)
+	passwordResetRepo := repository.NewPasswordResetRepository(db, timeouts)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db, timeouts)
+	roleRepo := repository.NewRoleRepository(db, timeouts)
+	mfaRepo := repository.NewMFARepository(db, timeouts)
+	webAuthnRepo := repository.NewWebAuthnRepository(db, timeouts)
+	oauthRepo := repository.NewOAuthRepository(db, timeouts)
+	apiKeyRepo := repository.NewAPIKeyRepository(db, timeouts)
+	if cfg.Lockout.Store == "memory" {
+		loginAttemptRepo = repository.NewMemoryLoginAttemptRepository()
+	}
+
+	mail := mailer.NewLogMailer(cfg.Mail.From)
+	if cfg.Mail.Driver == "file" {
+		mail = mailer.NewFileMailer(cfg.Mail.From, cfg.Mail.Dir)
+	}
+
+	userCase := logic.NewUserUsecase(userRepo, refreshTokenRepo, revocationRepo, passwordResetRepo, loginAttemptRepo, roleRepo, mfaRepo, webAuthnRepo, mail, logic.Options{
+		ResetURL:             cfg.Password.ResetURL,
+		ResetTokenTTL:        cfg.Password.ResetTTL,
+		VerifyURL:            cfg.Verify.URL,
+		VerifyTokenTTL:       cfg.Verify.TTL,
+		ChangeEmailURL:       cfg.Verify.ChangeEmailURL,
+		ResendInterval:       cfg.Verify.ResendInterval,
+		AllowUnverifiedLogin: cfg.Verify.AllowUnverifiedLogin,
+		Lockout: logic.LockoutPolicy{
+			MaxFailures:   cfg.Lockout.MaxFailures,
+			IPMaxFailures: cfg.Lockout.IPMaxFailures,
+			Window:        cfg.Lockout.Window,
+			BaseDuration:  cfg.Lockout.BaseDuration,
+			MaxDuration:   cfg.Lockout.MaxDuration,
+		},
+		MFAIssuer:       cfg.MFA.Issuer,
+		MFAChallengeTTL: cfg.MFA.ChallengeTTL,
+		RelyingParty: webauthn.RelyingParty{
+			ID:      cfg.WebAuthn.RPID,
+			Name:    cfg.WebAuthn.RPName,
+			Origins: cfg.WebAuthn.OriginList(),
+			Timeout: cfg.WebAuthn.Timeout,
+		},
+	})
+
+	roleCase := logic.NewRoleUsecase(roleRepo, userRepo, revocationRepo)
+	if err := roleCase.EnsureDefaultRoles(context.Background(), cfg.RBAC.AdminEmail); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	oauthCase := logic.NewOAuthUsecase(oauthRepo, userRepo, refreshTokenRepo, revocationRepo, logic.OAuthOptions{
+		CodeTTL:          cfg.OAuth.CodeTTL,
+		Issuer:           cfg.OAuth.Issuer,
+		AuthorizationURL: cfg.OAuth.AuthorizationURL,
+	})
+
+	apiKeyCase := logic.NewAPIKeyUsecase(apiKeyRepo, userRepo, roleRepo)
+
+	r := app.Routes(db, userCase, roleCase, oauthCase, apiKeyCase, revocationRepo, roleRepo)
+	r.Run(cfg.Server.Addr)
+}
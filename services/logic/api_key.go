@@ -0,0 +1,179 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"crypto/subtle"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var serviceAccountPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// apiKeyTouchInterval limits how often the last use of a key is written.
+var apiKeyTouchInterval = time.Minute
+
+type APIKeyUsecase struct {
+	Keys  repository.APIKeyRepositoryInterface
+	Users repository.UserRepositoryInterface
+	Roles repository.RoleRepositoryInterface
+}
+
+type APIKeyUsecaseInterface interface {
+	GetAPIKeys(ctx context.Context, principal *domains.Principal) ([]repository.APIKey, error)
+	CreateAPIKeyHandler(ctx context.Context, principal *domains.Principal, input *domains.CreateAPIKey) (*domains.APIKeyCreated, error)
+	RevokeAPIKeyHandler(ctx context.Context, principal *domains.Principal, keyId string) error
+	GetServiceAPIKeys(ctx context.Context) ([]repository.APIKey, error)
+	CreateServiceAPIKeyHandler(ctx context.Context, principal *domains.Principal, input *domains.CreateServiceAPIKey) (*domains.APIKeyCreated, error)
+	RevokeServiceAPIKeyHandler(ctx context.Context, keyId string) error
+	ResolveAPIKey(ctx context.Context, key string) (*domains.Principal, error)
+}
+
+func NewAPIKeyUsecase(Keys repository.APIKeyRepositoryInterface, Users repository.UserRepositoryInterface, Roles repository.RoleRepositoryInterface) APIKeyUsecaseInterface {
+	return &APIKeyUsecase{
+		Keys:  Keys,
+		Users: Users,
+		Roles: Roles,
+	}
+}
+
+func (ak *APIKeyUsecase) GetAPIKeys(ctx context.Context, principal *domains.Principal) ([]repository.APIKey, error) {
+	return ak.Keys.UserAPIKeys(ctx, principal.ID)
+}
+
+func (ak *APIKeyUsecase) CreateAPIKeyHandler(ctx context.Context, principal *domains.Principal, input *domains.CreateAPIKey) (*domains.APIKeyCreated, error) {
+	return ak.createAPIKey(ctx, principal, principal.ID, "", input)
+}
+
+func (ak *APIKeyUsecase) RevokeAPIKeyHandler(ctx context.Context, principal *domains.Principal, keyId string) error {
+	return ak.Keys.RevokeAPIKey(ctx, principal.ID, keyId)
+}
+
+func (ak *APIKeyUsecase) GetServiceAPIKeys(ctx context.Context) ([]repository.APIKey, error) {
+	return ak.Keys.ServiceAPIKeys(ctx)
+}
+
+// CreateServiceAPIKeyHandler creates a key that acts as the service account
+// instead of a user. It has no roles, only its scopes.
+func (ak *APIKeyUsecase) CreateServiceAPIKeyHandler(ctx context.Context, principal *domains.Principal, input *domains.CreateServiceAPIKey) (*domains.APIKeyCreated, error) {
+	if !serviceAccountPattern.MatchString(input.ServiceAccount) {
+		return nil, invalidField("serviceAccount", "Service account must be lowercase letters, digits, - or _!")
+	}
+	return ak.createAPIKey(ctx, principal, "", input.ServiceAccount, &input.CreateAPIKey)
+}
+
+func (ak *APIKeyUsecase) RevokeServiceAPIKeyHandler(ctx context.Context, keyId string) error {
+	return ak.Keys.RevokeAPIKey(ctx, "", keyId)
+}
+
+// createAPIKey stores a new key and returns it, the only time the secret is
+// known. The creator can only pass on permissions they hold themselves.
+func (ak *APIKeyUsecase) createAPIKey(ctx context.Context, principal *domains.Principal, userId, serviceAccount string, input *domains.CreateAPIKey) (*domains.APIKeyCreated, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, invalidField("name", "API key name is required!")
+	}
+	scopes := strings.Fields(joinScopes(input.Scopes...))
+	if len(scopes) == 0 {
+		return nil, invalidField("scopes", "API key needs at least one scope!")
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, invalidField("expiresAt", "API key expiry must be in the future!")
+	}
+	granted, err := ak.Roles.RolePermissions(ctx, principal.Roles)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		if !containsString(granted, scope) {
+			return nil, newError(KindForbidden, "scope_not_held", "Cannot grant "+scope+" without having it!")
+		}
+	}
+
+	key, lookup, secret, err := helper.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	salt, err := helper.GenerateSalt()
+	if err != nil {
+		return nil, err
+	}
+	stored := &repository.APIKey{
+		ID:             uuid.New().String(),
+		Lookup:         lookup,
+		Salt:           salt,
+		SecretHash:     helper.HashAPIKeySecret(salt, secret),
+		UserID:         userId,
+		ServiceAccount: serviceAccount,
+		Name:           name,
+		Scopes:         strings.Join(scopes, " "),
+		ExpiresAt:      input.ExpiresAt,
+	}
+	if err := ak.Keys.CreateAPIKey(ctx, stored); err != nil {
+		return nil, err
+	}
+	return &domains.APIKeyCreated{
+		ID:        stored.ID,
+		Key:       key,
+		Name:      stored.Name,
+		Scopes:    scopes,
+		ExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+// ResolveAPIKey checks a key from the Authorization header and returns the
+// principal it acts as. Keys of users get the current roles of their owner,
+// so taking a role away also restricts the keys.
+func (ak *APIKeyUsecase) ResolveAPIKey(ctx context.Context, key string) (*domains.Principal, error) {
+	invalid := newError(KindUnauthorized, "invalid_api_key", "Invalid API key!")
+	lookup, secret, ok := helper.SplitAPIKey(key)
+	if !ok {
+		return nil, invalid
+	}
+	stored := ak.Keys.FindAPIKeyByLookup(ctx, lookup)
+	if stored == nil {
+		return nil, invalid
+	}
+	if subtle.ConstantTimeCompare([]byte(helper.HashAPIKeySecret(stored.Salt, secret)), []byte(stored.SecretHash)) != 1 {
+		return nil, invalid
+	}
+	if stored.RevokedAt != nil {
+		return nil, newError(KindUnauthorized, "api_key_revoked", "API key has been revoked!")
+	}
+	now := time.Now()
+	if stored.ExpiresAt != nil && now.After(*stored.ExpiresAt) {
+		return nil, newError(KindUnauthorized, "api_key_expired", "API key expired!")
+	}
+
+	principal := &domains.Principal{
+		APIKeyID:       stored.ID,
+		ServiceAccount: stored.ServiceAccount,
+		Scope:          stored.Scopes,
+	}
+	if stored.UserID != "" {
+		user, err := ak.Users.FindById(ctx, stored.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, invalid
+		}
+		roles, err := ak.Roles.UserRoles(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		principal.ID = user.ID
+		principal.Email = user.Email
+		principal.Roles = roles
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) > apiKeyTouchInterval {
+		ak.Keys.TouchAPIKey(ctx, stored.ID, now)
+	}
+	return principal, nil
+}
@@ -0,0 +1,162 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	mokz "api-auth/mock"
+	"api-auth/services/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newAPIKeyUsecase() (*APIKeyUsecase, *mokz.APIKeyRepositoryMock, *mokz.RoleRepositoryMock) {
+	keys := &mokz.APIKeyRepositoryMock{Mock: mock.Mock{}}
+	roles := &mokz.RoleRepositoryMock{Mock: mock.Mock{}}
+	roles.Mock.On("RolePermissions", mock.Anything, []string{"support"}).Return([]string{PermissionUsersRead}, nil)
+	roles.Mock.On("RolePermissions", mock.Anything, []string{AdminRole}).Return(DefaultPermissions, nil)
+	usecase := &APIKeyUsecase{
+		Keys:  keys,
+		Users: userRepository,
+		Roles: roles,
+	}
+	return usecase, keys, roles
+}
+
+func TestAPIKeyUsecase_CreateAPIKeyHandler(t *testing.T) {
+	usecase, keys, _ := newAPIKeyUsecase()
+	principal := &domains.Principal{ID: "key_owner_uuid", Roles: []string{"support"}}
+
+	t.Run("success", func(t *testing.T) {
+		var stored repository.APIKey
+		keys.Mock.On("CreateAPIKey", mock.Anything, mock.MatchedBy(func(key *repository.APIKey) bool {
+			stored = *key
+			return true
+		})).Return(nil).Once()
+
+		created, err := usecase.CreateAPIKeyHandler(context.Background(), principal, &domains.CreateAPIKey{Name: " nightly export ", Scopes: []string{PermissionUsersRead, PermissionUsersRead}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "nightly export", created.Name)
+		assert.Equal(t, []string{PermissionUsersRead}, created.Scopes)
+		lookup, secret, ok := helper.SplitAPIKey(created.Key)
+		assert.True(t, ok)
+		assert.Equal(t, stored.Lookup, lookup)
+		assert.Equal(t, helper.HashAPIKeySecret(stored.Salt, secret), stored.SecretHash)
+		assert.NotContains(t, stored.SecretHash, secret)
+		assert.Equal(t, principal.ID, stored.UserID)
+		assert.Equal(t, PermissionUsersRead, stored.Scopes)
+	})
+
+	t.Run("scope_not_held", func(t *testing.T) {
+		_, err := usecase.CreateAPIKeyHandler(context.Background(), principal, &domains.CreateAPIKey{Name: "export", Scopes: []string{PermissionUsersDelete}})
+
+		assert.EqualError(t, err, "Cannot grant users:delete without having it!")
+	})
+
+	t.Run("no_scopes", func(t *testing.T) {
+		_, err := usecase.CreateAPIKeyHandler(context.Background(), principal, &domains.CreateAPIKey{Name: "export", Scopes: []string{}})
+
+		assert.EqualError(t, err, "API key needs at least one scope!")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+
+		_, err := usecase.CreateAPIKeyHandler(context.Background(), principal, &domains.CreateAPIKey{Name: "export", Scopes: []string{PermissionUsersRead}, ExpiresAt: &past})
+
+		assert.EqualError(t, err, "API key expiry must be in the future!")
+	})
+
+	t.Run("service_account", func(t *testing.T) {
+		admin := &domains.Principal{ID: "admin_uuid", Roles: []string{AdminRole}}
+		keys.Mock.On("CreateAPIKey", mock.Anything, mock.MatchedBy(func(key *repository.APIKey) bool {
+			return key.UserID == "" && key.ServiceAccount == "batch-jobs"
+		})).Return(nil).Once()
+
+		_, err := usecase.CreateServiceAPIKeyHandler(context.Background(), admin, &domains.CreateServiceAPIKey{
+			CreateAPIKey:   domains.CreateAPIKey{Name: "export", Scopes: []string{PermissionUsersRead}},
+			ServiceAccount: "batch-jobs",
+		})
+
+		assert.NoError(t, err)
+		keys.Mock.AssertExpectations(t)
+	})
+}
+
+func TestAPIKeyUsecase_ResolveAPIKey(t *testing.T) {
+	usecase, keys, roles := newAPIKeyUsecase()
+	userRepository.Mock.On("FindById", mock.Anything, "key_user_uuid").Return(repository.User{ID: "key_user_uuid", Email: "keys@gmail.com"})
+	roles.Mock.On("UserRoles", mock.Anything, "key_user_uuid").Return([]string{"support"}, nil)
+
+	store := func(key repository.APIKey) string {
+		plain, lookup, secret, _ := helper.GenerateAPIKey()
+		salt, _ := helper.GenerateSalt()
+		key.Lookup = lookup
+		key.Salt = salt
+		key.SecretHash = helper.HashAPIKeySecret(salt, secret)
+		keys.Mock.On("FindAPIKeyByLookup", mock.Anything, lookup).Return(key)
+		return plain
+	}
+	recently := time.Now()
+	past := time.Now().Add(-time.Minute)
+
+	userKey := store(repository.APIKey{ID: "user_key", UserID: "key_user_uuid", Scopes: PermissionUsersRead, LastUsedAt: &recently})
+	serviceKey := store(repository.APIKey{ID: "service_key", ServiceAccount: "batch-jobs", Scopes: PermissionUsersRead})
+	revokedKey := store(repository.APIKey{ID: "revoked_key", UserID: "key_user_uuid", RevokedAt: &past})
+	expiredKey := store(repository.APIKey{ID: "expired_key", UserID: "key_user_uuid", ExpiresAt: &past})
+	keys.Mock.On("FindAPIKeyByLookup", mock.Anything, mock.Anything).Return(nil)
+	keys.Mock.On("TouchAPIKey", mock.Anything, "service_key", mock.Anything).Return(nil).Once()
+
+	t.Run("user_key", func(t *testing.T) {
+		principal, err := usecase.ResolveAPIKey(context.Background(), userKey)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &domains.Principal{
+			ID:       "key_user_uuid",
+			Email:    "keys@gmail.com",
+			Roles:    []string{"support"},
+			Scope:    PermissionUsersRead,
+			APIKeyID: "user_key",
+		}, principal)
+		keys.Mock.AssertNotCalled(t, "TouchAPIKey", mock.Anything, "user_key", mock.Anything)
+	})
+
+	t.Run("service_key", func(t *testing.T) {
+		principal, err := usecase.ResolveAPIKey(context.Background(), serviceKey)
+
+		assert.NoError(t, err)
+		assert.Empty(t, principal.ID)
+		assert.Equal(t, "batch-jobs", principal.ServiceAccount)
+		keys.Mock.AssertCalled(t, "TouchAPIKey", mock.Anything, "service_key", mock.Anything)
+	})
+
+	t.Run("wrong_secret", func(t *testing.T) {
+		lookup, _, _ := helper.SplitAPIKey(userKey)
+
+		_, err := usecase.ResolveAPIKey(context.Background(), helper.APIKeyPrefix+lookup+".guessed")
+
+		assert.EqualError(t, err, "Invalid API key!")
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		_, err := usecase.ResolveAPIKey(context.Background(), revokedKey)
+
+		assert.EqualError(t, err, "API key has been revoked!")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := usecase.ResolveAPIKey(context.Background(), expiredKey)
+
+		assert.EqualError(t, err, "API key expired!")
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := usecase.ResolveAPIKey(context.Background(), "not-a-key")
+
+		assert.EqualError(t, err, "Invalid API key!")
+	})
+}
@@ -0,0 +1,94 @@
+package logic
+
+import (
+	"api-auth/services/repository"
+	"errors"
+)
+
+// ErrorKind tells callers how to treat an Error without looking at its
+// code, the controllers turn it into the HTTP status.
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindRateLimited
+)
+
+// Error is a failure the API shows to clients. Code is stable and what
+// clients branch on, Message is meant for people and may change.
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	// Fields maps the invalid input fields of a validation error to what is
+	// wrong with them.
+	Fields map[string]string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches errors by code, so errors.Is(err, ErrNotFound) holds for every
+// not found error, whatever its message.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// The errors clients most often branch on. Other codes are created where
+// they are returned.
+var (
+	ErrValidation         = &Error{Kind: KindValidation, Code: "validation_failed", Message: "Invalid input!"}
+	ErrInvalidCredentials = &Error{Kind: KindUnauthorized, Code: "invalid_credentials", Message: "Invalid email or password!"}
+	ErrNotFound           = &Error{Kind: KindNotFound, Code: "not_found", Message: "Not found!"}
+	ErrEmailTaken         = &Error{Kind: KindConflict, Code: "email_taken", Message: "Email has been used!"}
+	ErrEmailNotVerified   = &Error{Kind: KindForbidden, Code: "email_not_verified", Message: "Email not verified!"}
+	ErrWrongPassword      = &Error{Kind: KindForbidden, Code: "wrong_password", Message: "Current password is wrong!"}
+	ErrInvalidMFACode     = &Error{Kind: KindUnauthorized, Code: "invalid_mfa_code", Message: "Invalid MFA code!"}
+	ErrRateLimited        = &Error{Kind: KindRateLimited, Code: "rate_limited", Message: "Too many requests, please wait!"}
+	ErrInternal           = &Error{Kind: KindInternal, Code: "internal_error", Message: "Something went wrong!"}
+)
+
+func newError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// invalidField is a validation error of a single input field.
+func invalidField(field, message string) *Error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    ErrValidation.Code,
+		Message: message,
+		Fields:  map[string]string{field: message},
+	}
+}
+
+func notFound(message string) *Error {
+	return newError(KindNotFound, ErrNotFound.Code, message)
+}
+
+// AsError returns err as an Error. The errors of the repositories get the
+// kind they stand for, any other error is internal and its message, which
+// may describe the database, is left out.
+func AsError(err error) *Error {
+	var domainErr *Error
+	switch {
+	case errors.As(err, &domainErr):
+		return domainErr
+	case errors.Is(err, repository.ErrNotFound):
+		return notFound(err.Error())
+	case errors.Is(err, repository.ErrConflict):
+		return newError(KindConflict, "conflict", err.Error())
+	case errors.Is(err, repository.ErrInvalidQuery):
+		return newError(KindValidation, ErrValidation.Code, err.Error())
+	case errors.Is(err, repository.ErrVerificationSentRecently), errors.Is(err, repository.ErrEmailChangeSentRecently):
+		return newError(KindRateLimited, ErrRateLimited.Code, err.Error())
+	}
+	return ErrInternal
+}
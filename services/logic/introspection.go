@@ -0,0 +1,104 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"time"
+)
+
+// Token type hints of RFC 7009 and RFC 7662.
+const (
+	TokenTypeAccessToken  = "access_token"
+	TokenTypeRefreshToken = "refresh_token"
+)
+
+// IntrospectHandler tells a resource server whether a token is active.
+// Only confidential clients may ask, public ones cannot authenticate.
+func (ou *OAuthUsecase) IntrospectHandler(ctx context.Context, input *domains.TokenHint) (*domains.Introspection, error) {
+	client, err := ou.authenticateClient(ctx, input.ClientID, input.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if client.SecretHash == "" {
+		return nil, oauthError("invalid_client", "Public clients cannot introspect tokens!")
+	}
+
+	claims, refresh := ou.findToken(ctx, input.Token, input.TokenTypeHint)
+	switch {
+	case claims != nil:
+		subject := claims.ID
+		if subject == "" {
+			subject = claims.ClientID
+		}
+		return &domains.Introspection{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Username:  claims.Email,
+			TokenType: "Bearer",
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			IssuedAt:  claims.IssuedAt.Unix(),
+			Subject:   subject,
+			TokenID:   claims.RegisteredClaims.ID,
+			Roles:     claims.Roles,
+		}, nil
+	case refresh != nil:
+		return &domains.Introspection{
+			Active:    true,
+			Scope:     refresh.Scope,
+			ClientID:  refresh.ClientID,
+			TokenType: TokenTypeRefreshToken,
+			ExpiresAt: refresh.ExpiresAt.Unix(),
+			IssuedAt:  refresh.CreatedAt.Unix(),
+			Subject:   refresh.UserID,
+		}, nil
+	}
+	return &domains.Introspection{Active: false}, nil
+}
+
+// RevokeHandler revokes an access token or the family of a refresh token.
+// Unknown tokens are not an error, as RFC 7009 asks, and neither are the
+// tokens of other clients, which are left alone.
+func (ou *OAuthUsecase) RevokeHandler(ctx context.Context, input *domains.TokenHint) error {
+	client, err := ou.authenticateClient(ctx, input.ClientID, input.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	claims, refresh := ou.findToken(ctx, input.Token, input.TokenTypeHint)
+	switch {
+	case claims != nil && claims.ClientID == client.ID:
+		return ou.Revocations.RevokeToken(ctx, claims.RegisteredClaims.ID, claims.ExpiresAt.Time)
+	case refresh != nil && refresh.ClientID == client.ID:
+		return ou.RefreshTokens.RevokeRefreshTokenFamily(ctx, refresh.FamilyID)
+	}
+	return nil
+}
+
+// findToken returns the claims of an active access token or an active
+// refresh token. The hinted type is tried first, but the hint may be wrong.
+func (ou *OAuthUsecase) findToken(ctx context.Context, token, hint string) (*helper.Claims, *repository.RefreshToken) {
+	if hint == TokenTypeRefreshToken {
+		if refresh := ou.activeRefreshToken(ctx, token); refresh != nil {
+			return nil, refresh
+		}
+	}
+	// Access tokens go through the same checks as in the auth middleware.
+	if claims, err := helper.ValidateAccessToken(ctx, token, ou.Revocations); err == nil {
+		return claims, nil
+	}
+	if hint == TokenTypeRefreshToken {
+		return nil, nil
+	}
+	return nil, ou.activeRefreshToken(ctx, token)
+}
+
+func (ou *OAuthUsecase) activeRefreshToken(ctx context.Context, token string) *repository.RefreshToken {
+	refresh := ou.RefreshTokens.FindRefreshTokenByHash(ctx, helper.HashToken(token))
+	if refresh == nil || refresh.RevokedAt != nil || time.Now().After(refresh.ExpiresAt) {
+		return nil
+	}
+	return refresh
+}
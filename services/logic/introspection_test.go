@@ -0,0 +1,159 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOAuthUsecase_IntrospectHandler(t *testing.T) {
+	usecase, _, refreshTokens := newOAuthUsecase()
+	refreshTokens.Mock.On("FindRefreshTokenByHash", mock.Anything, helper.HashToken("live_refresh")).Return(repository.RefreshToken{
+		ID:        "refresh_uuid",
+		UserID:    "introspect_uuid",
+		ClientID:  publicClient.ID,
+		Scope:     "openid",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	refreshTokens.Mock.On("FindRefreshTokenByHash", mock.Anything, mock.Anything).Return(nil)
+
+	introspect := func(token, hint string) (*domains.Introspection, error) {
+		return usecase.IntrospectHandler(context.Background(), &domains.TokenHint{
+			Token:         token,
+			TokenTypeHint: hint,
+			ClientID:      confidentialClient.ID,
+			ClientSecret:  "service_secret",
+		})
+	}
+
+	t.Run("public_client", func(t *testing.T) {
+		_, err := usecase.IntrospectHandler(context.Background(), &domains.TokenHint{Token: "token", ClientID: publicClient.ID})
+
+		assert.Equal(t, "invalid_client", err.(*OAuthError).Code)
+	})
+
+	t.Run("access_token", func(t *testing.T) {
+		token, _ := helper.GenerateOAuthJWT("introspect_uuid", "kale@gmail.com", publicClient.ID, "openid email")
+
+		info, err := introspect(token, "")
+
+		assert.NoError(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, "introspect_uuid", info.Subject)
+		assert.Equal(t, publicClient.ID, info.ClientID)
+		assert.Equal(t, "openid email", info.Scope)
+		assert.Equal(t, "kale@gmail.com", info.Username)
+		assert.NotEmpty(t, info.TokenID)
+	})
+
+	t.Run("first_party_token", func(t *testing.T) {
+		token, _ := helper.GenerateJWT("introspect_uuid", "kale@gmail.com", "admin")
+
+		info, err := introspect(token, TokenTypeAccessToken)
+
+		assert.NoError(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, []string{"admin"}, info.Roles)
+	})
+
+	t.Run("client_credentials_token", func(t *testing.T) {
+		token, _ := helper.GenerateOAuthJWT("", "", confidentialClient.ID, "reports:read")
+
+		info, err := introspect(token, "")
+
+		assert.NoError(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, confidentialClient.ID, info.Subject)
+	})
+
+	t.Run("revoked_access_token", func(t *testing.T) {
+		token, _ := helper.GenerateOAuthJWT("introspect_uuid", "", publicClient.ID, "openid")
+		claims, _ := helper.ParseJWT(token)
+		usecase.Revocations.RevokeToken(context.Background(), claims.RegisteredClaims.ID, claims.ExpiresAt.Time)
+
+		info, err := introspect(token, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &domains.Introspection{Active: false}, info)
+	})
+
+	t.Run("refresh_token", func(t *testing.T) {
+		info, err := introspect("live_refresh", TokenTypeRefreshToken)
+
+		assert.NoError(t, err)
+		assert.True(t, info.Active)
+		assert.Equal(t, TokenTypeRefreshToken, info.TokenType)
+		assert.Equal(t, "introspect_uuid", info.Subject)
+	})
+
+	t.Run("wrong_hint", func(t *testing.T) {
+		info, err := introspect("live_refresh", TokenTypeAccessToken)
+
+		assert.NoError(t, err)
+		assert.True(t, info.Active)
+	})
+
+	t.Run("unknown_token", func(t *testing.T) {
+		info, err := introspect("garbage", "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &domains.Introspection{Active: false}, info)
+	})
+}
+
+func TestOAuthUsecase_RevokeHandler(t *testing.T) {
+	usecase, _, refreshTokens := newOAuthUsecase()
+	refreshTokens.Mock.On("FindRefreshTokenByHash", mock.Anything, helper.HashToken("spa_refresh")).Return(repository.RefreshToken{
+		ID:        "refresh_uuid",
+		UserID:    "revoke_uuid",
+		FamilyID:  "spa_family",
+		ClientID:  publicClient.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	refreshTokens.Mock.On("FindRefreshTokenByHash", mock.Anything, mock.Anything).Return(nil)
+
+	revoke := func(token, clientId, secret string) error {
+		return usecase.RevokeHandler(context.Background(), &domains.TokenHint{Token: token, ClientID: clientId, ClientSecret: secret})
+	}
+
+	t.Run("access_token", func(t *testing.T) {
+		token, _ := helper.GenerateOAuthJWT("revoke_uuid", "", publicClient.ID, "openid")
+
+		assert.NoError(t, revoke(token, publicClient.ID, ""))
+
+		_, err := helper.ValidateAccessToken(context.Background(), token, usecase.Revocations)
+		assert.EqualError(t, err, "Token has been revoked!")
+	})
+
+	t.Run("other_client_access_token", func(t *testing.T) {
+		token, _ := helper.GenerateOAuthJWT("revoke_uuid", "", publicClient.ID, "openid")
+
+		assert.NoError(t, revoke(token, confidentialClient.ID, "service_secret"))
+
+		_, err := helper.ValidateAccessToken(context.Background(), token, usecase.Revocations)
+		assert.NoError(t, err)
+	})
+
+	t.Run("refresh_token", func(t *testing.T) {
+		refreshTokens.Mock.On("RevokeRefreshTokenFamily", mock.Anything, "spa_family").Return(nil).Once()
+
+		assert.NoError(t, revoke("spa_refresh", publicClient.ID, ""))
+		refreshTokens.Mock.AssertCalled(t, "RevokeRefreshTokenFamily", mock.Anything, "spa_family")
+	})
+
+	t.Run("unknown_token", func(t *testing.T) {
+		assert.NoError(t, revoke("garbage", publicClient.ID, ""))
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		err := revoke("spa_refresh", confidentialClient.ID, "guess")
+
+		assert.Equal(t, "invalid_client", err.(*OAuthError).Code)
+	})
+}
@@ -0,0 +1,166 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LockoutPolicy decides when repeated login failures lock an account or a
+// client address. Each lockout of the same key lasts twice as long as the
+// previous one, up to MaxDuration.
+type LockoutPolicy struct {
+	MaxFailures   int
+	IPMaxFailures int
+	Window        time.Duration
+	BaseDuration  time.Duration
+	MaxDuration   time.Duration
+}
+
+// LockedError is returned while an account or address is locked out.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("Too many failed login attempts, try again in %s!", e.RetryAfter.Round(time.Second))
+}
+
+type loginKey struct {
+	id          string
+	maxFailures int
+}
+
+func (uu *UserUsecase) loginKeys(email, clientIP string) []loginKey {
+	keys := []loginKey{{"account:" + strings.ToLower(strings.TrimSpace(email)), uu.Options.Lockout.MaxFailures}}
+	if clientIP != "" {
+		keys = append(keys, loginKey{"ip:" + clientIP, uu.Options.Lockout.IPMaxFailures})
+	}
+	return keys
+}
+
+func (uu *UserUsecase) checkLoginLocked(ctx context.Context, keys []loginKey) error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		attempt := uu.LoginAttempts.FindLoginAttempt(ctx, key.id)
+		if attempt == nil || attempt.LockedUntil == nil || !attempt.LockedUntil.After(now) {
+			continue
+		}
+		if wait := attempt.LockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login against every key and locks the
+// ones that crossed their threshold. It returns a LockedError when this
+// failure caused a lockout. Errors of the repository are returned as well, a
+// failure that cannot be counted fails the login instead of going unnoticed.
+func (uu *UserUsecase) recordLoginFailure(ctx context.Context, keys []loginKey) error {
+	policy := uu.Options.Lockout
+	var locked *LockedError
+	for _, key := range keys {
+		if key.maxFailures <= 0 {
+			continue
+		}
+		attempt, err := uu.LoginAttempts.RecordLoginFailure(ctx, key.id, policy.Window)
+		if err != nil {
+			return err
+		}
+		if attempt.Failures < key.maxFailures {
+			continue
+		}
+		duration := lockoutDuration(policy, attempt.Lockouts)
+		if err := uu.LoginAttempts.LockLogin(ctx, key.id, time.Now().Add(duration)); err != nil {
+			return err
+		}
+		if locked == nil || duration > locked.RetryAfter {
+			locked = &LockedError{RetryAfter: duration}
+		}
+	}
+	if locked != nil {
+		return locked
+	}
+	return nil
+}
+
+// guardCredentials runs check, which verifies the password and maybe a second
+// factor of a signed in user, under the lockout of their account. Otherwise a
+// stolen session could guess them without ever being locked out. The
+// failures are only cleared once the whole check passed.
+func (uu *UserUsecase) guardCredentials(ctx context.Context, user *repository.User, check func() error) error {
+	keys := uu.loginKeys(user.Email, "")
+	if err := uu.checkLoginLocked(ctx, keys); err != nil {
+		return err
+	}
+	if err := check(); err != nil {
+		var wrong *Error
+		if errors.As(err, &wrong) && (wrong.Kind == KindUnauthorized || errors.Is(err, ErrWrongPassword)) {
+			if err := uu.recordLoginFailure(ctx, keys); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	uu.clearLoginFailures(ctx, keys)
+	return nil
+}
+
+func checkCurrentPassword(user *repository.User, password string) error {
+	if err := helper.CheckPasswordHash(password, user.Password); err != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// clearLoginFailures forgets the failures of every key after a successful
+// login. It does not fail the login.
+func (uu *UserUsecase) clearLoginFailures(ctx context.Context, keys []loginKey) {
+	for _, key := range keys {
+		uu.LoginAttempts.ClearLoginAttempts(ctx, key.id)
+	}
+}
+
+func lockoutDuration(policy LockoutPolicy, lockouts int) time.Duration {
+	duration := policy.BaseDuration
+	for i := 0; i < lockouts && duration < policy.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > policy.MaxDuration {
+		duration = policy.MaxDuration
+	}
+	return duration
+}
+
+// UnlockHandler clears failures and lockouts of an account, an address or
+// both.
+func (uu *UserUsecase) UnlockHandler(ctx context.Context, input *domains.Unlock) error {
+	if input.Email == "" && input.IP == "" {
+		return invalidField("email", "Email or IP is required!")
+	}
+	if input.Email != "" {
+		email, err := helper.NormalizeEmail(input.Email)
+		if err != nil {
+			return invalidField("email", err.Error())
+		}
+		if err := uu.LoginAttempts.ClearLoginAttempts(ctx, uu.loginKeys(email, "")[0].id); err != nil {
+			return err
+		}
+	}
+	if input.IP != "" {
+		if err := uu.LoginAttempts.ClearLoginAttempts(ctx, "ip:"+input.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package logic
+
+import (
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserUsecase_LoginLockout(t *testing.T) {
+	attempts := repository.NewMemoryLoginAttemptRepository()
+	guarded := userUsecase
+	guarded.LoginAttempts = attempts
+	guarded.Options.Lockout = LockoutPolicy{
+		MaxFailures:   3,
+		IPMaxFailures: 10,
+		Window:        time.Minute,
+		BaseDuration:  time.Minute,
+		MaxDuration:   time.Hour,
+	}
+
+	user := repository.User{ID: "locked_uuid", Email: "locked@gmail.com", Password: hashPassword("password")}
+	userRepository.Mock.On("FindByEmail", mock.Anything, user.Email).Return(user)
+	wrong := &domains.Login{Email: user.Email, Password: "wrong_password"}
+	right := &domains.Login{Email: user.Email, Password: "password"}
+
+	for i := 0; i < 2; i++ {
+		_, _, err := guarded.LoginHandler(context.Background(), wrong, "10.0.0.1")
+		assert.Equal(t, ErrInvalidCredentials, err)
+	}
+
+	_, _, err := guarded.LoginHandler(context.Background(), wrong, "10.0.0.1")
+	var locked *LockedError
+	assert.True(t, errors.As(err, &locked))
+	assert.Equal(t, time.Minute, locked.RetryAfter)
+
+	// The right password does not help while locked, from any address.
+	_, token, err := guarded.LoginHandler(context.Background(), right, "10.0.0.2")
+	assert.Nil(t, token)
+	assert.True(t, errors.As(err, &locked))
+
+	// An admin unlock clears the account.
+	assert.NoError(t, guarded.UnlockHandler(context.Background(), &domains.Unlock{Email: "LOCKED@gmail.com"}))
+	refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+	_, token, err = guarded.LoginHandler(context.Background(), right, "10.0.0.1")
+	assert.Nil(t, err)
+	assert.NotNil(t, token)
+	// The login clears the failures of the address as well.
+	assert.Nil(t, attempts.FindLoginAttempt(context.Background(), "ip:10.0.0.1"))
+}
+
+type failingLoginAttempts struct {
+	repository.LoginAttemptRepositoryInterface
+}
+
+func (failingLoginAttempts) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*repository.LoginAttempt, error) {
+	return nil, errors.New("Cannot record login failure!")
+}
+
+func TestUserUsecase_LoginLockoutFailsClosed(t *testing.T) {
+	guarded := userUsecase
+	guarded.LoginAttempts = failingLoginAttempts{repository.NewMemoryLoginAttemptRepository()}
+
+	user := repository.User{ID: "unrecorded_uuid", Email: "unrecorded@gmail.com", Password: hashPassword("password")}
+	userRepository.Mock.On("FindByEmail", mock.Anything, user.Email).Return(user).Once()
+
+	_, token, err := guarded.LoginHandler(context.Background(), &domains.Login{Email: user.Email, Password: "wrong_password"}, "10.0.0.3")
+
+	assert.Nil(t, token)
+	assert.EqualError(t, err, "Cannot record login failure!")
+}
+
+func TestUserUsecase_IPLockout(t *testing.T) {
+	guarded := userUsecase
+	guarded.LoginAttempts = repository.NewMemoryLoginAttemptRepository()
+	guarded.Options.Lockout = LockoutPolicy{
+		MaxFailures:   100,
+		IPMaxFailures: 3,
+		Window:        time.Minute,
+		BaseDuration:  time.Minute,
+		MaxDuration:   time.Hour,
+	}
+
+	// Spraying different accounts from one address still trips the lock.
+	for i, email := range []string{"spray1@gmail.com", "spray2@gmail.com", "spray3@gmail.com"} {
+		userRepository.Mock.On("FindByEmail", mock.Anything, email).Return(nil).Once()
+		_, _, err := guarded.LoginHandler(context.Background(), &domains.Login{Email: email, Password: "password"}, "10.0.0.9")
+		var locked *LockedError
+		assert.Equal(t, i == 2, errors.As(err, &locked))
+	}
+
+	_, _, err := guarded.LoginHandler(context.Background(), &domains.Login{Email: "spray4@gmail.com", Password: "password"}, "10.0.0.9")
+	var locked *LockedError
+	assert.True(t, errors.As(err, &locked))
+
+	assert.EqualError(t, guarded.UnlockHandler(context.Background(), &domains.Unlock{}), "Email or IP is required!")
+	assert.NoError(t, guarded.UnlockHandler(context.Background(), &domains.Unlock{IP: "10.0.0.9"}))
+	userRepository.Mock.On("FindByEmail", mock.Anything, "spray4@gmail.com").Return(nil).Once()
+	_, _, err = guarded.LoginHandler(context.Background(), &domains.Login{Email: "spray4@gmail.com", Password: "password"}, "10.0.0.9")
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestUserUsecase_ChangePasswordLockout(t *testing.T) {
+	attempts := repository.NewMemoryLoginAttemptRepository()
+	guarded := userUsecase
+	guarded.LoginAttempts = attempts
+	guarded.Options.Lockout = LockoutPolicy{
+		MaxFailures:  3,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  time.Hour,
+	}
+
+	user := repository.User{ID: "locked_change_uuid", Email: "locked_change@gmail.com", Password: hashPassword("password")}
+	principal := &domains.Principal{ID: user.ID, Email: user.Email}
+	userRepository.Mock.On("FindById", mock.Anything, user.ID).Return(user)
+	wrong := &domains.ChangePassword{CurrentPassword: "wrong_password", NewPassword: "new_password", PasswordConfirm: "new_password"}
+	right := &domains.ChangePassword{CurrentPassword: "password", NewPassword: "new_password", PasswordConfirm: "new_password"}
+
+	// A stolen session cannot guess the password without being locked out.
+	assert.Equal(t, ErrWrongPassword, guarded.ChangePasswordHandler(context.Background(), principal, wrong))
+	assert.Equal(t, ErrWrongPassword, guarded.ChangePasswordHandler(context.Background(), principal, wrong))
+	var locked *LockedError
+	assert.True(t, errors.As(guarded.ChangePasswordHandler(context.Background(), principal, wrong), &locked))
+	assert.True(t, errors.As(guarded.ChangePasswordHandler(context.Background(), principal, right), &locked))
+
+	// The right password clears the failures.
+	assert.NoError(t, guarded.UnlockHandler(context.Background(), &domains.Unlock{Email: user.Email}))
+	assert.Equal(t, ErrWrongPassword, guarded.ChangePasswordHandler(context.Background(), principal, wrong))
+	userRepository.Mock.On("UpdatePassword", mock.Anything, user.ID, mock.Anything).Return(nil).Once()
+	revocationRepository.Mock.On("RevokeUserTokens", mock.Anything, user.ID, mock.Anything).Return(nil).Once()
+	refreshTokenRepository.Mock.On("RevokeUserRefreshTokens", mock.Anything, user.ID).Return(nil).Once()
+	assert.NoError(t, guarded.ChangePasswordHandler(context.Background(), principal, right))
+	assert.Nil(t, attempts.FindLoginAttempt(context.Background(), "account:"+user.Email))
+}
+
+func TestLockoutDuration(t *testing.T) {
+	policy := LockoutPolicy{BaseDuration: time.Minute, MaxDuration: 10 * time.Minute}
+
+	assert.Equal(t, time.Minute, lockoutDuration(policy, 0))
+	assert.Equal(t, 2*time.Minute, lockoutDuration(policy, 1))
+	assert.Equal(t, 8*time.Minute, lockoutDuration(policy, 3))
+	assert.Equal(t, 10*time.Minute, lockoutDuration(policy, 4))
+	assert.Equal(t, 10*time.Minute, lockoutDuration(policy, 60))
+}
@@ -0,0 +1,208 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const recoveryCodeCount = 10
+
+var errInvalidMFAChallenge = newError(KindUnauthorized, "invalid_mfa_challenge", "Invalid or expired MFA challenge!")
+
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+)
+
+func (uu *UserUsecase) totpEnabled(ctx context.Context, userId string) bool {
+	secret := uu.MFA.FindMFASecret(ctx, userId)
+	return secret != nil && secret.ConfirmedAt != nil
+}
+
+// mfaMethods lists the second factors of the user. Logins need one of them
+// when the list is not empty, a registered passkey counts as one.
+func (uu *UserUsecase) mfaMethods(ctx context.Context, userId string) ([]string, error) {
+	var methods []string
+	if uu.totpEnabled(ctx, userId) {
+		methods = append(methods, MFAMethodTOTP)
+	}
+	credentials, err := uu.WebAuthn.UserWebAuthnCredentials(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) > 0 {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+	return methods, nil
+}
+
+// MFALoginHandler is the second step of a login with MFA. Failures count
+// towards the same lockout as wrong passwords, which is what keeps the six
+// digit codes from being guessed.
+func (uu *UserUsecase) MFALoginHandler(ctx context.Context, input *domains.MFALogin, clientIP string) (*repository.User, *domains.Token, error) {
+	claims, err := helper.ParseMFAChallengeToken(input.MFAToken)
+	if err != nil {
+		return nil, nil, errInvalidMFAChallenge
+	}
+	user, err := uu.Repository.FindById(ctx, claims.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, notFound("User not found!")
+	}
+	keys := uu.loginKeys(user.Email, clientIP)
+	if err := uu.checkLoginLocked(ctx, keys); err != nil {
+		return nil, nil, err
+	}
+	if err := uu.verifySecondFactor(ctx, user.ID, input.Code); err != nil {
+		if err := uu.recordLoginFailure(ctx, keys); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, err
+	}
+	uu.clearLoginFailures(ctx, keys)
+
+	token, err := uu.issueTokens(ctx, user, uuid.New().String(), "", time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, token, nil
+}
+
+// verifySecondFactor accepts a TOTP code or an unused recovery code.
+func (uu *UserUsecase) verifySecondFactor(ctx context.Context, userId, code string) error {
+	secret := uu.MFA.FindMFASecret(ctx, userId)
+	if secret == nil || secret.ConfirmedAt == nil {
+		return newError(KindConflict, "mfa_not_enabled", "MFA is not enabled!")
+	}
+	var err error
+	if step, ok := helper.ValidateTOTP(secret.Secret, code, time.Now()); ok {
+		err = uu.MFA.UseTOTPStep(ctx, userId, step)
+	} else if len(code) > 6 {
+		err = uu.MFA.UseRecoveryCode(ctx, userId, helper.HashToken(helper.NormalizeRecoveryCode(code)))
+	} else {
+		return ErrInvalidMFACode
+	}
+	// Codes that were used already are as good as wrong ones.
+	if errors.Is(err, repository.ErrConflict) || errors.Is(err, repository.ErrNotFound) {
+		return newError(KindUnauthorized, ErrInvalidMFACode.Code, err.Error())
+	}
+	return err
+}
+
+// EnrollMFAHandler creates a TOTP secret for the user. MFA is not enforced
+// until the enrollment is confirmed with a code from the authenticator, but
+// the secret is only handed out after a reauthentication, otherwise a stolen
+// session could enroll an authenticator of its own.
+func (uu *UserUsecase) EnrollMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.Reauthentication) (*domains.MFAEnrollment, error) {
+	if uu.totpEnabled(ctx, principal.ID) {
+		return nil, newError(KindConflict, "mfa_already_enabled", "MFA already enabled!")
+	}
+	user, err := uu.Repository.FindById(ctx, principal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, notFound("User not found!")
+	}
+	credentials, err := uu.WebAuthn.UserWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uu.reauthenticate(ctx, user, credentials, input); err != nil {
+		return nil, err
+	}
+	secret, err := helper.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := uu.MFA.SaveMFASecret(ctx, principal.ID, secret); err != nil {
+		return nil, err
+	}
+
+	uri := helper.TOTPURI(uu.Options.MFAIssuer, principal.Email, secret)
+	png, err := helper.QRCodePNG(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &domains.MFAEnrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// ConfirmMFAHandler enables MFA once the first code checks out and returns
+// the recovery codes. They are only ever shown here.
+func (uu *UserUsecase) ConfirmMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.MFACode) ([]string, error) {
+	secret := uu.MFA.FindMFASecret(ctx, principal.ID)
+	if secret == nil || secret.ConfirmedAt != nil {
+		return nil, newError(KindConflict, "no_pending_mfa_enrollment", "No pending MFA enrollment!")
+	}
+	step, ok := helper.ValidateTOTP(secret.Secret, input.Code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	if err := uu.MFA.ConfirmMFASecret(ctx, principal.ID); err != nil {
+		return nil, err
+	}
+	if err := uu.MFA.UseTOTPStep(ctx, principal.ID, step); err != nil {
+		return nil, err
+	}
+	return uu.newRecoveryCodes(ctx, principal.ID)
+}
+
+// DisableMFAHandler turns MFA off. It takes the password and a second factor
+// so a stolen session alone cannot downgrade the account.
+func (uu *UserUsecase) DisableMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.DisableMFA) error {
+	user, err := uu.Repository.FindById(ctx, principal.ID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return notFound("User not found!")
+	}
+	err = uu.guardCredentials(ctx, user, func() error {
+		if err := checkCurrentPassword(user, input.Password); err != nil {
+			return err
+		}
+		return uu.verifySecondFactor(ctx, user.ID, input.Code)
+	})
+	if err != nil {
+		return err
+	}
+	return uu.MFA.DeleteMFA(ctx, user.ID)
+}
+
+// RegenerateRecoveryCodesHandler replaces all recovery codes, used or not.
+func (uu *UserUsecase) RegenerateRecoveryCodesHandler(ctx context.Context, principal *domains.Principal, input *domains.MFACode) ([]string, error) {
+	if err := uu.verifySecondFactor(ctx, principal.ID, input.Code); err != nil {
+		return nil, err
+	}
+	return uu.newRecoveryCodes(ctx, principal.ID)
+}
+
+func (uu *UserUsecase) newRecoveryCodes(ctx context.Context, userId string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := helper.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = helper.HashToken(code)
+	}
+	if err := uu.MFA.ReplaceRecoveryCodes(ctx, userId, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
@@ -0,0 +1,185 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	mokz "api-auth/mock"
+	"api-auth/services/repository"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newMFAUsecase() (UserUsecase, *mokz.MFARepositoryMock) {
+	mfa := &mokz.MFARepositoryMock{Mock: mock.Mock{}}
+	usecase := userUsecase
+	usecase.MFA = mfa
+	usecase.LoginAttempts = repository.NewMemoryLoginAttemptRepository()
+	return usecase, mfa
+}
+
+func currentTOTP(secret string) string {
+	code, _ := helper.TOTPCode(secret, helper.TOTPStep(time.Now()))
+	return code
+}
+
+func TestUserUsecase_EnrollMFAHandler(t *testing.T) {
+	usecase, mfa := newMFAUsecase()
+	principal := &domains.Principal{ID: "mfa_uuid", Email: "mfa@gmail.com"}
+
+	userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(repository.User{ID: principal.ID, Email: principal.Email, Password: hashPassword("passwords")})
+	mfa.Mock.On("FindMFASecret", mock.Anything, principal.ID).Return(nil).Once()
+
+	_, err := usecase.EnrollMFAHandler(context.Background(), principal, &domains.Reauthentication{Password: "wrong"})
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	mfa.Mock.AssertNotCalled(t, "SaveMFASecret", mock.Anything, principal.ID, mock.Anything)
+
+	mfa.Mock.On("FindMFASecret", mock.Anything, principal.ID).Return(nil).Twice()
+	mfa.Mock.On("SaveMFASecret", mock.Anything, principal.ID, mock.Anything).Return(nil).Once()
+
+	enrollment, err := usecase.EnrollMFAHandler(context.Background(), principal, &domains.Reauthentication{Password: "passwords"})
+
+	assert.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	assert.Contains(t, enrollment.URI, "mfa@gmail.com")
+	assert.True(t, strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,"))
+	mfa.Mock.AssertCalled(t, "SaveMFASecret", mock.Anything, principal.ID, enrollment.Secret)
+
+	confirmed := time.Now()
+	mfa.Mock.On("FindMFASecret", mock.Anything, principal.ID).Return(repository.MFASecret{UserID: principal.ID, ConfirmedAt: &confirmed}).Once()
+
+	_, err = usecase.EnrollMFAHandler(context.Background(), principal, &domains.Reauthentication{Password: "passwords"})
+	assert.EqualError(t, err, "MFA already enabled!")
+}
+
+func TestUserUsecase_ConfirmMFAHandler(t *testing.T) {
+	usecase, mfa := newMFAUsecase()
+	principal := &domains.Principal{ID: "mfa_uuid", Email: "mfa@gmail.com"}
+	secret, _ := helper.GenerateTOTPSecret()
+	pending := repository.MFASecret{UserID: principal.ID, Secret: secret}
+
+	mfa.Mock.On("FindMFASecret", mock.Anything, principal.ID).Return(pending).Once()
+	_, err := usecase.ConfirmMFAHandler(context.Background(), principal, &domains.MFACode{Code: "000000x"})
+	assert.EqualError(t, err, "Invalid MFA code!")
+	mfa.Mock.AssertNotCalled(t, "ConfirmMFASecret", mock.Anything, principal.ID)
+
+	var stored []string
+	mfa.Mock.On("FindMFASecret", mock.Anything, principal.ID).Return(pending).Once()
+	mfa.Mock.On("ConfirmMFASecret", mock.Anything, principal.ID).Return(nil).Once()
+	mfa.Mock.On("UseTOTPStep", mock.Anything, principal.ID, mock.AnythingOfType("int64")).Return(nil).Once()
+	mfa.Mock.On("ReplaceRecoveryCodes", mock.Anything, principal.ID, mock.MatchedBy(func(hashes []string) bool {
+		stored = hashes
+		return true
+	})).Return(nil).Once()
+
+	codes, err := usecase.ConfirmMFAHandler(context.Background(), principal, &domains.MFACode{Code: currentTOTP(secret)})
+
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	for i, code := range codes {
+		assert.Equal(t, helper.HashToken(code), stored[i])
+	}
+}
+
+func TestUserUsecase_MFALogin(t *testing.T) {
+	usecase, mfa := newMFAUsecase()
+	secret, _ := helper.GenerateTOTPSecret()
+	confirmed := time.Now()
+	user := repository.User{ID: "mfa_login_uuid", Email: "mfa_login@gmail.com", Password: hashPassword("passwords")}
+
+	mfa.Mock.On("FindMFASecret", mock.Anything, user.ID).Return(repository.MFASecret{UserID: user.ID, Secret: secret, ConfirmedAt: &confirmed})
+	userRepository.Mock.On("FindByEmail", mock.Anything, user.Email).Return(user).Once()
+
+	_, challenge, err := usecase.LoginHandler(context.Background(), &domains.Login{Email: user.Email, Password: "passwords"}, "127.0.0.1")
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, challenge.MFAToken)
+	assert.Equal(t, []string{MFAMethodTOTP}, challenge.MFAMethods)
+	assert.Empty(t, challenge.AccessToken)
+	assert.Empty(t, challenge.RefreshToken)
+
+	userRepository.Mock.On("FindById", mock.Anything, user.ID).Return(user)
+
+	t.Run("totp", func(t *testing.T) {
+		mfa.Mock.On("UseTOTPStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(nil).Once()
+		refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+
+		_, token, err := usecase.MFALoginHandler(context.Background(), &domains.MFALogin{MFAToken: challenge.MFAToken, Code: currentTOTP(secret)}, "127.0.0.1")
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, token.AccessToken)
+		assert.NotEmpty(t, token.RefreshToken)
+	})
+
+	t.Run("replayed_totp", func(t *testing.T) {
+		mfa.Mock.On("UseTOTPStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(errors.New("")).Once()
+
+		_, token, err := usecase.MFALoginHandler(context.Background(), &domains.MFALogin{MFAToken: challenge.MFAToken, Code: currentTOTP(secret)}, "127.0.0.1")
+
+		assert.EqualError(t, err, "MFA code already used!")
+		assert.Nil(t, token)
+	})
+
+	t.Run("recovery_code", func(t *testing.T) {
+		mfa.Mock.On("UseRecoveryCode", mock.Anything, user.ID, helper.HashToken("abcde-fghij")).Return(nil).Once()
+		refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+
+		_, token, err := usecase.MFALoginHandler(context.Background(), &domains.MFALogin{MFAToken: challenge.MFAToken, Code: "ABCDEFGHIJ"}, "127.0.0.1")
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, token.AccessToken)
+	})
+
+	t.Run("not_a_challenge", func(t *testing.T) {
+		access, _ := helper.GenerateJWT(user.ID, user.Email)
+
+		_, _, err := usecase.MFALoginHandler(context.Background(), &domains.MFALogin{MFAToken: access, Code: currentTOTP(secret)}, "127.0.0.1")
+
+		assert.EqualError(t, err, "Invalid or expired MFA challenge!")
+	})
+
+	t.Run("guessing_locks_out", func(t *testing.T) {
+		mfa.Mock.On("UseRecoveryCode", mock.Anything, user.ID, mock.Anything).Return(errors.New(""))
+		var err error
+		for i := 0; i < usecase.Options.Lockout.MaxFailures; i++ {
+			_, _, err = usecase.MFALoginHandler(context.Background(), &domains.MFALogin{MFAToken: challenge.MFAToken, Code: "wrong-code"}, "127.0.0.1")
+		}
+		var locked *LockedError
+		assert.True(t, errors.As(err, &locked))
+
+		// The password step does not reset the count while MFA is pending.
+		userRepository.Mock.On("FindByEmail", mock.Anything, user.Email).Return(user).Once()
+		_, _, err = usecase.LoginHandler(context.Background(), &domains.Login{Email: user.Email, Password: "passwords"}, "127.0.0.1")
+		assert.True(t, errors.As(err, &locked))
+	})
+}
+
+func TestUserUsecase_DisableMFAHandler(t *testing.T) {
+	usecase, mfa := newMFAUsecase()
+	secret, _ := helper.GenerateTOTPSecret()
+	confirmed := time.Now()
+	principal := &domains.Principal{ID: "mfa_disable_uuid", Email: "mfa_disable@gmail.com"}
+	user := repository.User{ID: principal.ID, Email: principal.Email, Password: hashPassword("passwords")}
+
+	userRepository.Mock.On("FindById", mock.Anything, user.ID).Return(user)
+	mfa.Mock.On("FindMFASecret", mock.Anything, user.ID).Return(repository.MFASecret{UserID: user.ID, Secret: secret, ConfirmedAt: &confirmed})
+
+	err := usecase.DisableMFAHandler(context.Background(), principal, &domains.DisableMFA{Password: "wrong_password", Code: currentTOTP(secret)})
+	assert.EqualError(t, err, "Current password is wrong!")
+
+	err = usecase.DisableMFAHandler(context.Background(), principal, &domains.DisableMFA{Password: "passwords", Code: "123"})
+	assert.EqualError(t, err, "Invalid MFA code!")
+	mfa.Mock.AssertNotCalled(t, "DeleteMFA", mock.Anything, user.ID)
+
+	mfa.Mock.On("UseTOTPStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(nil).Once()
+	mfa.Mock.On("DeleteMFA", mock.Anything, user.ID).Return(nil).Once()
+
+	err = usecase.DisableMFAHandler(context.Background(), principal, &domains.DisableMFA{Password: "passwords", Code: currentTOTP(secret)})
+	assert.NoError(t, err)
+	mfa.Mock.AssertCalled(t, "DeleteMFA", mock.Anything, user.ID)
+}
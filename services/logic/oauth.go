@@ -0,0 +1,562 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+var supportedGrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials}
+
+// Scope tokens as defined in RFC 6749 section 3.3.
+var scopePattern = regexp.MustCompile(`^[\x21\x23-\x5B\x5D-\x7E]{1,64}$`)
+
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// OAuthError is an error response of RFC 6749. Code is the error code the
+// spec defines, e.g. "invalid_grant".
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+type OAuthUsecase struct {
+	Clients       repository.OAuthRepositoryInterface
+	Users         repository.UserRepositoryInterface
+	RefreshTokens repository.RefreshTokenRepositoryInterface
+	Revocations   repository.RevocationRepositoryInterface
+	Options       OAuthOptions
+}
+
+type OAuthOptions struct {
+	CodeTTL time.Duration
+	// Issuer and AuthorizationURL are published in the discovery document.
+	Issuer           string
+	AuthorizationURL string
+}
+
+type OAuthUsecaseInterface interface {
+	GetClients(ctx context.Context) ([]repository.OAuthClient, error)
+	CreateClientHandler(ctx context.Context, input *domains.CreateOAuthClient) (*domains.OAuthClientCredentials, error)
+	DeleteClientHandler(ctx context.Context, clientId string) error
+	AuthorizeHandler(ctx context.Context, principal *domains.Principal, input *domains.AuthorizeRequest) (*domains.AuthorizeResponse, error)
+	ConsentHandler(ctx context.Context, principal *domains.Principal, input *domains.AuthorizeConsent) (*domains.AuthorizeResponse, error)
+	TokenHandler(ctx context.Context, input *domains.TokenRequest) (*domains.OAuthToken, error)
+	DiscoveryHandler() *domains.OpenIDConfiguration
+	UserInfoHandler(ctx context.Context, principal *domains.Principal) (*domains.UserInfo, error)
+	IntrospectHandler(ctx context.Context, input *domains.TokenHint) (*domains.Introspection, error)
+	RevokeHandler(ctx context.Context, input *domains.TokenHint) error
+}
+
+func NewOAuthUsecase(Clients repository.OAuthRepositoryInterface, Users repository.UserRepositoryInterface, RefreshTokens repository.RefreshTokenRepositoryInterface, Revocations repository.RevocationRepositoryInterface, Options OAuthOptions) OAuthUsecaseInterface {
+	return &OAuthUsecase{
+		Clients:       Clients,
+		Users:         Users,
+		RefreshTokens: RefreshTokens,
+		Revocations:   Revocations,
+		Options:       Options,
+	}
+}
+
+func (ou *OAuthUsecase) GetClients(ctx context.Context) ([]repository.OAuthClient, error) {
+	return ou.Clients.OAuthClients(ctx)
+}
+
+// CreateClientHandler registers a client. Confidential clients get a secret
+// that is returned only here.
+func (ou *OAuthUsecase) CreateClientHandler(ctx context.Context, input *domains.CreateOAuthClient) (*domains.OAuthClientCredentials, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, oauthError("invalid_request", "Client name is required!")
+	}
+	grants := map[string]bool{}
+	for _, grant := range input.GrantTypes {
+		if !containsString(supportedGrantTypes, grant) {
+			return nil, oauthError("invalid_request", "Unsupported grant type "+grant+"!")
+		}
+		grants[grant] = true
+	}
+	if grants[GrantClientCredentials] && input.Public {
+		return nil, oauthError("invalid_request", "Public clients cannot use client_credentials!")
+	}
+	if grants[GrantRefreshToken] && !grants[GrantAuthorizationCode] {
+		return nil, oauthError("invalid_request", "refresh_token needs authorization_code!")
+	}
+	if grants[GrantAuthorizationCode] && len(input.RedirectURIs) == 0 {
+		return nil, oauthError("invalid_request", "authorization_code needs a redirect URI!")
+	}
+	for _, uri := range input.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, err
+		}
+	}
+	for _, scope := range input.Scopes {
+		if !scopePattern.MatchString(scope) {
+			return nil, oauthError("invalid_request", "Invalid scope "+scope+"!")
+		}
+	}
+
+	client := &repository.OAuthClient{
+		ID:           uuid.New().String(),
+		Name:         strings.TrimSpace(input.Name),
+		RedirectURIs: strings.Join(input.RedirectURIs, " "),
+		GrantTypes:   strings.Join(input.GrantTypes, " "),
+		Scopes:       strings.Join(input.Scopes, " "),
+	}
+	credentials := &domains.OAuthClientCredentials{ClientID: client.ID}
+	if !input.Public {
+		secret, hash, err := helper.GenerateOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+		client.SecretHash = hash
+		credentials.ClientSecret = secret
+	}
+	if err := ou.Clients.CreateOAuthClient(ctx, client); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (ou *OAuthUsecase) DeleteClientHandler(ctx context.Context, clientId string) error {
+	return ou.Clients.DeleteOAuthClient(ctx, clientId)
+}
+
+// validateRedirectURI accepts https URIs, http on the loopback interface for
+// development and private-use schemes of native apps like
+// "com.example.app:/callback".
+func validateRedirectURI(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil || !parsed.IsAbs() || parsed.Fragment != "" || strings.Contains(uri, "#") {
+		return oauthError("invalid_request", "Redirect URI "+uri+" must be absolute without a fragment!")
+	}
+	switch {
+	case parsed.Scheme == "https" && parsed.Host != "":
+	case parsed.Scheme == "http" && (parsed.Hostname() == "localhost" || parsed.Hostname() == "127.0.0.1" || parsed.Hostname() == "::1"):
+	case strings.Contains(parsed.Scheme, "."):
+	default:
+		return oauthError("invalid_request", "Redirect URI "+uri+" must use https!")
+	}
+	return nil
+}
+
+// AuthorizeHandler checks an authorization request for the logged in user.
+// When the user consented to the scopes before the code is issued right
+// away, otherwise the frontend shows a consent screen and answers through
+// ConsentHandler.
+func (ou *OAuthUsecase) AuthorizeHandler(ctx context.Context, principal *domains.Principal, input *domains.AuthorizeRequest) (*domains.AuthorizeResponse, error) {
+	client, err := ou.authorizeClient(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	scope, oauthErr := ou.authorizeParameters(client, input)
+	if oauthErr != nil {
+		return errorRedirect(input, oauthErr), nil
+	}
+
+	consent := ou.Clients.FindOAuthConsent(ctx, principal.ID, client.ID)
+	if consent != nil && scopeCovers(consent.Scope, scope) {
+		return ou.issueCode(ctx, principal, client, input, scope)
+	}
+	return &domains.AuthorizeResponse{
+		ConsentRequired: true,
+		ClientID:        client.ID,
+		ClientName:      client.Name,
+		Scopes:          strings.Fields(scope),
+	}, nil
+}
+
+// ConsentHandler records the decision of the user and sends the browser
+// back to the client, with a code when the user approved.
+func (ou *OAuthUsecase) ConsentHandler(ctx context.Context, principal *domains.Principal, input *domains.AuthorizeConsent) (*domains.AuthorizeResponse, error) {
+	request := &input.AuthorizeRequest
+	client, err := ou.authorizeClient(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	scope, oauthErr := ou.authorizeParameters(client, request)
+	if oauthErr != nil {
+		return errorRedirect(request, oauthErr), nil
+	}
+	if !input.Approve {
+		return errorRedirect(request, oauthError("access_denied", "The user denied access!")), nil
+	}
+
+	granted := scope
+	if consent := ou.Clients.FindOAuthConsent(ctx, principal.ID, client.ID); consent != nil {
+		granted = joinScopes(consent.Scope, scope)
+	}
+	if err := ou.Clients.SaveOAuthConsent(ctx, principal.ID, client.ID, granted); err != nil {
+		return nil, err
+	}
+	return ou.issueCode(ctx, principal, client, request, scope)
+}
+
+// authorizeClient checks the client and the redirect URI. Errors here must
+// not be sent to the redirect URI since it could belong to anyone.
+func (ou *OAuthUsecase) authorizeClient(ctx context.Context, input *domains.AuthorizeRequest) (*repository.OAuthClient, error) {
+	client := ou.Clients.FindOAuthClient(ctx, input.ClientID)
+	if client == nil {
+		return nil, oauthError("invalid_client", "Unknown client!")
+	}
+	// Redirect URIs are compared as plain strings, no normalization.
+	if input.RedirectURI == "" || !containsString(strings.Fields(client.RedirectURIs), input.RedirectURI) {
+		return nil, oauthError("invalid_request", "Redirect URI is not registered for the client!")
+	}
+	return client, nil
+}
+
+// authorizeParameters checks the rest of the request and returns the scope
+// to grant.
+func (ou *OAuthUsecase) authorizeParameters(client *repository.OAuthClient, input *domains.AuthorizeRequest) (string, *OAuthError) {
+	if input.ResponseType != "code" {
+		return "", oauthError("unsupported_response_type", "Only the code response type is supported!")
+	}
+	if !hasGrant(client, GrantAuthorizationCode) {
+		return "", oauthError("unauthorized_client", "Client cannot use authorization_code!")
+	}
+	if input.CodeChallenge == "" || input.CodeChallengeMethod != "S256" {
+		return "", oauthError("invalid_request", "PKCE with code_challenge_method S256 is required!")
+	}
+	if len(input.CodeChallenge) != 43 {
+		return "", oauthError("invalid_request", "Invalid code_challenge!")
+	}
+	if len(input.Nonce) > 255 {
+		return "", oauthError("invalid_request", "nonce is too long!")
+	}
+	return requestedScope(client, input.Scope)
+}
+
+func (ou *OAuthUsecase) issueCode(ctx context.Context, principal *domains.Principal, client *repository.OAuthClient, input *domains.AuthorizeRequest, scope string) (*domains.AuthorizeResponse, error) {
+	code, codeHash, err := helper.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	authTime := principal.AuthTime
+	if authTime.IsZero() {
+		authTime = now
+	}
+	err = ou.Clients.CreateAuthorizationCode(ctx, &repository.AuthorizationCode{
+		ID:            uuid.New().String(),
+		CodeHash:      codeHash,
+		ClientID:      client.ID,
+		UserID:        principal.ID,
+		RedirectURI:   input.RedirectURI,
+		Scope:         scope,
+		CodeChallenge: input.CodeChallenge,
+		Nonce:         input.Nonce,
+		AuthTime:      authTime,
+		ExpiresAt:     now.Add(ou.Options.CodeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domains.AuthorizeResponse{
+		RedirectTo: redirectWith(input.RedirectURI, url.Values{"code": {code}, "state": {input.State}}),
+	}, nil
+}
+
+func errorRedirect(input *domains.AuthorizeRequest, err *OAuthError) *domains.AuthorizeResponse {
+	return &domains.AuthorizeResponse{
+		RedirectTo: redirectWith(input.RedirectURI, url.Values{
+			"error":             {err.Code},
+			"error_description": {err.Description},
+			"state":             {input.State},
+		}),
+	}
+}
+
+// redirectWith adds the parameters to the query of the redirect URI, keeping
+// the query it was registered with.
+func redirectWith(redirectURI string, params url.Values) string {
+	parsed, _ := url.Parse(redirectURI)
+	query := parsed.Query()
+	for key, values := range params {
+		if values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// TokenHandler is the token endpoint.
+func (ou *OAuthUsecase) TokenHandler(ctx context.Context, input *domains.TokenRequest) (*domains.OAuthToken, error) {
+	if !containsString(supportedGrantTypes, input.GrantType) {
+		return nil, oauthError("unsupported_grant_type", "Unsupported grant type!")
+	}
+	client, err := ou.authenticateClient(ctx, input.ClientID, input.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !hasGrant(client, input.GrantType) {
+		return nil, oauthError("unauthorized_client", "Client cannot use "+input.GrantType+"!")
+	}
+
+	switch input.GrantType {
+	case GrantAuthorizationCode:
+		return ou.exchangeCode(ctx, client, input)
+	case GrantRefreshToken:
+		return ou.refreshToken(ctx, client, input)
+	default:
+		return ou.clientCredentials(client, input)
+	}
+}
+
+// authenticateClient checks the secret of confidential clients. Public
+// clients only identify themselves, PKCE is what protects their codes.
+func (ou *OAuthUsecase) authenticateClient(ctx context.Context, clientId, secret string) (*repository.OAuthClient, error) {
+	invalid := oauthError("invalid_client", "Client authentication failed!")
+	if clientId == "" {
+		return nil, invalid
+	}
+	client := ou.Clients.FindOAuthClient(ctx, clientId)
+	if client == nil {
+		return nil, invalid
+	}
+	if client.SecretHash != "" && subtle.ConstantTimeCompare([]byte(helper.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, invalid
+	}
+	return client, nil
+}
+
+// exchangeCode redeems an authorization code. A code that is presented a
+// second time was most likely stolen, so the refresh tokens issued for it
+// are revoked.
+func (ou *OAuthUsecase) exchangeCode(ctx context.Context, client *repository.OAuthClient, input *domains.TokenRequest) (*domains.OAuthToken, error) {
+	if input.Code == "" || input.CodeVerifier == "" {
+		return nil, oauthError("invalid_request", "code and code_verifier are required!")
+	}
+	code := ou.Clients.FindAuthorizationCode(ctx, helper.HashToken(input.Code))
+	if code == nil || code.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "Invalid authorization code!")
+	}
+	if code.UsedAt != nil {
+		ou.RefreshTokens.RevokeRefreshTokenFamily(ctx, code.ID)
+		return nil, oauthError("invalid_grant", "Authorization code already used!")
+	}
+	if time.Now().After(code.ExpiresAt) {
+		return nil, oauthError("invalid_grant", "Authorization code expired!")
+	}
+	if code.RedirectURI != input.RedirectURI {
+		return nil, oauthError("invalid_grant", "Redirect URI does not match the authorization request!")
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, input.CodeVerifier) {
+		return nil, oauthError("invalid_grant", "Invalid code_verifier!")
+	}
+	if err := ou.Clients.UseAuthorizationCode(ctx, code.ID); errors.Is(err, repository.ErrConflict) {
+		ou.RefreshTokens.RevokeRefreshTokenFamily(ctx, code.ID)
+		return nil, oauthError("invalid_grant", err.Error())
+	} else if err != nil {
+		return nil, err
+	}
+
+	user, err := ou.Users.FindById(ctx, code.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, oauthError("invalid_grant", "User not found!")
+	}
+	// The refresh tokens of the code form one family named after it.
+	token, err := ou.issueTokens(ctx, client, user, code.Scope, code.Scope, code.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	if containsString(strings.Fields(code.Scope), ScopeOpenID) {
+		if token.IDToken, err = ou.idToken(client, user, code); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+// refreshToken rotates a refresh token of the client like RefreshHandler
+// does for first party logins. A narrower scope may be asked for, the new
+// refresh token keeps the original one.
+func (ou *OAuthUsecase) refreshToken(ctx context.Context, client *repository.OAuthClient, input *domains.TokenRequest) (*domains.OAuthToken, error) {
+	current := ou.RefreshTokens.FindRefreshTokenByHash(ctx, helper.HashToken(input.RefreshToken))
+	if current == nil || current.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "Invalid refresh token!")
+	}
+	if current.RevokedAt != nil {
+		ou.RefreshTokens.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+		return nil, oauthError("invalid_grant", "Refresh token reused, please login again!")
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, oauthError("invalid_grant", "Refresh token expired!")
+	}
+	scope := current.Scope
+	if input.Scope != "" {
+		if !scopeCovers(current.Scope, input.Scope) {
+			return nil, oauthError("invalid_scope", "Scope exceeds the original grant!")
+		}
+		scope = joinScopes(input.Scope)
+	}
+
+	user, err := ou.Users.FindById(ctx, current.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		ou.RefreshTokens.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+		return nil, oauthError("invalid_grant", "User not found!")
+	}
+	return ou.issueTokens(ctx, client, user, scope, current.Scope, current.FamilyID, current.ID)
+}
+
+// clientCredentials issues a token to a confidential client acting on its
+// own behalf. There is no user and no refresh token.
+func (ou *OAuthUsecase) clientCredentials(client *repository.OAuthClient, input *domains.TokenRequest) (*domains.OAuthToken, error) {
+	if client.SecretHash == "" {
+		return nil, oauthError("unauthorized_client", "Public clients cannot use client_credentials!")
+	}
+	scope, err := requestedScope(client, input.Scope)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, genErr := helper.GenerateOAuthJWT("", "", client.ID, scope)
+	if genErr != nil {
+		return nil, genErr
+	}
+	return &domains.OAuthToken{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(helper.AccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+func (ou *OAuthUsecase) issueTokens(ctx context.Context, client *repository.OAuthClient, user *repository.User, scope, refreshScope, familyId, previousId string) (*domains.OAuthToken, error) {
+	// The email is only part of the token when the user shared it.
+	email := ""
+	if containsString(strings.Fields(scope), ScopeEmail) {
+		email = user.Email
+	}
+	accessToken, err := helper.GenerateOAuthJWT(user.ID, email, client.ID, scope)
+	if err != nil {
+		return nil, err
+	}
+	token := &domains.OAuthToken{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(helper.AccessTokenTTL.Seconds()),
+		Scope:       scope,
+	}
+	if !hasGrant(client, GrantRefreshToken) {
+		return token, nil
+	}
+
+	refreshToken, refreshHash, err := helper.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	newRefresh := &repository.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		FamilyID:  familyId,
+		TokenHash: refreshHash,
+		ClientID:  client.ID,
+		Scope:     refreshScope,
+		ExpiresAt: time.Now().Add(helper.RefreshTokenTTL),
+	}
+	if previousId != "" {
+		if err := ou.RefreshTokens.RotateRefreshToken(ctx, previousId, newRefresh.ID); err != nil {
+			ou.RefreshTokens.RevokeRefreshTokenFamily(ctx, familyId)
+			return nil, oauthError("invalid_grant", "Refresh token reused, please login again!")
+		}
+	}
+	if err := ou.RefreshTokens.CreateRefreshToken(ctx, newRefresh); err != nil {
+		return nil, err
+	}
+	token.RefreshToken = refreshToken
+	return token, nil
+}
+
+// verifyCodeChallenge checks a PKCE verifier against the S256 challenge of
+// the authorization request.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// requestedScope checks the requested scopes against the ones the client is
+// allowed. Without a scope parameter all of them are granted.
+func requestedScope(client *repository.OAuthClient, requested string) (string, *OAuthError) {
+	if requested == "" {
+		return client.Scopes, nil
+	}
+	allowed := strings.Fields(client.Scopes)
+	for _, scope := range strings.Fields(requested) {
+		if !containsString(allowed, scope) {
+			return "", oauthError("invalid_scope", "Scope "+scope+" is not allowed for the client!")
+		}
+	}
+	return joinScopes(requested), nil
+}
+
+func hasGrant(client *repository.OAuthClient, grant string) bool {
+	return containsString(strings.Fields(client.GrantTypes), grant)
+}
+
+// scopeCovers reports whether every scope of requested is in granted.
+func scopeCovers(granted, requested string) bool {
+	scopes := strings.Fields(granted)
+	for _, scope := range strings.Fields(requested) {
+		if !containsString(scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// joinScopes merges scope strings without duplicates, keeping their order.
+func joinScopes(scopes ...string) string {
+	var merged []string
+	for _, scope := range scopes {
+		for _, name := range strings.Fields(scope) {
+			if !containsString(merged, name) {
+				merged = append(merged, name)
+			}
+		}
+	}
+	return strings.Join(merged, " ")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
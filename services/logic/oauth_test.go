@@ -0,0 +1,367 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	mokz "api-auth/mock"
+	"api-auth/services/repository"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const codeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+var publicClient = repository.OAuthClient{
+	ID:           "spa_client",
+	Name:         "Frontend",
+	RedirectURIs: "https://app.example.com/callback http://localhost:8080/callback",
+	GrantTypes:   "authorization_code refresh_token",
+	Scopes:       "openid profile email",
+}
+
+var confidentialClient = repository.OAuthClient{
+	ID:         "service_client",
+	Name:       "Reporting",
+	SecretHash: helper.HashToken("service_secret"),
+	GrantTypes: "client_credentials",
+	Scopes:     "reports:read reports:write",
+}
+
+func newOAuthUsecase() (*OAuthUsecase, *mokz.OAuthRepositoryMock, *mokz.RefreshTokenRepositoryMock) {
+	clients := &mokz.OAuthRepositoryMock{Mock: mock.Mock{}}
+	refreshTokens := &mokz.RefreshTokenRepositoryMock{Mock: mock.Mock{}}
+	clients.Mock.On("FindOAuthClient", mock.Anything, publicClient.ID).Return(publicClient)
+	clients.Mock.On("FindOAuthClient", mock.Anything, confidentialClient.ID).Return(confidentialClient)
+	clients.Mock.On("FindOAuthClient", mock.Anything, mock.Anything).Return(nil)
+	usecase := &OAuthUsecase{
+		Clients:       clients,
+		Users:         userRepository,
+		RefreshTokens: refreshTokens,
+		Revocations:   repository.NewMemoryRevocationRepository(),
+		Options: OAuthOptions{
+			CodeTTL:          time.Minute,
+			Issuer:           "https://auth.example.com",
+			AuthorizationURL: "https://app.example.com/authorize",
+		},
+	}
+	return usecase, clients, refreshTokens
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorizeRequest() domains.AuthorizeRequest {
+	return domains.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            publicClient.ID,
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "openid email",
+		State:               "xyz",
+		CodeChallenge:       codeChallenge(codeVerifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func TestOAuthUsecase_AuthorizationCodeFlow(t *testing.T) {
+	usecase, clients, refreshTokens := newOAuthUsecase()
+	principal := &domains.Principal{ID: "oauth_user_uuid", Email: "oauth@gmail.com"}
+	userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(repository.User{ID: principal.ID, Email: principal.Email})
+	request := authorizeRequest()
+
+	clients.Mock.On("FindOAuthConsent", mock.Anything, principal.ID, publicClient.ID).Return(nil).Twice()
+
+	response, err := usecase.AuthorizeHandler(context.Background(), principal, &request)
+
+	assert.NoError(t, err)
+	assert.True(t, response.ConsentRequired)
+	assert.Equal(t, []string{"openid", "email"}, response.Scopes)
+	assert.Empty(t, response.RedirectTo)
+
+	var stored repository.AuthorizationCode
+	clients.Mock.On("SaveOAuthConsent", mock.Anything, principal.ID, publicClient.ID, "openid email").Return(nil).Once()
+	clients.Mock.On("CreateAuthorizationCode", mock.Anything, mock.MatchedBy(func(code *repository.AuthorizationCode) bool {
+		stored = *code
+		return true
+	})).Return(nil).Once()
+
+	response, err = usecase.ConsentHandler(context.Background(), principal, &domains.AuthorizeConsent{AuthorizeRequest: request, Approve: true})
+
+	assert.NoError(t, err)
+	redirect, _ := url.Parse(response.RedirectTo)
+	assert.Equal(t, "app.example.com", redirect.Host)
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+	code := redirect.Query().Get("code")
+	assert.Equal(t, helper.HashToken(code), stored.CodeHash)
+	assert.Equal(t, principal.ID, stored.UserID)
+
+	exchange := &domains.TokenRequest{
+		GrantType:    GrantAuthorizationCode,
+		Code:         code,
+		RedirectURI:  request.RedirectURI,
+		CodeVerifier: codeVerifier,
+		ClientID:     publicClient.ID,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		clients.Mock.On("FindAuthorizationCode", mock.Anything, stored.CodeHash).Return(stored).Once()
+		clients.Mock.On("UseAuthorizationCode", mock.Anything, stored.ID).Return(nil).Once()
+		refreshTokens.Mock.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(token *repository.RefreshToken) bool {
+			return token.FamilyID == stored.ID && token.ClientID == publicClient.ID && token.Scope == "openid email"
+		})).Return(nil).Once()
+
+		token, err := usecase.TokenHandler(context.Background(), exchange)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", token.TokenType)
+		assert.Equal(t, "openid email", token.Scope)
+		assert.NotEmpty(t, token.RefreshToken)
+		claims, err := helper.ParseJWT(token.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, principal.ID, claims.Subject)
+		assert.Equal(t, publicClient.ID, claims.ClientID)
+		assert.Equal(t, principal.Email, claims.Email)
+		assert.Empty(t, claims.Roles)
+	})
+
+	t.Run("code_reused", func(t *testing.T) {
+		used := stored
+		usedAt := time.Now()
+		used.UsedAt = &usedAt
+		clients.Mock.On("FindAuthorizationCode", mock.Anything, stored.CodeHash).Return(used).Once()
+		refreshTokens.Mock.On("RevokeRefreshTokenFamily", mock.Anything, stored.ID).Return(nil).Once()
+
+		_, err := usecase.TokenHandler(context.Background(), exchange)
+
+		assert.Equal(t, oauthError("invalid_grant", "Authorization code already used!"), err)
+		refreshTokens.Mock.AssertCalled(t, "RevokeRefreshTokenFamily", mock.Anything, stored.ID)
+	})
+
+	t.Run("consent_remembered", func(t *testing.T) {
+		clients.Mock.On("FindOAuthConsent", mock.Anything, principal.ID, publicClient.ID).Return(repository.OAuthConsent{Scope: "openid email profile"}).Once()
+		clients.Mock.On("CreateAuthorizationCode", mock.Anything, mock.Anything).Return(nil).Once()
+
+		response, err := usecase.AuthorizeHandler(context.Background(), principal, &request)
+
+		assert.NoError(t, err)
+		assert.False(t, response.ConsentRequired)
+		assert.Contains(t, response.RedirectTo, "code=")
+	})
+}
+
+func TestOAuthUsecase_AuthorizeRejects(t *testing.T) {
+	usecase, _, _ := newOAuthUsecase()
+	principal := &domains.Principal{ID: "oauth_user_uuid"}
+
+	t.Run("not_redirected", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			modify func(*domains.AuthorizeRequest)
+			code   string
+		}{
+			{name: "unknown_client", modify: func(r *domains.AuthorizeRequest) { r.ClientID = "nope" }, code: "invalid_client"},
+			{name: "missing_redirect_uri", modify: func(r *domains.AuthorizeRequest) { r.RedirectURI = "" }, code: "invalid_request"},
+			{name: "redirect_uri_not_exact", modify: func(r *domains.AuthorizeRequest) { r.RedirectURI += "/" }, code: "invalid_request"},
+			{name: "redirect_uri_with_query", modify: func(r *domains.AuthorizeRequest) { r.RedirectURI += "?next=evil" }, code: "invalid_request"},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				request := authorizeRequest()
+				test.modify(&request)
+
+				response, err := usecase.AuthorizeHandler(context.Background(), principal, &request)
+
+				assert.Nil(t, response)
+				assert.Equal(t, test.code, err.(*OAuthError).Code)
+			})
+		}
+	})
+
+	t.Run("redirected", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			modify func(*domains.AuthorizeRequest)
+			code   string
+		}{
+			{name: "implicit_flow", modify: func(r *domains.AuthorizeRequest) { r.ResponseType = "token" }, code: "unsupported_response_type"},
+			{name: "missing_pkce", modify: func(r *domains.AuthorizeRequest) { r.CodeChallenge = "" }, code: "invalid_request"},
+			{name: "plain_pkce", modify: func(r *domains.AuthorizeRequest) { r.CodeChallengeMethod = "plain" }, code: "invalid_request"},
+			{name: "scope_not_allowed", modify: func(r *domains.AuthorizeRequest) { r.Scope = "openid admin" }, code: "invalid_scope"},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				request := authorizeRequest()
+				test.modify(&request)
+
+				response, err := usecase.AuthorizeHandler(context.Background(), principal, &request)
+
+				assert.NoError(t, err)
+				redirect, _ := url.Parse(response.RedirectTo)
+				assert.Equal(t, test.code, redirect.Query().Get("error"))
+				assert.Equal(t, "xyz", redirect.Query().Get("state"))
+			})
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		response, err := usecase.ConsentHandler(context.Background(), principal, &domains.AuthorizeConsent{AuthorizeRequest: authorizeRequest()})
+
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(response.RedirectTo, "https://app.example.com/callback?"))
+		assert.Contains(t, response.RedirectTo, "error=access_denied")
+	})
+}
+
+func TestOAuthUsecase_ExchangeCodeRejects(t *testing.T) {
+	usecase, clients, _ := newOAuthUsecase()
+	code := repository.AuthorizationCode{
+		ID:            "code_uuid",
+		CodeHash:      helper.HashToken("the_code"),
+		ClientID:      publicClient.ID,
+		UserID:        "oauth_user_uuid",
+		RedirectURI:   "https://app.example.com/callback",
+		CodeChallenge: codeChallenge(codeVerifier),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	clients.Mock.On("FindAuthorizationCode", mock.Anything, code.CodeHash).Return(code)
+	clients.Mock.On("FindAuthorizationCode", mock.Anything, mock.Anything).Return(nil)
+
+	tests := []struct {
+		name        string
+		modify      func(*domains.TokenRequest)
+		description string
+	}{
+		{name: "wrong_verifier", modify: func(r *domains.TokenRequest) { r.CodeVerifier = strings.Repeat("a", 43) }, description: "Invalid code_verifier!"},
+		{name: "missing_verifier", modify: func(r *domains.TokenRequest) { r.CodeVerifier = "" }, description: "code and code_verifier are required!"},
+		{name: "other_redirect_uri", modify: func(r *domains.TokenRequest) { r.RedirectURI = "http://localhost:8080/callback" }, description: "Redirect URI does not match the authorization request!"},
+		{name: "unknown_code", modify: func(r *domains.TokenRequest) { r.Code = "other" }, description: "Invalid authorization code!"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := &domains.TokenRequest{
+				GrantType:    GrantAuthorizationCode,
+				Code:         "the_code",
+				RedirectURI:  code.RedirectURI,
+				CodeVerifier: codeVerifier,
+				ClientID:     publicClient.ID,
+			}
+			test.modify(request)
+
+			_, err := usecase.TokenHandler(context.Background(), request)
+
+			assert.Equal(t, test.description, err.Error())
+		})
+	}
+	clients.Mock.AssertNotCalled(t, "UseAuthorizationCode", mock.Anything, mock.Anything)
+}
+
+func TestOAuthUsecase_ClientCredentials(t *testing.T) {
+	usecase, _, _ := newOAuthUsecase()
+
+	t.Run("wrong_secret", func(t *testing.T) {
+		_, err := usecase.TokenHandler(context.Background(), &domains.TokenRequest{GrantType: GrantClientCredentials, ClientID: confidentialClient.ID, ClientSecret: "guess"})
+
+		assert.Equal(t, "invalid_client", err.(*OAuthError).Code)
+	})
+
+	t.Run("grant_not_allowed", func(t *testing.T) {
+		_, err := usecase.TokenHandler(context.Background(), &domains.TokenRequest{GrantType: GrantClientCredentials, ClientID: publicClient.ID})
+
+		assert.Equal(t, "unauthorized_client", err.(*OAuthError).Code)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		token, err := usecase.TokenHandler(context.Background(), &domains.TokenRequest{GrantType: GrantClientCredentials, ClientID: confidentialClient.ID, ClientSecret: "service_secret", Scope: "reports:read"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "reports:read", token.Scope)
+		assert.Empty(t, token.RefreshToken)
+		claims := &helper.Claims{}
+		assert.NoError(t, helper.VerifyToken(token.AccessToken, claims))
+		assert.Equal(t, confidentialClient.ID, claims.Subject)
+		assert.Empty(t, claims.ID)
+	})
+}
+
+func TestOAuthUsecase_RefreshToken(t *testing.T) {
+	usecase, _, refreshTokens := newOAuthUsecase()
+	userRepository.Mock.On("FindById", mock.Anything, "oauth_refresh_uuid").Return(repository.User{ID: "oauth_refresh_uuid"})
+	current := repository.RefreshToken{
+		ID:        "refresh_uuid",
+		UserID:    "oauth_refresh_uuid",
+		FamilyID:  "family_uuid",
+		ClientID:  publicClient.ID,
+		Scope:     "openid email",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	refreshTokens.Mock.On("FindRefreshTokenByHash", mock.Anything, helper.HashToken("oauth_refresh")).Return(current)
+	refreshTokens.Mock.On("FindRefreshTokenByHash", mock.Anything, helper.HashToken("first_party_refresh")).Return(repository.RefreshToken{ID: "session", UserID: "oauth_refresh_uuid"})
+
+	t.Run("first_party_token", func(t *testing.T) {
+		_, err := usecase.TokenHandler(context.Background(), &domains.TokenRequest{GrantType: GrantRefreshToken, ClientID: publicClient.ID, RefreshToken: "first_party_refresh"})
+
+		assert.Equal(t, oauthError("invalid_grant", "Invalid refresh token!"), err)
+	})
+
+	t.Run("wider_scope", func(t *testing.T) {
+		_, err := usecase.TokenHandler(context.Background(), &domains.TokenRequest{GrantType: GrantRefreshToken, ClientID: publicClient.ID, RefreshToken: "oauth_refresh", Scope: "openid profile"})
+
+		assert.Equal(t, "invalid_scope", err.(*OAuthError).Code)
+	})
+
+	t.Run("narrower_scope", func(t *testing.T) {
+		refreshTokens.Mock.On("RotateRefreshToken", mock.Anything, current.ID, mock.Anything).Return(nil).Once()
+		refreshTokens.Mock.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(token *repository.RefreshToken) bool {
+			return token.FamilyID == current.FamilyID && token.Scope == current.Scope
+		})).Return(nil).Once()
+
+		token, err := usecase.TokenHandler(context.Background(), &domains.TokenRequest{GrantType: GrantRefreshToken, ClientID: publicClient.ID, RefreshToken: "oauth_refresh", Scope: "openid"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "openid", token.Scope)
+		assert.NotEmpty(t, token.RefreshToken)
+	})
+}
+
+func TestOAuthUsecase_CreateClientHandler(t *testing.T) {
+	usecase, clients, _ := newOAuthUsecase()
+
+	invalid := []domains.CreateOAuthClient{
+		{Name: "plain http", GrantTypes: []string{GrantAuthorizationCode}, RedirectURIs: []string{"http://app.example.com/callback"}},
+		{Name: "fragment", GrantTypes: []string{GrantAuthorizationCode}, RedirectURIs: []string{"https://app.example.com/callback#x"}},
+		{Name: "no redirect", GrantTypes: []string{GrantAuthorizationCode}},
+		{Name: "public service", GrantTypes: []string{GrantClientCredentials}, Public: true},
+		{Name: "implicit", GrantTypes: []string{"implicit"}},
+	}
+	for _, input := range invalid {
+		_, err := usecase.CreateClientHandler(context.Background(), &input)
+		assert.Error(t, err, input.Name)
+	}
+
+	var created repository.OAuthClient
+	clients.Mock.On("CreateOAuthClient", mock.Anything, mock.MatchedBy(func(client *repository.OAuthClient) bool {
+		created = *client
+		return true
+	})).Return(nil).Once()
+
+	credentials, err := usecase.CreateClientHandler(context.Background(), &domains.CreateOAuthClient{
+		Name:         "Mobile",
+		GrantTypes:   []string{GrantAuthorizationCode, GrantRefreshToken},
+		RedirectURIs: []string{"com.example.app:/callback", "http://127.0.0.1:5000/cb"},
+		Scopes:       []string{"openid", "profile"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, credentials.ClientID)
+	assert.Equal(t, helper.HashToken(credentials.ClientSecret), created.SecretHash)
+	assert.Equal(t, "com.example.app:/callback http://127.0.0.1:5000/cb", created.RedirectURIs)
+}
@@ -0,0 +1,99 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Scopes of OpenID Connect. Without openid a client gets a plain OAuth
+// access token and no id token.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// DiscoveryHandler builds the OpenID Connect discovery document. The
+// endpoints are relative to the configured issuer, except for the
+// authorization endpoint which is a page of the frontend.
+func (ou *OAuthUsecase) DiscoveryHandler() *domains.OpenIDConfiguration {
+	issuer := ou.Options.Issuer
+	return &domains.OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             ou.Options.AuthorizationURL,
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{ScopeOpenID, ScopeProfile, ScopeEmail},
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               supportedGrantTypes,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  helper.CurrentKeyRing().Algorithms(),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp",
+			"name", "email", "email_verified",
+		},
+	}
+}
+
+// UserInfoHandler returns the claims of the user for an access token that
+// was granted the openid scope.
+func (ou *OAuthUsecase) UserInfoHandler(ctx context.Context, principal *domains.Principal) (*domains.UserInfo, error) {
+	if principal.ClientID == "" || !containsString(strings.Fields(principal.Scope), ScopeOpenID) {
+		return nil, oauthError("insufficient_scope", "The token was not granted the openid scope!")
+	}
+	user, err := ou.Users.FindById(ctx, principal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, oauthError("invalid_token", "User not found!")
+	}
+	return userInfo(user, principal.Scope), nil
+}
+
+// idToken signs the id token for a redeemed authorization code.
+func (ou *OAuthUsecase) idToken(client *repository.OAuthClient, user *repository.User, code *repository.AuthorizationCode) (string, error) {
+	info := userInfo(user, code.Scope)
+	now := time.Now()
+	return helper.SignToken(helper.IDTokenClaims{
+		Nonce:           code.Nonce,
+		AuthTime:        jwt.NewNumericDate(code.AuthTime),
+		AuthorizedParty: client.ID,
+		Name:            info.Name,
+		Email:           info.Email,
+		EmailVerified:   info.EmailVerified,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ou.Options.Issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{client.ID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(helper.AccessTokenTTL)),
+		},
+	})
+}
+
+// userInfo releases the claims covered by the scope, the subject is always
+// included.
+func userInfo(user *repository.User, scope string) *domains.UserInfo {
+	scopes := strings.Fields(scope)
+	info := &domains.UserInfo{Subject: user.ID}
+	if containsString(scopes, ScopeProfile) {
+		info.Name = user.Name
+	}
+	if containsString(scopes, ScopeEmail) {
+		verified := user.EmailVerifiedAt != nil
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	return info
+}
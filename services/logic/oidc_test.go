@@ -0,0 +1,122 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOAuthUsecase_IDToken(t *testing.T) {
+	usecase, clients, refreshTokens := newOAuthUsecase()
+	verifiedAt := time.Now()
+	userRepository.Mock.On("FindById", mock.Anything, "oidc_user_uuid").Return(repository.User{ID: "oidc_user_uuid", Name: "kale", Email: "oidc@gmail.com", EmailVerifiedAt: &verifiedAt})
+	refreshTokens.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	clients.Mock.On("UseAuthorizationCode", mock.Anything, mock.Anything).Return(nil)
+
+	exchange := func(code string, scope string) *domains.OAuthToken {
+		clients.Mock.On("FindAuthorizationCode", mock.Anything, helper.HashToken(code)).Return(repository.AuthorizationCode{
+			ID:            code + "_uuid",
+			ClientID:      publicClient.ID,
+			UserID:        "oidc_user_uuid",
+			RedirectURI:   "https://app.example.com/callback",
+			Scope:         scope,
+			CodeChallenge: codeChallenge(codeVerifier),
+			Nonce:         "n-0S6_WzA2Mj",
+			AuthTime:      time.Unix(1700000000, 0),
+			ExpiresAt:     time.Now().Add(time.Minute),
+		}).Once()
+
+		token, err := usecase.TokenHandler(context.Background(), &domains.TokenRequest{
+			GrantType:    GrantAuthorizationCode,
+			Code:         code,
+			RedirectURI:  "https://app.example.com/callback",
+			CodeVerifier: codeVerifier,
+			ClientID:     publicClient.ID,
+		})
+		assert.NoError(t, err)
+		return token
+	}
+
+	t.Run("openid_email", func(t *testing.T) {
+		token := exchange("email_code", "openid email")
+
+		claims := &helper.IDTokenClaims{}
+		assert.NoError(t, helper.VerifyToken(token.IDToken, claims))
+		assert.Equal(t, "https://auth.example.com", claims.Issuer)
+		assert.Equal(t, "oidc_user_uuid", claims.Subject)
+		assert.Equal(t, []string{publicClient.ID}, []string(claims.Audience))
+		assert.Equal(t, "n-0S6_WzA2Mj", claims.Nonce)
+		assert.Equal(t, int64(1700000000), claims.AuthTime.Unix())
+		assert.Equal(t, "oidc@gmail.com", claims.Email)
+		assert.True(t, *claims.EmailVerified)
+		assert.Empty(t, claims.Name)
+	})
+
+	t.Run("openid_profile", func(t *testing.T) {
+		token := exchange("profile_code", "openid profile")
+
+		claims := &helper.IDTokenClaims{}
+		assert.NoError(t, helper.VerifyToken(token.IDToken, claims))
+		assert.Equal(t, "kale", claims.Name)
+		assert.Empty(t, claims.Email)
+		assert.Nil(t, claims.EmailVerified)
+	})
+
+	t.Run("without_openid", func(t *testing.T) {
+		token := exchange("plain_code", "email")
+
+		assert.Empty(t, token.IDToken)
+	})
+}
+
+func TestOAuthUsecase_UserInfoHandler(t *testing.T) {
+	usecase, _, _ := newOAuthUsecase()
+	userRepository.Mock.On("FindById", mock.Anything, "userinfo_uuid").Return(repository.User{ID: "userinfo_uuid", Name: "kale", Email: "userinfo@gmail.com"})
+
+	t.Run("first_party_token", func(t *testing.T) {
+		_, err := usecase.UserInfoHandler(context.Background(), &domains.Principal{ID: "userinfo_uuid"})
+
+		assert.Equal(t, "insufficient_scope", err.(*OAuthError).Code)
+	})
+
+	t.Run("without_openid", func(t *testing.T) {
+		_, err := usecase.UserInfoHandler(context.Background(), &domains.Principal{ID: "userinfo_uuid", ClientID: publicClient.ID, Scope: "email"})
+
+		assert.Equal(t, "insufficient_scope", err.(*OAuthError).Code)
+	})
+
+	t.Run("filtered_by_scope", func(t *testing.T) {
+		info, err := usecase.UserInfoHandler(context.Background(), &domains.Principal{ID: "userinfo_uuid", ClientID: publicClient.ID, Scope: "openid email"})
+
+		assert.NoError(t, err)
+		verified := false
+		assert.Equal(t, &domains.UserInfo{Subject: "userinfo_uuid", Email: "userinfo@gmail.com", EmailVerified: &verified}, info)
+	})
+
+	t.Run("openid_only", func(t *testing.T) {
+		info, err := usecase.UserInfoHandler(context.Background(), &domains.Principal{ID: "userinfo_uuid", ClientID: publicClient.ID, Scope: "openid"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &domains.UserInfo{Subject: "userinfo_uuid"}, info)
+	})
+}
+
+func TestOAuthUsecase_DiscoveryHandler(t *testing.T) {
+	usecase, _, _ := newOAuthUsecase()
+
+	discovery := usecase.DiscoveryHandler()
+
+	assert.Equal(t, "https://auth.example.com", discovery.Issuer)
+	assert.Equal(t, "https://app.example.com/authorize", discovery.AuthorizationEndpoint)
+	assert.Equal(t, "https://auth.example.com/oauth/token", discovery.TokenEndpoint)
+	assert.Equal(t, "https://auth.example.com/userinfo", discovery.UserInfoEndpoint)
+	assert.Equal(t, "https://auth.example.com/.well-known/jwks.json", discovery.JWKSURI)
+	assert.Equal(t, helper.CurrentKeyRing().Algorithms(), discovery.IDTokenSigningAlgValuesSupported)
+	assert.NotEmpty(t, discovery.IDTokenSigningAlgValuesSupported)
+}
@@ -0,0 +1,89 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/mailer"
+	"api-auth/services/repository"
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errInvalidResetToken = newError(KindValidation, "invalid_reset_token", "Invalid or expired reset token!")
+
+// ForgotPasswordHandler mails a reset link to the account owner. It reports
+// success for unknown emails too so the endpoint cannot be used to find out
+// which addresses are registered.
+func (uu *UserUsecase) ForgotPasswordHandler(ctx context.Context, input *domains.ForgotPassword) error {
+	email, err := helper.NormalizeEmail(input.Email)
+	if err != nil {
+		return invalidField("email", err.Error())
+	}
+	input.Email = email
+	user, err := uu.Repository.FindByEmail(ctx, input.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	// Only the most recent link works.
+	if err := uu.PasswordResets.InvalidateUserPasswordResetTokens(ctx, user.ID); err != nil {
+		return err
+	}
+
+	token, tokenHash, err := helper.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = uu.PasswordResets.CreatePasswordResetToken(ctx, &repository.PasswordResetToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(uu.Options.ResetTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := uu.Options.ResetURL + "?" + url.Values{"token": {token}}.Encode()
+	return uu.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and works once.\n\n%s\n\nIf you did not ask for this you can ignore this email.\n",
+			user.Name, uu.Options.ResetTokenTTL, link),
+	})
+}
+
+// ResetPasswordHandler consumes a reset token and sets the new password.
+func (uu *UserUsecase) ResetPasswordHandler(ctx context.Context, input *domains.ResetPassword) error {
+	err := helper.PasswordRequired(input.NewPassword, input.PasswordConfirm)
+	if err != nil {
+		return invalidField("newPassword", err.Error())
+	}
+
+	token, err := uu.PasswordResets.FindPasswordResetTokenByHash(ctx, helper.HashToken(input.Token))
+	if err != nil {
+		return err
+	}
+	if token == nil || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return errInvalidResetToken
+	}
+	if err := uu.PasswordResets.UsePasswordResetToken(ctx, token.ID); err != nil {
+		return errInvalidResetToken
+	}
+
+	user, err := uu.Repository.FindById(ctx, token.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return notFound("User not found!")
+	}
+	return uu.setPassword(ctx, user.ID, input.NewPassword)
+}
@@ -0,0 +1,150 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/mailer"
+	"api-auth/services/repository"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserUsecase_ForgotPasswordHandler(t *testing.T) {
+	t.Run("unknown_email", func(t *testing.T) {
+		input := &domains.ForgotPassword{Email: "nobody@gmail.com"}
+		userRepository.Mock.On("FindByEmail", mock.Anything, input.Email).Return(nil).Once()
+
+		err := userUsecase.ForgotPasswordHandler(context.Background(), input)
+
+		assert.Nil(t, err)
+		mailSender.Mock.AssertNotCalled(t, "Send", mock.Anything)
+	})
+
+	t.Run("invalid_email", func(t *testing.T) {
+		err := userUsecase.ForgotPasswordHandler(context.Background(), &domains.ForgotPassword{Email: "nobody"})
+
+		assert.EqualError(t, err, "Email does'n contains @ or .")
+	})
+
+	t.Run("sends_reset_link", func(t *testing.T) {
+		input := &domains.ForgotPassword{Email: "forgot@gmail.com"}
+		var stored *repository.PasswordResetToken
+		var sent mailer.Message
+
+		userRepository.Mock.On("FindByEmail", mock.Anything, input.Email).Return(repository.User{ID: "forgot_uuid", Name: "kale", Email: input.Email}).Once()
+		passwordResetRepository.Mock.On("InvalidateUserPasswordResetTokens", mock.Anything, "forgot_uuid").Return(nil).Once()
+		passwordResetRepository.Mock.On("CreatePasswordResetToken", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*repository.PasswordResetToken)
+		}).Return(nil).Once()
+		mailSender.Mock.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(0).(mailer.Message)
+		}).Return(nil).Once()
+
+		err := userUsecase.ForgotPasswordHandler(context.Background(), input)
+
+		assert.Nil(t, err)
+		assert.Equal(t, input.Email, sent.To)
+		assert.Equal(t, "forgot_uuid", stored.UserID)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), stored.ExpiresAt, time.Minute)
+
+		// The mailed token is the one whose hash was stored, and only the hash.
+		link := sent.Body[strings.Index(sent.Body, "http://"):]
+		parsed, _ := url.Parse(strings.Fields(link)[0])
+		token := parsed.Query().Get("token")
+		assert.NotEmpty(t, token)
+		assert.Equal(t, helper.HashToken(token), stored.TokenHash)
+		assert.NotContains(t, sent.Body, stored.TokenHash)
+	})
+}
+
+func TestUserUsecase_ResetPasswordHandler(t *testing.T) {
+	valid := func(token string) repository.PasswordResetToken {
+		return repository.PasswordResetToken{
+			ID:        token + "_uuid",
+			UserID:    "reset_uuid",
+			TokenHash: helper.HashToken(token),
+			ExpiresAt: time.Now().Add(time.Minute),
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		input := &domains.ResetPassword{Token: "good_token", NewPassword: "password", PasswordConfirm: "password"}
+		token := valid(input.Token)
+
+		passwordResetRepository.Mock.On("FindPasswordResetTokenByHash", mock.Anything, token.TokenHash).Return(token).Once()
+		passwordResetRepository.Mock.On("UsePasswordResetToken", mock.Anything, token.ID).Return(nil).Once()
+		userRepository.Mock.On("FindById", mock.Anything, "reset_uuid").Return(repository.User{ID: "reset_uuid"}).Once()
+		userRepository.Mock.On("UpdatePassword", mock.Anything, "reset_uuid", mock.Anything).Return(nil).Once()
+		revocationRepository.Mock.On("RevokeUserTokens", mock.Anything, "reset_uuid", mock.Anything).Return(nil).Once()
+		refreshTokenRepository.Mock.On("RevokeUserRefreshTokens", mock.Anything, "reset_uuid").Return(nil).Once()
+
+		err := userUsecase.ResetPasswordHandler(context.Background(), input)
+
+		assert.Nil(t, err)
+		revocationRepository.Mock.AssertCalled(t, "RevokeUserTokens", mock.Anything, "reset_uuid", mock.Anything)
+	})
+
+	t.Run("used_token", func(t *testing.T) {
+		input := &domains.ResetPassword{Token: "used_token", NewPassword: "password", PasswordConfirm: "password"}
+		token := valid(input.Token)
+		usedAt := time.Now()
+		token.UsedAt = &usedAt
+
+		passwordResetRepository.Mock.On("FindPasswordResetTokenByHash", mock.Anything, token.TokenHash).Return(token).Once()
+
+		err := userUsecase.ResetPasswordHandler(context.Background(), input)
+
+		assert.EqualError(t, err, "Invalid or expired reset token!")
+	})
+
+	t.Run("expired_token", func(t *testing.T) {
+		input := &domains.ResetPassword{Token: "expired_token", NewPassword: "password", PasswordConfirm: "password"}
+		token := valid(input.Token)
+		token.ExpiresAt = time.Now().Add(-time.Minute)
+
+		passwordResetRepository.Mock.On("FindPasswordResetTokenByHash", mock.Anything, token.TokenHash).Return(token).Once()
+
+		err := userUsecase.ResetPasswordHandler(context.Background(), input)
+
+		assert.EqualError(t, err, "Invalid or expired reset token!")
+	})
+
+	t.Run("lookup_failed", func(t *testing.T) {
+		input := &domains.ResetPassword{Token: "timed_out_token", NewPassword: "password", PasswordConfirm: "password"}
+		token := valid(input.Token)
+
+		passwordResetRepository.Mock.On("FindPasswordResetTokenByHash", mock.Anything, token.TokenHash).Return(context.DeadlineExceeded).Once()
+
+		err := userUsecase.ResetPasswordHandler(context.Background(), input)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		passwordResetRepository.Mock.AssertNotCalled(t, "UsePasswordResetToken", mock.Anything, token.ID)
+	})
+
+	t.Run("lost_race", func(t *testing.T) {
+		input := &domains.ResetPassword{Token: "raced_token", NewPassword: "password", PasswordConfirm: "password"}
+		token := valid(input.Token)
+
+		passwordResetRepository.Mock.On("FindPasswordResetTokenByHash", mock.Anything, token.TokenHash).Return(token).Once()
+		passwordResetRepository.Mock.On("UsePasswordResetToken", mock.Anything, token.ID).Return(errors.New("")).Once()
+
+		err := userUsecase.ResetPasswordHandler(context.Background(), input)
+
+		assert.EqualError(t, err, "Invalid or expired reset token!")
+	})
+
+	t.Run("weak_password", func(t *testing.T) {
+		input := &domains.ResetPassword{Token: "good_token", NewPassword: "pass", PasswordConfirm: "pass"}
+
+		err := userUsecase.ResetPasswordHandler(context.Background(), input)
+
+		assert.EqualError(t, err, "Password must be greater than 8 characters!")
+	})
+}
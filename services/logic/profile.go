@@ -0,0 +1,152 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/mailer"
+	"api-auth/services/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxNameLength = 100
+
+// GetProfileHandler returns the account of the authenticated user.
+func (uu *UserUsecase) GetProfileHandler(ctx context.Context, principal *domains.Principal) (*repository.User, error) {
+	user, err := uu.Repository.FindById(ctx, principal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, notFound("User not found!")
+	}
+	return user, nil
+}
+
+// UpdateProfileHandler changes the fields of the profile that are set in
+// input and returns the updated account. The email has its own flow, see
+// ChangeEmailHandler.
+func (uu *UserUsecase) UpdateProfileHandler(ctx context.Context, principal *domains.Principal, input *domains.UpdateProfile) (*repository.User, error) {
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return nil, invalidField("name", "Name must not be empty!")
+		}
+		if utf8.RuneCountInString(name) > maxNameLength {
+			return nil, invalidField("name", fmt.Sprintf("Name must be at most %d characters!", maxNameLength))
+		}
+		input.Name = &name
+	}
+	user, err := uu.Repository.FindById(ctx, principal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, notFound("User not found!")
+	}
+	if err := uu.Repository.UpdateProfile(ctx, user.ID, input); err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+	return user, nil
+}
+
+// ChangeEmailHandler starts moving the account of the authenticated user to
+// another address. The old address is told about it and the new one gets a
+// link, the email only changes once that link is opened. Requests are rate
+// limited per account, a request whose mail fails does not count.
+func (uu *UserUsecase) ChangeEmailHandler(ctx context.Context, principal *domains.Principal, input *domains.ChangeEmail) error {
+	email, err := helper.NormalizeEmail(input.NewEmail)
+	if err != nil {
+		return invalidField("newEmail", err.Error())
+	}
+	input.NewEmail = email
+	user, err := uu.Repository.FindById(ctx, principal.ID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return notFound("User not found!")
+	}
+	err = uu.guardCredentials(ctx, user, func() error {
+		return checkCurrentPassword(user, input.CurrentPassword)
+	})
+	if err != nil {
+		return err
+	}
+	if input.NewEmail == user.Email {
+		return invalidField("newEmail", "This is already your email!")
+	}
+	taken, err := uu.Repository.FindByEmail(ctx, input.NewEmail)
+	if err != nil {
+		return err
+	}
+	if taken != nil {
+		return ErrEmailTaken
+	}
+	if err := uu.Repository.MarkEmailChangeSent(ctx, user.ID, time.Now().Add(-uu.Options.ResendInterval)); err != nil {
+		return err
+	}
+	if err := uu.sendEmailChange(user, input.NewEmail); err != nil {
+		if clearErr := uu.Repository.ClearEmailChangeSent(ctx, user.ID); clearErr != nil {
+			log.Printf("cannot clear email change of user %s: %s", user.ID, clearErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (uu *UserUsecase) sendEmailChange(user *repository.User, newEmail string) error {
+	token, err := helper.GenerateEmailChangeToken(user.ID, user.Email, newEmail, uu.Options.VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	err = uu.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your email is being changed",
+		Body: fmt.Sprintf("Hi %s,\n\nA change of your account email to %s was requested. It takes effect once confirmed from the new address. If this was not you, change your password right away.\n",
+			user.Name, newEmail),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := uu.Options.ChangeEmailURL + "?" + url.Values{"token": {token}}.Encode()
+	return uu.Mailer.Send(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm %s as the new email of your account by opening the link below. It expires in %s.\n\n%s\n",
+			user.Name, newEmail, uu.Options.VerifyTokenTTL, link),
+	})
+}
+
+// ConfirmEmailChangeHandler moves the account to the address in a
+// confirmation link. The link dies once the email changed another way. The
+// sessions of the account end, they were opened under the old address.
+func (uu *UserUsecase) ConfirmEmailChangeHandler(ctx context.Context, token string) error {
+	invalid := newError(KindValidation, "invalid_email_change_token", "Invalid or expired confirmation link!")
+	claims, err := helper.ParseEmailChangeToken(token)
+	if err != nil {
+		return invalid
+	}
+	err = uu.Repository.ChangeEmail(ctx, claims.Subject, claims.PreviousEmail, claims.Email)
+	if errors.Is(err, repository.ErrEmailExists) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return invalid
+	}
+	return uu.revokeUserSessions(ctx, claims.Subject)
+}
@@ -0,0 +1,212 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/mailer"
+	"api-auth/services/repository"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserUsecase_GetProfileHandler(t *testing.T) {
+	principal := &domains.Principal{ID: "me_uuid", Email: "kale@gmail.com"}
+	userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(repository.User{ID: principal.ID, Name: "kale", Email: principal.Email}).Once()
+
+	user, err := userUsecase.GetProfileHandler(context.Background(), principal)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "kale", user.Name)
+
+	userRepository.Mock.On("FindById", mock.Anything, "deleted_uuid").Return(nil).Once()
+	_, err = userUsecase.GetProfileHandler(context.Background(), &domains.Principal{ID: "deleted_uuid"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUserUsecase_UpdateProfileHandler(t *testing.T) {
+	principal := &domains.Principal{ID: "profile_uuid", Email: "kale@gmail.com"}
+
+	t.Run("updates_name", func(t *testing.T) {
+		name := "  Kale Leo "
+		userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(repository.User{ID: principal.ID, Name: "kale", Email: principal.Email}).Once()
+		userRepository.Mock.On("UpdateProfile", mock.Anything, principal.ID, mock.MatchedBy(func(input *domains.UpdateProfile) bool {
+			return *input.Name == "Kale Leo"
+		})).Return(nil).Once()
+
+		user, err := userUsecase.UpdateProfileHandler(context.Background(), principal, &domains.UpdateProfile{Name: &name})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Kale Leo", user.Name)
+		assert.Equal(t, principal.Email, user.Email)
+	})
+
+	t.Run("nothing_set", func(t *testing.T) {
+		userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(repository.User{ID: principal.ID, Name: "kale", Email: principal.Email}).Once()
+		userRepository.Mock.On("UpdateProfile", mock.Anything, principal.ID, &domains.UpdateProfile{}).Return(nil).Once()
+
+		user, err := userUsecase.UpdateProfileHandler(context.Background(), principal, &domains.UpdateProfile{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "kale", user.Name)
+	})
+
+	for _, name := range []string{"", "   ", strings.Repeat("ü", maxNameLength+1)} {
+		name := name
+		_, err := userUsecase.UpdateProfileHandler(context.Background(), principal, &domains.UpdateProfile{Name: &name})
+
+		var domainErr *Error
+		if assert.ErrorAs(t, err, &domainErr) {
+			assert.Contains(t, domainErr.Fields, "name")
+		}
+	}
+	userRepository.Mock.AssertNotCalled(t, "UpdateProfile", mock.Anything, principal.ID, mock.MatchedBy(func(input *domains.UpdateProfile) bool {
+		return input.Name != nil && strings.TrimSpace(*input.Name) == ""
+	}))
+}
+
+func TestUserUsecase_ChangeEmailHandler(t *testing.T) {
+	principal := &domains.Principal{ID: "email_uuid", Email: "kale@gmail.com"}
+	account := repository.User{ID: principal.ID, Name: "kale", Email: principal.Email, Password: hashPassword("password")}
+
+	t.Run("mails_both_addresses", func(t *testing.T) {
+		var sent []mailer.Message
+		userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(account).Once()
+		userRepository.Mock.On("FindByEmail", mock.Anything, "leo@gmail.com").Return(nil).Once()
+		userRepository.Mock.On("MarkEmailChangeSent", mock.Anything, principal.ID, mock.Anything).Return(nil).Once()
+		mailSender.Mock.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+			sent = append(sent, args.Get(0).(mailer.Message))
+		}).Return(nil).Twice()
+
+		err := userUsecase.ChangeEmailHandler(context.Background(), principal, &domains.ChangeEmail{NewEmail: " Leo@GMail.com", CurrentPassword: "password"})
+
+		assert.NoError(t, err)
+		if assert.Len(t, sent, 2) {
+			assert.Equal(t, "kale@gmail.com", sent[0].To)
+			assert.Contains(t, sent[0].Body, "leo@gmail.com")
+			assert.NotContains(t, sent[0].Body, "token=")
+
+			assert.Equal(t, "leo@gmail.com", sent[1].To)
+			link, _ := url.Parse(strings.Fields(sent[1].Body[strings.Index(sent[1].Body, "http://"):])[0])
+			assert.Equal(t, "/me/email/confirm", link.Path)
+			claims, err := helper.ParseEmailChangeToken(link.Query().Get("token"))
+			assert.NoError(t, err)
+			assert.Equal(t, principal.ID, claims.Subject)
+			assert.Equal(t, "kale@gmail.com", claims.PreviousEmail)
+			assert.Equal(t, "leo@gmail.com", claims.Email)
+		}
+		userRepository.Mock.AssertNotCalled(t, "MarkVerificationSent", mock.Anything, principal.ID, mock.Anything)
+		userRepository.Mock.AssertNotCalled(t, "ClearEmailChangeSent", mock.Anything, principal.ID)
+	})
+
+	t.Run("wrong_password", func(t *testing.T) {
+		userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(account).Once()
+
+		err := userUsecase.ChangeEmailHandler(context.Background(), principal, &domains.ChangeEmail{NewEmail: "leo@gmail.com", CurrentPassword: "wrong_password"})
+
+		assert.ErrorIs(t, err, ErrWrongPassword)
+	})
+
+	t.Run("same_email", func(t *testing.T) {
+		userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(account).Once()
+
+		err := userUsecase.ChangeEmailHandler(context.Background(), principal, &domains.ChangeEmail{NewEmail: "KALE@gmail.com", CurrentPassword: "password"})
+
+		assert.ErrorIs(t, err, ErrValidation)
+	})
+
+	t.Run("email_taken", func(t *testing.T) {
+		userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(account).Once()
+		userRepository.Mock.On("FindByEmail", mock.Anything, "taken@gmail.com").Return(repository.User{ID: "other_uuid", Email: "taken@gmail.com"}).Once()
+
+		err := userUsecase.ChangeEmailHandler(context.Background(), principal, &domains.ChangeEmail{NewEmail: "taken@gmail.com", CurrentPassword: "password"})
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+	})
+
+	t.Run("rate_limited", func(t *testing.T) {
+		userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(account).Once()
+		userRepository.Mock.On("FindByEmail", mock.Anything, "again@gmail.com").Return(nil).Once()
+		userRepository.Mock.On("MarkEmailChangeSent", mock.Anything, principal.ID, mock.Anything).Return(repository.ErrEmailChangeSentRecently).Once()
+
+		err := userUsecase.ChangeEmailHandler(context.Background(), principal, &domains.ChangeEmail{NewEmail: "again@gmail.com", CurrentPassword: "password"})
+
+		assert.ErrorIs(t, err, repository.ErrEmailChangeSentRecently)
+		assert.Equal(t, KindRateLimited, AsError(err).Kind)
+		mailSender.Mock.AssertNotCalled(t, "Send", mock.MatchedBy(func(msg mailer.Message) bool {
+			return msg.To == "again@gmail.com"
+		}))
+	})
+
+	t.Run("mail_fails", func(t *testing.T) {
+		failing := repository.User{ID: "email_fail_uuid", Name: "kale", Email: "unreachable@gmail.com", Password: hashPassword("password")}
+		userRepository.Mock.On("FindById", mock.Anything, failing.ID).Return(failing).Once()
+		userRepository.Mock.On("FindByEmail", mock.Anything, "leo@gmail.com").Return(nil).Once()
+		userRepository.Mock.On("MarkEmailChangeSent", mock.Anything, failing.ID, mock.Anything).Return(nil).Once()
+		mailSender.Mock.On("Send", mock.MatchedBy(func(msg mailer.Message) bool {
+			return msg.To == failing.Email
+		})).Return(errors.New("Mail server is down!")).Once()
+		userRepository.Mock.On("ClearEmailChangeSent", mock.Anything, failing.ID).Return(nil).Once()
+
+		err := userUsecase.ChangeEmailHandler(context.Background(), &domains.Principal{ID: failing.ID, Email: failing.Email}, &domains.ChangeEmail{NewEmail: "leo@gmail.com", CurrentPassword: "password"})
+
+		assert.EqualError(t, err, "Mail server is down!")
+		userRepository.Mock.AssertCalled(t, "ClearEmailChangeSent", mock.Anything, failing.ID)
+	})
+
+	t.Run("invalid_email", func(t *testing.T) {
+		err := userUsecase.ChangeEmailHandler(context.Background(), principal, &domains.ChangeEmail{NewEmail: "leo", CurrentPassword: "password"})
+
+		var domainErr *Error
+		if assert.ErrorAs(t, err, &domainErr) {
+			assert.Contains(t, domainErr.Fields, "newEmail")
+		}
+	})
+}
+
+func TestUserUsecase_ConfirmEmailChangeHandler(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		token, _ := helper.GenerateEmailChangeToken("confirm_uuid", "kale@gmail.com", "leo@gmail.com", time.Hour)
+		userRepository.Mock.On("ChangeEmail", mock.Anything, "confirm_uuid", "kale@gmail.com", "leo@gmail.com").Return(nil).Once()
+		revocationRepository.Mock.On("RevokeUserTokens", mock.Anything, "confirm_uuid", mock.Anything).Return(nil).Once()
+		refreshTokenRepository.Mock.On("RevokeUserRefreshTokens", mock.Anything, "confirm_uuid").Return(nil).Once()
+
+		err := userUsecase.ConfirmEmailChangeHandler(context.Background(), token)
+
+		assert.NoError(t, err)
+		revocationRepository.Mock.AssertCalled(t, "RevokeUserTokens", mock.Anything, "confirm_uuid", mock.Anything)
+		refreshTokenRepository.Mock.AssertCalled(t, "RevokeUserRefreshTokens", mock.Anything, "confirm_uuid")
+	})
+
+	t.Run("taken_meanwhile", func(t *testing.T) {
+		token, _ := helper.GenerateEmailChangeToken("confirm_uuid", "kale@gmail.com", "taken@gmail.com", time.Hour)
+		userRepository.Mock.On("ChangeEmail", mock.Anything, "confirm_uuid", "kale@gmail.com", "taken@gmail.com").Return(repository.ErrEmailExists).Once()
+
+		err := userUsecase.ConfirmEmailChangeHandler(context.Background(), token)
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+	})
+
+	t.Run("email_changed", func(t *testing.T) {
+		token, _ := helper.GenerateEmailChangeToken("confirm_uuid", "old@gmail.com", "leo@gmail.com", time.Hour)
+		userRepository.Mock.On("ChangeEmail", mock.Anything, "confirm_uuid", "old@gmail.com", "leo@gmail.com").Return(errors.New("User not found!")).Once()
+
+		err := userUsecase.ConfirmEmailChangeHandler(context.Background(), token)
+
+		assert.EqualError(t, err, "Invalid or expired confirmation link!")
+	})
+
+	t.Run("verification_link", func(t *testing.T) {
+		token, _ := helper.GenerateVerificationToken("confirm_uuid", "leo@gmail.com", time.Hour)
+
+		err := userUsecase.ConfirmEmailChangeHandler(context.Background(), token)
+
+		assert.EqualError(t, err, "Invalid or expired confirmation link!")
+	})
+}
@@ -0,0 +1,231 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"context"
+	"log"
+	"regexp"
+)
+
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersDelete = "users:delete"
+	PermissionUsersUnlock = "users:unlock"
+	PermissionRolesManage = "roles:manage"
+	// PermissionClientsManage allows registering OAuth clients.
+	PermissionClientsManage = "clients:manage"
+	// PermissionAPIKeysManage allows creating keys for service accounts.
+	PermissionAPIKeysManage = "apikeys:manage"
+
+	// AdminRole is granted every permission in DefaultPermissions.
+	AdminRole = "admin"
+)
+
+// DefaultPermissions are the permissions the routes check for.
+var DefaultPermissions = []string{
+	PermissionUsersRead,
+	PermissionUsersDelete,
+	PermissionUsersUnlock,
+	PermissionRolesManage,
+	PermissionClientsManage,
+	PermissionAPIKeysManage,
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+var permissionNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}:[a-z0-9_*-]{1,64}$`)
+
+type RoleUsecase struct {
+	Roles       repository.RoleRepositoryInterface
+	Users       repository.UserRepositoryInterface
+	Revocations repository.RevocationRepositoryInterface
+}
+
+type RoleUsecaseInterface interface {
+	GetRoles(ctx context.Context) ([]repository.Role, error)
+	CreateRoleHandler(ctx context.Context, input *domains.CreateRole) (*repository.Role, error)
+	DeleteRoleHandler(ctx context.Context, roleId string) error
+	GetPermissions(ctx context.Context) ([]repository.Permission, error)
+	CreatePermissionHandler(ctx context.Context, input *domains.CreatePermission) (*repository.Permission, error)
+	GrantPermissionHandler(ctx context.Context, roleId string, input *domains.GrantPermission) error
+	RevokePermissionHandler(ctx context.Context, roleId string, permissionId string) error
+	GetUserRolesHandler(ctx context.Context, userId string) ([]string, error)
+	AssignRoleHandler(ctx context.Context, userId string, input *domains.AssignRole) error
+	UnassignRoleHandler(ctx context.Context, userId string, roleId string) error
+	EnsureDefaultRoles(ctx context.Context, adminEmail string) error
+}
+
+func NewRoleUsecase(Roles repository.RoleRepositoryInterface, Users repository.UserRepositoryInterface, Revocations repository.RevocationRepositoryInterface) RoleUsecaseInterface {
+	return &RoleUsecase{
+		Roles:       Roles,
+		Users:       Users,
+		Revocations: Revocations,
+	}
+}
+
+func (ru *RoleUsecase) GetRoles(ctx context.Context) ([]repository.Role, error) {
+	return ru.Roles.Roles(ctx)
+}
+
+func (ru *RoleUsecase) CreateRoleHandler(ctx context.Context, input *domains.CreateRole) (*repository.Role, error) {
+	if !roleNamePattern.MatchString(input.Name) {
+		return nil, invalidField("name", "Role name must be lowercase letters, digits, - or _!")
+	}
+	if ru.Roles.FindRoleByName(ctx, input.Name) != nil {
+		return nil, newError(KindConflict, "role_exists", "Role already exists!")
+	}
+	return ru.Roles.CreateRole(ctx, input.Name, input.Description)
+}
+
+// DeleteRoleHandler removes a role. Access tokens name their roles, so the
+// tokens of its holders are revoked, a role created later under the same
+// name must not grant them anything. Refresh tokens stay valid and the next
+// refresh issues a token without the role.
+func (ru *RoleUsecase) DeleteRoleHandler(ctx context.Context, roleId string) error {
+	role := ru.Roles.FindRoleById(ctx, roleId)
+	if role == nil {
+		return notFound("Role not found!")
+	}
+	if role.Name == AdminRole {
+		return newError(KindForbidden, "admin_role_protected", "The admin role cannot be deleted!")
+	}
+	holders, err := ru.Roles.DeleteRole(ctx, roleId)
+	if err != nil {
+		return err
+	}
+	cutoff := revocationCutoff()
+	for _, userId := range holders {
+		if err := ru.Revocations.RevokeUserTokens(ctx, userId, cutoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ru *RoleUsecase) GetPermissions(ctx context.Context) ([]repository.Permission, error) {
+	return ru.Roles.Permissions(ctx)
+}
+
+func (ru *RoleUsecase) CreatePermissionHandler(ctx context.Context, input *domains.CreatePermission) (*repository.Permission, error) {
+	if !permissionNamePattern.MatchString(input.Name) {
+		return nil, invalidField("name", "Permission name must look like resource:action!")
+	}
+	if ru.Roles.FindPermissionByName(ctx, input.Name) != nil {
+		return nil, newError(KindConflict, "permission_exists", "Permission already exists!")
+	}
+	return ru.Roles.CreatePermission(ctx, input.Name, input.Description)
+}
+
+func (ru *RoleUsecase) GrantPermissionHandler(ctx context.Context, roleId string, input *domains.GrantPermission) error {
+	if ru.Roles.FindRoleById(ctx, roleId) == nil {
+		return notFound("Role not found!")
+	}
+	permission := ru.Roles.FindPermissionByName(ctx, input.Permission)
+	if permission == nil {
+		return notFound("Permission not found!")
+	}
+	return ru.Roles.GrantPermission(ctx, roleId, permission.ID)
+}
+
+func (ru *RoleUsecase) RevokePermissionHandler(ctx context.Context, roleId string, permissionId string) error {
+	role := ru.Roles.FindRoleById(ctx, roleId)
+	if role == nil {
+		return notFound("Role not found!")
+	}
+	if role.Name == AdminRole {
+		return newError(KindForbidden, "admin_role_protected", "Permissions of the admin role cannot be revoked!")
+	}
+	return ru.Roles.RevokePermission(ctx, roleId, permissionId)
+}
+
+func (ru *RoleUsecase) GetUserRolesHandler(ctx context.Context, userId string) ([]string, error) {
+	user, err := ru.Users.FindById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, notFound("User not found!")
+	}
+	return ru.Roles.UserRoles(ctx, userId)
+}
+
+// AssignRoleHandler gives the user a role. It shows up in access tokens
+// issued from now on, including ones from the next refresh.
+func (ru *RoleUsecase) AssignRoleHandler(ctx context.Context, userId string, input *domains.AssignRole) error {
+	user, err := ru.Users.FindById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return notFound("User not found!")
+	}
+	role := ru.Roles.FindRoleByName(ctx, input.Role)
+	if role == nil {
+		return notFound("Role not found!")
+	}
+	return ru.Roles.AssignRole(ctx, userId, role.ID)
+}
+
+// UnassignRoleHandler takes a role away. The role is still listed in the
+// user's current access tokens, so those are revoked. Refresh tokens stay
+// valid and the next refresh issues a token without the role.
+func (ru *RoleUsecase) UnassignRoleHandler(ctx context.Context, userId string, roleId string) error {
+	if ru.Roles.FindRoleById(ctx, roleId) == nil {
+		return notFound("Role not found!")
+	}
+	if err := ru.Roles.UnassignRole(ctx, userId, roleId); err != nil {
+		return err
+	}
+	return ru.Revocations.RevokeUserTokens(ctx, userId, revocationCutoff())
+}
+
+// EnsureDefaultRoles creates the default permissions and the admin role
+// holding all of them. The user with adminEmail becomes an admin when the
+// role is created, later starts leave the holders of the role alone so an
+// admin that was removed stays removed. It is safe to run on every start.
+func (ru *RoleUsecase) EnsureDefaultRoles(ctx context.Context, adminEmail string) error {
+	admin := ru.Roles.FindRoleByName(ctx, AdminRole)
+	isNew := admin == nil
+	if isNew {
+		created, err := ru.Roles.CreateRole(ctx, AdminRole, "Full access")
+		if err != nil {
+			return err
+		}
+		admin = created
+	}
+
+	for _, name := range DefaultPermissions {
+		permission := ru.Roles.FindPermissionByName(ctx, name)
+		if permission == nil {
+			created, err := ru.Roles.CreatePermission(ctx, name, "")
+			if err != nil {
+				return err
+			}
+			permission = created
+		}
+		if err := ru.Roles.GrantPermission(ctx, admin.ID, permission.ID); err != nil {
+			return err
+		}
+	}
+
+	if adminEmail == "" || !isNew {
+		return nil
+	}
+	email, err := helper.NormalizeEmail(adminEmail)
+	if err != nil {
+		return invalidField("email", err.Error())
+	}
+	user, err := ru.Users.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	// The account may be registered later, which is no reason to refuse to
+	// start.
+	if user == nil {
+		log.Printf("admin user %s not found, the admin role has no holder", adminEmail)
+		return nil
+	}
+	return ru.Roles.AssignRole(ctx, user.ID, admin.ID)
+}
@@ -0,0 +1,159 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	mokz "api-auth/mock"
+	"api-auth/services/repository"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newRoleUsecase() (*RoleUsecase, *mokz.RoleRepositoryMock, *mokz.RevocationRepositoryMock) {
+	roles := &mokz.RoleRepositoryMock{Mock: mock.Mock{}}
+	revocations := &mokz.RevocationRepositoryMock{Mock: mock.Mock{}}
+	return &RoleUsecase{Roles: roles, Users: userRepository, Revocations: revocations}, roles, revocations
+}
+
+func TestRoleUsecase_EnsureDefaultRoles(t *testing.T) {
+	usecase, roles, _ := newRoleUsecase()
+	admin := repository.Role{ID: "admin_uuid", Name: AdminRole}
+
+	// The first run creates everything and promotes the admin.
+	roles.Mock.On("FindRoleByName", mock.Anything, AdminRole).Return(nil).Once()
+	roles.Mock.On("CreateRole", mock.Anything, AdminRole, mock.Anything).Return(nil).Once()
+	for _, name := range DefaultPermissions {
+		roles.Mock.On("FindPermissionByName", mock.Anything, name).Return(nil).Once()
+		roles.Mock.On("CreatePermission", mock.Anything, name, "").Return(nil).Once()
+		roles.Mock.On("GrantPermission", mock.Anything, "uuid", name+"_uuid").Return(nil).Once()
+	}
+	userRepository.Mock.On("FindByEmail", mock.Anything, "root@gmail.com").Return(repository.User{ID: "root_uuid", Email: "root@gmail.com"}).Once()
+	roles.Mock.On("AssignRole", mock.Anything, "root_uuid", "uuid").Return(nil).Once()
+
+	assert.NoError(t, usecase.EnsureDefaultRoles(context.Background(), "root@gmail.com"))
+
+	// A second run only fills in what is missing and leaves the holders of
+	// the role alone.
+	roles.Mock.On("FindRoleByName", mock.Anything, AdminRole).Return(admin).Once()
+	for _, name := range DefaultPermissions {
+		roles.Mock.On("FindPermissionByName", mock.Anything, name).Return(repository.Permission{ID: name + "_id", Name: name}).Once()
+		roles.Mock.On("GrantPermission", mock.Anything, admin.ID, name+"_id").Return(nil).Once()
+	}
+
+	assert.NoError(t, usecase.EnsureDefaultRoles(context.Background(), "root@gmail.com"))
+	roles.Mock.AssertNumberOfCalls(t, "CreateRole", 1)
+	roles.Mock.AssertNumberOfCalls(t, "AssignRole", 1)
+}
+
+func TestRoleUsecase_EnsureDefaultRolesWithoutAdmin(t *testing.T) {
+	usecase, roles, _ := newRoleUsecase()
+
+	roles.Mock.On("FindRoleByName", mock.Anything, AdminRole).Return(nil).Once()
+	roles.Mock.On("CreateRole", mock.Anything, AdminRole, mock.Anything).Return(nil).Once()
+	for _, name := range DefaultPermissions {
+		roles.Mock.On("FindPermissionByName", mock.Anything, name).Return(nil).Once()
+		roles.Mock.On("CreatePermission", mock.Anything, name, "").Return(nil).Once()
+		roles.Mock.On("GrantPermission", mock.Anything, "uuid", name+"_uuid").Return(nil).Once()
+	}
+	userRepository.Mock.On("FindByEmail", mock.Anything, "nobody@gmail.com").Return(nil).Once()
+
+	// A missing account does not keep the server from starting.
+	assert.NoError(t, usecase.EnsureDefaultRoles(context.Background(), "nobody@gmail.com"))
+	roles.Mock.AssertNotCalled(t, "AssignRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRoleUsecase_CreateHandlers(t *testing.T) {
+	usecase, roles, _ := newRoleUsecase()
+
+	_, err := usecase.CreateRoleHandler(context.Background(), &domains.CreateRole{Name: "Support Team"})
+	assert.Error(t, err)
+
+	roles.Mock.On("FindRoleByName", mock.Anything, "support").Return(nil).Once()
+	roles.Mock.On("CreateRole", mock.Anything, "support", "Helpdesk").Return(nil).Once()
+	role, err := usecase.CreateRoleHandler(context.Background(), &domains.CreateRole{Name: "support", Description: "Helpdesk"})
+	assert.NoError(t, err)
+	assert.Equal(t, "support", role.Name)
+
+	roles.Mock.On("FindRoleByName", mock.Anything, "support").Return(repository.Role{ID: "uuid", Name: "support"}).Once()
+	_, err = usecase.CreateRoleHandler(context.Background(), &domains.CreateRole{Name: "support"})
+	assert.EqualError(t, err, "Role already exists!")
+
+	for _, name := range []string{"users", "users:", ":read", "Users:Read", "users:read:all"} {
+		_, err = usecase.CreatePermissionHandler(context.Background(), &domains.CreatePermission{Name: name})
+		assert.Error(t, err, name)
+	}
+	roles.Mock.On("FindPermissionByName", mock.Anything, "reports:read").Return(nil).Once()
+	roles.Mock.On("CreatePermission", mock.Anything, "reports:read", "").Return(nil).Once()
+	permission, err := usecase.CreatePermissionHandler(context.Background(), &domains.CreatePermission{Name: "reports:read"})
+	assert.NoError(t, err)
+	assert.Equal(t, "reports:read", permission.Name)
+}
+
+func TestRoleUsecase_AdminRoleIsProtected(t *testing.T) {
+	usecase, roles, _ := newRoleUsecase()
+	roles.Mock.On("FindRoleById", mock.Anything, "admin_uuid").Return(repository.Role{ID: "admin_uuid", Name: AdminRole})
+
+	assert.Error(t, usecase.DeleteRoleHandler(context.Background(), "admin_uuid"))
+	assert.Error(t, usecase.RevokePermissionHandler(context.Background(), "admin_uuid", "permission_uuid"))
+	roles.Mock.AssertNotCalled(t, "DeleteRole", mock.Anything, mock.Anything)
+	roles.Mock.AssertNotCalled(t, "RevokePermission", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRoleUsecase_AssignAndUnassignRole(t *testing.T) {
+	usecase, roles, revocations := newRoleUsecase()
+	support := repository.Role{ID: "support_uuid", Name: "support"}
+
+	userRepository.Mock.On("FindById", mock.Anything, "missing_uuid").Return(nil).Once()
+	assert.ErrorIs(t, usecase.AssignRoleHandler(context.Background(), "missing_uuid", &domains.AssignRole{Role: "support"}), ErrNotFound)
+
+	userRepository.Mock.On("FindById", mock.Anything, "member_uuid").Return(repository.User{ID: "member_uuid"}).Once()
+	roles.Mock.On("FindRoleByName", mock.Anything, "support").Return(support).Once()
+	roles.Mock.On("AssignRole", mock.Anything, "member_uuid", support.ID).Return(nil).Once()
+	assert.NoError(t, usecase.AssignRoleHandler(context.Background(), "member_uuid", &domains.AssignRole{Role: "support"}))
+
+	// The role stays in issued tokens, so they have to go.
+	roles.Mock.On("FindRoleById", mock.Anything, support.ID).Return(support).Once()
+	roles.Mock.On("UnassignRole", mock.Anything, "member_uuid", support.ID).Return(nil).Once()
+	revocations.Mock.On("RevokeUserTokens", mock.Anything, "member_uuid", mock.Anything).Return(nil).Once()
+	assert.NoError(t, usecase.UnassignRoleHandler(context.Background(), "member_uuid", support.ID))
+	revocations.Mock.AssertCalled(t, "RevokeUserTokens", mock.Anything, "member_uuid", mock.Anything)
+}
+
+func TestRoleUsecase_DeleteRole(t *testing.T) {
+	usecase, roles, revocations := newRoleUsecase()
+	support := repository.Role{ID: "support_uuid", Name: "support"}
+
+	// The holders' tokens still name the role, a new role of that name
+	// must not inherit them.
+	roles.Mock.On("FindRoleById", mock.Anything, support.ID).Return(support).Once()
+	roles.Mock.On("DeleteRole", mock.Anything, support.ID).Return([]string{"ann_uuid", "bob_uuid"}, nil).Once()
+	revocations.Mock.On("RevokeUserTokens", mock.Anything, mock.Anything, mock.Anything).Return(nil).Twice()
+
+	assert.NoError(t, usecase.DeleteRoleHandler(context.Background(), support.ID))
+	revocations.Mock.AssertCalled(t, "RevokeUserTokens", mock.Anything, "ann_uuid", mock.Anything)
+	revocations.Mock.AssertCalled(t, "RevokeUserTokens", mock.Anything, "bob_uuid", mock.Anything)
+
+	roles.Mock.On("FindRoleById", mock.Anything, "missing_uuid").Return(nil).Once()
+	assert.ErrorIs(t, usecase.DeleteRoleHandler(context.Background(), "missing_uuid"), ErrNotFound)
+}
+
+func TestUserUsecase_TokensCarryRoles(t *testing.T) {
+	roles := &mokz.RoleRepositoryMock{Mock: mock.Mock{}}
+	roles.Mock.On("UserRoles", mock.Anything, "admin_uuid").Return([]string{"admin", "support"}, nil).Once()
+	withRoles := userUsecase
+	withRoles.Roles = roles
+
+	user := repository.User{ID: "admin_uuid", Email: "admin@gmail.com", Password: hashPassword("passwords")}
+	userRepository.Mock.On("FindByEmail", mock.Anything, user.Email).Return(user).Once()
+	refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+
+	_, token, err := withRoles.LoginHandler(context.Background(), &domains.Login{Email: user.Email, Password: "passwords"}, "127.0.0.1")
+	assert.NoError(t, err)
+
+	claims, err := helper.ParseJWT(token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "support"}, claims.Roles)
+}
@@ -3,112 +3,438 @@ package logic
 import (
 	"api-auth/app/helper"
 	"api-auth/domains"
+	"api-auth/services/mailer"
 	"api-auth/services/repository"
+	"api-auth/services/webauthn"
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+var (
+	errInvalidRefreshToken = newError(KindUnauthorized, "invalid_refresh_token", "Invalid refresh token!")
+	errRefreshTokenReused  = newError(KindUnauthorized, "refresh_token_reused", "Refresh token reused, please login again!")
 )
 
 type UserUsecase struct {
-	Repository repository.UserRepositoryInterface
+	Repository     repository.UserRepositoryInterface
+	RefreshTokens  repository.RefreshTokenRepositoryInterface
+	Revocations    repository.RevocationRepositoryInterface
+	PasswordResets repository.PasswordResetRepositoryInterface
+	LoginAttempts  repository.LoginAttemptRepositoryInterface
+	Roles          repository.RoleRepositoryInterface
+	MFA            repository.MFARepositoryInterface
+	WebAuthn       repository.WebAuthnRepositoryInterface
+	Mailer         mailer.Mailer
+	Options        Options
+}
+
+// Options holds the usecase settings that come from configuration.
+type Options struct {
+	// ResetURL is the page the reset email links to, the token is appended
+	// as the "token" query parameter.
+	ResetURL      string
+	ResetTokenTTL time.Duration
+	// VerifyURL is the endpoint the verification email links to.
+	VerifyURL      string
+	VerifyTokenTTL time.Duration
+	// ChangeEmailURL is the endpoint the email confirming a new address
+	// links to. Its links live as long as verification links.
+	ChangeEmailURL       string
+	ResendInterval       time.Duration
+	AllowUnverifiedLogin bool
+	Lockout              LockoutPolicy
+	// MFAIssuer is the account label shown in authenticator apps.
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+	RelyingParty    webauthn.RelyingParty
 }
 
 type UserUsecaseInterface interface {
-	GetUsers() ([]repository.User, error)
-	RegisterHandler(input *domains.Register) error
-	LoginHandler(input *domains.Login) (*repository.User, string, error)
-	ChangePasswordHandler(input *domains.ChangePassword) error
-	GetSingleUserHandler(userId string) (*repository.User, error)
-	DeleteUserHandler(userId string) error
+	GetUsers(ctx context.Context, query *domains.UserQuery) (*repository.UserPage, error)
+	RegisterHandler(ctx context.Context, input *domains.Register) error
+	LoginHandler(ctx context.Context, input *domains.Login, clientIP string) (*repository.User, *domains.Token, error)
+	MFALoginHandler(ctx context.Context, input *domains.MFALogin, clientIP string) (*repository.User, *domains.Token, error)
+	EnrollMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.Reauthentication) (*domains.MFAEnrollment, error)
+	ConfirmMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.MFACode) ([]string, error)
+	DisableMFAHandler(ctx context.Context, principal *domains.Principal, input *domains.DisableMFA) error
+	RegenerateRecoveryCodesHandler(ctx context.Context, principal *domains.Principal, input *domains.MFACode) ([]string, error)
+	BeginWebAuthnRegistrationHandler(ctx context.Context, principal *domains.Principal, input *domains.Reauthentication) (*domains.WebAuthnOptions, error)
+	FinishWebAuthnRegistrationHandler(ctx context.Context, principal *domains.Principal, input *domains.WebAuthnRegistration) (*repository.WebAuthnCredential, error)
+	BeginWebAuthnLoginHandler(ctx context.Context) (*domains.WebAuthnOptions, error)
+	FinishWebAuthnLoginHandler(ctx context.Context, input *domains.WebAuthnLogin, clientIP string) (*repository.User, *domains.Token, error)
+	BeginWebAuthnMFAHandler(ctx context.Context, input *domains.WebAuthnMFABegin) (*domains.WebAuthnOptions, error)
+	FinishWebAuthnMFAHandler(ctx context.Context, input *domains.WebAuthnMFALogin, clientIP string) (*repository.User, *domains.Token, error)
+	GetWebAuthnCredentialsHandler(ctx context.Context, principal *domains.Principal) ([]repository.WebAuthnCredential, error)
+	DeleteWebAuthnCredentialHandler(ctx context.Context, principal *domains.Principal, credentialId string, input *domains.Reauthentication) error
+	BeginWebAuthnReauthHandler(ctx context.Context, principal *domains.Principal) (*domains.WebAuthnOptions, error)
+	RefreshHandler(ctx context.Context, input *domains.RefreshToken) (*domains.Token, error)
+	LogoutHandler(ctx context.Context, principal *domains.Principal, input *domains.RefreshToken) error
+	ChangePasswordHandler(ctx context.Context, principal *domains.Principal, input *domains.ChangePassword) error
+	ForgotPasswordHandler(ctx context.Context, input *domains.ForgotPassword) error
+	ResetPasswordHandler(ctx context.Context, input *domains.ResetPassword) error
+	VerifyEmailHandler(ctx context.Context, token string) error
+	ResendVerificationHandler(ctx context.Context, input *domains.ResendVerification) error
+	UnlockHandler(ctx context.Context, input *domains.Unlock) error
+	GetSingleUserHandler(ctx context.Context, userId string) (*repository.User, error)
+	GetProfileHandler(ctx context.Context, principal *domains.Principal) (*repository.User, error)
+	UpdateProfileHandler(ctx context.Context, principal *domains.Principal, input *domains.UpdateProfile) (*repository.User, error)
+	ChangeEmailHandler(ctx context.Context, principal *domains.Principal, input *domains.ChangeEmail) error
+	ConfirmEmailChangeHandler(ctx context.Context, token string) error
+	DeleteUserHandler(ctx context.Context, userId string) error
 }
 
-func NewUserUsecase(Repository repository.UserRepositoryInterface) UserUsecaseInterface {
+func NewUserUsecase(Repository repository.UserRepositoryInterface, RefreshTokens repository.RefreshTokenRepositoryInterface, Revocations repository.RevocationRepositoryInterface, PasswordResets repository.PasswordResetRepositoryInterface, LoginAttempts repository.LoginAttemptRepositoryInterface, Roles repository.RoleRepositoryInterface, MFA repository.MFARepositoryInterface, WebAuthn repository.WebAuthnRepositoryInterface, Mailer mailer.Mailer, Options Options) UserUsecaseInterface {
 	return &UserUsecase{
-		Repository: Repository,
+		Repository:     Repository,
+		RefreshTokens:  RefreshTokens,
+		Revocations:    Revocations,
+		PasswordResets: PasswordResets,
+		LoginAttempts:  LoginAttempts,
+		Roles:          Roles,
+		MFA:            MFA,
+		WebAuthn:       WebAuthn,
+		Mailer:         Mailer,
+		Options:        Options,
 	}
 }
 
-func (uu *UserUsecase) GetUsers() ([]repository.User, error) {
-	users, err := uu.Repository.Users()
-
-	if err != nil || users == nil {
-		return nil, errors.New("Users not found!")
+// GetUsers validates the query, fills in the defaults and returns a page of
+// users.
+func (uu *UserUsecase) GetUsers(ctx context.Context, query *domains.UserQuery) (*repository.UserPage, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultUserPageSize
+	}
+	if query.Limit < 0 || query.Limit > maxUserPageSize {
+		return nil, invalidField("limit", fmt.Sprintf("Limit must be between 1 and %d!", maxUserPageSize))
+	}
+	if query.Sort == "" {
+		query.Sort = "createdAt"
+	}
+	if _, ok := repository.UserSortFields[strings.TrimPrefix(query.Sort, "-")]; !ok {
+		return nil, invalidField("sort", "Users can only be sorted by createdAt, email or name!")
+	}
+	switch query.Status {
+	case "", repository.UserStatusVerified, repository.UserStatusUnverified:
+	default:
+		return nil, invalidField("status", "Status must be verified or unverified!")
 	}
+	if query.CreatedAfter != nil && query.CreatedBefore != nil && !query.CreatedAfter.Before(*query.CreatedBefore) {
+		return nil, invalidField("createdAfter", "createdAfter must be before createdBefore!")
+	}
+	// Full addresses are looked up the way they are stored, a prefix of
+	// one can only be case folded.
+	if email, err := helper.NormalizeEmail(query.Email); err == nil {
+		query.Email = email
+	} else {
+		query.Email = strings.ToLower(strings.TrimSpace(query.Email))
+	}
+	query.Name = strings.TrimSpace(query.Name)
 
-	return users, nil
+	page, err := uu.Repository.Users(ctx, query)
+	if errors.Is(err, repository.ErrInvalidQuery) {
+		// The sort was checked above, so it is the cursor.
+		return nil, invalidField("cursor", err.Error())
+	}
+	return page, err
 }
 
-func (uu *UserUsecase) RegisterHandler(input *domains.Register) error {
+func (uu *UserUsecase) RegisterHandler(ctx context.Context, input *domains.Register) error {
 
-	err := helper.EmailRequired(input.Email)
+	email, err := helper.NormalizeEmail(input.Email)
 	if err != nil {
-		return err
+		return invalidField("email", err.Error())
 	}
+	input.Email = email
 	err = helper.PasswordRequired(input.Password, input.PasswordConfirm)
+	if err != nil {
+		return invalidField("password", err.Error())
+	}
+	user, err := uu.Repository.FindByEmail(ctx, input.Email)
 	if err != nil {
 		return err
 	}
-	user := uu.Repository.FindByEmail(input.Email)
 	if user != nil {
-		return errors.New("Email has been used!")
+		return ErrEmailTaken
 	}
-	input.Password = helper.PasswordHashing(input.Password)
-	err = uu.Repository.CreateUser(input)
+	input.Password, err = helper.PasswordHashing(input.Password)
 	if err != nil {
 		return err
 	}
+	// The lookup above is only a shortcut, the unique index decides when
+	// two registrations race.
+	created, err := uu.Repository.CreateUser(ctx, input)
+	if errors.Is(err, repository.ErrEmailExists) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	// The account exists at this point, a lost email can be sent again
+	// through the resend endpoint.
+	if err := uu.sendVerification(ctx, created); err != nil {
+		log.Printf("cannot send verification email to user %s: %s", created.ID, err.Error())
+	}
 	return nil
 }
 
-func (uu *UserUsecase) LoginHandler(input *domains.Login) (*repository.User, string, error) {
-	err := helper.EmailRequired(input.Email)
+func (uu *UserUsecase) LoginHandler(ctx context.Context, input *domains.Login, clientIP string) (*repository.User, *domains.Token, error) {
+	email, err := helper.NormalizeEmail(input.Email)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, invalidField("email", err.Error())
+	}
+	input.Email = email
+	keys := uu.loginKeys(input.Email, clientIP)
+	if err := uu.checkLoginLocked(ctx, keys); err != nil {
+		return nil, nil, err
+	}
+	user, err := uu.Repository.FindByEmail(ctx, input.Email)
+	if err != nil {
+		return nil, nil, err
 	}
-	user := uu.Repository.FindByEmail(input.Email)
 	if user == nil {
-		return user, "", errors.New("Email not register!")
+		// A lookup cut short with the request is no failed login.
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		// Unknown accounts pay for a hash as well, otherwise the time of the
+		// answer tells which emails have one.
+		helper.CheckDummyPassword(input.Password)
+		if err := uu.recordLoginFailure(ctx, keys); err != nil {
+			return nil, nil, err
+		}
+		return user, nil, ErrInvalidCredentials
 	}
 	err = helper.CheckPasswordHash(input.Password, user.Password)
 	if err != nil {
-		return user, "", err
+		if err := uu.recordLoginFailure(ctx, keys); err != nil {
+			return nil, nil, err
+		}
+		return user, nil, ErrInvalidCredentials
+	}
+	uu.rehashPassword(ctx, user, input.Password)
+	if user.EmailVerifiedAt == nil && !uu.Options.AllowUnverifiedLogin {
+		return user, nil, ErrEmailNotVerified
+	}
+	// With MFA the failures are only cleared once the second factor checks
+	// out, otherwise knowing the password would allow unlimited guesses.
+	methods, err := uu.mfaMethods(ctx, user.ID)
+	if err != nil {
+		return user, nil, err
+	}
+	if len(methods) > 0 {
+		challenge, err := helper.GenerateMFAChallengeToken(user.ID, uu.Options.MFAChallengeTTL)
+		if err != nil {
+			return user, nil, err
+		}
+		return user, &domains.Token{MFAToken: challenge, MFAMethods: methods}, nil
+	}
+	uu.clearLoginFailures(ctx, keys)
+	token, err := uu.issueTokens(ctx, user, uuid.New().String(), "", time.Now())
+	if err != nil {
+		return user, nil, err
 	}
-	token, _ := helper.GenerateJWT(user.ID, user.Email)
 
 	return user, token, nil
 }
 
-func (uu *UserUsecase) ChangePasswordHandler(input *domains.ChangePassword) error {
-	err := helper.EmailRequired(input.Email)
+// RefreshHandler exchanges a refresh token for a new access/refresh pair.
+// Presenting a token that was already rotated is treated as theft and
+// revokes every token in its family.
+func (uu *UserUsecase) RefreshHandler(ctx context.Context, input *domains.RefreshToken) (*domains.Token, error) {
+	current := uu.RefreshTokens.FindRefreshTokenByHash(ctx, helper.HashToken(input.RefreshToken))
+	// Tokens of OAuth clients are refreshed at the token endpoint.
+	if current == nil || current.ClientID != "" {
+		return nil, errInvalidRefreshToken
+	}
+	if current.RevokedAt != nil {
+		uu.RefreshTokens.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+		return nil, errRefreshTokenReused
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, newError(KindUnauthorized, "refresh_token_expired", "Refresh token expired!")
+	}
+
+	user, err := uu.Repository.FindById(ctx, current.UserID)
 	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		uu.RefreshTokens.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+		return nil, notFound("User not found!")
+	}
+
+	// Families from before auth times were stored started with their first
+	// token, which is no later than the login.
+	authTime := current.AuthTime
+	if authTime.IsZero() {
+		authTime = current.CreatedAt
+	}
+	return uu.issueTokens(ctx, user, current.FamilyID, current.ID, authTime)
+}
+
+// LogoutHandler revokes the access token used for the request and, when the
+// client sends it along, the refresh token family of that session.
+func (uu *UserUsecase) LogoutHandler(ctx context.Context, principal *domains.Principal, input *domains.RefreshToken) error {
+	if err := uu.Revocations.RevokeToken(ctx, principal.TokenID, principal.ExpiresAt); err != nil {
+		return err
+	}
+	if input == nil || input.RefreshToken == "" {
+		return nil
+	}
+	current := uu.RefreshTokens.FindRefreshTokenByHash(ctx, helper.HashToken(input.RefreshToken))
+	if current == nil || current.UserID != principal.ID {
+		return nil
+	}
+	return uu.RefreshTokens.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+}
+
+// revocationCutoff is the time before which access tokens are revoked by
+// RevokeUserTokens. It is rounded up to the next second because iat only has
+// second precision.
+func revocationCutoff() time.Time {
+	return time.Now().Truncate(time.Second).Add(time.Second)
+}
+
+// revokeUserSessions invalidates every access and refresh token the user
+// holds.
+func (uu *UserUsecase) revokeUserSessions(ctx context.Context, userId string) error {
+	if err := uu.Revocations.RevokeUserTokens(ctx, userId, revocationCutoff()); err != nil {
 		return err
 	}
-	err = helper.PasswordRequired(input.NewPassword, input.PasswordConfirm)
+	return uu.RefreshTokens.RevokeUserRefreshTokens(ctx, userId)
+}
+
+// issueTokens signs an access token and stores a new refresh token in the
+// given family. When rotating, the previous refresh token is retired first so
+// that a lost race is reported as reuse. authTime is when the user logged in
+// to the family.
+func (uu *UserUsecase) issueTokens(ctx context.Context, user *repository.User, familyId, previousId string, authTime time.Time) (*domains.Token, error) {
+	refreshToken, refreshHash, err := helper.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	newRefresh := &repository.RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		FamilyID:  familyId,
+		TokenHash: refreshHash,
+		AuthTime:  authTime,
+		ExpiresAt: time.Now().Add(helper.RefreshTokenTTL),
+	}
+
+	if previousId != "" {
+		if err := uu.RefreshTokens.RotateRefreshToken(ctx, previousId, newRefresh.ID); err != nil {
+			uu.RefreshTokens.RevokeRefreshTokenFamily(ctx, familyId)
+			return nil, errRefreshTokenReused
+		}
+	}
+	if err := uu.RefreshTokens.CreateRefreshToken(ctx, newRefresh); err != nil {
+		return nil, err
+	}
+
+	roles, err := uu.Roles.UserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := helper.GenerateSessionJWT(user.ID, user.Email, authTime, roles...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domains.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// ChangePasswordHandler changes the password of the authenticated user, who
+// has to prove they know the current one.
+func (uu *UserUsecase) ChangePasswordHandler(ctx context.Context, principal *domains.Principal, input *domains.ChangePassword) error {
+	err := helper.PasswordRequired(input.NewPassword, input.PasswordConfirm)
+	if err != nil {
+		return invalidField("newPassword", err.Error())
+	}
+	user, err := uu.Repository.FindById(ctx, principal.ID)
 	if err != nil {
 		return err
 	}
-	user := uu.Repository.FindByEmail(input.Email)
 	if user == nil {
-		return errors.New("Email not register!")
+		return notFound("User not found!")
 	}
-	newPasswordHash := helper.PasswordHashing(input.NewPassword)
-	input.NewPassword = newPasswordHash
-	err = uu.Repository.UpdatePassword(input, user.ID)
+	err = uu.guardCredentials(ctx, user, func() error {
+		return checkCurrentPassword(user, input.CurrentPassword)
+	})
 	if err != nil {
 		return err
 	}
-	return nil
+	return uu.setPassword(ctx, user.ID, input.NewPassword)
 }
 
-func (uu *UserUsecase) GetSingleUserHandler(userId string) (*repository.User, error) {
-	user := uu.Repository.FindById(userId)
+// rehashPassword upgrades a legacy or weak hash right after the password was
+// verified. Failing to do so does not fail the login.
+func (uu *UserUsecase) rehashPassword(ctx context.Context, user *repository.User, password string) {
+	if !helper.PasswordNeedsRehash(user.Password) {
+		return
+	}
+	hash, err := helper.PasswordHashing(password)
+	if err == nil {
+		err = uu.Repository.RehashPassword(ctx, user.ID, user.Password, hash)
+	}
+	if err != nil {
+		log.Printf("cannot rehash password of user %s: %s", user.ID, err.Error())
+		return
+	}
+	user.Password = hash
+}
+
+// setPassword stores a new password and logs the user out everywhere.
+func (uu *UserUsecase) setPassword(ctx context.Context, userId, password string) error {
+	hash, err := helper.PasswordHashing(password)
+	if err != nil {
+		return err
+	}
+	err = uu.Repository.UpdatePassword(ctx, userId, hash)
+	if err != nil {
+		return err
+	}
+	return uu.revokeUserSessions(ctx, userId)
+}
+
+func (uu *UserUsecase) GetSingleUserHandler(ctx context.Context, userId string) (*repository.User, error) {
+	user, err := uu.Repository.FindById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
 	if user == nil {
-		return nil, errors.New("User not found!")
+		return nil, notFound("User not found!")
 	}
 	return user, nil
 }
 
-func (uu *UserUsecase) DeleteUserHandler(userId string) error {
-	if err := uu.Repository.DeleteUserById(userId); err != nil {
+func (uu *UserUsecase) DeleteUserHandler(ctx context.Context, userId string) error {
+	user, err := uu.Repository.FindById(ctx, userId)
+	if err != nil {
 		return err
 	}
-	return nil
+	if user == nil {
+		return notFound("User not found!")
+	}
+	if err := uu.Repository.DeleteUserById(ctx, userId); err != nil {
+		return err
+	}
+	if err := uu.Roles.UnassignAllRoles(ctx, userId); err != nil {
+		return err
+	}
+	return uu.revokeUserSessions(ctx, userId)
 }
@@ -4,16 +4,87 @@ import (
 	"api-auth/app/helper"
 	"api-auth/domains"
 	mokz "api-auth/mock"
+	"api-auth/services/mailer"
 	"api-auth/services/repository"
+	"api-auth/services/webauthn"
+	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var userRepository = &mokz.UserRepositoryMock{Mock: mock.Mock{}}
-var userUsecase = UserUsecase{Repository: userRepository}
+var refreshTokenRepository = &mokz.RefreshTokenRepositoryMock{Mock: mock.Mock{}}
+var revocationRepository = &mokz.RevocationRepositoryMock{Mock: mock.Mock{}}
+var passwordResetRepository = &mokz.PasswordResetRepositoryMock{Mock: mock.Mock{}}
+var mailSender = &mokz.MailerMock{Mock: mock.Mock{}}
+var roleRepository = func() *mokz.RoleRepositoryMock {
+	roles := &mokz.RoleRepositoryMock{Mock: mock.Mock{}}
+	// Users have no roles unless a test sets up its own repository.
+	roles.Mock.On("UserRoles", mock.Anything, mock.Anything).Return([]string{}, nil)
+	roles.Mock.On("UnassignAllRoles", mock.Anything, mock.Anything).Return(nil)
+	return roles
+}()
+var mfaRepository = func() *mokz.MFARepositoryMock {
+	mfa := &mokz.MFARepositoryMock{Mock: mock.Mock{}}
+	// MFA is off unless a test sets up its own repository.
+	mfa.Mock.On("FindMFASecret", mock.Anything, mock.Anything).Return(nil)
+	return mfa
+}()
+var webAuthnRepository = func() *mokz.WebAuthnRepositoryMock {
+	credentials := &mokz.WebAuthnRepositoryMock{Mock: mock.Mock{}}
+	// Users have no passkeys unless a test sets up its own repository.
+	credentials.Mock.On("UserWebAuthnCredentials", mock.Anything, mock.Anything).Return([]repository.WebAuthnCredential{}, nil)
+	return credentials
+}()
+var userUsecase = UserUsecase{
+	Repository:     userRepository,
+	RefreshTokens:  refreshTokenRepository,
+	Revocations:    revocationRepository,
+	PasswordResets: passwordResetRepository,
+	LoginAttempts:  repository.NewMemoryLoginAttemptRepository(),
+	Roles:          roleRepository,
+	MFA:            mfaRepository,
+	WebAuthn:       webAuthnRepository,
+	Mailer:         mailSender,
+	Options: Options{
+		ResetURL:             "http://localhost:3000/password/reset",
+		ResetTokenTTL:        time.Minute * 30,
+		VerifyURL:            "http://localhost:3000/verify-email",
+		VerifyTokenTTL:       time.Hour,
+		ChangeEmailURL:       "http://localhost:3000/me/email/confirm",
+		ResendInterval:       time.Minute,
+		AllowUnverifiedLogin: true,
+		Lockout: LockoutPolicy{
+			MaxFailures:   5,
+			IPMaxFailures: 50,
+			Window:        time.Minute * 15,
+			BaseDuration:  time.Minute,
+			MaxDuration:   time.Hour,
+		},
+		MFAIssuer:       "api-auth",
+		MFAChallengeTTL: time.Minute * 5,
+		RelyingParty: webauthn.RelyingParty{
+			ID:      "localhost",
+			Name:    "api-auth",
+			Origins: []string{"http://localhost:3000"},
+			Timeout: time.Minute * 5,
+		},
+	},
+}
+
+func hashPassword(password string) string {
+	hash, err := helper.PasswordHashing(password)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
 
 func TestUserUsecase_SuccessRegisterHandler(t *testing.T) {
 
@@ -24,12 +95,17 @@ func TestUserUsecase_SuccessRegisterHandler(t *testing.T) {
 		PasswordConfirm: "password",
 	}
 
-	userRepository.Mock.On("FindByEmail", input.Email).Return(nil).Once()
+	userRepository.Mock.On("FindByEmail", mock.Anything, input.Email).Return(nil).Once()
 
-	userRepository.Mock.On("CreateUser", input).Return(nil).Once()
+	userRepository.Mock.On("CreateUser", mock.Anything, input).Return(nil).Once()
+	userRepository.Mock.On("MarkVerificationSent", mock.Anything, "uuid", mock.Anything).Return(nil).Once()
+	mailSender.Mock.On("Send", mock.MatchedBy(func(msg mailer.Message) bool {
+		return msg.To == input.Email
+	})).Return(nil).Once()
 
-	err := userUsecase.RegisterHandler(input)
+	err := userUsecase.RegisterHandler(context.Background(), input)
 	assert.Nil(t, err)
+	mailSender.Mock.AssertCalled(t, "Send", mock.Anything)
 }
 
 func TestUserUsecase_FailedRegisterHandler(t *testing.T) {
@@ -58,7 +134,7 @@ func TestUserUsecase_FailedRegisterHandler(t *testing.T) {
 				Password:        "12345678",
 				PasswordConfirm: "12345678",
 			},
-			expected: errors.New("Email has been used!"),
+			expected: ErrEmailTaken,
 		},
 		{
 			name: "user_fail_3",
@@ -91,12 +167,12 @@ func TestUserUsecase_FailedRegisterHandler(t *testing.T) {
 		}
 
 		t.Run(test.name, func(t *testing.T) {
-			userRepository.Mock.On("FindByEmail", test.request.Email).Return(user).Once()
-			userRepository.Mock.On("CreateUser", user.ID, test.request).Return(errors.New("Cannot create user!")).Once()
+			userRepository.Mock.On("FindByEmail", mock.Anything, test.request.Email).Return(user).Once()
+			userRepository.Mock.On("CreateUser", mock.Anything, user.ID, test.request).Return(errors.New("Cannot create user!")).Once()
 
-			err := userUsecase.RegisterHandler(test.request)
+			err := userUsecase.RegisterHandler(context.Background(), test.request)
 
-			assert.Equal(t, test.expected, err)
+			assert.EqualError(t, err, test.expected.Error())
 			assert.NotNil(t, err)
 		})
 	}
@@ -112,14 +188,54 @@ func TestUserUsecase_FailedRegisterHandler(t *testing.T) {
 		expected: errors.New("Cannot create user!"),
 	}
 	t.Run(test2.name, func(t *testing.T) {
-		userRepository.Mock.On("FindByEmail", test2.request.Email).Return(nil).Once()
-		userRepository.Mock.On("CreateUser", test2.request).Return(errors.New("Cannot create user!")).Once()
+		userRepository.Mock.On("FindByEmail", mock.Anything, test2.request.Email).Return(nil).Once()
+		userRepository.Mock.On("CreateUser", mock.Anything, test2.request).Return(errors.New("Cannot create user!")).Once()
 
-		err := userUsecase.RegisterHandler(test2.request)
+		err := userUsecase.RegisterHandler(context.Background(), test2.request)
 
 		assert.Equal(t, test2.expected, err)
 		assert.NotNil(t, err)
 	})
+
+	t.Run("email_taken_by_concurrent_registration", func(t *testing.T) {
+		request := &domains.Register{
+			Name:            "leoe",
+			Email:           "leoe@gmail.com",
+			Password:        "123456789",
+			PasswordConfirm: "123456789",
+		}
+		userRepository.Mock.On("FindByEmail", mock.Anything, request.Email).Return(nil).Once()
+		userRepository.Mock.On("CreateUser", mock.Anything, request).Return(repository.ErrEmailExists).Once()
+
+		err := userUsecase.RegisterHandler(context.Background(), request)
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+	})
+}
+
+func TestUserUsecase_NormalizesEmail(t *testing.T) {
+	register := &domains.Register{
+		Name:            "Ardhito",
+		Email:           " Ardhito@GMail.COM ",
+		Password:        "password",
+		PasswordConfirm: "password",
+	}
+	userRepository.Mock.On("FindByEmail", mock.Anything, "ardhito@gmail.com").Return(repository.User{ID: "uuid", Email: "ardhito@gmail.com"}).Once()
+
+	assert.ErrorIs(t, userUsecase.RegisterHandler(context.Background(), register), ErrEmailTaken)
+
+	login := &domains.Login{Email: "Joko@Bücher.de", Password: "passwords"}
+	userRepository.Mock.On("FindByEmail", mock.Anything, "joko@xn--bcher-kva.de").Return(repository.User{
+		ID:       "uuid",
+		Email:    "joko@xn--bcher-kva.de",
+		Password: hashPassword("passwords"),
+	}).Once()
+	refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+
+	user, _, err := userUsecase.LoginHandler(context.Background(), login, "127.0.0.1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "joko@xn--bcher-kva.de", user.Email)
 }
 
 func TestUserUsecase_SuccessLoginHandler(t *testing.T) {
@@ -150,22 +266,53 @@ func TestUserUsecase_SuccessLoginHandler(t *testing.T) {
 			ID:       "uuid",
 			Name:     test.request.Name,
 			Email:    test.request.Email,
-			Password: helper.PasswordHashing(test.request.Password),
+			Password: hashPassword(test.request.Password),
 		}
 
 		t.Run(test.name, func(t *testing.T) {
-			userRepository.Mock.On("FindByEmail", test.request.Email).Return(user1).Once()
-			user, token, err := userUsecase.LoginHandler(test.request)
+			userRepository.Mock.On("FindByEmail", mock.Anything, test.request.Email).Return(user1).Once()
+			refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+			user, token, err := userUsecase.LoginHandler(context.Background(), test.request, "127.0.0.1")
 
 			assert.NotNil(t, user)
 			assert.Nil(t, err)
-			assert.NotEmpty(t, token)
+			assert.NotEmpty(t, token.AccessToken)
+			assert.NotEmpty(t, token.RefreshToken)
 			assert.Equal(t, user1.Email, user.Email)
 			assert.Equal(t, user1.Name, user.Name)
 		})
 	}
 }
 
+func TestUserUsecase_RehashOnLogin(t *testing.T) {
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("passwords"), bcrypt.MinCost)
+	user1 := repository.User{ID: "legacy_uuid", Email: "legacy@gmail.com", Password: string(legacy)}
+	input := &domains.Login{Email: user1.Email, Password: "passwords"}
+
+	userRepository.Mock.On("FindByEmail", mock.Anything, user1.Email).Return(user1).Once()
+	refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+	userRepository.Mock.On("RehashPassword", mock.Anything, user1.ID, user1.Password, mock.MatchedBy(func(hash string) bool {
+		return strings.HasPrefix(hash, "$argon2id$") && helper.CheckPasswordHash("passwords", hash) == nil
+	})).Return(nil).Once()
+
+	user, token, err := userUsecase.LoginHandler(context.Background(), input, "127.0.0.1")
+
+	assert.Nil(t, err)
+	assert.NotNil(t, token)
+	assert.False(t, helper.PasswordNeedsRehash(user.Password))
+	userRepository.Mock.AssertCalled(t, "RehashPassword", mock.Anything, user1.ID, user1.Password, mock.Anything)
+
+	// A current hash is left alone.
+	user2 := repository.User{ID: "current_uuid", Email: "current@gmail.com", Password: hashPassword("passwords")}
+	userRepository.Mock.On("FindByEmail", mock.Anything, user2.Email).Return(user2).Once()
+	refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+
+	_, _, err = userUsecase.LoginHandler(context.Background(), &domains.Login{Email: user2.Email, Password: "passwords"}, "127.0.0.1")
+
+	assert.Nil(t, err)
+	userRepository.Mock.AssertNotCalled(t, "RehashPassword", mock.Anything, user2.ID, mock.Anything, mock.Anything)
+}
+
 func TestUserUsecase_FailedLoginHandler(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -188,15 +335,15 @@ func TestUserUsecase_FailedLoginHandler(t *testing.T) {
 				Email:    "rahmad@gmail.com",
 				Password: "password",
 			},
-			expected: errors.New("Email not register!"),
+			expected: ErrInvalidCredentials,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			userRepository.Mock.On("FindByEmail", test.request.Email).Return(nil).Once()
+			userRepository.Mock.On("FindByEmail", mock.Anything, test.request.Email).Return(nil).Once()
 
-			user, token, _ := userUsecase.LoginHandler(test.request)
+			user, token, _ := userUsecase.LoginHandler(context.Background(), test.request, "127.0.0.1")
 
 			assert.Nil(t, user)
 			assert.Empty(t, token)
@@ -217,14 +364,169 @@ func TestUserUsecase_FailedLoginHandler(t *testing.T) {
 		Password: "123456789",
 	}
 	t.Run("user_fail_3", func(t *testing.T) {
-		userRepository.Mock.On("FindByEmail", inputUser.Email).Return(userQuery)
+		userRepository.Mock.On("FindByEmail", mock.Anything, inputUser.Email).Return(userQuery)
 
-		user, token, err := userUsecase.LoginHandler(inputUser)
+		user, token, err := userUsecase.LoginHandler(context.Background(), inputUser, "127.0.0.1")
 
 		assert.NotNil(t, user)
 		assert.Empty(t, token)
 		assert.NotNil(t, err)
-		assert.Equal(t, err, errors.New("Invalid password!"))
+		assert.Equal(t, ErrInvalidCredentials, err)
+	})
+
+	t.Run("request_canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		request := &domains.Login{Email: "gone@gmail.com", Password: "password"}
+		userRepository.Mock.On("FindByEmail", mock.Anything, request.Email).Return(nil).Once()
+
+		_, _, err := userUsecase.LoginHandler(ctx, request, "127.0.0.1")
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, userUsecase.LoginAttempts.FindLoginAttempt(context.Background(), "account:"+request.Email))
+	})
+}
+
+func TestUserUsecase_SuccessRefreshHandler(t *testing.T) {
+	input := &domains.RefreshToken{RefreshToken: "live_refresh_token"}
+	current := repository.RefreshToken{
+		ID:        "refresh_uuid",
+		UserID:    "user_uuid",
+		FamilyID:  "family_uuid",
+		TokenHash: helper.HashToken(input.RefreshToken),
+		AuthTime:  time.Now().Add(-time.Hour).Truncate(time.Second),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	refreshTokenRepository.Mock.On("FindRefreshTokenByHash", mock.Anything, current.TokenHash).Return(current).Once()
+	userRepository.Mock.On("FindById", mock.Anything, current.UserID).Return(repository.User{ID: current.UserID, Email: "kale@gmail.com"}).Once()
+	refreshTokenRepository.Mock.On("RotateRefreshToken", mock.Anything, current.ID, mock.Anything).Return(nil).Once()
+	refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(token *repository.RefreshToken) bool {
+		return token.FamilyID == current.FamilyID && token.UserID == current.UserID && token.AuthTime.Equal(current.AuthTime)
+	})).Return(nil).Once()
+
+	token, err := userUsecase.RefreshHandler(context.Background(), input)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token.AccessToken)
+	assert.NotEmpty(t, token.RefreshToken)
+	assert.NotEqual(t, input.RefreshToken, token.RefreshToken)
+	claims, err := helper.ParseJWT(token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, current.AuthTime.Unix(), claims.AuthTime.Unix())
+}
+
+func TestUserUsecase_FailedRefreshHandler(t *testing.T) {
+	t.Run("unknown_token", func(t *testing.T) {
+		input := &domains.RefreshToken{RefreshToken: "unknown_refresh_token"}
+		refreshTokenRepository.Mock.On("FindRefreshTokenByHash", mock.Anything, helper.HashToken(input.RefreshToken)).Return(nil).Once()
+
+		token, err := userUsecase.RefreshHandler(context.Background(), input)
+
+		assert.Nil(t, token)
+		assert.EqualError(t, err, "Invalid refresh token!")
+	})
+
+	t.Run("reused_token_revokes_family", func(t *testing.T) {
+		input := &domains.RefreshToken{RefreshToken: "rotated_refresh_token"}
+		revokedAt := time.Now().Add(-time.Minute)
+		current := repository.RefreshToken{
+			ID:        "rotated_uuid",
+			UserID:    "user_uuid",
+			FamilyID:  "stolen_family_uuid",
+			TokenHash: helper.HashToken(input.RefreshToken),
+			ExpiresAt: time.Now().Add(time.Hour),
+			RevokedAt: &revokedAt,
+		}
+		refreshTokenRepository.Mock.On("FindRefreshTokenByHash", mock.Anything, current.TokenHash).Return(current).Once()
+		refreshTokenRepository.Mock.On("RevokeRefreshTokenFamily", mock.Anything, current.FamilyID).Return(nil).Once()
+
+		token, err := userUsecase.RefreshHandler(context.Background(), input)
+
+		assert.Nil(t, token)
+		assert.Error(t, err)
+		refreshTokenRepository.Mock.AssertCalled(t, "RevokeRefreshTokenFamily", mock.Anything, current.FamilyID)
+	})
+
+	t.Run("lost_rotation_race_revokes_family", func(t *testing.T) {
+		input := &domains.RefreshToken{RefreshToken: "raced_refresh_token"}
+		current := repository.RefreshToken{
+			ID:        "raced_uuid",
+			UserID:    "user_uuid",
+			FamilyID:  "raced_family_uuid",
+			TokenHash: helper.HashToken(input.RefreshToken),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+		refreshTokenRepository.Mock.On("FindRefreshTokenByHash", mock.Anything, current.TokenHash).Return(current).Once()
+		userRepository.Mock.On("FindById", mock.Anything, current.UserID).Return(repository.User{ID: current.UserID}).Once()
+		refreshTokenRepository.Mock.On("RotateRefreshToken", mock.Anything, current.ID, mock.Anything).Return(errors.New("")).Once()
+		refreshTokenRepository.Mock.On("RevokeRefreshTokenFamily", mock.Anything, current.FamilyID).Return(nil).Once()
+
+		token, err := userUsecase.RefreshHandler(context.Background(), input)
+
+		assert.Nil(t, token)
+		assert.Error(t, err)
+		refreshTokenRepository.Mock.AssertCalled(t, "RevokeRefreshTokenFamily", mock.Anything, current.FamilyID)
+	})
+
+	t.Run("expired_token", func(t *testing.T) {
+		input := &domains.RefreshToken{RefreshToken: "expired_refresh_token"}
+		current := repository.RefreshToken{
+			ID:        "expired_uuid",
+			UserID:    "user_uuid",
+			FamilyID:  "family_uuid",
+			TokenHash: helper.HashToken(input.RefreshToken),
+			ExpiresAt: time.Now().Add(-time.Hour),
+		}
+		refreshTokenRepository.Mock.On("FindRefreshTokenByHash", mock.Anything, current.TokenHash).Return(current).Once()
+
+		token, err := userUsecase.RefreshHandler(context.Background(), input)
+
+		assert.Nil(t, token)
+		assert.EqualError(t, err, "Refresh token expired!")
+	})
+}
+
+func TestUserUsecase_LogoutHandler(t *testing.T) {
+	principal := &domains.Principal{
+		ID:        "user_uuid",
+		Email:     "kale@gmail.com",
+		TokenID:   "jti_uuid",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	t.Run("access_token_only", func(t *testing.T) {
+		revocationRepository.Mock.On("RevokeToken", mock.Anything, principal.TokenID, principal.ExpiresAt).Return(nil).Once()
+
+		err := userUsecase.LogoutHandler(context.Background(), principal, &domains.RefreshToken{})
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("with_refresh_token", func(t *testing.T) {
+		input := &domains.RefreshToken{RefreshToken: "logout_refresh_token"}
+		current := repository.RefreshToken{
+			ID:        "refresh_uuid",
+			UserID:    principal.ID,
+			FamilyID:  "logout_family_uuid",
+			TokenHash: helper.HashToken(input.RefreshToken),
+		}
+		revocationRepository.Mock.On("RevokeToken", mock.Anything, principal.TokenID, principal.ExpiresAt).Return(nil).Once()
+		refreshTokenRepository.Mock.On("FindRefreshTokenByHash", mock.Anything, current.TokenHash).Return(current).Once()
+		refreshTokenRepository.Mock.On("RevokeRefreshTokenFamily", mock.Anything, current.FamilyID).Return(nil).Once()
+
+		err := userUsecase.LogoutHandler(context.Background(), principal, input)
+
+		assert.Nil(t, err)
+		refreshTokenRepository.Mock.AssertCalled(t, "RevokeRefreshTokenFamily", mock.Anything, current.FamilyID)
+	})
+
+	t.Run("failed_revoke", func(t *testing.T) {
+		revocationRepository.Mock.On("RevokeToken", mock.Anything, principal.TokenID, principal.ExpiresAt).Return(errors.New("")).Once()
+
+		err := userUsecase.LogoutHandler(context.Background(), principal, &domains.RefreshToken{})
+
+		assert.NotNil(t, err)
 	})
 }
 
@@ -232,12 +534,10 @@ func TestUserUsecase_GetSingleUserHandler(t *testing.T) {
 	userId := "random_id"
 
 	t.Run("user_not_found", func(t *testing.T) {
-		userRepository.Mock.On("FindById", userId).Return(nil)
-		user, err := userUsecase.GetSingleUserHandler(userId)
-		customError := errors.New("User not found!")
-
+		userRepository.Mock.On("FindById", mock.Anything, userId).Return(nil)
+		user, err := userUsecase.GetSingleUserHandler(context.Background(), userId)
 		assert.Nil(t, user)
-		assert.Equal(t, err, customError)
+		assert.ErrorIs(t, err, ErrNotFound)
 		assert.NotNil(t, err)
 	})
 
@@ -251,101 +551,148 @@ func TestUserUsecase_GetSingleUserHandler(t *testing.T) {
 	}
 
 	t.Run("success_get_user", func(t *testing.T) {
-		userRepository.Mock.On("FindById", userId).Return(user1)
+		userRepository.Mock.On("FindById", mock.Anything, userId).Return(user1)
 
-		user, err := userUsecase.GetSingleUserHandler(userId)
+		user, err := userUsecase.GetSingleUserHandler(context.Background(), userId)
 
 		assert.NotNil(t, user)
 		assert.Nil(t, err)
 		assert.Equal(t, user.ID, user1.ID)
 
 	})
+
+	t.Run("store_error", func(t *testing.T) {
+		failure := errors.New("Cannot fetch user!")
+		userRepository.Mock.On("FindById", mock.Anything, "broken_id").Return(failure)
+
+		user, err := userUsecase.GetSingleUserHandler(context.Background(), "broken_id")
+
+		assert.Nil(t, user)
+		assert.Equal(t, failure, err)
+		assert.Equal(t, ErrInternal, AsError(err))
+	})
 }
 
 func TestUserUsecase_FailedChangePasswordHandler(t *testing.T) {
+	principal := &domains.Principal{ID: "change_fail_uuid", Email: "kale@gmail.com"}
+	userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(repository.User{
+		ID:       principal.ID,
+		Email:    principal.Email,
+		Password: hashPassword("current_password"),
+	})
+
 	tests := []struct {
-		name     string
-		request  *domains.ChangePassword
-		expected error
+		name      string
+		principal *domains.Principal
+		request   *domains.ChangePassword
+		expected  error
 	}{
 		{
-			name: "user_fail_1",
-			request: &domains.ChangePassword{
-				Email:           "kalegmail.com",
-				NewPassword:     "password123",
-				PasswordConfirm: "password123",
-			},
-			expected: errors.New("Email does'n contains @ or ."),
-		},
-		{
-			name: "user_fail_2",
+			name:      "user_fail_1",
+			principal: principal,
 			request: &domains.ChangePassword{
-				Email:           "kale@gmail.com",
+				CurrentPassword: "current_password",
 				NewPassword:     "password12",
 				PasswordConfirm: "password123",
 			},
 			expected: errors.New("Password not match!"),
 		},
 		{
-			name: "user_fail_3",
+			name:      "user_fail_2",
+			principal: principal,
 			request: &domains.ChangePassword{
-				Email:           "kale@gmail.com",
+				CurrentPassword: "current_password",
 				NewPassword:     "pass",
 				PasswordConfirm: "pass",
 			},
 			expected: errors.New("Password must be greater than 8 characters!"),
 		},
 		{
-			name: "user_fail_4",
+			name:      "user_fail_3",
+			principal: principal,
 			request: &domains.ChangePassword{
-				Email:           "email_not_found@gmail.com",
+				CurrentPassword: "wrong_password",
 				NewPassword:     "password",
 				PasswordConfirm: "password",
 			},
-			expected: errors.New("Cannot update password!"),
+			expected: ErrWrongPassword,
+		},
+		{
+			name:      "user_fail_4",
+			principal: &domains.Principal{ID: "deleted_uuid"},
+			request: &domains.ChangePassword{
+				CurrentPassword: "current_password",
+				NewPassword:     "password",
+				PasswordConfirm: "password",
+			},
+			expected: errors.New("User not found!"),
 		},
 	}
 
+	userRepository.Mock.On("FindById", mock.Anything, "deleted_uuid").Return(nil)
+
 	for _, test := range tests {
-		userRepository.Mock.On("FindByEmail", test.request.Email).Return(nil)
-		userRepository.Mock.On("UpdatePassword", test.request).Return(errors.New("Error"))
-		err := userUsecase.ChangePasswordHandler(test.request)
-		assert.Error(t, err)
-		// assert.Equal(t, err, test.expected)
+		t.Run(test.name, func(t *testing.T) {
+			err := userUsecase.ChangePasswordHandler(context.Background(), test.principal, test.request)
+
+			assert.EqualError(t, err, test.expected.Error())
+		})
 	}
+	userRepository.Mock.AssertNotCalled(t, "UpdatePassword", mock.Anything, principal.ID, mock.Anything)
 }
 
 func TestUserUsecase_SuccessChangePasswordHandler(t *testing.T) {
+	principal := &domains.Principal{ID: "id", Email: "kale@gmail.com"}
 	userInput := &domains.ChangePassword{
-		Email:           "kale@gmail.com",
+		CurrentPassword: "current_password",
 		NewPassword:     "password",
 		PasswordConfirm: "password",
 	}
 
-	userRepository.Mock.On("FindByEmail", userInput.Email).Return(repository.User{ID: "id", Name: "kale", Email: userInput.Email, Password: userInput.PasswordConfirm})
-	userRepository.Mock.On("UpdatePassword", userInput, "id").Return(nil)
-	err := userUsecase.ChangePasswordHandler(userInput)
+	userRepository.Mock.On("FindById", mock.Anything, principal.ID).Return(repository.User{ID: "id", Name: "kale", Email: principal.Email, Password: hashPassword(userInput.CurrentPassword)}).Once()
+	userRepository.Mock.On("UpdatePassword", mock.Anything, "id", mock.MatchedBy(func(hash string) bool {
+		return helper.CheckPasswordHash(userInput.NewPassword, hash) == nil
+	})).Return(nil).Once()
+	revocationRepository.Mock.On("RevokeUserTokens", mock.Anything, "id", mock.Anything).Return(nil).Once()
+	refreshTokenRepository.Mock.On("RevokeUserRefreshTokens", mock.Anything, "id").Return(nil).Once()
+	err := userUsecase.ChangePasswordHandler(context.Background(), principal, userInput)
 
 	assert.Nil(t, err)
+	revocationRepository.Mock.AssertCalled(t, "RevokeUserTokens", mock.Anything, "id", mock.Anything)
+	refreshTokenRepository.Mock.AssertCalled(t, "RevokeUserRefreshTokens", mock.Anything, "id")
 }
 
 func TestUserUsecase_DeleteUserHandler(t *testing.T) {
 	userId := "random_uuid"
 
+	t.Run("user_not_found", func(t *testing.T) {
+		userRepository.Mock.On("FindById", mock.Anything, "missing_uuid").Return(nil).Once()
+
+		err := userUsecase.DeleteUserHandler(context.Background(), "missing_uuid")
+		assert.ErrorIs(t, err, ErrNotFound)
+		userRepository.Mock.AssertNotCalled(t, "DeleteUserById", mock.Anything, "missing_uuid")
+	})
+
 	t.Run("failed_delete_user", func(t *testing.T) {
-		userRepository.Mock.On("DeleteUserById", userId).Return(errors.New("")).Once()
+		userRepository.Mock.On("FindById", mock.Anything, userId).Return(repository.User{ID: userId}).Once()
+		userRepository.Mock.On("DeleteUserById", mock.Anything, userId).Return(errors.New("")).Once()
 
-		err := userUsecase.DeleteUserHandler(userId)
+		err := userUsecase.DeleteUserHandler(context.Background(), userId)
 		assert.NotNil(t, err)
 
 	})
 
 	userId = "real_uuid"
 	t.Run("success_delete_user", func(t *testing.T) {
-		userRepository.Mock.On("DeleteUserById", userId).Return(nil)
+		userRepository.Mock.On("FindById", mock.Anything, userId).Return(repository.User{ID: userId}).Once()
+		userRepository.Mock.On("DeleteUserById", mock.Anything, userId).Return(nil)
+		revocationRepository.Mock.On("RevokeUserTokens", mock.Anything, userId, mock.Anything).Return(nil).Once()
+		refreshTokenRepository.Mock.On("RevokeUserRefreshTokens", mock.Anything, userId).Return(nil).Once()
 
-		err := userUsecase.DeleteUserHandler(userId)
+		err := userUsecase.DeleteUserHandler(context.Background(), userId)
 		assert.Nil(t, err)
+		revocationRepository.Mock.AssertCalled(t, "RevokeUserTokens", mock.Anything, userId, mock.Anything)
+		roleRepository.Mock.AssertCalled(t, "UnassignAllRoles", mock.Anything, userId)
 	})
 }
 
@@ -365,27 +712,64 @@ func TestUserUsecase_SuccessGetUsers(t *testing.T) {
 			Password: "password2",
 		},
 	}
+	query := &domains.UserQuery{Email: " kale "}
 
-	userRepository.Mock.On("Users").Return(users_test, nil).Once()
+	userRepository.Mock.On("Users", mock.Anything, query).Return(repository.UserPage{Users: users_test, NextCursor: "next"}, nil).Once()
 
-	users_got, err := userUsecase.GetUsers()
+	page, err := userUsecase.GetUsers(context.Background(), query)
 
-	// assert.Nil(t, users_got)
-	// assert.NotNil(t, err)
-
-	assert.NotEmpty(t, users_got)
 	assert.Nil(t, err)
+	assert.Equal(t, users_test, page.Users)
+	assert.Equal(t, "next", page.NextCursor)
+	assert.Equal(t, &domains.UserQuery{Email: "kale", Limit: 20, Sort: "createdAt"}, query)
+}
+
+func TestUserUsecase_GetUsersNormalizesEmail(t *testing.T) {
+	tests := map[string]string{
+		" Kale@Bücher.de": "kale@xn--bcher-kva.de",
+		"Kale@Büch":       "kale@büch",
+	}
+	for email, expected := range tests {
+		query := &domains.UserQuery{Email: email}
+		userRepository.Mock.On("Users", mock.Anything, query).Return(repository.UserPage{}, nil).Once()
+
+		_, err := userUsecase.GetUsers(context.Background(), query)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, query.Email)
+	}
 }
 
 func TestUserUsecase_FailGetUsers(t *testing.T) {
+	after := time.Now()
+	before := after.Add(-time.Hour)
+
+	tests := map[string]struct {
+		query *domains.UserQuery
+		err   string
+	}{
+		"limit_too_big":  {query: &domains.UserQuery{Limit: 101}, err: "Limit must be between 1 and 100!"},
+		"negative_limit": {query: &domains.UserQuery{Limit: -1}, err: "Limit must be between 1 and 100!"},
+		"unknown_sort":   {query: &domains.UserQuery{Sort: "-password"}, err: "Users can only be sorted by createdAt, email or name!"},
+		"unknown_status": {query: &domains.UserQuery{Status: "locked"}, err: "Status must be verified or unverified!"},
+		"empty_range":    {query: &domains.UserQuery{CreatedAfter: &after, CreatedBefore: &before}, err: "createdAfter must be before createdBefore!"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			page, err := userUsecase.GetUsers(context.Background(), test.query)
 
-	userRepository.Mock.On("Users").Return(nil, errors.New(""))
+			assert.Nil(t, page)
+			assert.EqualError(t, err, test.err)
+		})
+	}
 
-	users, err := userUsecase.GetUsers()
-	errExpected := errors.New("Users not found!")
+	t.Run("repository", func(t *testing.T) {
+		query := &domains.UserQuery{Limit: 5}
+		userRepository.Mock.On("Users", mock.Anything, query).Return(nil, errors.New("")).Once()
 
-	assert.NotNil(t, err)
-	assert.Nil(t, users)
-	assert.Equal(t, errExpected, err)
+		page, err := userUsecase.GetUsers(context.Background(), query)
 
+		assert.Nil(t, page)
+		assert.EqualError(t, err, "Cannot fetch users!")
+	})
 }
@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/mailer"
+	"api-auth/services/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// VerifyEmailHandler marks the address in a verification link as verified.
+func (uu *UserUsecase) VerifyEmailHandler(ctx context.Context, token string) error {
+	invalid := newError(KindValidation, "invalid_verification_token", "Invalid or expired verification link!")
+	claims, err := helper.ParseVerificationToken(token)
+	if err != nil {
+		return invalid
+	}
+	if err := uu.Repository.VerifyEmail(ctx, claims.Subject, claims.Email); err != nil {
+		return invalid
+	}
+	return nil
+}
+
+// ResendVerificationHandler mails a new verification link. Like the password
+// reset it does not tell whether the email is registered, so a resend inside
+// the interval succeeds without mailing anything, as one for an unknown
+// address does.
+func (uu *UserUsecase) ResendVerificationHandler(ctx context.Context, input *domains.ResendVerification) error {
+	email, err := helper.NormalizeEmail(input.Email)
+	if err != nil {
+		return invalidField("email", err.Error())
+	}
+	input.Email = email
+	user, err := uu.Repository.FindByEmail(ctx, input.Email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	err = uu.sendVerification(ctx, user)
+	if errors.Is(err, repository.ErrVerificationSentRecently) {
+		return nil
+	}
+	return err
+}
+
+func (uu *UserUsecase) sendVerification(ctx context.Context, user *repository.User) error {
+	if err := uu.Repository.MarkVerificationSent(ctx, user.ID, time.Now().Add(-uu.Options.ResendInterval)); err != nil {
+		return err
+	}
+
+	token, err := helper.GenerateVerificationToken(user.ID, user.Email, uu.Options.VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := uu.Options.VerifyURL + "?" + url.Values{"token": {token}}.Encode()
+	return uu.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n",
+			user.Name, uu.Options.VerifyTokenTTL, link),
+	})
+}
@@ -0,0 +1,130 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/mailer"
+	"api-auth/services/repository"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserUsecase_VerifyEmailHandler(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		token, _ := helper.GenerateVerificationToken("verify_uuid", "verify@gmail.com", time.Hour)
+		userRepository.Mock.On("VerifyEmail", mock.Anything, "verify_uuid", "verify@gmail.com").Return(nil).Once()
+
+		err := userUsecase.VerifyEmailHandler(context.Background(), token)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("email_changed", func(t *testing.T) {
+		token, _ := helper.GenerateVerificationToken("verify_uuid", "old@gmail.com", time.Hour)
+		userRepository.Mock.On("VerifyEmail", mock.Anything, "verify_uuid", "old@gmail.com").Return(errors.New("")).Once()
+
+		err := userUsecase.VerifyEmailHandler(context.Background(), token)
+
+		assert.EqualError(t, err, "Invalid or expired verification link!")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token, _ := helper.GenerateVerificationToken("verify_uuid", "verify@gmail.com", -time.Minute)
+
+		err := userUsecase.VerifyEmailHandler(context.Background(), token)
+
+		assert.EqualError(t, err, "Invalid or expired verification link!")
+	})
+
+	t.Run("access_token", func(t *testing.T) {
+		token, _ := helper.GenerateJWT("verify_uuid", "verify@gmail.com")
+
+		err := userUsecase.VerifyEmailHandler(context.Background(), token)
+
+		assert.EqualError(t, err, "Invalid or expired verification link!")
+	})
+}
+
+func TestUserUsecase_ResendVerificationHandler(t *testing.T) {
+	t.Run("sends_link", func(t *testing.T) {
+		input := &domains.ResendVerification{Email: "resend@gmail.com"}
+		var sent mailer.Message
+
+		userRepository.Mock.On("FindByEmail", mock.Anything, input.Email).Return(repository.User{ID: "resend_uuid", Email: input.Email}).Once()
+		userRepository.Mock.On("MarkVerificationSent", mock.Anything, "resend_uuid", mock.MatchedBy(func(notBefore time.Time) bool {
+			return notBefore.Before(time.Now().Add(-50 * time.Second))
+		})).Return(nil).Once()
+		mailSender.Mock.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(0).(mailer.Message)
+		}).Return(nil).Once()
+
+		err := userUsecase.ResendVerificationHandler(context.Background(), input)
+
+		assert.Nil(t, err)
+		link, _ := url.Parse(strings.Fields(sent.Body[strings.Index(sent.Body, "http://"):])[0])
+		claims, err := helper.ParseVerificationToken(link.Query().Get("token"))
+		assert.NoError(t, err)
+		assert.Equal(t, "resend_uuid", claims.Subject)
+		assert.Equal(t, input.Email, claims.Email)
+	})
+
+	t.Run("rate_limited", func(t *testing.T) {
+		input := &domains.ResendVerification{Email: "limited@gmail.com"}
+		userRepository.Mock.On("FindByEmail", mock.Anything, input.Email).Return(repository.User{ID: "limited_uuid", Email: input.Email}).Once()
+		userRepository.Mock.On("MarkVerificationSent", mock.Anything, "limited_uuid", mock.Anything).Return(repository.ErrVerificationSentRecently).Once()
+
+		err := userUsecase.ResendVerificationHandler(context.Background(), input)
+
+		// Answered like an unknown address, only no mail goes out.
+		assert.Nil(t, err)
+		mailSender.Mock.AssertNotCalled(t, "Send", mock.MatchedBy(func(msg mailer.Message) bool {
+			return msg.To == input.Email
+		}))
+	})
+
+	t.Run("failed_update", func(t *testing.T) {
+		input := &domains.ResendVerification{Email: "failed@gmail.com"}
+		userRepository.Mock.On("FindByEmail", mock.Anything, input.Email).Return(repository.User{ID: "failed_uuid", Email: input.Email}).Once()
+		userRepository.Mock.On("MarkVerificationSent", mock.Anything, "failed_uuid", mock.Anything).Return(errors.New("Cannot send verification email!")).Once()
+
+		err := userUsecase.ResendVerificationHandler(context.Background(), input)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("already_verified", func(t *testing.T) {
+		input := &domains.ResendVerification{Email: "verified@gmail.com"}
+		verifiedAt := time.Now()
+		userRepository.Mock.On("FindByEmail", mock.Anything, input.Email).Return(repository.User{ID: "verified_uuid", Email: input.Email, EmailVerifiedAt: &verifiedAt}).Once()
+
+		err := userUsecase.ResendVerificationHandler(context.Background(), input)
+
+		assert.Nil(t, err)
+		userRepository.Mock.AssertNotCalled(t, "MarkVerificationSent", mock.Anything, "verified_uuid", mock.Anything)
+	})
+}
+
+func TestUserUsecase_LoginUnverified(t *testing.T) {
+	strict := userUsecase
+	strict.Options.AllowUnverifiedLogin = false
+
+	input := &domains.Login{Email: "unverified@gmail.com", Password: "password"}
+	userRepository.Mock.On("FindByEmail", mock.Anything, input.Email).Return(repository.User{
+		ID:       "unverified_uuid",
+		Email:    input.Email,
+		Password: hashPassword(input.Password),
+	}).Once()
+
+	user, token, err := strict.LoginHandler(context.Background(), input, "127.0.0.1")
+
+	assert.NotNil(t, user)
+	assert.Nil(t, token)
+	assert.EqualError(t, err, "Email not verified!")
+}
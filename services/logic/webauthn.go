@@ -0,0 +1,373 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	"api-auth/services/repository"
+	"api-auth/services/webauthn"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes of WebAuthn sessions.
+const (
+	webAuthnRegister = "register"
+	webAuthnLogin    = "login"
+	webAuthnMFA      = "mfa"
+	webAuthnReauth   = "reauth"
+)
+
+const maxPasskeyNameLength = 64
+
+var (
+	errPasskeyRequestExpired = newError(KindValidation, "passkey_request_expired", "Passkey request expired, please try again!")
+	errPasskeyNotRegistered  = newError(KindUnauthorized, "passkey_not_registered", "Passkey not registered!")
+	errInvalidPasskey        = newError(KindUnauthorized, "invalid_passkey_response", "Invalid passkey response!")
+	errSecondFactorRequired  = newError(KindForbidden, "second_factor_required", "A code or a passkey is required!")
+)
+
+// BeginWebAuthnRegistrationHandler starts adding a passkey to the account of
+// the caller. Passkeys the user already has are excluded so an authenticator
+// is not registered twice. A passkey logs the user in on its own, so adding
+// one takes re-authentication.
+func (uu *UserUsecase) BeginWebAuthnRegistrationHandler(ctx context.Context, principal *domains.Principal, input *domains.Reauthentication) (*domains.WebAuthnOptions, error) {
+	user, err := uu.Repository.FindById(ctx, principal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, notFound("User not found!")
+	}
+	credentials, err := uu.WebAuthn.UserWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uu.reauthenticate(ctx, user, credentials, input); err != nil {
+		return nil, err
+	}
+	session, err := uu.startWebAuthnSession(ctx, user.ID, webAuthnRegister)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := user.Name
+	if displayName == "" {
+		displayName = user.Email
+	}
+	options := uu.Options.RelyingParty.CreationOptions(session.Challenge, []byte(user.ID), user.Email, displayName, credentialDescriptors(credentials))
+	return &domains.WebAuthnOptions{SessionID: session.ID, PublicKey: options}, nil
+}
+
+// FinishWebAuthnRegistrationHandler verifies the new credential and stores
+// it. From then on the passkey logs the user in on its own and is accepted
+// as a second factor after a password.
+func (uu *UserUsecase) FinishWebAuthnRegistrationHandler(ctx context.Context, principal *domains.Principal, input *domains.WebAuthnRegistration) (*repository.WebAuthnCredential, error) {
+	session := uu.WebAuthn.UseWebAuthnSession(ctx, input.SessionID, webAuthnRegister)
+	if session == nil || session.UserID != principal.ID {
+		return nil, errPasskeyRequestExpired
+	}
+	clientData, err := webauthn.Decode(input.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, newError(KindValidation, errInvalidPasskey.Code, errInvalidPasskey.Message)
+	}
+	attestation, err := webauthn.Decode(input.Credential.Response.AttestationObject)
+	if err != nil {
+		return nil, newError(KindValidation, errInvalidPasskey.Code, errInvalidPasskey.Message)
+	}
+	verified, err := uu.Options.RelyingParty.VerifyRegistration(session.Challenge, clientData, attestation, false)
+	if err != nil {
+		return nil, newError(KindValidation, errInvalidPasskey.Code, err.Error())
+	}
+
+	id := webauthn.Encode(verified.ID)
+	// The id is the primary key, longer ones do not fit the column.
+	if len(id) > 255 {
+		return nil, invalidField("credential", "Passkey id is too long!")
+	}
+	if uu.WebAuthn.FindWebAuthnCredential(ctx, id) != nil {
+		return nil, newError(KindConflict, "passkey_exists", "Passkey already registered!")
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		return nil, invalidField("name", "Passkey name is too long!")
+	}
+	aaguid, _ := uuid.FromBytes(verified.AAGUID)
+
+	credential := &repository.WebAuthnCredential{
+		ID:         id,
+		UserID:     principal.ID,
+		Name:       name,
+		PublicKey:  verified.PublicKey,
+		Algorithm:  verified.Algorithm,
+		SignCount:  verified.SignCount,
+		AAGUID:     aaguid.String(),
+		Transports: strings.Join(input.Credential.Response.Transports, ","),
+		CreatedAt:  time.Now(),
+	}
+	if err := uu.WebAuthn.CreateWebAuthnCredential(ctx, credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// BeginWebAuthnLoginHandler starts a passwordless login. No credentials are
+// listed, the browser offers the passkeys it has for this site, so the
+// endpoint does not reveal which accounts exist.
+func (uu *UserUsecase) BeginWebAuthnLoginHandler(ctx context.Context) (*domains.WebAuthnOptions, error) {
+	session, err := uu.startWebAuthnSession(ctx, "", webAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+	options := uu.Options.RelyingParty.RequestOptions(session.Challenge, nil, webauthn.UserVerificationRequired)
+	return &domains.WebAuthnOptions{SessionID: session.ID, PublicKey: options}, nil
+}
+
+// FinishWebAuthnLoginHandler logs in with a passkey alone. User verification
+// is required, so the passkey stands for both factors and TOTP is not asked
+// for.
+func (uu *UserUsecase) FinishWebAuthnLoginHandler(ctx context.Context, input *domains.WebAuthnLogin, clientIP string) (*repository.User, *domains.Token, error) {
+	session := uu.WebAuthn.UseWebAuthnSession(ctx, input.SessionID, webAuthnLogin)
+	if session == nil {
+		return nil, nil, errPasskeyRequestExpired
+	}
+	credential := uu.WebAuthn.FindWebAuthnCredential(ctx, input.Credential.ID)
+	if credential == nil {
+		return nil, nil, errPasskeyNotRegistered
+	}
+	user, err := uu.Repository.FindById(ctx, credential.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, notFound("User not found!")
+	}
+	keys := uu.loginKeys(user.Email, clientIP)
+	if err := uu.checkLoginLocked(ctx, keys); err != nil {
+		return nil, nil, err
+	}
+	if err := uu.verifyPasskey(ctx, session, credential, &input.Credential, true); err != nil {
+		if err := uu.recordLoginFailure(ctx, keys); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, err
+	}
+	if user.EmailVerifiedAt == nil && !uu.Options.AllowUnverifiedLogin {
+		return user, nil, ErrEmailNotVerified
+	}
+	uu.clearLoginFailures(ctx, keys)
+
+	token, err := uu.issueTokens(ctx, user, uuid.New().String(), "", time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, token, nil
+}
+
+// BeginWebAuthnMFAHandler starts the second login step with a passkey, for
+// users who logged in with their password first.
+func (uu *UserUsecase) BeginWebAuthnMFAHandler(ctx context.Context, input *domains.WebAuthnMFABegin) (*domains.WebAuthnOptions, error) {
+	claims, err := helper.ParseMFAChallengeToken(input.MFAToken)
+	if err != nil {
+		return nil, errInvalidMFAChallenge
+	}
+	credentials, err := uu.WebAuthn.UserWebAuthnCredentials(ctx, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, newError(KindNotFound, "no_passkey_registered", "No passkey registered!")
+	}
+	session, err := uu.startWebAuthnSession(ctx, claims.Subject, webAuthnMFA)
+	if err != nil {
+		return nil, err
+	}
+	options := uu.Options.RelyingParty.RequestOptions(session.Challenge, credentialDescriptors(credentials), webauthn.UserVerificationPreferred)
+	return &domains.WebAuthnOptions{SessionID: session.ID, PublicKey: options}, nil
+}
+
+// FinishWebAuthnMFAHandler completes a password login with a passkey. Like
+// MFALoginHandler, failures count towards the lockout.
+func (uu *UserUsecase) FinishWebAuthnMFAHandler(ctx context.Context, input *domains.WebAuthnMFALogin, clientIP string) (*repository.User, *domains.Token, error) {
+	claims, err := helper.ParseMFAChallengeToken(input.MFAToken)
+	if err != nil {
+		return nil, nil, errInvalidMFAChallenge
+	}
+	user, err := uu.Repository.FindById(ctx, claims.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, notFound("User not found!")
+	}
+	keys := uu.loginKeys(user.Email, clientIP)
+	if err := uu.checkLoginLocked(ctx, keys); err != nil {
+		return nil, nil, err
+	}
+	session := uu.WebAuthn.UseWebAuthnSession(ctx, input.SessionID, webAuthnMFA)
+	if session == nil || session.UserID != user.ID {
+		return nil, nil, errPasskeyRequestExpired
+	}
+
+	credential := uu.WebAuthn.FindWebAuthnCredential(ctx, input.Credential.ID)
+	if credential == nil || credential.UserID != user.ID {
+		err = errPasskeyNotRegistered
+	} else {
+		err = uu.verifyPasskey(ctx, session, credential, &input.Credential, false)
+	}
+	if err != nil {
+		if err := uu.recordLoginFailure(ctx, keys); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, err
+	}
+	uu.clearLoginFailures(ctx, keys)
+
+	token, err := uu.issueTokens(ctx, user, uuid.New().String(), "", time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, token, nil
+}
+
+func (uu *UserUsecase) GetWebAuthnCredentialsHandler(ctx context.Context, principal *domains.Principal) ([]repository.WebAuthnCredential, error) {
+	return uu.WebAuthn.UserWebAuthnCredentials(ctx, principal.ID)
+}
+
+// DeleteWebAuthnCredentialHandler removes a passkey. Passkeys count as a
+// second factor, so like DisableMFAHandler it takes re-authentication.
+func (uu *UserUsecase) DeleteWebAuthnCredentialHandler(ctx context.Context, principal *domains.Principal, credentialId string, input *domains.Reauthentication) error {
+	user, err := uu.Repository.FindById(ctx, principal.ID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return notFound("User not found!")
+	}
+	credentials, err := uu.WebAuthn.UserWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if findCredential(credentials, credentialId) == nil {
+		return notFound("Passkey not found!")
+	}
+	if err := uu.reauthenticate(ctx, user, credentials, input); err != nil {
+		return err
+	}
+	return uu.WebAuthn.DeleteWebAuthnCredential(ctx, user.ID, credentialId)
+}
+
+// BeginWebAuthnReauthHandler starts proving a passkey of the caller, which
+// confirms a change to the sign-in methods of the account.
+func (uu *UserUsecase) BeginWebAuthnReauthHandler(ctx context.Context, principal *domains.Principal) (*domains.WebAuthnOptions, error) {
+	credentials, err := uu.WebAuthn.UserWebAuthnCredentials(ctx, principal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, newError(KindNotFound, "no_passkey_registered", "No passkey registered!")
+	}
+	session, err := uu.startWebAuthnSession(ctx, principal.ID, webAuthnReauth)
+	if err != nil {
+		return nil, err
+	}
+	options := uu.Options.RelyingParty.RequestOptions(session.Challenge, credentialDescriptors(credentials), webauthn.UserVerificationPreferred)
+	return &domains.WebAuthnOptions{SessionID: session.ID, PublicKey: options}, nil
+}
+
+// reauthenticate checks the password and, when the account has a second
+// factor, a code or one of its passkeys, so that a stolen session alone
+// cannot add or remove a way to log in. Wrong answers count towards the
+// lockout of the account.
+func (uu *UserUsecase) reauthenticate(ctx context.Context, user *repository.User, credentials []repository.WebAuthnCredential, input *domains.Reauthentication) error {
+	return uu.guardCredentials(ctx, user, func() error {
+		return uu.checkReauthentication(ctx, user, credentials, input)
+	})
+}
+
+func (uu *UserUsecase) checkReauthentication(ctx context.Context, user *repository.User, credentials []repository.WebAuthnCredential, input *domains.Reauthentication) error {
+	if err := checkCurrentPassword(user, input.Password); err != nil {
+		return err
+	}
+	totp := uu.totpEnabled(ctx, user.ID)
+	switch {
+	case input.Passkey != nil && len(credentials) > 0:
+		session := uu.WebAuthn.UseWebAuthnSession(ctx, input.Passkey.SessionID, webAuthnReauth)
+		if session == nil || session.UserID != user.ID {
+			return errPasskeyRequestExpired
+		}
+		credential := findCredential(credentials, input.Passkey.Credential.ID)
+		if credential == nil {
+			return errPasskeyNotRegistered
+		}
+		return uu.verifyPasskey(ctx, session, credential, &input.Passkey.Credential, false)
+	case input.Code != "" && totp:
+		return uu.verifySecondFactor(ctx, user.ID, input.Code)
+	case !totp && len(credentials) == 0:
+		return nil
+	}
+	return errSecondFactorRequired
+}
+
+func findCredential(credentials []repository.WebAuthnCredential, credentialId string) *repository.WebAuthnCredential {
+	for i := range credentials {
+		if credentials[i].ID == credentialId {
+			return &credentials[i]
+		}
+	}
+	return nil
+}
+
+func (uu *UserUsecase) startWebAuthnSession(ctx context.Context, userId, purpose string) (*repository.WebAuthnSession, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	return uu.WebAuthn.CreateWebAuthnSession(ctx, userId, challenge, purpose, time.Now().Add(uu.Options.RelyingParty.Timeout))
+}
+
+// verifyPasskey checks an assertion against the stored credential and saves
+// the new signature counter.
+func (uu *UserUsecase) verifyPasskey(ctx context.Context, session *repository.WebAuthnSession, credential *repository.WebAuthnCredential, response *domains.PublicKeyCredential, requireUV bool) error {
+	invalid := errInvalidPasskey
+	clientData, err := webauthn.Decode(response.Response.ClientDataJSON)
+	if err != nil {
+		return invalid
+	}
+	authData, err := webauthn.Decode(response.Response.AuthenticatorData)
+	if err != nil {
+		return invalid
+	}
+	signature, err := webauthn.Decode(response.Response.Signature)
+	if err != nil {
+		return invalid
+	}
+	if response.Response.UserHandle != "" {
+		userHandle, err := webauthn.Decode(response.Response.UserHandle)
+		if err != nil || string(userHandle) != credential.UserID {
+			return newError(KindUnauthorized, errInvalidPasskey.Code, "Passkey does not belong to this user!")
+		}
+	}
+
+	count, err := uu.Options.RelyingParty.VerifyAssertion(session.Challenge, clientData, authData, signature, credential.PublicKey, credential.SignCount, requireUV)
+	if err != nil {
+		return newError(KindUnauthorized, errInvalidPasskey.Code, err.Error())
+	}
+	return uu.WebAuthn.UpdateWebAuthnSignCount(ctx, credential.ID, credential.SignCount, count)
+}
+
+func credentialDescriptors(credentials []repository.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		descriptors[i] = webauthn.CredentialDescriptor{Type: "public-key", ID: credential.ID}
+		if credential.Transports != "" {
+			descriptors[i].Transports = strings.Split(credential.Transports, ",")
+		}
+	}
+	return descriptors
+}
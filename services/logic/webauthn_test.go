@@ -0,0 +1,283 @@
+package logic
+
+import (
+	"api-auth/app/helper"
+	"api-auth/domains"
+	mokz "api-auth/mock"
+	"api-auth/services/repository"
+	"api-auth/services/webauthn"
+	"api-auth/services/webauthn/webauthntest"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newWebAuthnUsecase() (UserUsecase, *mokz.WebAuthnRepositoryMock) {
+	credentials := &mokz.WebAuthnRepositoryMock{Mock: mock.Mock{}}
+	usecase := userUsecase
+	usecase.WebAuthn = credentials
+	usecase.LoginAttempts = repository.NewMemoryLoginAttemptRepository()
+	return usecase, credentials
+}
+
+func toPublicKeyCredential(t *testing.T, credential *webauthntest.Credential) domains.PublicKeyCredential {
+	raw, err := json.Marshal(credential)
+	assert.NoError(t, err)
+	result := domains.PublicKeyCredential{}
+	assert.NoError(t, json.Unmarshal(raw, &result))
+	return result
+}
+
+// registerPasskey runs a registration with the software authenticator and
+// returns what the repository was asked to store.
+func registerPasskey(t *testing.T, usecase UserUsecase, credentials *mokz.WebAuthnRepositoryMock, authenticator *webauthntest.Authenticator, user repository.User) repository.WebAuthnCredential {
+	principal := &domains.Principal{ID: user.ID, Email: user.Email}
+	userRepository.Mock.On("FindById", mock.Anything, user.ID).Return(user)
+	credentials.Mock.On("UserWebAuthnCredentials", mock.Anything, user.ID).Return([]repository.WebAuthnCredential{}, nil).Once()
+	credentials.Mock.On("CreateWebAuthnSession", mock.Anything, user.ID, mock.Anything, webAuthnRegister, mock.Anything).Return("register_session", nil).Once()
+
+	options, err := usecase.BeginWebAuthnRegistrationHandler(context.Background(), principal, &domains.Reauthentication{Password: "passwords"})
+	assert.NoError(t, err)
+	publicKey := options.PublicKey.(*webauthn.CreationOptions)
+	assert.Equal(t, webauthn.Encode([]byte(user.ID)), publicKey.User.ID)
+
+	response, err := authenticator.Create(publicKey)
+	assert.NoError(t, err)
+
+	var stored repository.WebAuthnCredential
+	credentials.Mock.On("UseWebAuthnSession", mock.Anything, "register_session", webAuthnRegister).
+		Return(repository.WebAuthnSession{ID: "register_session", UserID: user.ID, Challenge: publicKey.Challenge, Purpose: webAuthnRegister}).Once()
+	credentials.Mock.On("FindWebAuthnCredential", mock.Anything, response.ID).Return(nil).Once()
+	credentials.Mock.On("CreateWebAuthnCredential", mock.Anything, mock.MatchedBy(func(credential *repository.WebAuthnCredential) bool {
+		stored = *credential
+		return true
+	})).Return(nil).Once()
+
+	created, err := usecase.FinishWebAuthnRegistrationHandler(context.Background(), principal, &domains.WebAuthnRegistration{
+		SessionID:  options.SessionID,
+		Name:       "Laptop",
+		Credential: toPublicKeyCredential(t, response),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, response.ID, created.ID)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.Equal(t, "Laptop", stored.Name)
+	assert.Equal(t, webauthn.AlgES256, stored.Algorithm)
+	return stored
+}
+
+func TestUserUsecase_WebAuthnPasswordlessLogin(t *testing.T) {
+	usecase, credentials := newWebAuthnUsecase()
+	authenticator := webauthntest.New("localhost", "http://localhost:3000")
+	authenticator.CountSignatures = true
+	user := repository.User{ID: "passkey_uuid", Email: "passkey@gmail.com", Password: hashPassword("passwords")}
+
+	stored := registerPasskey(t, usecase, credentials, authenticator, user)
+
+	login := func(t *testing.T) (*domains.Token, error) {
+		credentials.Mock.On("CreateWebAuthnSession", mock.Anything, "", mock.Anything, webAuthnLogin, mock.Anything).Return("login_session", nil).Once()
+
+		options, err := usecase.BeginWebAuthnLoginHandler(context.Background())
+		assert.NoError(t, err)
+		publicKey := options.PublicKey.(*webauthn.RequestOptions)
+		assert.Empty(t, publicKey.AllowCredentials)
+		assert.Equal(t, webauthn.UserVerificationRequired, publicKey.UserVerification)
+
+		response, err := authenticator.Get(publicKey)
+		assert.NoError(t, err)
+		credentials.Mock.On("UseWebAuthnSession", mock.Anything, "login_session", webAuthnLogin).
+			Return(repository.WebAuthnSession{ID: "login_session", Challenge: publicKey.Challenge, Purpose: webAuthnLogin}).Once()
+		credentials.Mock.On("FindWebAuthnCredential", mock.Anything, stored.ID).Return(stored).Once()
+
+		_, token, err := usecase.FinishWebAuthnLoginHandler(context.Background(), &domains.WebAuthnLogin{
+			SessionID:  options.SessionID,
+			Credential: toPublicKeyCredential(t, response),
+		}, "127.0.0.1")
+		return token, err
+	}
+
+	t.Run("success", func(t *testing.T) {
+		credentials.Mock.On("UpdateWebAuthnSignCount", mock.Anything, stored.ID, stored.SignCount, stored.SignCount+1).Return(nil).Once()
+		refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+
+		token, err := login(t)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, token.AccessToken)
+		assert.NotEmpty(t, token.RefreshToken)
+	})
+
+	t.Run("cloned_authenticator", func(t *testing.T) {
+		// The stored counter is still the one from registration, the
+		// authenticator is one use ahead. Going back below it looks like a
+		// copy of the key being used.
+		stored.SignCount = 10
+
+		_, err := login(t)
+
+		assert.EqualError(t, err, "Signature counter did not increase, the authenticator may be cloned!")
+		failures := usecase.LoginAttempts.FindLoginAttempt(context.Background(), "account:"+user.Email)
+		assert.Equal(t, 1, failures.Failures)
+	})
+
+	t.Run("user_verification_required", func(t *testing.T) {
+		stored.SignCount = 0
+		authenticator.UserVerified = false
+
+		_, err := login(t)
+
+		assert.EqualError(t, err, "User verification is required!")
+	})
+}
+
+func TestUserUsecase_WebAuthnSecondFactor(t *testing.T) {
+	usecase, credentials := newWebAuthnUsecase()
+	authenticator := webauthntest.New("localhost", "http://localhost:3000")
+	authenticator.UserVerified = false
+	user := repository.User{ID: "passkey_mfa_uuid", Email: "passkey_mfa@gmail.com", Password: hashPassword("passwords")}
+
+	stored := registerPasskey(t, usecase, credentials, authenticator, user)
+	credentials.Mock.On("UserWebAuthnCredentials", mock.Anything, user.ID).Return([]repository.WebAuthnCredential{stored}, nil)
+	userRepository.Mock.On("FindByEmail", mock.Anything, user.Email).Return(user).Once()
+
+	_, challenge, err := usecase.LoginHandler(context.Background(), &domains.Login{Email: user.Email, Password: "passwords"}, "127.0.0.1")
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, challenge.MFAToken)
+	assert.Empty(t, challenge.AccessToken)
+	assert.Equal(t, []string{MFAMethodWebAuthn}, challenge.MFAMethods)
+
+	credentials.Mock.On("CreateWebAuthnSession", mock.Anything, user.ID, mock.Anything, webAuthnMFA, mock.Anything).Return("mfa_session", nil).Once()
+
+	options, err := usecase.BeginWebAuthnMFAHandler(context.Background(), &domains.WebAuthnMFABegin{MFAToken: challenge.MFAToken})
+	assert.NoError(t, err)
+	publicKey := options.PublicKey.(*webauthn.RequestOptions)
+	assert.Equal(t, stored.ID, publicKey.AllowCredentials[0].ID)
+
+	response, err := authenticator.Get(publicKey)
+	assert.NoError(t, err)
+	session := repository.WebAuthnSession{ID: "mfa_session", UserID: user.ID, Challenge: publicKey.Challenge, Purpose: webAuthnMFA}
+	input := &domains.WebAuthnMFALogin{MFAToken: challenge.MFAToken, SessionID: options.SessionID, Credential: toPublicKeyCredential(t, response)}
+
+	t.Run("session_of_other_user", func(t *testing.T) {
+		other := session
+		other.UserID = "someone_else"
+		credentials.Mock.On("UseWebAuthnSession", mock.Anything, "mfa_session", webAuthnMFA).Return(other).Once()
+
+		_, _, err := usecase.FinishWebAuthnMFAHandler(context.Background(), input, "127.0.0.1")
+
+		assert.EqualError(t, err, "Passkey request expired, please try again!")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		credentials.Mock.On("UseWebAuthnSession", mock.Anything, "mfa_session", webAuthnMFA).Return(session).Once()
+		credentials.Mock.On("FindWebAuthnCredential", mock.Anything, stored.ID).Return(stored).Once()
+		credentials.Mock.On("UpdateWebAuthnSignCount", mock.Anything, stored.ID, uint32(0), uint32(0)).Return(nil).Once()
+		refreshTokenRepository.Mock.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+
+		_, token, err := usecase.FinishWebAuthnMFAHandler(context.Background(), input, "127.0.0.1")
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, token.AccessToken)
+	})
+}
+
+func TestUserUsecase_WebAuthnReauthentication(t *testing.T) {
+	usecase, credentials := newWebAuthnUsecase()
+	authenticator := webauthntest.New("localhost", "http://localhost:3000")
+	user := repository.User{ID: "passkey_reauth_uuid", Email: "passkey_reauth@gmail.com", Password: hashPassword("passwords")}
+	principal := &domains.Principal{ID: user.ID, Email: user.Email}
+
+	stored := registerPasskey(t, usecase, credentials, authenticator, user)
+	credentials.Mock.On("UserWebAuthnCredentials", mock.Anything, user.ID).Return([]repository.WebAuthnCredential{stored}, nil)
+
+	t.Run("wrong_password", func(t *testing.T) {
+		_, err := usecase.BeginWebAuthnRegistrationHandler(context.Background(), principal, &domains.Reauthentication{Password: "wrong_password"})
+		assert.ErrorIs(t, err, ErrWrongPassword)
+
+		err = usecase.DeleteWebAuthnCredentialHandler(context.Background(), principal, stored.ID, &domains.Reauthentication{Password: "wrong_password"})
+		assert.ErrorIs(t, err, ErrWrongPassword)
+	})
+
+	t.Run("password_alone", func(t *testing.T) {
+		// The passkey is the only second factor. Without it a stolen
+		// session and password could neither add a passkey nor remove it.
+		_, err := usecase.BeginWebAuthnRegistrationHandler(context.Background(), principal, &domains.Reauthentication{Password: "passwords"})
+		assert.ErrorIs(t, err, errSecondFactorRequired)
+
+		err = usecase.DeleteWebAuthnCredentialHandler(context.Background(), principal, stored.ID, &domains.Reauthentication{Password: "passwords", Code: "123456"})
+		assert.ErrorIs(t, err, errSecondFactorRequired)
+		credentials.Mock.AssertNotCalled(t, "DeleteWebAuthnCredential", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown_passkey", func(t *testing.T) {
+		err := usecase.DeleteWebAuthnCredentialHandler(context.Background(), principal, "missing", &domains.Reauthentication{Password: "passwords"})
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("with_passkey", func(t *testing.T) {
+		credentials.Mock.On("CreateWebAuthnSession", mock.Anything, user.ID, mock.Anything, webAuthnReauth, mock.Anything).Return("reauth_session", nil).Once()
+
+		options, err := usecase.BeginWebAuthnReauthHandler(context.Background(), principal)
+		assert.NoError(t, err)
+		publicKey := options.PublicKey.(*webauthn.RequestOptions)
+		assert.Equal(t, stored.ID, publicKey.AllowCredentials[0].ID)
+
+		response, err := authenticator.Get(publicKey)
+		assert.NoError(t, err)
+		credentials.Mock.On("UseWebAuthnSession", mock.Anything, "reauth_session", webAuthnReauth).
+			Return(repository.WebAuthnSession{ID: "reauth_session", UserID: user.ID, Challenge: publicKey.Challenge, Purpose: webAuthnReauth}).Once()
+		credentials.Mock.On("UpdateWebAuthnSignCount", mock.Anything, stored.ID, mock.Anything, mock.Anything).Return(nil).Once()
+		credentials.Mock.On("DeleteWebAuthnCredential", mock.Anything, user.ID, stored.ID).Return(nil).Once()
+
+		err = usecase.DeleteWebAuthnCredentialHandler(context.Background(), principal, stored.ID, &domains.Reauthentication{
+			Password: "passwords",
+			Passkey:  &domains.WebAuthnAssertion{SessionID: options.SessionID, Credential: toPublicKeyCredential(t, response)},
+		})
+
+		assert.NoError(t, err)
+		credentials.Mock.AssertCalled(t, "DeleteWebAuthnCredential", mock.Anything, user.ID, stored.ID)
+	})
+}
+
+func TestUserUsecase_BeginWebAuthnRegistrationWithTOTP(t *testing.T) {
+	usecase, credentials := newWebAuthnUsecase()
+	mfa := &mokz.MFARepositoryMock{Mock: mock.Mock{}}
+	usecase.MFA = mfa
+	secret, _ := helper.GenerateTOTPSecret()
+	confirmed := time.Now()
+	user := repository.User{ID: "passkey_totp_uuid", Email: "passkey_totp@gmail.com", Password: hashPassword("passwords")}
+	principal := &domains.Principal{ID: user.ID, Email: user.Email}
+
+	userRepository.Mock.On("FindById", mock.Anything, user.ID).Return(user)
+	credentials.Mock.On("UserWebAuthnCredentials", mock.Anything, user.ID).Return([]repository.WebAuthnCredential{}, nil)
+	mfa.Mock.On("FindMFASecret", mock.Anything, user.ID).Return(repository.MFASecret{UserID: user.ID, Secret: secret, ConfirmedAt: &confirmed})
+
+	_, err := usecase.BeginWebAuthnRegistrationHandler(context.Background(), principal, &domains.Reauthentication{Password: "passwords"})
+	assert.ErrorIs(t, err, errSecondFactorRequired)
+
+	mfa.Mock.On("UseTOTPStep", mock.Anything, user.ID, mock.AnythingOfType("int64")).Return(nil).Once()
+	credentials.Mock.On("CreateWebAuthnSession", mock.Anything, user.ID, mock.Anything, webAuthnRegister, mock.Anything).Return("register_session", nil).Once()
+
+	options, err := usecase.BeginWebAuthnRegistrationHandler(context.Background(), principal, &domains.Reauthentication{Password: "passwords", Code: currentTOTP(secret)})
+	assert.NoError(t, err)
+	assert.Equal(t, "register_session", options.SessionID)
+}
+
+func TestUserUsecase_FinishWebAuthnRegistrationExpired(t *testing.T) {
+	usecase, credentials := newWebAuthnUsecase()
+	principal := &domains.Principal{ID: "passkey_uuid"}
+
+	credentials.Mock.On("UseWebAuthnSession", mock.Anything, "gone", webAuthnRegister).Return(nil).Once()
+
+	_, err := usecase.FinishWebAuthnRegistrationHandler(context.Background(), principal, &domains.WebAuthnRegistration{SessionID: "gone"})
+
+	assert.EqualError(t, err, "Passkey request expired, please try again!")
+	credentials.Mock.AssertNotCalled(t, "CreateWebAuthnCredential", mock.Anything, mock.Anything)
+}
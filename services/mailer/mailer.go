@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// LogMailer prints messages to the standard logger. It is meant for local
+// development where no mail server is around.
+type LogMailer struct {
+	From string
+}
+
+func NewLogMailer(from string) Mailer {
+	return &LogMailer{
+		From: from,
+	}
+}
+
+func (lm *LogMailer) Send(msg Message) error {
+	log.Printf("mail from=%s to=%s subject=%q\n%s", lm.From, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes every message as an .eml file into Dir.
+type FileMailer struct {
+	From string
+	Dir  string
+}
+
+func NewFileMailer(from, dir string) Mailer {
+	return &FileMailer{
+		From: from,
+		Dir:  dir,
+	}
+}
+
+func (fm *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(fm.Dir, 0700); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", fm.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), uuid.New().String())
+	return os.WriteFile(filepath.Join(fm.Dir, name), []byte(b.String()), 0600)
+}
@@ -0,0 +1,25 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMailer_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := NewFileMailer("no-reply@api-auth.local", dir)
+
+	err := m.Send(Message{To: "kale@gmail.com", Subject: "Hello", Body: "body line"})
+	assert.NoError(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.Len(t, files, 1)
+
+	raw, _ := os.ReadFile(files[0])
+	assert.True(t, strings.Contains(string(raw), "To: kale@gmail.com\r\n"))
+	assert.True(t, strings.HasSuffix(string(raw), "body line"))
+}
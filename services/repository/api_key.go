@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// APIKey belongs either to a user or, when UserID is empty, to the named
+// service account. Lookup is the public part of the key it is found by, the
+// secret part is only kept as a salted hash.
+type APIKey struct {
+	ID             string `json:"id" gorm:"primary_key"`
+	Lookup         string `json:"lookup" gorm:"unique_index"`
+	Salt           string `json:"-"`
+	SecretHash     string `json:"-"`
+	UserID         string `json:"userId,omitempty" gorm:"index"`
+	ServiceAccount string `json:"serviceAccount,omitempty" gorm:"index"`
+	Name           string `json:"name"`
+	// Scopes are the space separated permissions the key may use.
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type APIKeyRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type APIKeyRepositoryInterface interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	FindAPIKeyByLookup(ctx context.Context, lookup string) *APIKey
+	UserAPIKeys(ctx context.Context, userId string) ([]APIKey, error)
+	ServiceAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, userId, keyId string) error
+	TouchAPIKey(ctx context.Context, keyId string, usedAt time.Time) error
+}
+
+func NewAPIKeyRepository(db *gorm.DB, timeouts Timeouts) APIKeyRepositoryInterface {
+	return &APIKeyRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (ar *APIKeyRepository) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	return withContext(ctx, ar.db, ar.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Create(key)
+		if result.Error != nil {
+			return errors.New("Cannot create API key!")
+		}
+		return nil
+	})
+}
+
+func (ar *APIKeyRepository) FindAPIKeyByLookup(ctx context.Context, lookup string) *APIKey {
+	key := APIKey{}
+
+	err := withContext(ctx, ar.db, ar.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&key, "lookup = ?", lookup).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &key
+}
+
+// UserAPIKeys lists the keys of the user that were not revoked.
+func (ar *APIKeyRepository) UserAPIKeys(ctx context.Context, userId string) ([]APIKey, error) {
+	keys := []APIKey{}
+
+	err := withContext(ctx, ar.db, ar.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Where("user_id = ? AND revoked_at IS NULL", userId).Order("created_at").Find(&keys).Error; err != nil {
+			return errors.New("Cannot fetch API keys!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ServiceAPIKeys lists the keys of all service accounts that were not
+// revoked.
+func (ar *APIKeyRepository) ServiceAPIKeys(ctx context.Context) ([]APIKey, error) {
+	keys := []APIKey{}
+
+	err := withContext(ctx, ar.db, ar.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Where("user_id = ? AND revoked_at IS NULL", "").Order("service_account, created_at").Find(&keys).Error; err != nil {
+			return errors.New("Cannot fetch API keys!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes a key of the user, or of a service account when
+// userId is empty.
+func (ar *APIKeyRepository) RevokeAPIKey(ctx context.Context, userId, keyId string) error {
+	return withContext(ctx, ar.db, ar.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&APIKey{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyId, userId).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot revoke API key!")
+		}
+		if result.RowsAffected == 0 {
+			return notFound("API key not found!")
+		}
+		return nil
+	})
+}
+
+func (ar *APIKeyRepository) TouchAPIKey(ctx context.Context, keyId string, usedAt time.Time) error {
+	return withContext(ctx, ar.db, ar.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&APIKey{}).Where("id = ?", keyId).Update("last_used_at", usedAt)
+		if result.Error != nil {
+			return errors.New("Cannot update API key!")
+		}
+		return nil
+	})
+}
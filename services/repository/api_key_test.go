@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyRepository_RevokeAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := APIKeyRepository{db: dbase}
+
+	query := "UPDATE `api_keys` SET `revoked_at` = ? WHERE (id = ? AND user_id = ? AND revoked_at IS NULL)"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), "key", "uuid").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), "key", "other").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repos.RevokeAPIKey(context.Background(), "uuid", "key"))
+	err = repos.RevokeAPIKey(context.Background(), "other", "key")
+	assert.EqualError(t, err, "API key not found!")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"time"
+	"unsafe"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Timeouts bound a single operation of a repository, on top of the deadline
+// of the request it runs for. Zero leaves an operation to the request alone.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+// withTimeout is context.WithTimeout, without a timeout for zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// contextCommon are the methods *sql.DB and *sql.Tx run statements with a
+// context by.
+type contextCommon interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// contextTx passes ctx to every statement gorm runs on a transaction.
+type contextTx struct {
+	ctx context.Context
+	db  contextCommon
+}
+
+func (ct contextTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return ct.db.ExecContext(ct.ctx, query, args...)
+}
+
+func (ct contextTx) Prepare(query string) (*sql.Stmt, error) {
+	return ct.db.PrepareContext(ct.ctx, query)
+}
+
+func (ct contextTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return ct.db.QueryContext(ct.ctx, query, args...)
+}
+
+func (ct contextTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return ct.db.QueryRowContext(ct.ctx, query, args...)
+}
+
+// contextDB is contextTx for a connection pool. The transactions gorm
+// begins around a single write are bound to ctx as well.
+type contextDB struct {
+	contextTx
+	pool *sql.DB
+}
+
+func (cd contextDB) Begin() (*sql.Tx, error) {
+	return cd.pool.BeginTx(cd.ctx, nil)
+}
+
+func (cd contextDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return cd.pool.BeginTx(ctx, opts)
+}
+
+// scoped returns a copy of db that runs its statements with ctx. gorm v1
+// only takes a context when a transaction begins, and cannot swap the
+// connection of a handle, so the copy gets its connection set directly.
+// It keeps the logger, callbacks and other settings of db, and the
+// transaction db may be in.
+func scoped(ctx context.Context, db *gorm.DB) *gorm.DB {
+	var common gorm.SQLCommon
+	switch conn := db.CommonDB().(type) {
+	case *sql.DB:
+		common = contextDB{contextTx: contextTx{ctx: ctx, db: conn}, pool: conn}
+	case contextCommon:
+		common = contextTx{ctx: ctx, db: conn}
+	default:
+		return db
+	}
+	handle := db.New()
+	field := reflect.ValueOf(handle).Elem().FieldByName("db")
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(common))
+	handle.Dialect().SetDB(common)
+	return handle
+}
+
+// withContext runs fn with db scoped to ctx and timeout. fn runs single
+// statements, gorm still wraps a create or an update in a transaction of
+// its own. Rows must be read before fn returns.
+func withContext(ctx context.Context, db *gorm.DB, timeout time.Duration, fn func(db *gorm.DB) error) error {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := fn(scoped(ctx, db)); err != nil {
+		return contextErr(ctx, err)
+	}
+	return nil
+}
+
+// withTransaction runs fn in a transaction of db that is bound to ctx and
+// ends after timeout, for writes of several statements. fn must do all of
+// its work on tx, another repository call would wait for a connection the
+// transaction may hold.
+func withTransaction(ctx context.Context, db *gorm.DB, timeout time.Duration, fn func(tx *gorm.DB) error) error {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	tx := db.BeginTx(ctx, nil)
+	if tx.Error != nil {
+		return contextErr(ctx, tx.Error)
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err := fn(tx); err != nil {
+		return contextErr(ctx, err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return contextErr(ctx, err)
+	}
+	return nil
+}
+
+// contextErr returns the error of ctx once it is done, so callers can tell a
+// timeout or a cancelled request from a failing statement, and err otherwise.
+func contextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
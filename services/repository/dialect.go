@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// The repositories work with MySQL, PostgreSQL and SQLite. IDs are UUIDs
+// made by newUUID and stored as strings rather than in the UUID types some
+// of them have, and the differences that are left are kept in this file.
+
+// isUniqueViolation tells whether err is a duplicate of a primary key or a
+// unique index, whichever database reported it.
+func isUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	var pqErr *pq.Error
+	var sqliteErr sqlite3.Error
+	switch {
+	case errors.As(err, &mysqlErr):
+		return mysqlErr.Number == 1062
+	case errors.As(err, &pqErr):
+		return pqErr.Code == "23505"
+	case errors.As(err, &sqliteErr):
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
+
+// likeOperator matches case insensitively like LIKE does on MySQL and
+// SQLite. PostgreSQL needs ILIKE for that.
+func likeOperator(db *gorm.DB) string {
+	if db.Dialect().GetName() == "postgres" {
+		return "ILIKE"
+	}
+	return "LIKE"
+}
+
+// columnTime prepares a time for comparing with a column. SQLite keeps times
+// as text with the offset they were written with, so they only compare
+// correctly with times of the same zone. Stored times are local.
+func columnTime(t time.Time) time.Time {
+	return t.Local()
+}
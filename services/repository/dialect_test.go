@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLikeOperator(t *testing.T) {
+	for dialect, operator := range map[string]string{"mysql": "LIKE", "postgres": "ILIKE", "sqlite3": "LIKE"} {
+		db, _, err := sqlmock.New()
+		assert.NoError(t, err)
+		dbase, err := gorm.Open(dialect, db)
+		assert.NoError(t, err)
+
+		assert.Equal(t, operator, likeOperator(dbase), dialect)
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	assert.True(t, isUniqueViolation(&pq.Error{Code: "23505"}))
+	assert.False(t, isUniqueViolation(&pq.Error{Code: "23503"}))
+	assert.False(t, isUniqueViolation(nil))
+}
@@ -0,0 +1,39 @@
+package repository
+
+import "errors"
+
+// The kinds of failures callers can tell apart from a broken database.
+// errors.Is(err, ErrNotFound) holds for every write whose row is missing,
+// ErrConflict for every write a concurrent or earlier one got ahead of and
+// ErrInvalidQuery for a query that cannot be run as given. The errors keep a
+// message naming what failed.
+var (
+	ErrNotFound     = errors.New("Not found!")
+	ErrConflict     = errors.New("Conflict!")
+	ErrInvalidQuery = errors.New("Invalid query!")
+)
+
+type kindError struct {
+	kind    error
+	message string
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func notFound(message string) error {
+	return &kindError{kind: ErrNotFound, message: message}
+}
+
+func conflict(message string) error {
+	return &kindError{kind: ErrConflict, message: message}
+}
+
+func invalidQuery(message string) error {
+	return &kindError{kind: ErrInvalidQuery, message: message}
+}
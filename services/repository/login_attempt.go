@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// LoginAttempt counts failed logins for one identifier, which is either an
+// account ("account:<email>") or a client address ("ip:<addr>").
+type LoginAttempt struct {
+	Identifier  string `gorm:"primary_key"`
+	Failures    int
+	WindowStart time.Time
+	// Lockouts is how many times the key has been locked so far, it drives
+	// the exponential backoff.
+	Lockouts    int
+	LockedUntil *time.Time
+}
+
+type LoginAttemptRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type LoginAttemptRepositoryInterface interface {
+	FindLoginAttempt(ctx context.Context, key string) *LoginAttempt
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*LoginAttempt, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ClearLoginAttempts(ctx context.Context, key string) error
+}
+
+func NewLoginAttemptRepository(db *gorm.DB, timeouts Timeouts) LoginAttemptRepositoryInterface {
+	return &LoginAttemptRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (lr *LoginAttemptRepository) FindLoginAttempt(ctx context.Context, key string) *LoginAttempt {
+	attempt := LoginAttempt{}
+
+	err := withContext(ctx, lr.db, lr.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&attempt, "identifier = ?", key).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &attempt
+}
+
+// RecordLoginFailure bumps the failure counter in a single statement so that
+// parallel guesses cannot overwrite each other's count. A counter whose
+// window has passed starts again at one.
+func (lr *LoginAttemptRepository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*LoginAttempt, error) {
+	now := time.Now()
+	windowStart := now.Add(-window)
+
+	// A failed create ends the transaction, the retry runs in a new one.
+	raced := errors.New("Login attempt was created concurrently!")
+	for i := 0; i < 2; i++ {
+		attempt := LoginAttempt{}
+		err := withTransaction(ctx, lr.db, lr.timeouts.Write, func(tx *gorm.DB) error {
+			result := tx.Model(&LoginAttempt{}).Where("identifier = ?", key).Updates(map[string]interface{}{
+				"failures":     gorm.Expr("CASE WHEN window_start < ? THEN 1 ELSE failures + 1 END", windowStart),
+				"window_start": gorm.Expr("CASE WHEN window_start < ? THEN ? ELSE window_start END", windowStart, now),
+			})
+			if result.Error != nil {
+				return errors.New("Cannot record login attempt!")
+			}
+			if result.RowsAffected == 0 {
+				// First failure for this key. If a parallel request inserted
+				// it in the meantime the create fails and the update is
+				// retried.
+				create := tx.Create(&LoginAttempt{Identifier: key, Failures: 1, WindowStart: now})
+				if isUniqueViolation(create.Error) {
+					return raced
+				}
+				if create.Error != nil {
+					return errors.New("Cannot record login attempt!")
+				}
+			}
+			if err := tx.First(&attempt, "identifier = ?", key).Error; err != nil {
+				return errors.New("Cannot record login attempt!")
+			}
+			return nil
+		})
+		if err == raced {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &attempt, nil
+	}
+	return nil, errors.New("Cannot record login attempt!")
+}
+
+func (lr *LoginAttemptRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	return withContext(ctx, lr.db, lr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&LoginAttempt{}).Where("identifier = ?", key).Updates(map[string]interface{}{
+			"failures":     0,
+			"lockouts":     gorm.Expr("lockouts + 1"),
+			"locked_until": until,
+		})
+		if result.Error != nil {
+			return errors.New("Cannot lock login!")
+		}
+		return nil
+	})
+}
+
+func (lr *LoginAttemptRepository) ClearLoginAttempts(ctx context.Context, key string) error {
+	return withContext(ctx, lr.db, lr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Where("identifier = ?", key).Delete(&LoginAttempt{})
+		if result.Error != nil {
+			return errors.New("Cannot clear login attempts!")
+		}
+		return nil
+	})
+}
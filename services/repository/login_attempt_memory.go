@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLoginAttemptRepository keeps login failures in process memory. Use
+// the SQL store when more than one instance serves logins. Operations never
+// block, so they ignore their context.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]*LoginAttempt
+	writes   int
+}
+
+// pruneEvery bounds how often stale entries are swept, so that a flood of
+// addresses cannot grow the map without limit.
+const pruneEvery = 1024
+
+func NewMemoryLoginAttemptRepository() LoginAttemptRepositoryInterface {
+	return &MemoryLoginAttemptRepository{
+		attempts: map[string]*LoginAttempt{},
+	}
+}
+
+func (mr *MemoryLoginAttemptRepository) FindLoginAttempt(ctx context.Context, key string) *LoginAttempt {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	attempt, ok := mr.attempts[key]
+	if !ok {
+		return nil
+	}
+	copied := *attempt
+	return &copied
+}
+
+func (mr *MemoryLoginAttemptRepository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (*LoginAttempt, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	now := time.Now()
+	mr.writes++
+	if mr.writes%pruneEvery == 0 {
+		mr.prune(now.Add(-window), now)
+	}
+
+	attempt, ok := mr.attempts[key]
+	if !ok {
+		attempt = &LoginAttempt{Identifier: key, WindowStart: now}
+		mr.attempts[key] = attempt
+	}
+	if attempt.WindowStart.Before(now.Add(-window)) {
+		attempt.Failures = 0
+		attempt.WindowStart = now
+	}
+	attempt.Failures++
+
+	copied := *attempt
+	return &copied, nil
+}
+
+func (mr *MemoryLoginAttemptRepository) LockLogin(ctx context.Context, key string, until time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	attempt, ok := mr.attempts[key]
+	if !ok {
+		attempt = &LoginAttempt{Identifier: key, WindowStart: time.Now()}
+		mr.attempts[key] = attempt
+	}
+	attempt.Failures = 0
+	attempt.Lockouts++
+	attempt.LockedUntil = &until
+	return nil
+}
+
+func (mr *MemoryLoginAttemptRepository) ClearLoginAttempts(ctx context.Context, key string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	delete(mr.attempts, key)
+	return nil
+}
+
+// prune drops entries that are neither counting failures nor locked. Their
+// lockout history goes with them, which only shortens the next backoff.
+func (mr *MemoryLoginAttemptRepository) prune(windowStart, now time.Time) {
+	for key, attempt := range mr.attempts {
+		locked := attempt.LockedUntil != nil && attempt.LockedUntil.After(now)
+		if !locked && attempt.WindowStart.Before(windowStart) {
+			delete(mr.attempts, key)
+		}
+	}
+}
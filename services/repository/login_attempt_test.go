@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryLoginAttemptRepository(t *testing.T) {
+	attempts := NewMemoryLoginAttemptRepository()
+
+	assert.Nil(t, attempts.FindLoginAttempt(context.Background(), "account:kale@gmail.com"))
+
+	attempt, _ := attempts.RecordLoginFailure(context.Background(), "account:kale@gmail.com", time.Minute)
+	assert.Equal(t, 1, attempt.Failures)
+	attempt, _ = attempts.RecordLoginFailure(context.Background(), "account:kale@gmail.com", time.Minute)
+	assert.Equal(t, 2, attempt.Failures)
+
+	// A window of zero has always passed, so counting starts again.
+	attempt, _ = attempts.RecordLoginFailure(context.Background(), "account:kale@gmail.com", 0)
+	assert.Equal(t, 1, attempt.Failures)
+
+	until := time.Now().Add(time.Minute)
+	attempts.LockLogin(context.Background(), "account:kale@gmail.com", until)
+	attempt = attempts.FindLoginAttempt(context.Background(), "account:kale@gmail.com")
+	assert.Equal(t, 0, attempt.Failures)
+	assert.Equal(t, 1, attempt.Lockouts)
+	assert.Equal(t, until, *attempt.LockedUntil)
+
+	attempts.ClearLoginAttempts(context.Background(), "account:kale@gmail.com")
+	assert.Nil(t, attempts.FindLoginAttempt(context.Background(), "account:kale@gmail.com"))
+}
+
+func TestLoginAttemptRepository_RecordLoginFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, err := gorm.Open("mysql", db)
+	assert.NoError(t, err)
+	repos := LoginAttemptRepository{db: dbase}
+
+	update := "UPDATE `login_attempts` SET `failures` = CASE WHEN window_start < ? THEN 1 ELSE failures + 1 END, `window_start` = CASE WHEN window_start < ? THEN ? ELSE window_start END WHERE (identifier = ?)"
+	insert := "INSERT INTO `login_attempts`"
+	selectQuery := "SELECT * FROM `login_attempts` WHERE (identifier = ?)"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(update)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(insert)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs("ip:10.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"identifier", "failures", "window_start", "lockouts"}).AddRow("ip:10.0.0.1", 1, time.Now(), 0))
+	mock.ExpectCommit()
+
+	attempt, err := repos.RecordLoginFailure(context.Background(), "ip:10.0.0.1", time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
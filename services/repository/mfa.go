@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// MFASecret is the TOTP secret of a user. It only protects logins once
+// ConfirmedAt is set, which happens when the user proves their authenticator
+// produces valid codes.
+type MFASecret struct {
+	UserID      string `gorm:"primary_key"`
+	Secret      string
+	ConfirmedAt *time.Time
+	// LastUsedStep is the TOTP time step of the last accepted code, a code
+	// is never accepted twice.
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// RecoveryCode is a single-use fallback for a lost authenticator. Only the
+// hash is stored.
+type RecoveryCode struct {
+	ID       string `gorm:"primary_key"`
+	UserID   string `gorm:"index"`
+	CodeHash string `gorm:"unique_index"`
+	UsedAt   *time.Time
+}
+
+type MFARepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type MFARepositoryInterface interface {
+	FindMFASecret(ctx context.Context, userId string) *MFASecret
+	SaveMFASecret(ctx context.Context, userId string, secret string) error
+	ConfirmMFASecret(ctx context.Context, userId string) error
+	UseTOTPStep(ctx context.Context, userId string, step int64) error
+	DeleteMFA(ctx context.Context, userId string) error
+	ReplaceRecoveryCodes(ctx context.Context, userId string, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userId string, codeHash string) error
+}
+
+func NewMFARepository(db *gorm.DB, timeouts Timeouts) MFARepositoryInterface {
+	return &MFARepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (mr *MFARepository) FindMFASecret(ctx context.Context, userId string) *MFASecret {
+	secret := MFASecret{}
+
+	err := withContext(ctx, mr.db, mr.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&secret, "user_id = ?", userId).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &secret
+}
+
+// SaveMFASecret starts a new enrollment, replacing an unconfirmed one. It
+// fails when MFA is already enabled.
+func (mr *MFARepository) SaveMFASecret(ctx context.Context, userId string, secret string) error {
+	return withTransaction(ctx, mr.db, mr.timeouts.Write, func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND confirmed_at IS NULL", userId).Delete(&MFASecret{})
+		if result.Error != nil {
+			return errors.New("Cannot save MFA secret!")
+		}
+		result = tx.Create(&MFASecret{UserID: userId, Secret: secret})
+		if isUniqueViolation(result.Error) {
+			return conflict("MFA already enabled!")
+		}
+		if result.Error != nil {
+			return errors.New("Cannot save MFA secret!")
+		}
+		return nil
+	})
+}
+
+func (mr *MFARepository) ConfirmMFASecret(ctx context.Context, userId string) error {
+	return withContext(ctx, mr.db, mr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&MFASecret{}).
+			Where("user_id = ? AND confirmed_at IS NULL", userId).
+			Update("confirmed_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot confirm MFA!")
+		}
+		if result.RowsAffected == 0 {
+			return conflict("No pending MFA enrollment!")
+		}
+		return nil
+	})
+}
+
+// UseTOTPStep records that the code of a time step was used. It fails when
+// a code of that step or a later one was accepted before, which stops both
+// replays and concurrent use of the same code.
+func (mr *MFARepository) UseTOTPStep(ctx context.Context, userId string, step int64) error {
+	return withContext(ctx, mr.db, mr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&MFASecret{}).
+			Where("user_id = ? AND last_used_step < ?", userId, step).
+			Update("last_used_step", step)
+		if result.Error != nil {
+			return errors.New("Cannot use MFA code!")
+		}
+		if result.RowsAffected == 0 {
+			return conflict("MFA code already used!")
+		}
+		return nil
+	})
+}
+
+// DeleteMFA turns MFA off by removing the secret and the recovery codes.
+func (mr *MFARepository) DeleteMFA(ctx context.Context, userId string) error {
+	return withTransaction(ctx, mr.db, mr.timeouts.Write, func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&RecoveryCode{}).Error; err != nil {
+			return errors.New("Cannot disable MFA!")
+		}
+		if err := tx.Where("user_id = ?", userId).Delete(&MFASecret{}).Error; err != nil {
+			return errors.New("Cannot disable MFA!")
+		}
+		return nil
+	})
+}
+
+// ReplaceRecoveryCodes invalidates every recovery code of the user and
+// stores the new ones.
+func (mr *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userId string, codeHashes []string) error {
+	return withTransaction(ctx, mr.db, mr.timeouts.Write, func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&RecoveryCode{}).Error; err != nil {
+			return errors.New("Cannot save recovery codes!")
+		}
+		for _, hash := range codeHashes {
+			code := RecoveryCode{ID: newUUID(), UserID: userId, CodeHash: hash}
+			if err := tx.Create(&code).Error; err != nil {
+				return errors.New("Cannot save recovery codes!")
+			}
+		}
+		return nil
+	})
+}
+
+// UseRecoveryCode consumes a recovery code. Like the reset tokens, the update
+// is conditional so a code cannot be redeemed twice.
+func (mr *MFARepository) UseRecoveryCode(ctx context.Context, userId string, codeHash string) error {
+	return withContext(ctx, mr.db, mr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot use recovery code!")
+		}
+		if result.RowsAffected == 0 {
+			return notFound("Invalid MFA code!")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFARepository_UseTOTPStep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := MFARepository{db: dbase}
+
+	query := "UPDATE `mfa_secrets` SET `last_used_step` = ? WHERE (user_id = ? AND last_used_step < ?)"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(int64(100), "uuid", int64(100)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(int64(100), "uuid", int64(100)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repos.UseTOTPStep(context.Background(), "uuid", 100))
+	err = repos.UseTOTPStep(context.Background(), "uuid", 100)
+	assert.EqualError(t, err, "MFA code already used!")
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFARepository_UseRecoveryCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := MFARepository{db: dbase}
+
+	query := "UPDATE `recovery_codes` SET `used_at` = ? WHERE (user_id = ? AND code_hash = ? AND used_at IS NULL)"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), "uuid", "hash").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.EqualError(t, repos.UseRecoveryCode(context.Background(), "uuid", "hash"), "Invalid MFA code!")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
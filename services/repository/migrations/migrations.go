@@ -0,0 +1,143 @@
+// Package migrations keeps the versioned schema of the repositories. Every
+// dialect has a directory of migrations named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, versions count up from 1 without gaps. An up
+// migration can have a Go step as well, see steps.go.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed mysql postgres sqlite3
+var files embed.FS
+
+// Dialects are the directories of migrations, named like the gorm dialects.
+var Dialects = []string{"mysql", "postgres", "sqlite3"}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Step runs before the statements of Up, see steps.
+	Step Step
+}
+
+// Load reads the migrations of a dialect from dir, ordered by version.
+func Load(dir fs.FS, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(dir, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s/%s is not named <version>_<name>.(up|down).sql", dialect, entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(dir, dialect+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is used by %s and %s", dialect, version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("%s: expected version %d, found %d", dialect, i+1, migration.Version)
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("%s: version %d needs an up and a down migration", dialect, migration.Version)
+		}
+	}
+	return migrations, nil
+}
+
+// statements splits a migration into its statements, which end with a
+// semicolon at the end of a line. Lines starting with -- are comments.
+func statements(migration string) []string {
+	var result []string
+	var current []string
+	for _, line := range strings.Split(migration, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";"))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		result = append(result, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return result
+}
+
+// Create adds empty up and down migrations called name to the directory of
+// every dialect under dir, with the version after the newest one. It
+// returns the paths of the new files.
+func Create(dir, name string) ([]string, error) {
+	if !regexp.MustCompile(`^\w+$`).MatchString(name) {
+		return nil, errors.New("migration name may only have letters, digits and underscores")
+	}
+
+	version := 0
+	for _, dialect := range Dialects {
+		existing, err := Load(os.DirFS(dir), dialect)
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > version {
+			version = len(existing)
+		}
+	}
+	version++
+
+	var paths []string
+	for _, dialect := range Dialects {
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(dir, dialect, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+			content := fmt.Sprintf("-- %s %s for %s.\n", name, direction, dialect)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return paths, err
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,200 @@
+package migrations
+
+import (
+	"api-auth/services/repository"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Couldn't open sqlite: %v\n", err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoad(t *testing.T) {
+	dir := fstest.MapFS{
+		"mysql/0002_b.up.sql":   {Data: []byte("B;")},
+		"mysql/0002_b.down.sql": {Data: []byte("-B;")},
+		"mysql/0001_a.up.sql":   {Data: []byte("A;")},
+		"mysql/0001_a.down.sql": {Data: []byte("-A;")},
+	}
+
+	migrations, err := Load(dir, "mysql")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{{Version: 1, Name: "a", Up: "A;", Down: "-A;"}, {Version: 2, Name: "b", Up: "B;", Down: "-B;"}}, migrations)
+
+	tests := []struct {
+		name string
+		dir  fstest.MapFS
+	}{
+		{name: "gap", dir: fstest.MapFS{"mysql/0002_b.up.sql": {}, "mysql/0002_b.down.sql": {}}},
+		{name: "missing_down", dir: fstest.MapFS{"mysql/0001_a.up.sql": {Data: []byte("A;")}}},
+		{name: "bad_name", dir: fstest.MapFS{"mysql/init.sql": {}}},
+		{name: "same_version", dir: fstest.MapFS{"mysql/0001_a.up.sql": {}, "mysql/0001_b.down.sql": {}}},
+		{name: "no_dialect", dir: fstest.MapFS{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Load(test.dir, "mysql")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	migration := `-- comment
+CREATE TABLE a (
+  id int
+);
+
+UPDATE a SET id = 1;
+DROP TABLE b`
+
+	assert.Equal(t, []string{"CREATE TABLE a (\n  id int\n)", "UPDATE a SET id = 1", "DROP TABLE b"}, statements(migration))
+}
+
+// Every dialect must have the same migrations.
+func TestEmbeddedMigrations(t *testing.T) {
+	expected, err := Load(files, Dialects[0])
+	assert.NoError(t, err)
+
+	for _, dialect := range Dialects[1:] {
+		migrations, err := Load(files, dialect)
+		assert.NoError(t, err)
+		assert.Equal(t, len(expected), len(migrations), dialect)
+		for i := range migrations {
+			assert.Equal(t, expected[i].Name, migrations[i].Name, dialect)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db := openSQLite(t)
+	migrator, err := NewMigrator(db)
+	assert.NoError(t, err)
+
+	assert.EqualError(t, migrator.Check(), fmt.Sprintf("database schema is at version 0 but %d is needed, run migrate up", migrator.Latest()))
+
+	done, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Len(t, done, migrator.Latest())
+	assert.NoError(t, migrator.Check())
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt, status.Name)
+	}
+
+	// Up again has nothing to do.
+	done, err = migrator.Up()
+	assert.NoError(t, err)
+	assert.Empty(t, done)
+
+	reverted, err := migrator.Down()
+	assert.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), reverted.Version)
+	assert.Error(t, migrator.Check())
+}
+
+// The migrations must create every column the models of the repositories
+// use.
+func TestMigrationsMatchModels(t *testing.T) {
+	db := openSQLite(t)
+	migrator, err := NewMigrator(db)
+	assert.NoError(t, err)
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+
+	for _, model := range repository.Models {
+		scope := db.NewScope(model)
+		for _, field := range scope.GetModelStruct().StructFields {
+			if !field.IsNormal {
+				continue
+			}
+			assert.True(t, db.Dialect().HasColumn(scope.TableName(), field.DBName), scope.TableName()+"."+field.DBName)
+		}
+	}
+}
+
+func TestMigrationsAdoptAutoMigratedSchema(t *testing.T) {
+	db := openSQLite(t)
+	assert.NoError(t, db.AutoMigrate(repository.Models...).Error)
+	migrator, err := NewMigrator(db)
+	assert.NoError(t, err)
+
+	_, err = migrator.Up()
+
+	assert.NoError(t, err)
+	assert.NoError(t, migrator.Check())
+}
+
+func TestMigrationsNormalizeUserEmails(t *testing.T) {
+	db := openSQLite(t)
+	migrator, err := NewMigrator(db)
+	assert.NoError(t, err)
+	initial := &Migrator{db: db, migrations: migrator.migrations[:1]}
+	_, err = initial.Up()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec(`INSERT INTO users (id, email) VALUES ('a', ' Kale@Bücher.de'), ('b', 'Not An Email')`).Error)
+
+	_, err = migrator.Up()
+
+	assert.NoError(t, err)
+	var emails []string
+	assert.NoError(t, db.Table("users").Order("id").Pluck("email", &emails).Error)
+	assert.Equal(t, []string{"kale@xn--bcher-kva.de", "not an email"}, emails)
+}
+
+func TestMigrationsNormalizeUserEmailsConflict(t *testing.T) {
+	db := openSQLite(t)
+	migrator, err := NewMigrator(db)
+	assert.NoError(t, err)
+	initial := &Migrator{db: db, migrations: migrator.migrations[:1]}
+	_, err = initial.Up()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec(`INSERT INTO users (id, email) VALUES ('a', 'Kale@gmail.com'), ('b', 'kale@gmail.com '), ('c', 'other@gmail.com'), ('d', 'KALE@GMAIL.COM')`).Error)
+
+	_, err = migrator.Up()
+
+	assert.EqualError(t, err, "migration 0002_unique_user_email failed: emails of different users are the same once normalized, change all but one of each: kale@gmail.com (users a, b, d)")
+	var emails []string
+	assert.NoError(t, db.Table("users").Order("id").Pluck("email", &emails).Error)
+	assert.Equal(t, []string{"Kale@gmail.com", "kale@gmail.com ", "other@gmail.com", "KALE@GMAIL.COM"}, emails)
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	for _, dialect := range Dialects {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, dialect), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, dialect, "0001_init.up.sql"), []byte("A;"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, dialect, "0001_init.down.sql"), []byte("-A;"), 0644))
+	}
+
+	paths, err := Create(dir, "add_phone")
+
+	assert.NoError(t, err)
+	assert.Len(t, paths, 2*len(Dialects))
+	assert.Contains(t, paths, filepath.Join(dir, "postgres", "0002_add_phone.up.sql"))
+	for _, dialect := range Dialects {
+		migrations, err := Load(os.DirFS(dir), dialect)
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 2)
+	}
+
+	_, err = Create(dir, "add phone")
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   int `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status tells whether a migration has been applied, AppliedAt is nil when
+// it has not.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator with the embedded migrations of the
+// dialect of db.
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Load(files, db.Dialect().GetName())
+	if err != nil {
+		return nil, err
+	}
+	for i := range migrations {
+		migrations[i].Step = steps[migrations[i].Name]
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Latest is the version the schema has after all migrations.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version is the version of the schema, 0 for a database that was never
+// migrated.
+func (m *Migrator) Version() (int, error) {
+	if !m.db.HasTable(&SchemaMigration{}) {
+		return 0, nil
+	}
+	var version struct{ Max *int }
+	if err := m.db.Model(&SchemaMigration{}).Select("MAX(version) AS max").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("cannot read schema version: %w", err)
+	}
+	if version.Max == nil {
+		return 0, nil
+	}
+	return *version.Max, nil
+}
+
+// Check fails unless the schema is at the version of this build, so the
+// service does not run against tables it does not know.
+func (m *Migrator) Check() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	switch {
+	case version < m.Latest():
+		return fmt.Errorf("database schema is at version %d but %d is needed, run migrate up", version, m.Latest())
+	case version > m.Latest():
+		return fmt.Errorf("database schema is at version %d, newer than the %d of this build", version, m.Latest())
+	}
+	return nil
+}
+
+// Status lists every migration and when it was applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up applies the pending migrations in order and returns them. It stops at
+// the first one that fails.
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.db.AutoMigrate(&SchemaMigration{}).Error; err != nil {
+		return nil, fmt.Errorf("cannot create schema_migrations: %w", err)
+	}
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations[min(version, len(m.migrations)):] {
+		err := m.run(migration.Step, migration.Up, func(tx *gorm.DB) error {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the newest applied migration and returns it.
+func (m *Migrator) Down() (*Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return nil, errors.New("no migration to revert")
+	}
+	if version > m.Latest() {
+		return nil, fmt.Errorf("migration %d is not known to this build", version)
+	}
+
+	migration := m.migrations[version-1]
+	err = m.run(nil, migration.Down, func(tx *gorm.DB) error {
+		return tx.Where("version = ?", migration.Version).Delete(&SchemaMigration{}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reverting %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	return &migration, nil
+}
+
+// run executes a migration, after its step if it has one, and records it in
+// one transaction. MySQL commits on every schema change though, there a
+// failed migration may leave some of its statements applied.
+func (m *Migrator) run(step Step, migration string, record func(tx *gorm.DB) error) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if step != nil {
+			if err := step(tx); err != nil {
+				return err
+			}
+		}
+		for _, statement := range statements(migration) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
+
+func (m *Migrator) applied() (map[int]SchemaMigration, error) {
+	applied := map[int]SchemaMigration{}
+	if !m.db.HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+	var records []SchemaMigration
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("cannot read schema_migrations: %w", err)
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,192 @@
+-- The schema AutoMigrate created before migrations existed. IF NOT EXISTS
+-- lets this migration adopt databases that already have it.
+
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` varchar(255),
+  `name` varchar(255),
+  `email` varchar(255),
+  `password` varchar(255),
+  `email_verified_at` DATETIME NULL,
+  `verification_sent_at` DATETIME NULL,
+  `created_at` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_users_created_at (`created_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `refresh_tokens` (
+  `id` varchar(255),
+  `user_id` varchar(255),
+  `family_id` varchar(255),
+  `token_hash` varchar(255),
+  `replaced_by` varchar(255),
+  `client_id` varchar(255),
+  `scope` varchar(255),
+  `auth_time` DATETIME NULL,
+  `expires_at` DATETIME NULL,
+  `revoked_at` DATETIME NULL,
+  `created_at` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_refresh_tokens_user_id (`user_id`),
+  INDEX idx_refresh_tokens_family_id (`family_id`),
+  UNIQUE INDEX uix_refresh_tokens_token_hash (`token_hash`)
+);
+
+CREATE TABLE IF NOT EXISTS `revoked_tokens` (
+  `jti` varchar(255),
+  `expires_at` DATETIME NULL,
+  PRIMARY KEY (`jti`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_revocations` (
+  `user_id` varchar(255),
+  `revoked_before` DATETIME NULL,
+  PRIMARY KEY (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `password_reset_tokens` (
+  `id` varchar(255),
+  `user_id` varchar(255),
+  `token_hash` varchar(255),
+  `expires_at` DATETIME NULL,
+  `used_at` DATETIME NULL,
+  `created_at` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_password_reset_tokens_user_id (`user_id`),
+  UNIQUE INDEX uix_password_reset_tokens_token_hash (`token_hash`)
+);
+
+CREATE TABLE IF NOT EXISTS `login_attempts` (
+  `identifier` varchar(255),
+  `failures` int,
+  `window_start` DATETIME NULL,
+  `lockouts` int,
+  `locked_until` DATETIME NULL,
+  PRIMARY KEY (`identifier`)
+);
+
+CREATE TABLE IF NOT EXISTS `roles` (
+  `id` varchar(255),
+  `name` varchar(255),
+  `description` varchar(255),
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX uix_roles_name (`name`)
+);
+
+CREATE TABLE IF NOT EXISTS `permissions` (
+  `id` varchar(255),
+  `name` varchar(255),
+  `description` varchar(255),
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX uix_permissions_name (`name`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_roles` (
+  `user_id` varchar(255),
+  `role_id` varchar(255),
+  PRIMARY KEY (`user_id`, `role_id`),
+  INDEX idx_user_roles_role_id (`role_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `role_permissions` (
+  `role_id` varchar(255),
+  `permission_id` varchar(255),
+  PRIMARY KEY (`role_id`, `permission_id`),
+  INDEX idx_role_permissions_permission_id (`permission_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `mfa_secrets` (
+  `user_id` varchar(255),
+  `secret` varchar(255),
+  `confirmed_at` DATETIME NULL,
+  `last_used_step` bigint,
+  `created_at` DATETIME NULL,
+  PRIMARY KEY (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+  `id` varchar(255),
+  `user_id` varchar(255),
+  `code_hash` varchar(255),
+  `used_at` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_recovery_codes_user_id (`user_id`),
+  UNIQUE INDEX uix_recovery_codes_code_hash (`code_hash`)
+);
+
+CREATE TABLE IF NOT EXISTS `web_authn_credentials` (
+  `id` varchar(255),
+  `user_id` varchar(255),
+  `name` varchar(255),
+  `public_key` varbinary(255),
+  `algorithm` int,
+  `sign_count` int unsigned,
+  `aa_guid` varchar(255),
+  `transports` varchar(255),
+  `created_at` DATETIME NULL,
+  `last_used_at` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_web_authn_credentials_user_id (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `web_authn_sessions` (
+  `id` varchar(255),
+  `user_id` varchar(255),
+  `challenge` varchar(255),
+  `purpose` varchar(255),
+  `expires_at` DATETIME NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `o_auth_clients` (
+  `id` varchar(255),
+  `secret_hash` varchar(255),
+  `name` varchar(255),
+  `redirect_uris` varchar(255),
+  `grant_types` varchar(255),
+  `scopes` varchar(255),
+  `created_at` DATETIME NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `authorization_codes` (
+  `id` varchar(255),
+  `code_hash` varchar(255),
+  `client_id` varchar(255),
+  `user_id` varchar(255),
+  `redirect_uri` varchar(255),
+  `scope` varchar(255),
+  `code_challenge` varchar(255),
+  `nonce` varchar(255),
+  `auth_time` DATETIME NULL,
+  `expires_at` DATETIME NULL,
+  `used_at` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX uix_authorization_codes_code_hash (`code_hash`)
+);
+
+CREATE TABLE IF NOT EXISTS `o_auth_consents` (
+  `user_id` varchar(255),
+  `client_id` varchar(255),
+  `scope` varchar(255),
+  `updated_at` DATETIME NULL,
+  PRIMARY KEY (`user_id`, `client_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `api_keys` (
+  `id` varchar(255),
+  `lookup` varchar(255),
+  `salt` varchar(255),
+  `secret_hash` varchar(255),
+  `user_id` varchar(255),
+  `service_account` varchar(255),
+  `name` varchar(255),
+  `scopes` varchar(255),
+  `expires_at` DATETIME NULL,
+  `last_used_at` DATETIME NULL,
+  `revoked_at` DATETIME NULL,
+  `created_at` DATETIME NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_api_keys_user_id (`user_id`),
+  INDEX idx_api_keys_service_account (`service_account`),
+  UNIQUE INDEX uix_api_keys_lookup (`lookup`)
+);
@@ -0,0 +1 @@
+DROP INDEX uix_users_email ON `users`;
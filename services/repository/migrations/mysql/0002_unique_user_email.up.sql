@@ -0,0 +1,5 @@
+-- Emails are stored normalized and unique. Addresses saved before were
+-- normalized by the Go step of this migration, see steps.go. The index
+-- can't be created while two of them collide, the step fails with the ids
+-- of such accounts first.
+CREATE UNIQUE INDEX uix_users_email ON `users` (`email`);
@@ -0,0 +1 @@
+ALTER TABLE `users` DROP COLUMN `email_change_sent_at`;
@@ -0,0 +1,209 @@
+-- The schema AutoMigrate created before migrations existed. IF NOT EXISTS
+-- lets this migration adopt databases that already have it.
+
+CREATE TABLE IF NOT EXISTS "users" (
+  "id" text,
+  "name" text,
+  "email" text,
+  "password" text,
+  "email_verified_at" timestamp with time zone,
+  "verification_sent_at" timestamp with time zone,
+  "created_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON "users" ("created_at");
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+  "id" text,
+  "user_id" text,
+  "family_id" text,
+  "token_hash" text,
+  "replaced_by" text,
+  "client_id" text,
+  "scope" text,
+  "auth_time" timestamp with time zone,
+  "expires_at" timestamp with time zone,
+  "revoked_at" timestamp with time zone,
+  "created_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON "refresh_tokens" ("user_id");
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON "refresh_tokens" ("family_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_refresh_tokens_token_hash ON "refresh_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+  "jti" text,
+  "expires_at" timestamp with time zone,
+  PRIMARY KEY ("jti")
+);
+
+CREATE TABLE IF NOT EXISTS "user_revocations" (
+  "user_id" text,
+  "revoked_before" timestamp with time zone,
+  PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "password_reset_tokens" (
+  "id" text,
+  "user_id" text,
+  "token_hash" text,
+  "expires_at" timestamp with time zone,
+  "used_at" timestamp with time zone,
+  "created_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON "password_reset_tokens" ("user_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_password_reset_tokens_token_hash ON "password_reset_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+  "identifier" text,
+  "failures" integer,
+  "window_start" timestamp with time zone,
+  "lockouts" integer,
+  "locked_until" timestamp with time zone,
+  PRIMARY KEY ("identifier")
+);
+
+CREATE TABLE IF NOT EXISTS "roles" (
+  "id" text,
+  "name" text,
+  "description" text,
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_roles_name ON "roles" ("name");
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+  "id" text,
+  "name" text,
+  "description" text,
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_permissions_name ON "permissions" ("name");
+
+CREATE TABLE IF NOT EXISTS "user_roles" (
+  "user_id" text,
+  "role_id" text,
+  PRIMARY KEY ("user_id", "role_id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON "user_roles" ("role_id");
+
+CREATE TABLE IF NOT EXISTS "role_permissions" (
+  "role_id" text,
+  "permission_id" text,
+  PRIMARY KEY ("role_id", "permission_id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_permissions_permission_id ON "role_permissions" ("permission_id");
+
+CREATE TABLE IF NOT EXISTS "mfa_secrets" (
+  "user_id" text,
+  "secret" text,
+  "confirmed_at" timestamp with time zone,
+  "last_used_step" bigint,
+  "created_at" timestamp with time zone,
+  PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+  "id" text,
+  "user_id" text,
+  "code_hash" text,
+  "used_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON "recovery_codes" ("user_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_recovery_codes_code_hash ON "recovery_codes" ("code_hash");
+
+CREATE TABLE IF NOT EXISTS "web_authn_credentials" (
+  "id" text,
+  "user_id" text,
+  "name" text,
+  "public_key" bytea,
+  "algorithm" integer,
+  "sign_count" bigint,
+  "aa_guid" text,
+  "transports" text,
+  "created_at" timestamp with time zone,
+  "last_used_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_web_authn_credentials_user_id ON "web_authn_credentials" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "web_authn_sessions" (
+  "id" text,
+  "user_id" text,
+  "challenge" text,
+  "purpose" text,
+  "expires_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "o_auth_clients" (
+  "id" text,
+  "secret_hash" text,
+  "name" text,
+  "redirect_uris" text,
+  "grant_types" text,
+  "scopes" text,
+  "created_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "authorization_codes" (
+  "id" text,
+  "code_hash" text,
+  "client_id" text,
+  "user_id" text,
+  "redirect_uri" text,
+  "scope" text,
+  "code_challenge" text,
+  "nonce" text,
+  "auth_time" timestamp with time zone,
+  "expires_at" timestamp with time zone,
+  "used_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_authorization_codes_code_hash ON "authorization_codes" ("code_hash");
+
+CREATE TABLE IF NOT EXISTS "o_auth_consents" (
+  "user_id" text,
+  "client_id" text,
+  "scope" text,
+  "updated_at" timestamp with time zone,
+  PRIMARY KEY ("user_id", "client_id")
+);
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+  "id" text,
+  "lookup" text,
+  "salt" text,
+  "secret_hash" text,
+  "user_id" text,
+  "service_account" text,
+  "name" text,
+  "scopes" text,
+  "expires_at" timestamp with time zone,
+  "last_used_at" timestamp with time zone,
+  "revoked_at" timestamp with time zone,
+  "created_at" timestamp with time zone,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON "api_keys" ("user_id");
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_service_account ON "api_keys" ("service_account");
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_api_keys_lookup ON "api_keys" ("lookup");
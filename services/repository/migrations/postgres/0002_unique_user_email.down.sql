@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uix_users_email;
@@ -0,0 +1 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "email_change_sent_at";
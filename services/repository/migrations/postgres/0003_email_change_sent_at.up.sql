@@ -0,0 +1,4 @@
+-- Email changes are rate limited on their own, apart from verification
+-- links, so one flow cannot hold back the other. The column is added by the
+-- Go step of this migration, see steps.go, since a schema made by
+-- AutoMigrate may have it already.
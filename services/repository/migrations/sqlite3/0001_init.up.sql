@@ -0,0 +1,209 @@
+-- The schema AutoMigrate created before migrations existed. IF NOT EXISTS
+-- lets this migration adopt databases that already have it.
+
+CREATE TABLE IF NOT EXISTS "users" (
+  "id" varchar(255),
+  "name" varchar(255),
+  "email" varchar(255),
+  "password" varchar(255),
+  "email_verified_at" datetime,
+  "verification_sent_at" datetime,
+  "created_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON "users" ("created_at");
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+  "id" varchar(255),
+  "user_id" varchar(255),
+  "family_id" varchar(255),
+  "token_hash" varchar(255),
+  "replaced_by" varchar(255),
+  "client_id" varchar(255),
+  "scope" varchar(255),
+  "auth_time" datetime,
+  "expires_at" datetime,
+  "revoked_at" datetime,
+  "created_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON "refresh_tokens" ("user_id");
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON "refresh_tokens" ("family_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_refresh_tokens_token_hash ON "refresh_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+  "jti" varchar(255),
+  "expires_at" datetime,
+  PRIMARY KEY ("jti")
+);
+
+CREATE TABLE IF NOT EXISTS "user_revocations" (
+  "user_id" varchar(255),
+  "revoked_before" datetime,
+  PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "password_reset_tokens" (
+  "id" varchar(255),
+  "user_id" varchar(255),
+  "token_hash" varchar(255),
+  "expires_at" datetime,
+  "used_at" datetime,
+  "created_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON "password_reset_tokens" ("user_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_password_reset_tokens_token_hash ON "password_reset_tokens" ("token_hash");
+
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+  "identifier" varchar(255),
+  "failures" integer,
+  "window_start" datetime,
+  "lockouts" integer,
+  "locked_until" datetime,
+  PRIMARY KEY ("identifier")
+);
+
+CREATE TABLE IF NOT EXISTS "roles" (
+  "id" varchar(255),
+  "name" varchar(255),
+  "description" varchar(255),
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_roles_name ON "roles" ("name");
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+  "id" varchar(255),
+  "name" varchar(255),
+  "description" varchar(255),
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_permissions_name ON "permissions" ("name");
+
+CREATE TABLE IF NOT EXISTS "user_roles" (
+  "user_id" varchar(255),
+  "role_id" varchar(255),
+  PRIMARY KEY ("user_id", "role_id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON "user_roles" ("role_id");
+
+CREATE TABLE IF NOT EXISTS "role_permissions" (
+  "role_id" varchar(255),
+  "permission_id" varchar(255),
+  PRIMARY KEY ("role_id", "permission_id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_permissions_permission_id ON "role_permissions" ("permission_id");
+
+CREATE TABLE IF NOT EXISTS "mfa_secrets" (
+  "user_id" varchar(255),
+  "secret" varchar(255),
+  "confirmed_at" datetime,
+  "last_used_step" bigint,
+  "created_at" datetime,
+  PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+  "id" varchar(255),
+  "user_id" varchar(255),
+  "code_hash" varchar(255),
+  "used_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON "recovery_codes" ("user_id");
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_recovery_codes_code_hash ON "recovery_codes" ("code_hash");
+
+CREATE TABLE IF NOT EXISTS "web_authn_credentials" (
+  "id" varchar(255),
+  "user_id" varchar(255),
+  "name" varchar(255),
+  "public_key" blob,
+  "algorithm" integer,
+  "sign_count" integer,
+  "aa_guid" varchar(255),
+  "transports" varchar(255),
+  "created_at" datetime,
+  "last_used_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_web_authn_credentials_user_id ON "web_authn_credentials" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "web_authn_sessions" (
+  "id" varchar(255),
+  "user_id" varchar(255),
+  "challenge" varchar(255),
+  "purpose" varchar(255),
+  "expires_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "o_auth_clients" (
+  "id" varchar(255),
+  "secret_hash" varchar(255),
+  "name" varchar(255),
+  "redirect_uris" varchar(255),
+  "grant_types" varchar(255),
+  "scopes" varchar(255),
+  "created_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "authorization_codes" (
+  "id" varchar(255),
+  "code_hash" varchar(255),
+  "client_id" varchar(255),
+  "user_id" varchar(255),
+  "redirect_uri" varchar(255),
+  "scope" varchar(255),
+  "code_challenge" varchar(255),
+  "nonce" varchar(255),
+  "auth_time" datetime,
+  "expires_at" datetime,
+  "used_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_authorization_codes_code_hash ON "authorization_codes" ("code_hash");
+
+CREATE TABLE IF NOT EXISTS "o_auth_consents" (
+  "user_id" varchar(255),
+  "client_id" varchar(255),
+  "scope" varchar(255),
+  "updated_at" datetime,
+  PRIMARY KEY ("user_id", "client_id")
+);
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+  "id" varchar(255),
+  "lookup" varchar(255),
+  "salt" varchar(255),
+  "secret_hash" varchar(255),
+  "user_id" varchar(255),
+  "service_account" varchar(255),
+  "name" varchar(255),
+  "scopes" varchar(255),
+  "expires_at" datetime,
+  "last_used_at" datetime,
+  "revoked_at" datetime,
+  "created_at" datetime,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON "api_keys" ("user_id");
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_service_account ON "api_keys" ("service_account");
+
+CREATE UNIQUE INDEX IF NOT EXISTS uix_api_keys_lookup ON "api_keys" ("lookup");
@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"api-auth/app/helper"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Step is Go code an up migration runs in its transaction before its
+// statements, for changes SQL cannot make the same way on every dialect.
+type Step func(tx *gorm.DB) error
+
+// steps are the Go steps of the migrations by name, for every dialect.
+var steps = map[string]Step{
+	"unique_user_email":    normalizeUserEmails,
+	"email_change_sent_at": addEmailChangeSentAt,
+}
+
+// timestampColumns are the types of nullable timestamps in each dialect, as
+// in the first migration.
+var timestampColumns = map[string]string{
+	"mysql":    "DATETIME NULL",
+	"postgres": "timestamp with time zone",
+	"sqlite3":  "datetime",
+}
+
+// normalizeUserEmails brings emails saved before they were normalized into
+// the form of helper.NormalizeEmail, with the domains of internationalized
+// addresses in punycode. Emails it rejects are trimmed and case folded.
+// Accounts whose emails end up the same are left to an operator, the step
+// fails with their ids before it changes anything.
+func normalizeUserEmails(tx *gorm.DB) error {
+	var users []struct {
+		ID    string
+		Email string
+	}
+	if err := tx.Raw("SELECT id, email FROM users ORDER BY id").Scan(&users).Error; err != nil {
+		return err
+	}
+	normalized := make([]string, len(users))
+	owners := map[string][]string{}
+	for i, user := range users {
+		email, err := helper.NormalizeEmail(user.Email)
+		if err != nil {
+			email = strings.ToLower(strings.TrimSpace(user.Email))
+		}
+		normalized[i] = email
+		owners[email] = append(owners[email], user.ID)
+	}
+	var conflicts []string
+	for email, ids := range owners {
+		if len(ids) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("%s (users %s)", email, strings.Join(ids, ", ")))
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("emails of different users are the same once normalized, change all but one of each: %s", strings.Join(conflicts, "; "))
+	}
+
+	for i, user := range users {
+		if normalized[i] == user.Email {
+			continue
+		}
+		if err := tx.Exec("UPDATE users SET email = ? WHERE id = ?", normalized[i], user.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEmailChangeSentAt adds the column the email change flow is rate limited
+// by, unless it exists. Neither MySQL nor SQLite can add a column only if it
+// is missing.
+func addEmailChangeSentAt(tx *gorm.DB) error {
+	dialect := tx.Dialect()
+	if dialect.HasColumn("users", "email_change_sent_at") {
+		return nil
+	}
+	return tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+		dialect.Quote("users"), dialect.Quote("email_change_sent_at"), timestampColumns[dialect.GetName()])).Error
+}
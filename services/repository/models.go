@@ -0,0 +1,24 @@
+package repository
+
+// Models are the tables of the repositories. The migrations are tested to
+// create every column of them.
+var Models = []interface{}{
+	&User{},
+	&RefreshToken{},
+	&RevokedToken{},
+	&UserRevocation{},
+	&PasswordResetToken{},
+	&LoginAttempt{},
+	&Role{},
+	&Permission{},
+	&UserRole{},
+	&RolePermission{},
+	&MFASecret{},
+	&RecoveryCode{},
+	&WebAuthnCredential{},
+	&WebAuthnSession{},
+	&OAuthClient{},
+	&AuthorizationCode{},
+	&OAuthConsent{},
+	&APIKey{},
+}
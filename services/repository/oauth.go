@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// OAuthClient is an application allowed to request tokens. Public clients,
+// like single page and native apps, have no secret. RedirectURIs, GrantTypes
+// and Scopes are space separated lists.
+type OAuthClient struct {
+	ID           string    `json:"clientId" gorm:"primary_key"`
+	SecretHash   string    `json:"-"`
+	Name         string    `json:"name"`
+	RedirectURIs string    `json:"redirectUris"`
+	GrantTypes   string    `json:"grantTypes"`
+	Scopes       string    `json:"scopes"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// AuthorizationCode is handed to the client through the redirect and
+// exchanged once for tokens. Only the hash of the code is stored.
+type AuthorizationCode struct {
+	ID            string `gorm:"primary_key"`
+	CodeHash      string `gorm:"unique_index"`
+	ClientID      string
+	UserID        string
+	RedirectURI   string
+	Scope         string
+	CodeChallenge string
+	Nonce         string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+// OAuthConsent remembers the scopes a user granted to a client so they are
+// not asked again.
+type OAuthConsent struct {
+	UserID    string `gorm:"primary_key"`
+	ClientID  string `gorm:"primary_key"`
+	Scope     string
+	UpdatedAt time.Time
+}
+
+type OAuthRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type OAuthRepositoryInterface interface {
+	CreateOAuthClient(ctx context.Context, client *OAuthClient) error
+	FindOAuthClient(ctx context.Context, clientId string) *OAuthClient
+	OAuthClients(ctx context.Context) ([]OAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, clientId string) error
+	CreateAuthorizationCode(ctx context.Context, code *AuthorizationCode) error
+	FindAuthorizationCode(ctx context.Context, codeHash string) *AuthorizationCode
+	UseAuthorizationCode(ctx context.Context, codeId string) error
+	FindOAuthConsent(ctx context.Context, userId, clientId string) *OAuthConsent
+	SaveOAuthConsent(ctx context.Context, userId, clientId, scope string) error
+}
+
+func NewOAuthRepository(db *gorm.DB, timeouts Timeouts) OAuthRepositoryInterface {
+	return &OAuthRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (or *OAuthRepository) CreateOAuthClient(ctx context.Context, client *OAuthClient) error {
+	return withContext(ctx, or.db, or.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Create(client)
+		if result.Error != nil {
+			return errors.New("Cannot create client!")
+		}
+		return nil
+	})
+}
+
+func (or *OAuthRepository) FindOAuthClient(ctx context.Context, clientId string) *OAuthClient {
+	client := OAuthClient{}
+
+	err := withContext(ctx, or.db, or.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&client, "id = ?", clientId).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &client
+}
+
+func (or *OAuthRepository) OAuthClients(ctx context.Context) ([]OAuthClient, error) {
+	var clients []OAuthClient
+
+	err := withContext(ctx, or.db, or.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Order("name").Find(&clients).Error; err != nil {
+			return errors.New("Cannot fetch clients!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// DeleteOAuthClient removes the client with its consents and pending codes.
+// Its refresh tokens stop working because the client cannot authenticate
+// anymore.
+func (or *OAuthRepository) DeleteOAuthClient(ctx context.Context, clientId string) error {
+	return withTransaction(ctx, or.db, or.timeouts.Write, func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", clientId).Delete(&OAuthConsent{}).Error; err != nil {
+			return errors.New("Cannot delete client!")
+		}
+		if err := tx.Where("client_id = ?", clientId).Delete(&AuthorizationCode{}).Error; err != nil {
+			return errors.New("Cannot delete client!")
+		}
+		result := tx.Where("id = ?", clientId).Delete(&OAuthClient{})
+		if result.Error != nil {
+			return errors.New("Cannot delete client!")
+		}
+		if result.RowsAffected == 0 {
+			return notFound("Client not found!")
+		}
+		return nil
+	})
+}
+
+func (or *OAuthRepository) CreateAuthorizationCode(ctx context.Context, code *AuthorizationCode) error {
+	return withContext(ctx, or.db, or.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Create(code)
+		if result.Error != nil {
+			return errors.New("Cannot create authorization code!")
+		}
+		return nil
+	})
+}
+
+func (or *OAuthRepository) FindAuthorizationCode(ctx context.Context, codeHash string) *AuthorizationCode {
+	code := AuthorizationCode{}
+
+	err := withContext(ctx, or.db, or.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&code, "code_hash = ?", codeHash).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &code
+}
+
+// UseAuthorizationCode marks the code as redeemed. Like the reset tokens the
+// update is conditional, so of two concurrent exchanges only one wins.
+func (or *OAuthRepository) UseAuthorizationCode(ctx context.Context, codeId string) error {
+	return withContext(ctx, or.db, or.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&AuthorizationCode{}).
+			Where("id = ? AND used_at IS NULL", codeId).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot use authorization code!")
+		}
+		if result.RowsAffected == 0 {
+			return conflict("Authorization code already used!")
+		}
+		return nil
+	})
+}
+
+func (or *OAuthRepository) FindOAuthConsent(ctx context.Context, userId, clientId string) *OAuthConsent {
+	consent := OAuthConsent{}
+
+	err := withContext(ctx, or.db, or.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&consent, "user_id = ? AND client_id = ?", userId, clientId).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &consent
+}
+
+func (or *OAuthRepository) SaveOAuthConsent(ctx context.Context, userId, clientId, scope string) error {
+	return withContext(ctx, or.db, or.timeouts.Write, func(db *gorm.DB) error {
+		consent := OAuthConsent{UserID: userId, ClientID: clientId}
+
+		result := db.Where(consent).Assign(OAuthConsent{Scope: scope}).FirstOrCreate(&consent)
+		if result.Error != nil {
+			return errors.New("Cannot save consent!")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuthRepository_UseAuthorizationCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := OAuthRepository{db: dbase}
+
+	query := "UPDATE `authorization_codes` SET `used_at` = ? WHERE (id = ? AND used_at IS NULL)"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), "code").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), "code").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repos.UseAuthorizationCode(context.Background(), "code"))
+	err = repos.UseAuthorizationCode(context.Background(), "code")
+	assert.EqualError(t, err, "Authorization code already used!")
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// PasswordResetToken is a single-use token mailed to the account owner. Only
+// the hash is stored.
+type PasswordResetToken struct {
+	ID        string `gorm:"primary_key"`
+	UserID    string `gorm:"index"`
+	TokenHash string `gorm:"unique_index"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type PasswordResetRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type PasswordResetRepositoryInterface interface {
+	CreatePasswordResetToken(ctx context.Context, token *PasswordResetToken) error
+	FindPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*PasswordResetToken, error)
+	UsePasswordResetToken(ctx context.Context, tokenId string) error
+	InvalidateUserPasswordResetTokens(ctx context.Context, userId string) error
+}
+
+func NewPasswordResetRepository(db *gorm.DB, timeouts Timeouts) PasswordResetRepositoryInterface {
+	return &PasswordResetRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (pr *PasswordResetRepository) CreatePasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	return withContext(ctx, pr.db, pr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Create(token)
+		if result.Error != nil {
+			return errors.New("Cannot create reset token!")
+		}
+		return nil
+	})
+}
+
+// FindPasswordResetTokenByHash returns nil without an error when no token has
+// the hash.
+func (pr *PasswordResetRepository) FindPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*PasswordResetToken, error) {
+	token := PasswordResetToken{}
+	found := false
+
+	err := withContext(ctx, pr.db, pr.timeouts.Read, func(db *gorm.DB) error {
+		result := db.First(&token, "token_hash = ?", tokenHash)
+		if result.RecordNotFound() {
+			return nil
+		}
+		if result.Error != nil {
+			return errors.New("Cannot fetch reset token!")
+		}
+		found = true
+		return nil
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// UsePasswordResetToken consumes a token. It fails when the token was used
+// already, so a token cannot be redeemed twice even by concurrent requests.
+func (pr *PasswordResetRepository) UsePasswordResetToken(ctx context.Context, tokenId string) error {
+	return withContext(ctx, pr.db, pr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", tokenId).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot use reset token!")
+		}
+		if result.RowsAffected == 0 {
+			return conflict("Reset token already used!")
+		}
+		return nil
+	})
+}
+
+func (pr *PasswordResetRepository) InvalidateUserPasswordResetTokens(ctx context.Context, userId string) error {
+	return withContext(ctx, pr.db, pr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", userId).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot invalidate reset tokens!")
+		}
+		return nil
+	})
+}
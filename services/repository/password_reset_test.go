@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordResetRepository_FindPasswordResetTokenByHash(t *testing.T) {
+	query := "SELECT * FROM `password_reset_tokens` WHERE (token_hash = ?)"
+
+	setup := func() (*PasswordResetRepository, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		dbase, err := gorm.Open("mysql", db)
+		assert.NoError(t, err)
+		return &PasswordResetRepository{db: dbase}, mock
+	}
+
+	t.Run("found", func(t *testing.T) {
+		repos, mock := setup()
+		rows := sqlmock.NewRows([]string{"id", "user_id", "token_hash"}).AddRow("reset_uuid", "user_uuid", "hash")
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("hash").WillReturnRows(rows)
+
+		token, err := repos.FindPasswordResetTokenByHash(context.Background(), "hash")
+		assert.NoError(t, err)
+		assert.Equal(t, "reset_uuid", token.ID)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		repos, mock := setup()
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("unknown").WillReturnError(gorm.ErrRecordNotFound)
+
+		token, err := repos.FindPasswordResetTokenByHash(context.Background(), "unknown")
+		assert.NoError(t, err)
+		assert.Nil(t, token)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		repos, mock := setup()
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("hash").WillReturnError(errors.New("connection reset"))
+
+		token, err := repos.FindPasswordResetTokenByHash(context.Background(), "hash")
+		assert.EqualError(t, err, "Cannot fetch reset token!")
+		assert.Nil(t, token)
+	})
+}
+
+func TestPasswordResetRepository_UsePasswordResetToken(t *testing.T) {
+	query := "UPDATE `password_reset_tokens` SET `used_at` = ? WHERE (id = ? AND used_at IS NULL)"
+
+	setup := func() (*PasswordResetRepository, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		dbase, err := gorm.Open("mysql", db)
+		assert.NoError(t, err)
+		return &PasswordResetRepository{db: dbase}, mock
+	}
+
+	t.Run("unused_token", func(t *testing.T) {
+		repos, mock := setup()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), "reset_uuid").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repos.UsePasswordResetToken(context.Background(), "reset_uuid"))
+	})
+
+	t.Run("used_token", func(t *testing.T) {
+		repos, mock := setup()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), "reset_uuid").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.Error(t, repos.UsePasswordResetToken(context.Background(), "reset_uuid"))
+	})
+}
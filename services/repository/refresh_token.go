@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RefreshToken is stored hashed; the plain token is only ever handed to the
+// client. Tokens issued from the same login share a FamilyID so a reused
+// token can revoke everything that descended from it. ClientID and Scope are
+// set for tokens issued to OAuth clients.
+type RefreshToken struct {
+	ID         string `gorm:"primary_key"`
+	UserID     string `gorm:"index"`
+	FamilyID   string `gorm:"index"`
+	TokenHash  string `gorm:"unique_index"`
+	ReplacedBy string
+	ClientID   string
+	Scope      string
+	// AuthTime is when the user logged in to the family.
+	AuthTime  time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshTokenRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type RefreshTokenRepositoryInterface interface {
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+	FindRefreshTokenByHash(ctx context.Context, tokenHash string) *RefreshToken
+	RotateRefreshToken(ctx context.Context, tokenId, replacedBy string) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyId string) error
+	RevokeUserRefreshTokens(ctx context.Context, userId string) error
+}
+
+func NewRefreshTokenRepository(db *gorm.DB, timeouts Timeouts) RefreshTokenRepositoryInterface {
+	return &RefreshTokenRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (rr *RefreshTokenRepository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Create(token)
+		if result.Error != nil {
+			return errors.New("Cannot create refresh token!")
+		}
+		return nil
+	})
+}
+
+func (rr *RefreshTokenRepository) FindRefreshTokenByHash(ctx context.Context, tokenHash string) *RefreshToken {
+	token := RefreshToken{}
+
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&token, "token_hash = ?", tokenHash).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &token
+}
+
+// RotateRefreshToken marks a token as used. It only succeeds for a token that
+// is still live, so two concurrent refreshes with the same token cannot both
+// win.
+func (rr *RefreshTokenRepository) RotateRefreshToken(ctx context.Context, tokenId, replacedBy string) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", tokenId).
+			Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by": replacedBy})
+		if result.Error != nil {
+			return errors.New("Cannot rotate refresh token!")
+		}
+		if result.RowsAffected == 0 {
+			return conflict("Refresh token already used!")
+		}
+		return nil
+	})
+}
+
+func (rr *RefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyId).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot revoke refresh tokens!")
+		}
+		return nil
+	})
+}
+
+func (rr *RefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userId string) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userId).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot revoke refresh tokens!")
+		}
+		return nil
+	})
+}
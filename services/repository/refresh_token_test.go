@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRefreshTokenRepository(t *testing.T) (*RefreshTokenRepository, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, err := gorm.Open("mysql", db)
+	assert.NoError(t, err)
+
+	return &RefreshTokenRepository{db: dbase}, mock
+}
+
+func TestRefreshTokenRepository_FindRefreshTokenByHash(t *testing.T) {
+	repos, mock := setupRefreshTokenRepository(t)
+	query := "SELECT * FROM `refresh_tokens` WHERE (token_hash = ?) ORDER BY `refresh_tokens`.`id` ASC LIMIT 1"
+
+	t.Run("found", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "user_id", "family_id", "token_hash", "expires_at"}).
+			AddRow("refresh_uuid", "user_uuid", "family_uuid", "hash", time.Now().Add(time.Hour))
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("hash").WillReturnRows(rows)
+
+		token := repos.FindRefreshTokenByHash(context.Background(), "hash")
+
+		assert.NotNil(t, token)
+		assert.Equal(t, "family_uuid", token.FamilyID)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("unknown").WillReturnError(gorm.ErrRecordNotFound)
+
+		token := repos.FindRefreshTokenByHash(context.Background(), "unknown")
+
+		assert.Nil(t, token)
+	})
+}
+
+func TestRefreshTokenRepository_RotateRefreshToken(t *testing.T) {
+	query := "UPDATE `refresh_tokens` SET `replaced_by` = ?, `revoked_at` = ? WHERE (id = ? AND revoked_at IS NULL)"
+
+	t.Run("live_token", func(t *testing.T) {
+		repos, mock := setupRefreshTokenRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs("new_uuid", sqlmock.AnyArg(), "old_uuid").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repos.RotateRefreshToken(context.Background(), "old_uuid", "new_uuid"))
+	})
+
+	t.Run("already_rotated", func(t *testing.T) {
+		repos, mock := setupRefreshTokenRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs("new_uuid", sqlmock.AnyArg(), "old_uuid").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.Error(t, repos.RotateRefreshToken(context.Background(), "old_uuid", "new_uuid"))
+	})
+}
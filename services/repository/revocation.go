@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RevokedToken is a denylisted access token. Rows can be dropped once
+// ExpiresAt has passed because the token would be rejected anyway.
+type RevokedToken struct {
+	JTI       string `gorm:"primary_key"`
+	ExpiresAt time.Time
+}
+
+// UserRevocation invalidates every access token of a user issued before
+// RevokedBefore, which covers tokens whose jti we never saw.
+type UserRevocation struct {
+	UserID        string `gorm:"primary_key"`
+	RevokedBefore time.Time
+}
+
+type RevocationRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type RevocationRepositoryInterface interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUserTokens(ctx context.Context, userId string, before time.Time) error
+	IsTokenRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) bool
+}
+
+func NewRevocationRepository(db *gorm.DB, timeouts Timeouts) RevocationRepositoryInterface {
+	return &RevocationRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (rr *RevocationRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		db.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{})
+
+		result := db.Save(&RevokedToken{JTI: jti, ExpiresAt: expiresAt})
+		if result.Error != nil {
+			return errors.New("Cannot revoke token!")
+		}
+		return nil
+	})
+}
+
+func (rr *RevocationRepository) RevokeUserTokens(ctx context.Context, userId string, before time.Time) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Save(&UserRevocation{UserID: userId, RevokedBefore: before})
+		if result.Error != nil {
+			return errors.New("Cannot revoke user tokens!")
+		}
+		return nil
+	})
+}
+
+// IsTokenRevoked fails closed: if the store cannot be queried the token is
+// treated as revoked.
+func (rr *RevocationRepository) IsTokenRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) bool {
+	var count int
+	revocation := UserRevocation{}
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil || count > 0 {
+			return err
+		}
+		result := db.First(&revocation, "user_id = ?", userId)
+		if result.RecordNotFound() {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil || count > 0 {
+		return true
+	}
+	return issuedAt.Before(revocation.RevokedBefore)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRevocationRepository keeps the denylist in process memory. It is
+// only correct when a single instance of the service is running. Operations
+// never block, so they ignore their context.
+type MemoryRevocationRepository struct {
+	mu            sync.RWMutex
+	tokens        map[string]time.Time
+	revokedBefore map[string]time.Time
+}
+
+func NewMemoryRevocationRepository() RevocationRepositoryInterface {
+	return &MemoryRevocationRepository{
+		tokens:        map[string]time.Time{},
+		revokedBefore: map[string]time.Time{},
+	}
+}
+
+func (mr *MemoryRevocationRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range mr.tokens {
+		if exp.Before(now) {
+			delete(mr.tokens, id)
+		}
+	}
+	mr.tokens[jti] = expiresAt
+	return nil
+}
+
+func (mr *MemoryRevocationRepository) RevokeUserTokens(ctx context.Context, userId string, before time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if before.After(mr.revokedBefore[userId]) {
+		mr.revokedBefore[userId] = before
+	}
+	return nil
+}
+
+func (mr *MemoryRevocationRepository) IsTokenRevoked(ctx context.Context, jti, userId string, issuedAt time.Time) bool {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	if _, ok := mr.tokens[jti]; ok {
+		return true
+	}
+	return issuedAt.Before(mr.revokedBefore[userId])
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRevocationRepository(t *testing.T) {
+	revocations := NewMemoryRevocationRepository()
+	issuedAt := time.Now()
+
+	assert.False(t, revocations.IsTokenRevoked(context.Background(), "jti", "uuid", issuedAt))
+
+	revocations.RevokeToken(context.Background(), "jti", issuedAt.Add(time.Minute))
+	assert.True(t, revocations.IsTokenRevoked(context.Background(), "jti", "uuid", issuedAt))
+	assert.False(t, revocations.IsTokenRevoked(context.Background(), "other_jti", "uuid", issuedAt))
+
+	revocations.RevokeUserTokens(context.Background(), "uuid", issuedAt.Add(time.Second))
+	assert.True(t, revocations.IsTokenRevoked(context.Background(), "other_jti", "uuid", issuedAt))
+	assert.False(t, revocations.IsTokenRevoked(context.Background(), "other_jti", "uuid", issuedAt.Add(2*time.Second)))
+
+	// An older cutoff must not undo a newer one.
+	revocations.RevokeUserTokens(context.Background(), "uuid", issuedAt.Add(-time.Hour))
+	assert.True(t, revocations.IsTokenRevoked(context.Background(), "other_jti", "uuid", issuedAt))
+}
+
+func TestRevocationRepository_IsTokenRevoked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, err := gorm.Open("mysql", db)
+	assert.NoError(t, err)
+	repos := RevocationRepository{db: dbase}
+
+	countQuery := "SELECT count(*) FROM `revoked_tokens` WHERE (jti = ?)"
+	userQuery := "SELECT * FROM `user_revocations` WHERE (user_id = ?) ORDER BY `user_revocations`.`user_id` ASC LIMIT 1"
+	issuedAt := time.Now()
+
+	t.Run("denylisted", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(countQuery)).WithArgs("jti").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		assert.True(t, repos.IsTokenRevoked(context.Background(), "jti", "uuid", issuedAt))
+	})
+
+	t.Run("issued_before_cutoff", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(countQuery)).WithArgs("jti").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(userQuery)).WithArgs("uuid").WillReturnRows(sqlmock.NewRows([]string{"user_id", "revoked_before"}).AddRow("uuid", issuedAt.Add(time.Second)))
+
+		assert.True(t, repos.IsTokenRevoked(context.Background(), "jti", "uuid", issuedAt))
+	})
+
+	t.Run("not_revoked", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(countQuery)).WithArgs("jti").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(userQuery)).WithArgs("uuid").WillReturnError(gorm.ErrRecordNotFound)
+
+		assert.False(t, repos.IsTokenRevoked(context.Background(), "jti", "uuid", issuedAt))
+	})
+
+	t.Run("store_error_fails_closed", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(countQuery)).WithArgs("jti").WillReturnError(gorm.ErrInvalidSQL)
+
+		assert.True(t, repos.IsTokenRevoked(context.Background(), "jti", "uuid", issuedAt))
+	})
+}
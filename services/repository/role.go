@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+type Role struct {
+	ID          string       `json:"id" gorm:"primary_key"`
+	Name        string       `json:"name" gorm:"unique_index"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions" gorm:"-"`
+}
+
+// Permission is named "<resource>:<action>", e.g. "users:delete".
+type Permission struct {
+	ID          string `json:"id" gorm:"primary_key"`
+	Name        string `json:"name" gorm:"unique_index"`
+	Description string `json:"description"`
+}
+
+type UserRole struct {
+	UserID string `gorm:"primary_key"`
+	RoleID string `gorm:"primary_key;index"`
+}
+
+type RolePermission struct {
+	RoleID       string `gorm:"primary_key"`
+	PermissionID string `gorm:"primary_key;index"`
+}
+
+type RoleRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type RoleRepositoryInterface interface {
+	CreateRole(ctx context.Context, name, description string) (*Role, error)
+	FindRoleById(ctx context.Context, roleId string) *Role
+	FindRoleByName(ctx context.Context, name string) *Role
+	Roles(ctx context.Context) ([]Role, error)
+	DeleteRole(ctx context.Context, roleId string) ([]string, error)
+	CreatePermission(ctx context.Context, name, description string) (*Permission, error)
+	FindPermissionByName(ctx context.Context, name string) *Permission
+	Permissions(ctx context.Context) ([]Permission, error)
+	GrantPermission(ctx context.Context, roleId, permissionId string) error
+	RevokePermission(ctx context.Context, roleId, permissionId string) error
+	AssignRole(ctx context.Context, userId, roleId string) error
+	UnassignRole(ctx context.Context, userId, roleId string) error
+	UnassignAllRoles(ctx context.Context, userId string) error
+	UserRoles(ctx context.Context, userId string) ([]string, error)
+	RolePermissions(ctx context.Context, roleNames []string) ([]string, error)
+}
+
+func NewRoleRepository(db *gorm.DB, timeouts Timeouts) RoleRepositoryInterface {
+	return &RoleRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (rr *RoleRepository) CreateRole(ctx context.Context, name, description string) (*Role, error) {
+	role := Role{ID: newUUID(), Name: name, Description: description}
+
+	err := withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		if err := db.Create(&role).Error; err != nil {
+			return errors.New("Cannot create role!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (rr *RoleRepository) FindRoleById(ctx context.Context, roleId string) *Role {
+	role := Role{}
+
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&role, "id = ?", roleId).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &role
+}
+
+func (rr *RoleRepository) FindRoleByName(ctx context.Context, name string) *Role {
+	role := Role{}
+
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&role, "name = ?", name).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &role
+}
+
+// Roles lists every role along with the permissions granted to it.
+func (rr *RoleRepository) Roles(ctx context.Context) ([]Role, error) {
+	var roles []Role
+	var grants []struct {
+		RoleID string
+		Permission
+	}
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Order("name").Find(&roles).Error; err != nil {
+			return errors.New("Cannot fetch roles!")
+		}
+		if err := db.Table("role_permissions").
+			Select("role_permissions.role_id, permissions.id, permissions.name, permissions.description").
+			Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+			Order("permissions.name").
+			Scan(&grants).Error; err != nil {
+			return errors.New("Cannot fetch roles!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	index := map[string]int{}
+	for i := range roles {
+		roles[i].Permissions = []Permission{}
+		index[roles[i].ID] = i
+	}
+	for _, grant := range grants {
+		if i, ok := index[grant.RoleID]; ok {
+			roles[i].Permissions = append(roles[i].Permissions, grant.Permission)
+		}
+	}
+	return roles, nil
+}
+
+// DeleteRole removes the role together with its grants and assignments and
+// returns the IDs of the users that held it.
+func (rr *RoleRepository) DeleteRole(ctx context.Context, roleId string) ([]string, error) {
+	var holders []string
+	err := withTransaction(ctx, rr.db, rr.timeouts.Write, func(tx *gorm.DB) error {
+		if err := tx.Model(&UserRole{}).Where("role_id = ?", roleId).Pluck("user_id", &holders).Error; err != nil {
+			return errors.New("Cannot delete role!")
+		}
+		if err := tx.Where("role_id = ?", roleId).Delete(&RolePermission{}).Error; err != nil {
+			return errors.New("Cannot delete role!")
+		}
+		if err := tx.Where("role_id = ?", roleId).Delete(&UserRole{}).Error; err != nil {
+			return errors.New("Cannot delete role!")
+		}
+		result := tx.Where("id = ?", roleId).Delete(&Role{})
+		if result.Error != nil {
+			return errors.New("Cannot delete role!")
+		}
+		if result.RowsAffected == 0 {
+			return notFound("Role not found!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return holders, nil
+}
+
+func (rr *RoleRepository) CreatePermission(ctx context.Context, name, description string) (*Permission, error) {
+	permission := Permission{ID: newUUID(), Name: name, Description: description}
+
+	err := withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		if err := db.Create(&permission).Error; err != nil {
+			return errors.New("Cannot create permission!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (rr *RoleRepository) FindPermissionByName(ctx context.Context, name string) *Permission {
+	permission := Permission{}
+
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&permission, "name = ?", name).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &permission
+}
+
+func (rr *RoleRepository) Permissions(ctx context.Context) ([]Permission, error) {
+	var permissions []Permission
+
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Order("name").Find(&permissions).Error; err != nil {
+			return errors.New("Cannot fetch permissions!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// GrantPermission is idempotent, granting a permission twice is not an error.
+func (rr *RoleRepository) GrantPermission(ctx context.Context, roleId, permissionId string) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		grant := RolePermission{RoleID: roleId, PermissionID: permissionId}
+
+		result := db.Where(grant).FirstOrCreate(&grant)
+		if result.Error != nil {
+			return errors.New("Cannot grant permission!")
+		}
+		return nil
+	})
+}
+
+func (rr *RoleRepository) RevokePermission(ctx context.Context, roleId, permissionId string) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Where("role_id = ? AND permission_id = ?", roleId, permissionId).Delete(&RolePermission{})
+		if result.Error != nil {
+			return errors.New("Cannot revoke permission!")
+		}
+		return nil
+	})
+}
+
+// AssignRole is idempotent, assigning a role twice is not an error.
+func (rr *RoleRepository) AssignRole(ctx context.Context, userId, roleId string) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		assignment := UserRole{UserID: userId, RoleID: roleId}
+
+		result := db.Where(assignment).FirstOrCreate(&assignment)
+		if result.Error != nil {
+			return errors.New("Cannot assign role!")
+		}
+		return nil
+	})
+}
+
+func (rr *RoleRepository) UnassignRole(ctx context.Context, userId, roleId string) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Where("user_id = ? AND role_id = ?", userId, roleId).Delete(&UserRole{})
+		if result.Error != nil {
+			return errors.New("Cannot unassign role!")
+		}
+		return nil
+	})
+}
+
+func (rr *RoleRepository) UnassignAllRoles(ctx context.Context, userId string) error {
+	return withContext(ctx, rr.db, rr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Where("user_id = ?", userId).Delete(&UserRole{})
+		if result.Error != nil {
+			return errors.New("Cannot unassign roles!")
+		}
+		return nil
+	})
+}
+
+// UserRoles returns the names of the roles assigned to the user.
+func (rr *RoleRepository) UserRoles(ctx context.Context, userId string) ([]string, error) {
+	var names []string
+
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Table("roles").
+			Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+			Where("user_roles.user_id = ?", userId).
+			Order("roles.name").
+			Pluck("roles.name", &names).Error; err != nil {
+			return errors.New("Cannot fetch user roles!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// RolePermissions returns the distinct permission names granted to any of
+// the named roles.
+func (rr *RoleRepository) RolePermissions(ctx context.Context, roleNames []string) ([]string, error) {
+	var names []string
+	if len(roleNames) == 0 {
+		return names, nil
+	}
+
+	err := withContext(ctx, rr.db, rr.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Table("permissions").
+			Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+			Joins("JOIN roles ON roles.id = role_permissions.role_id").
+			Where("roles.name IN (?)", roleNames).
+			Order("permissions.name").
+			Pluck("DISTINCT permissions.name", &names).Error; err != nil {
+			return errors.New("Cannot fetch permissions!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
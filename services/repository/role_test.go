@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleRepository_UserRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := RoleRepository{db: dbase}
+
+	query := "SELECT roles.name FROM `roles` JOIN user_roles ON user_roles.role_id = roles.id WHERE (user_roles.user_id = ?) ORDER BY `roles`.`name`"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("admin").AddRow("support"))
+
+	roles, err := repos.UserRoles(context.Background(), "uuid")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "support"}, roles)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoleRepository_RolePermissions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := RoleRepository{db: dbase}
+
+	query := "SELECT DISTINCT permissions.name FROM `permissions` JOIN role_permissions ON role_permissions.permission_id = permissions.id JOIN roles ON roles.id = role_permissions.role_id WHERE (roles.name IN (?,?)) ORDER BY `permissions`.`name`"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("admin", "support").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("users:delete").AddRow("users:read"))
+
+	permissions, err := repos.RolePermissions(context.Background(), []string{"admin", "support"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users:delete", "users:read"}, permissions)
+
+	// No roles means no query at all.
+	permissions, err = repos.RolePermissions(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, permissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoleRepository_Roles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := RoleRepository{db: dbase}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `roles` ORDER BY `name`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description"}).AddRow("r1", "admin", "").AddRow("r2", "empty", ""))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT role_permissions.role_id, permissions.id, permissions.name, permissions.description FROM `role_permissions` JOIN permissions ON permissions.id = role_permissions.permission_id ORDER BY `permissions`.`name`")).
+		WillReturnRows(sqlmock.NewRows([]string{"role_id", "id", "name", "description"}).AddRow("r1", "p1", "users:delete", "").AddRow("r1", "p2", "users:read", ""))
+
+	roles, err := repos.Roles(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, roles, 2)
+	assert.Equal(t, []Permission{{ID: "p1", Name: "users:delete"}, {ID: "p2", Name: "users:read"}}, roles[0].Permissions)
+	assert.Empty(t, roles[1].Permissions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoleRepository_DeleteRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := RoleRepository{db: dbase}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT user_id FROM `user_roles` WHERE (role_id = ?)")).WithArgs("r1").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("ann").AddRow("bob"))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `role_permissions` WHERE (role_id = ?)")).WithArgs("r1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user_roles` WHERE (role_id = ?)")).WithArgs("r1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `roles` WHERE (id = ?)")).WithArgs("r1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	holders, err := repos.DeleteRole(context.Background(), "r1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ann", "bob"}, holders)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
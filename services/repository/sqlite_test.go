@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"api-auth/domains"
+	"api-auth/services/repository/migrations"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+// openSQLite opens an in memory database migrated to the latest schema.
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Couldn't open sqlite: %v\n", err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db)
+	assert.NoError(t, err)
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+
+	sequentialUUIDs(t)
+	return db
+}
+
+// sequentialUUIDs makes newUUID return uuid01, uuid02 and so on until the
+// test ends.
+func sequentialUUIDs(t *testing.T) {
+	var ids int64
+	restore := newUUID
+	newUUID = func() string {
+		return fmt.Sprintf("uuid%02d", atomic.AddInt64(&ids, 1))
+	}
+	t.Cleanup(func() { newUUID = restore })
+}
+
+func TestSQLite_UserRepository(t *testing.T) {
+	db := openSQLite(t)
+	users := NewUserRepository(db, Timeouts{})
+
+	for _, name := range []string{"Kale", "kalea", "Leo"} {
+		_, err := users.CreateUser(context.Background(), &domains.Register{Name: name, Email: name + "@gmail.com", Password: "hash"})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "Leo", findByEmail(t, users, "Leo@gmail.com").Name)
+
+	page, err := users.Users(context.Background(), &domains.UserQuery{Limit: 10, Sort: "name", Name: "ka", Total: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, *page.Total)
+
+	var seen []string
+	query := &domains.UserQuery{Limit: 1, Sort: "-createdAt"}
+	for {
+		page, err := users.Users(context.Background(), query)
+		assert.NoError(t, err)
+		for _, user := range page.Users {
+			seen = append(seen, user.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"Leo", "kalea", "Kale"}, seen)
+
+	duplicate := db.Create(&User{ID: "uuid01", Email: "other@gmail.com"})
+	assert.True(t, isUniqueViolation(duplicate.Error))
+	assert.False(t, isUniqueViolation(db.Exec("SELECT * FROM nope").Error))
+}
+
+func TestSQLite_RecordLoginFailure(t *testing.T) {
+	attempts := NewLoginAttemptRepository(openSQLite(t), Timeouts{})
+
+	attempt, err := attempts.RecordLoginFailure(context.Background(), "ip:10.0.0.1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+	attempt, err = attempts.RecordLoginFailure(context.Background(), "ip:10.0.0.1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempt.Failures)
+
+	// The window has passed, counting starts again.
+	attempt, err = attempts.RecordLoginFailure(context.Background(), "ip:10.0.0.1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+}
+
+func TestSQLite_SaveMFASecret(t *testing.T) {
+	mfa := NewMFARepository(openSQLite(t), Timeouts{})
+
+	assert.NoError(t, mfa.SaveMFASecret(context.Background(), "uuid", "secret"))
+	assert.NoError(t, mfa.SaveMFASecret(context.Background(), "uuid", "other"))
+	assert.NoError(t, mfa.ConfirmMFASecret(context.Background(), "uuid"))
+
+	assert.EqualError(t, mfa.SaveMFASecret(context.Background(), "uuid", "third"), "MFA already enabled!")
+	assert.Equal(t, "other", mfa.FindMFASecret(context.Background(), "uuid").Secret)
+}
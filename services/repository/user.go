@@ -1,112 +1,263 @@
-package repository
-
-import (
-	"api-auth/domains"
-	"errors"
-
-	"github.com/google/uuid"
-	"github.com/jinzhu/gorm"
-)
-
-type User struct {
-	ID       string `json:"id" gorm:"primary_key"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-type UserRepository struct {
-	db *gorm.DB
-}
-
-type UserRepositoryInterface interface {
-	FindByEmail(email string) *User
-	FindById(userId string) *User
-	CreateUser(input *domains.Register) error
-	UpdatePassword(input *domains.ChangePassword, userId string) error
-	Users() ([]User, error)
-	DeleteUserById(userId string) error
-}
-
-func NewUserRepository(db *gorm.DB) UserRepositoryInterface {
-	return &UserRepository{
-		db: db,
-	}
-}
-
-func (ur *UserRepository) FindByEmail(email string) *User {
-	user := User{}
-
-	result := ur.db.First(&user, "email = ?", email)
-	if result.Error != nil {
-		return nil
-	}
-
-	return &user
-}
-
-func (ur *UserRepository) FindById(userId string) *User {
-	user := User{}
-
-	result := ur.db.First(&user, "id = ?", userId)
-	if result.Error != nil {
-		return nil
-	}
-	return &user
-}
-
-func (ur *UserRepository) CreateUser(input *domains.Register) error {
-	user := User{}
-
-	newUser := User{
-		ID:       uuid.New().String(),
-		Name:     input.Name,
-		Email:    input.Email,
-		Password: input.Password,
-	}
-
-	result := ur.db.Model(&user).Create(&newUser)
-
-	if result.Error != nil {
-		return errors.New("Cannot create user!")
-	}
-
-	return nil
-}
-
-func (ur *UserRepository) UpdatePassword(input *domains.ChangePassword, userId string) (err error) {
-
-	result := ur.db.Model(&User{}).Where("id = ?", userId).Update("password", input.NewPassword)
-	// db.Model(User{}).Where("role = ?", "admin").Updates(User{Name: "hello", Age: 18})
-	// user.Password = input.NewPassword
-	// ur.db.Save(&user)
-
-	err = result.Error
-
-	if err != nil {
-		return
-	}
-	return
-}
-
-func (ur *UserRepository) Users() ([]User, error) {
-	var users []User
-
-	result := ur.db.Find(&users)
-
-	if result.Error != nil {
-		return nil, errors.New("User not found!")
-	}
-
-	return users, nil
-}
-
-func (ur *UserRepository) DeleteUserById(userId string) error {
-	user := User{}
-
-	result := ur.db.Where("id = ?", userId).Delete(&user)
-	if result.Error != nil {
-		return errors.New("Something wrong, cannot delete single user!")
-	}
-	return nil
-}
+package repository
+
+import (
+	"api-auth/domains"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+type User struct {
+	ID                 string     `json:"id" gorm:"primary_key"`
+	Name               string     `json:"name"`
+	Email              string     `json:"email" gorm:"unique_index"`
+	Password           string     `json:"-"`
+	EmailVerifiedAt    *time.Time `json:"emailVerifiedAt,omitempty"`
+	VerificationSentAt *time.Time `json:"-"`
+	EmailChangeSentAt  *time.Time `json:"-"`
+	CreatedAt          time.Time  `json:"createdAt" gorm:"index"`
+}
+
+// ErrUserExists is returned by CreateUser when the new user collides with
+// a unique key of an existing one, ErrEmailExists when that key is the
+// email. Emails are unique as given, callers normalize them first.
+// ErrVerificationSentRecently is returned by MarkVerificationSent inside
+// the resend interval, ErrEmailChangeSentRecently by MarkEmailChangeSent.
+var (
+	ErrUserExists               = errors.New("User already exists!")
+	ErrEmailExists              = errors.New("Email has been used!")
+	ErrVerificationSentRecently = errors.New("Verification email sent recently, please wait!")
+	ErrEmailChangeSentRecently  = errors.New("Email change requested recently, please wait!")
+)
+
+var newUUID = func() string {
+	return uuid.New().String()
+}
+
+type UserRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+// UserRepositoryInterface is implemented by the SQL and the memory
+// repository. Every method stops once ctx is done and returns its error.
+// The lookups return no user and no error when there is none.
+type UserRepositoryInterface interface {
+	FindByEmail(ctx context.Context, email string) (*User, error)
+	FindById(ctx context.Context, userId string) (*User, error)
+	CreateUser(ctx context.Context, input *domains.Register) (*User, error)
+	VerifyEmail(ctx context.Context, userId string, email string) error
+	MarkVerificationSent(ctx context.Context, userId string, notBefore time.Time) error
+	MarkEmailChangeSent(ctx context.Context, userId string, notBefore time.Time) error
+	ClearEmailChangeSent(ctx context.Context, userId string) error
+	UpdatePassword(ctx context.Context, userId string, passwordHash string) error
+	RehashPassword(ctx context.Context, userId string, oldHash string, newHash string) error
+	UpdateProfile(ctx context.Context, userId string, input *domains.UpdateProfile) error
+	ChangeEmail(ctx context.Context, userId string, previousEmail string, email string) error
+	Users(ctx context.Context, query *domains.UserQuery) (*UserPage, error)
+	DeleteUserById(ctx context.Context, userId string) error
+}
+
+func NewUserRepository(db *gorm.DB, timeouts Timeouts) UserRepositoryInterface {
+	return &UserRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (ur *UserRepository) FindByEmail(ctx context.Context, email string) (*User, error) {
+	return ur.findUser(ctx, "email = ?", email)
+}
+
+func (ur *UserRepository) FindById(ctx context.Context, userId string) (*User, error) {
+	return ur.findUser(ctx, "id = ?", userId)
+}
+
+func (ur *UserRepository) findUser(ctx context.Context, where string, value string) (*User, error) {
+	user := User{}
+	found := false
+
+	err := withContext(ctx, ur.db, ur.timeouts.Read, func(db *gorm.DB) error {
+		result := db.First(&user, where, value)
+		if result.RecordNotFound() {
+			return nil
+		}
+		if result.Error != nil {
+			return errors.New("Cannot fetch user!")
+		}
+		found = true
+		return nil
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (ur *UserRepository) CreateUser(ctx context.Context, input *domains.Register) (*User, error) {
+	newUser := User{
+		ID:       newUUID(),
+		Name:     input.Name,
+		Email:    input.Email,
+		Password: input.Password,
+	}
+
+	err := withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Create(&newUser)
+		if isUniqueViolation(result.Error) {
+			return ErrUserExists
+		}
+		if result.Error != nil {
+			return errors.New("Cannot create user!")
+		}
+		return nil
+	})
+	if errors.Is(err, ErrUserExists) {
+		existing, findErr := ur.FindByEmail(ctx, newUser.Email)
+		if findErr != nil {
+			return nil, findErr
+		}
+		if existing != nil {
+			return nil, ErrEmailExists
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &newUser, nil
+}
+
+// VerifyEmail marks the address as verified, as long as it is still the
+// address on the account.
+func (ur *UserRepository) VerifyEmail(ctx context.Context, userId string, email string) error {
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&User{}).
+			Where("id = ? AND email = ?", userId, email).
+			Update("email_verified_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot verify email!")
+		}
+		if result.RowsAffected == 0 {
+			return notFound("User not found!")
+		}
+		return nil
+	})
+}
+
+// MarkVerificationSent records that a verification email went out. It fails
+// when another one was sent after notBefore, which is how resends are rate
+// limited across instances.
+func (ur *UserRepository) MarkVerificationSent(ctx context.Context, userId string, notBefore time.Time) error {
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&User{}).
+			Where("id = ? AND (verification_sent_at IS NULL OR verification_sent_at < ?)", userId, notBefore).
+			Update("verification_sent_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot send verification email!")
+		}
+		if result.RowsAffected == 0 {
+			return ErrVerificationSentRecently
+		}
+		return nil
+	})
+}
+
+// MarkEmailChangeSent records that an email change confirmation went out,
+// like MarkVerificationSent does for verification links.
+func (ur *UserRepository) MarkEmailChangeSent(ctx context.Context, userId string, notBefore time.Time) error {
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&User{}).
+			Where("id = ? AND (email_change_sent_at IS NULL OR email_change_sent_at < ?)", userId, notBefore).
+			Update("email_change_sent_at", time.Now())
+		if result.Error != nil {
+			return errors.New("Cannot send email change confirmation!")
+		}
+		if result.RowsAffected == 0 {
+			return ErrEmailChangeSentRecently
+		}
+		return nil
+	})
+}
+
+// ClearEmailChangeSent gives back the slot taken by MarkEmailChangeSent when
+// the confirmation could not be sent.
+func (ur *UserRepository) ClearEmailChangeSent(ctx context.Context, userId string) error {
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		return db.Model(&User{}).Where("id = ?", userId).Update("email_change_sent_at", nil).Error
+	})
+}
+
+func (ur *UserRepository) UpdatePassword(ctx context.Context, userId string, passwordHash string) error {
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&User{}).Where("id = ?", userId).Update("password", passwordHash)
+		if result.Error != nil {
+			return errors.New("Cannot update password!")
+		}
+		return nil
+	})
+}
+
+// RehashPassword swaps the stored hash for a stronger one of the same
+// password. It does nothing when the password was changed in the meantime.
+func (ur *UserRepository) RehashPassword(ctx context.Context, userId string, oldHash string, newHash string) error {
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&User{}).
+			Where("id = ? AND password = ?", userId, oldHash).
+			Update("password", newHash)
+		if result.Error != nil {
+			return errors.New("Cannot update password!")
+		}
+		return nil
+	})
+}
+
+// UpdateProfile stores the fields of input that are set.
+func (ur *UserRepository) UpdateProfile(ctx context.Context, userId string, input *domains.UpdateProfile) error {
+	changes := map[string]interface{}{}
+	if input.Name != nil {
+		changes["name"] = *input.Name
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		if err := db.Model(&User{}).Where("id = ?", userId).Updates(changes).Error; err != nil {
+			return errors.New("Cannot update profile!")
+		}
+		return nil
+	})
+}
+
+// ChangeEmail moves the account to email, as long as previousEmail is still
+// the address on it. The new address counts as verified since the link that
+// confirmed the change was mailed there.
+func (ur *UserRepository) ChangeEmail(ctx context.Context, userId string, previousEmail string, email string) error {
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&User{}).
+			Where("id = ? AND email = ?", userId, previousEmail).
+			Updates(map[string]interface{}{"email": email, "email_verified_at": time.Now()})
+		if isUniqueViolation(result.Error) {
+			return ErrEmailExists
+		}
+		if result.Error != nil {
+			return errors.New("Cannot change email!")
+		}
+		if result.RowsAffected == 0 {
+			return notFound("User not found!")
+		}
+		return nil
+	})
+}
+
+func (ur *UserRepository) DeleteUserById(ctx context.Context, userId string) error {
+	return withContext(ctx, ur.db, ur.timeouts.Write, func(db *gorm.DB) error {
+		if err := db.Where("id = ?", userId).Delete(&User{}).Error; err != nil {
+			return errors.New("Something wrong, cannot delete single user!")
+		}
+		return nil
+	})
+}
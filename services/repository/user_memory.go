@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"api-auth/domains"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryUserRepository keeps users in process memory, for development and
+// tests. It behaves like UserRepository, strings compare byte by byte like
+// they do on SQLite. Operations never block, so the context is only checked
+// before each one.
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]*User
+}
+
+func NewMemoryUserRepository() UserRepositoryInterface {
+	return &MemoryUserRepository{
+		users: map[string]*User{},
+	}
+}
+
+func (mr *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	for _, user := range mr.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (mr *MemoryUserRepository) FindById(ctx context.Context, userId string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	user, ok := mr.users[userId]
+	if !ok {
+		return nil, nil
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (mr *MemoryUserRepository) CreateUser(ctx context.Context, input *domains.Register) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	newUser := User{
+		ID:        newUUID(),
+		Name:      input.Name,
+		Email:     input.Email,
+		Password:  input.Password,
+		CreatedAt: time.Now(),
+	}
+	if _, ok := mr.users[newUser.ID]; ok {
+		return nil, ErrUserExists
+	}
+	for _, user := range mr.users {
+		if user.Email == newUser.Email {
+			return nil, ErrEmailExists
+		}
+	}
+	stored := newUser
+	mr.users[newUser.ID] = &stored
+	return &newUser, nil
+}
+
+func (mr *MemoryUserRepository) VerifyEmail(ctx context.Context, userId string, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userId]
+	if !ok || user.Email != email {
+		return notFound("User not found!")
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+func (mr *MemoryUserRepository) MarkVerificationSent(ctx context.Context, userId string, notBefore time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userId]
+	if !ok || (user.VerificationSentAt != nil && !user.VerificationSentAt.Before(notBefore)) {
+		return ErrVerificationSentRecently
+	}
+	now := time.Now()
+	user.VerificationSentAt = &now
+	return nil
+}
+
+func (mr *MemoryUserRepository) MarkEmailChangeSent(ctx context.Context, userId string, notBefore time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userId]
+	if !ok || (user.EmailChangeSentAt != nil && !user.EmailChangeSentAt.Before(notBefore)) {
+		return ErrEmailChangeSentRecently
+	}
+	now := time.Now()
+	user.EmailChangeSentAt = &now
+	return nil
+}
+
+func (mr *MemoryUserRepository) ClearEmailChangeSent(ctx context.Context, userId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if user, ok := mr.users[userId]; ok {
+		user.EmailChangeSentAt = nil
+	}
+	return nil
+}
+
+func (mr *MemoryUserRepository) UpdatePassword(ctx context.Context, userId string, passwordHash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if user, ok := mr.users[userId]; ok {
+		user.Password = passwordHash
+	}
+	return nil
+}
+
+func (mr *MemoryUserRepository) RehashPassword(ctx context.Context, userId string, oldHash string, newHash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if user, ok := mr.users[userId]; ok && user.Password == oldHash {
+		user.Password = newHash
+	}
+	return nil
+}
+
+func (mr *MemoryUserRepository) UpdateProfile(ctx context.Context, userId string, input *domains.UpdateProfile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	user, ok := mr.users[userId]
+	if !ok {
+		return nil
+	}
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+	return nil
+}
+
+func (mr *MemoryUserRepository) ChangeEmail(ctx context.Context, userId string, previousEmail string, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for _, other := range mr.users {
+		if other.ID != userId && other.Email == email {
+			return ErrEmailExists
+		}
+	}
+	user, ok := mr.users[userId]
+	if !ok || user.Email != previousEmail {
+		return notFound("User not found!")
+	}
+	now := time.Now()
+	user.Email = email
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+func (mr *MemoryUserRepository) Users(ctx context.Context, query *domains.UserQuery) (*UserPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	field, descending, err := userSort(query.Sort)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeUserCursor(query.Cursor, query.Sort)
+	if err != nil {
+		return nil, err
+	}
+	var from User
+	if cursor != nil {
+		value, err := cursorValue(field, cursor.Value)
+		if err != nil {
+			return nil, err
+		}
+		from = User{ID: cursor.ID}
+		setUserSortValue(&from, field, value)
+	}
+
+	mr.mu.RLock()
+	matched := []User{}
+	for _, user := range mr.users {
+		if matchesUserFilters(user, query) {
+			matched = append(matched, *user)
+		}
+	}
+	mr.mu.RUnlock()
+
+	page := &UserPage{}
+	if query.Total {
+		total := len(matched)
+		page.Total = &total
+	}
+
+	ascending := cursor.ascending(descending)
+	sort.Slice(matched, func(i, j int) bool {
+		return (compareUsers(field, matched[i], matched[j]) < 0) == ascending
+	})
+
+	users := []User{}
+	for _, user := range matched {
+		if len(users) > query.Limit {
+			break
+		}
+		if cursor != nil {
+			order := compareUsers(field, user, from)
+			if (ascending && order <= 0) || (!ascending && order >= 0) {
+				continue
+			}
+		}
+		users = append(users, user)
+	}
+
+	page.fill(users, query, field, cursor)
+	return page, nil
+}
+
+func (mr *MemoryUserRepository) DeleteUserById(ctx context.Context, userId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	delete(mr.users, userId)
+	return nil
+}
+
+// matchesUserFilters is userFilters for a single user.
+func matchesUserFilters(user *User, query *domains.UserQuery) bool {
+	if query.Email != "" && !strings.HasPrefix(strings.ToLower(user.Email), strings.ToLower(query.Email)) {
+		return false
+	}
+	if query.Name != "" && !strings.HasPrefix(strings.ToLower(user.Name), strings.ToLower(query.Name)) {
+		return false
+	}
+	switch query.Status {
+	case UserStatusVerified:
+		if user.EmailVerifiedAt == nil {
+			return false
+		}
+	case UserStatusUnverified:
+		if user.EmailVerifiedAt != nil {
+			return false
+		}
+	}
+	if query.CreatedAfter != nil && user.CreatedAt.Before(*query.CreatedAfter) {
+		return false
+	}
+	if query.CreatedBefore != nil && !user.CreatedAt.Before(*query.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// compareUsers orders users by field and then by id, like the SQL sort.
+func compareUsers(field string, a, b User) int {
+	order := 0
+	switch field {
+	case "createdAt":
+		if a.CreatedAt.Before(b.CreatedAt) {
+			order = -1
+		} else if a.CreatedAt.After(b.CreatedAt) {
+			order = 1
+		}
+	case "email":
+		order = strings.Compare(a.Email, b.Email)
+	case "name":
+		order = strings.Compare(a.Name, b.Name)
+	}
+	if order != 0 {
+		return order
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+func setUserSortValue(user *User, field string, value interface{}) {
+	switch field {
+	case "createdAt":
+		user.CreatedAt = value.(time.Time)
+	case "email":
+		user.Email = value.(string)
+	case "name":
+		user.Name = value.(string)
+	}
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"api-auth/domains"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// User statuses that GET /users can filter by.
+const (
+	UserStatusVerified   = "verified"
+	UserStatusUnverified = "unverified"
+)
+
+// UserSortFields are the fields users can be sorted by and their columns.
+// The id breaks ties, so every sort is a total order that a cursor can
+// resume from.
+var UserSortFields = map[string]string{
+	"createdAt": "created_at",
+	"email":     "email",
+	"name":      "name",
+}
+
+// UserPage is one page of users. A cursor is empty when there is no page in
+// that direction. Total is only set when the query asked for it.
+type UserPage struct {
+	Users      []User
+	NextCursor string
+	PrevCursor string
+	Total      *int
+}
+
+// userCursor points at the row a page starts after, or before when Before
+// is set. It is only valid for the sort it was made for.
+type userCursor struct {
+	Sort   string `json:"s"`
+	Before bool   `json:"b,omitempty"`
+	Value  string `json:"v"`
+	ID     string `json:"id"`
+}
+
+// Users returns a page of the users matching the query. Paging is by
+// keyset, so a page costs the same wherever it is and rows that are added
+// or removed meanwhile do not shift the pages.
+func (ur *UserRepository) Users(ctx context.Context, query *domains.UserQuery) (*UserPage, error) {
+	field, descending, err := userSort(query.Sort)
+	if err != nil {
+		return nil, err
+	}
+	column := UserSortFields[field]
+
+	cursor, err := decodeUserCursor(query.Cursor, query.Sort)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if cursor != nil {
+		if value, err = cursorValue(field, cursor.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Going back reads the rows before the cursor in reverse and flips them
+	// afterwards.
+	operator, direction := ">", "ASC"
+	if !cursor.ascending(descending) {
+		operator, direction = "<", "DESC"
+	}
+
+	page := &UserPage{}
+	users := []User{}
+	err = withContext(ctx, ur.db, ur.timeouts.Read, func(db *gorm.DB) error {
+		filtered := userFilters(db.Model(&User{}), query)
+		if query.Total {
+			var total int
+			if result := filtered.Count(&total); result.Error != nil {
+				return errors.New("Cannot count users!")
+			}
+			page.Total = &total
+		}
+
+		paged := filtered
+		if cursor != nil {
+			paged = paged.Where(column+" "+operator+" ? OR ("+column+" = ? AND id "+operator+" ?)", value, value, cursor.ID)
+		}
+		result := paged.Order(column + " " + direction).Order("id " + direction).Limit(query.Limit + 1).Find(&users)
+		if result.Error != nil {
+			return errors.New("Cannot fetch users!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.fill(users, query, field, cursor)
+	return page, nil
+}
+
+// userSort splits a sort like "-createdAt" into the field and its direction.
+func userSort(sort string) (string, bool, error) {
+	field := strings.TrimPrefix(sort, "-")
+	if _, ok := UserSortFields[field]; !ok {
+		return "", false, invalidQuery("Invalid sort field!")
+	}
+	return field, strings.HasPrefix(sort, "-"), nil
+}
+
+// ascending tells whether the rows of a page are read in ascending order.
+// Going back from a cursor reads against the sort.
+func (c *userCursor) ascending(descending bool) bool {
+	before := c != nil && c.Before
+	return descending == before
+}
+
+// fill sets the users and cursors of the page from the rows read after
+// cursor, in reading order. There is one row more than the limit when
+// there are more pages.
+func (page *UserPage) fill(users []User, query *domains.UserQuery, field string, cursor *userCursor) {
+	before := cursor != nil && cursor.Before
+	more := len(users) > query.Limit
+	if more {
+		users = users[:query.Limit]
+	}
+	if before {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+	page.Users = users
+	if len(users) == 0 {
+		return
+	}
+
+	if (before && more) || (!before && cursor != nil) {
+		page.PrevCursor = encodeUserCursor(query.Sort, true, field, users[0])
+	}
+	if (!before && more) || before {
+		page.NextCursor = encodeUserCursor(query.Sort, false, field, users[len(users)-1])
+	}
+}
+
+func userFilters(db *gorm.DB, query *domains.UserQuery) *gorm.DB {
+	like := likeOperator(db)
+	if query.Email != "" {
+		db = db.Where("email "+like+" ? ESCAPE '!'", likePrefix(query.Email))
+	}
+	if query.Name != "" {
+		db = db.Where("name "+like+" ? ESCAPE '!'", likePrefix(query.Name))
+	}
+	switch query.Status {
+	case UserStatusVerified:
+		db = db.Where("email_verified_at IS NOT NULL")
+	case UserStatusUnverified:
+		db = db.Where("email_verified_at IS NULL")
+	}
+	if query.CreatedAfter != nil {
+		db = db.Where("created_at >= ?", columnTime(*query.CreatedAfter))
+	}
+	if query.CreatedBefore != nil {
+		db = db.Where("created_at < ?", columnTime(*query.CreatedBefore))
+	}
+	return db
+}
+
+// likePrefix escapes the wildcards of LIKE so the input only matches as a
+// prefix.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
+
+func encodeUserCursor(sort string, before bool, field string, user User) string {
+	value := user.Name
+	switch field {
+	case "createdAt":
+		value = user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "email":
+		value = user.Email
+	}
+	encoded, _ := json.Marshal(userCursor{Sort: sort, Before: before, Value: value, ID: user.ID})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeUserCursor returns nil for an empty cursor.
+func decodeUserCursor(encoded, sort string) (*userCursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	invalid := invalidQuery("Invalid cursor!")
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+	cursor := &userCursor{}
+	if err := json.Unmarshal(decoded, cursor); err != nil || cursor.ID == "" {
+		return nil, invalid
+	}
+	if cursor.Sort != sort {
+		return nil, invalidQuery("Cursor was made for another sort!")
+	}
+	return cursor, nil
+}
+
+func cursorValue(field, value string) (interface{}, error) {
+	if field != "createdAt" {
+		return value, nil
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, invalidQuery("Invalid cursor!")
+	}
+	return columnTime(createdAt), nil
+}
@@ -2,9 +2,12 @@ package repository
 
 import (
 	"api-auth/domains"
+	"context"
 	"database/sql"
+	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jinzhu/gorm"
@@ -35,29 +38,100 @@ func (s *Suite) SetupSuite() {
 	s.DB.LogMode(true)
 
 	s.userRepository = UserRepository{db: s.DB}
+
+	newUUID = func() string { return "uuid" }
 }
 
 func (s *Suite) TestUserRepository_SuccessGetUsers() {
-	rows := sqlmock.NewRows([]string{"id", "name", "email", "password"}).AddRow("uuid1", "name1", "email1", "pass1").AddRow("uuid2", "name2", "email2", "pass2")
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	columns := []string{"id", "name", "email", "password", "created_at"}
+	query := "SELECT * FROM `users` WHERE (email LIKE ? ESCAPE '!') ORDER BY created_at ASC,id ASC LIMIT 3"
+	rows := sqlmock.NewRows(columns).
+		AddRow("uuid1", "name1", "ka!e_1@gmail.com", "pass1", first).
+		AddRow("uuid2", "name2", "ka!e_2@gmail.com", "pass2", first).
+		AddRow("uuid3", "name3", "ka!e_3@gmail.com", "pass3", first.Add(time.Hour))
+
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `users` WHERE (email LIKE ? ESCAPE '!')")).
+		WithArgs("ka!!e!_%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	s.mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("ka!!e!_%").WillReturnRows(rows)
+
+	page, err := s.userRepository.Users(context.Background(), &domains.UserQuery{Limit: 2, Sort: "createdAt", Email: "ka!e_", Total: true})
+
+	s.Nil(err)
+	s.Len(page.Users, 2)
+	s.Equal(3, *page.Total)
+	s.Empty(page.PrevCursor)
+	s.NotEmpty(page.NextCursor)
+
+	// The next page resumes after uuid2, which shares its created_at with
+	// uuid1, and offers a way back.
+	query = "SELECT * FROM `users` WHERE (created_at > ? OR (created_at = ? AND id > ?)) ORDER BY created_at ASC,id ASC LIMIT 3"
+	s.mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(first.Local(), first.Local(), "uuid2").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("uuid3", "name3", "ka!e_3@gmail.com", "pass3", first.Add(time.Hour)))
+
+	next, err := s.userRepository.Users(context.Background(), &domains.UserQuery{Limit: 2, Sort: "createdAt", Cursor: page.NextCursor})
 
-	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users`")).WillReturnRows(rows)
+	s.Nil(err)
+	s.Len(next.Users, 1)
+	s.Empty(next.NextCursor)
+	s.NotEmpty(next.PrevCursor)
+
+	// Going back reads backwards from uuid3 and returns the rows in order.
+	query = "SELECT * FROM `users` WHERE (created_at < ? OR (created_at = ? AND id < ?)) ORDER BY created_at DESC,id DESC LIMIT 3"
+	s.mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(first.Add(time.Hour).Local(), first.Add(time.Hour).Local(), "uuid3").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("uuid2", "name2", "ka!e_2@gmail.com", "pass2", first).
+			AddRow("uuid1", "name1", "ka!e_1@gmail.com", "pass1", first))
 
-	users, err := s.userRepository.Users()
+	prev, err := s.userRepository.Users(context.Background(), &domains.UserQuery{Limit: 2, Sort: "createdAt", Cursor: next.PrevCursor})
 
-	s.NotEmpty(users)
-	s.NotNil(users)
 	s.Nil(err)
+	s.Equal("uuid1", prev.Users[0].ID)
+	s.Equal("uuid2", prev.Users[1].ID)
+	s.Empty(prev.PrevCursor)
+	s.NotEmpty(prev.NextCursor)
+	s.NoError(s.mock.ExpectationsWereMet())
 }
 
-func (s *Suite) TestUserRepository_FailGetUsers() {
+func (s *Suite) TestUserRepository_SuccessGetUsersDescending() {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	query := "SELECT * FROM `users` WHERE (email_verified_at IS NULL) AND (created_at >= ?) ORDER BY name DESC,id DESC LIMIT 11"
+	rows := sqlmock.NewRows([]string{"id", "name", "email", "password"}).AddRow("uuid1", "name1", "email1", "pass1")
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(after.Local()).WillReturnRows(rows)
 
+	page, err := s.userRepository.Users(context.Background(), &domains.UserQuery{Limit: 10, Sort: "-name", Status: UserStatusUnverified, CreatedAfter: &after})
+
+	s.Nil(err)
+	s.Len(page.Users, 1)
+	s.Nil(page.Total)
+	s.Empty(page.NextCursor)
+	s.Empty(page.PrevCursor)
+}
+
+func (s *Suite) TestUserRepository_FailGetUsers() {
 	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users`")).WillReturnError(gorm.ErrRecordNotFound)
 
-	users, err := s.userRepository.Users()
+	page, err := s.userRepository.Users(context.Background(), &domains.UserQuery{Limit: 20, Sort: "createdAt"})
 
-	s.Empty(users)
-	s.Nil(users)
-	s.NotNil(err)
+	s.Nil(page)
+	s.EqualError(err, "Cannot fetch users!")
+}
+
+func (s *Suite) TestUserRepository_FailGetUsersCursor() {
+	cursor := encodeUserCursor("email", false, "email", User{ID: "uuid1", Email: "kale@gmail.com"})
+
+	_, err := s.userRepository.Users(context.Background(), &domains.UserQuery{Limit: 20, Sort: "name", Cursor: cursor})
+	s.EqualError(err, "Cursor was made for another sort!")
+
+	_, err = s.userRepository.Users(context.Background(), &domains.UserQuery{Limit: 20, Sort: "name", Cursor: "not a cursor"})
+	s.EqualError(err, "Invalid cursor!")
+
+	_, err = s.userRepository.Users(context.Background(), &domains.UserQuery{Limit: 20, Sort: "password"})
+	s.EqualError(err, "Invalid sort field!")
 }
 
 func (s *Suite) TestUserRepository_SuccessFindByEmail() {
@@ -67,8 +141,9 @@ func (s *Suite) TestUserRepository_SuccessFindByEmail() {
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(email).WillReturnRows(rows)
 
-	user := s.userRepository.FindByEmail(email)
+	user, err := s.userRepository.FindByEmail(context.Background(), email)
 
+	s.NoError(err)
 	s.NotNil(user)
 	s.Equal(email, user.Email)
 }
@@ -79,8 +154,9 @@ func (s *Suite) TestUserRepository_FailFindByEmail() {
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(email).WillReturnError(gorm.ErrRecordNotFound)
 
-	user := s.userRepository.FindByEmail(email)
+	user, err := s.userRepository.FindByEmail(context.Background(), email)
 
+	s.NoError(err)
 	s.Nil(user)
 	s.Empty(user)
 }
@@ -98,8 +174,9 @@ func (s *Suite) TestUserRepository_SuccessFindById() {
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("valid_uuid").WillReturnRows(rows)
 
-	user := s.userRepository.FindById("valid_uuid")
+	user, err := s.userRepository.FindById(context.Background(), "valid_uuid")
 
+	s.NoError(err)
 	s.NotEmpty(user)
 	s.Equal(user1.ID, user.ID)
 
@@ -110,11 +187,23 @@ func (s *Suite) TestUserRepository_FailFindById() {
 
 	s.mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("invalid_uuid").WillReturnError(gorm.ErrRecordNotFound)
 
-	user := s.userRepository.FindById("invalid_uuid")
+	user, err := s.userRepository.FindById(context.Background(), "invalid_uuid")
 
+	s.NoError(err)
 	s.Empty(user)
 }
 
+func (s *Suite) TestUserRepository_ErrorFindById() {
+	query := "SELECT * FROM `users` WHERE (id = ?)"
+
+	s.mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("valid_uuid").WillReturnError(errors.New("connection reset"))
+
+	user, err := s.userRepository.FindById(context.Background(), "valid_uuid")
+
+	s.EqualError(err, "Cannot fetch user!")
+	s.Nil(user)
+}
+
 func (s *Suite) TestUserRepository_SuccessCreateUser() {
 	db, mockTemp, _ := sqlmock.New()
 	dbase, _ := gorm.Open("mysql", db)
@@ -128,13 +217,13 @@ func (s *Suite) TestUserRepository_SuccessCreateUser() {
 		PasswordConfirm: "password",
 	}
 
-	query := "INSERT INTO `users` (`id`,`name`,`email`,`password`) VALUES (?,?,?,?)"
+	query := "INSERT INTO `users` (`id`,`name`,`email`,`password`,`email_verified_at`,`verification_sent_at`,`email_change_sent_at`,`created_at`) VALUES (?,?,?,?,?,?,?,?)"
 
 	mockTemp.ExpectBegin()
-	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs("uuid", input.Name, input.Email, input.Password).WillReturnResult(sqlmock.NewResult(1, 1))
+	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs("uuid", input.Name, input.Email, input.Password, nil, nil, nil, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
 	mockTemp.ExpectCommit()
 
-	err := repos.CreateUser(input)
+	_, err := repos.CreateUser(context.Background(), input)
 
 	s.NoError(err)
 }
@@ -152,13 +241,13 @@ func (s *Suite) TestUserRepository_FailCreateUser() {
 		PasswordConfirm: "password",
 	}
 
-	query := "INSERT INTO `users` (`id`,`name`,`email`,`password`) VALUES (?,?,?,?)"
+	query := "INSERT INTO `users` (`id`,`name`,`email`,`password`,`email_verified_at`,`verification_sent_at`,`email_change_sent_at`,`created_at`) VALUES (?,?,?,?,?,?,?,?)"
 
 	mockTemp.ExpectBegin()
-	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs("uuid", input.Name, input.Email, input.Password).WillReturnError(gorm.Errors{})
-	mockTemp.ExpectCommit()
+	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs("uuid", input.Name, input.Email, input.Password, nil, nil, nil, sqlmock.AnyArg()).WillReturnError(gorm.Errors{})
+	mockTemp.ExpectRollback()
 
-	err := repos.CreateUser(input)
+	_, err := repos.CreateUser(context.Background(), input)
 
 	s.Error(err)
 }
@@ -175,7 +264,7 @@ func (s *Suite) TestUserRepository_SuccessDeleteUser() {
 	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs("userId").WillReturnResult(sqlmock.NewResult(1, 1))
 	mockTemp.ExpectCommit()
 
-	err := repos.DeleteUserById("userId")
+	err := repos.DeleteUserById(context.Background(), "userId")
 
 	s.NoError(err)
 }
@@ -190,9 +279,9 @@ func (s *Suite) TestUserRepository_FailDeleteUser() {
 
 	mockTemp.ExpectBegin()
 	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs("userId").WillReturnError(gorm.ErrRecordNotFound)
-	mockTemp.ExpectCommit()
+	mockTemp.ExpectRollback()
 
-	err := repos.DeleteUserById("userId")
+	err := repos.DeleteUserById(context.Background(), "userId")
 
 	s.Error(err)
 }
@@ -204,7 +293,6 @@ func (s *Suite) TestUserRepository_SuccessChangePassword() {
 	repos := UserRepository{db: dbase}
 
 	input := &domains.ChangePassword{
-		Email:           "email@gmial.com",
 		NewPassword:     "hash_password",
 		PasswordConfirm: "hash_password",
 	}
@@ -215,7 +303,7 @@ func (s *Suite) TestUserRepository_SuccessChangePassword() {
 	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs(input.NewPassword, "uuid").WillReturnResult(sqlmock.NewResult(0, 1))
 	mockTemp.ExpectCommit()
 
-	err := repos.UpdatePassword(input, "uuid")
+	err := repos.UpdatePassword(context.Background(), "uuid", input.NewPassword)
 
 	s.Nil(err)
 	s.NoError(err)
@@ -228,7 +316,6 @@ func (s *Suite) TestUserRepository_FailChangePassword() {
 	repos := UserRepository{db: dbase}
 
 	input := &domains.ChangePassword{
-		Email:           "email@gmial.com",
 		NewPassword:     "hash_password",
 		PasswordConfirm: "hash_password",
 	}
@@ -237,14 +324,50 @@ func (s *Suite) TestUserRepository_FailChangePassword() {
 
 	mockTemp.ExpectBegin()
 	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs("hash_password", "uuid").WillReturnError(gorm.Errors{})
-	mockTemp.ExpectCommit()
+	mockTemp.ExpectRollback()
 
-	err := repos.UpdatePassword(input, "uuid")
+	err := repos.UpdatePassword(context.Background(), "uuid", input.NewPassword)
 
 	s.NotNil(err)
 	s.Error(err)
 }
 
+func (s *Suite) TestUserRepository_RehashPassword() {
+	db, mockTemp, _ := sqlmock.New()
+	dbase, _ := gorm.Open("mysql", db)
+	repos := UserRepository{db: dbase}
+
+	query := "UPDATE `users` SET `password` = ? WHERE (id = ? AND password = ?)"
+
+	mockTemp.ExpectBegin()
+	mockTemp.ExpectExec(regexp.QuoteMeta(query)).WithArgs("new_hash", "uuid", "old_hash").WillReturnResult(sqlmock.NewResult(0, 1))
+	mockTemp.ExpectCommit()
+
+	err := repos.RehashPassword(context.Background(), "uuid", "old_hash", "new_hash")
+
+	s.NoError(err)
+	s.NoError(mockTemp.ExpectationsWereMet())
+}
+
+func (s *Suite) TestUserRepository_Timeouts() {
+	db, mockTemp, _ := sqlmock.New()
+	dbase, _ := gorm.Open("mysql", db)
+	repos := UserRepository{db: dbase, timeouts: Timeouts{Read: 10 * time.Millisecond, Write: 10 * time.Millisecond}}
+
+	mockTemp.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users`")).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mockTemp.ExpectBegin()
+	mockTemp.ExpectExec(regexp.QuoteMeta("UPDATE `users` SET `password` = ?")).
+		WillDelayFor(time.Second).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err := repos.Users(context.Background(), &domains.UserQuery{Limit: 2, Sort: "createdAt"})
+	s.ErrorIs(err, context.DeadlineExceeded)
+	err = repos.UpdatePassword(context.Background(), "uuid", "hash_password")
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
+
 func TestSuiteRepository(t *testing.T) {
 	suite.Run(t, new(Suite))
 }
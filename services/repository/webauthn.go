@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// WebAuthnCredential is a registered passkey. ID is the credential id in
+// base64url and PublicKey the COSE key the authenticator returned.
+type WebAuthnCredential struct {
+	ID        string `json:"id" gorm:"primary_key"`
+	UserID    string `json:"-" gorm:"index"`
+	Name      string `json:"name"`
+	PublicKey []byte `json:"-"`
+	Algorithm int    `json:"algorithm"`
+	// SignCount is the last signature counter seen, a credential whose
+	// counter goes backwards has been cloned.
+	SignCount  uint32     `json:"-"`
+	AAGUID     string     `json:"aaguid"`
+	Transports string     `json:"transports"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// WebAuthnSession keeps the challenge of a ceremony between its begin and
+// finish requests. Purpose tells the ceremonies apart so a challenge handed
+// out for one cannot be answered in another.
+type WebAuthnSession struct {
+	ID        string `gorm:"primary_key"`
+	UserID    string
+	Challenge string
+	Purpose   string
+	ExpiresAt time.Time
+}
+
+type WebAuthnRepository struct {
+	db       *gorm.DB
+	timeouts Timeouts
+}
+
+type WebAuthnRepositoryInterface interface {
+	CreateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error
+	FindWebAuthnCredential(ctx context.Context, credentialId string) *WebAuthnCredential
+	UserWebAuthnCredentials(ctx context.Context, userId string) ([]WebAuthnCredential, error)
+	UpdateWebAuthnSignCount(ctx context.Context, credentialId string, oldCount, newCount uint32) error
+	DeleteWebAuthnCredential(ctx context.Context, userId, credentialId string) error
+	CreateWebAuthnSession(ctx context.Context, userId, challenge, purpose string, expiresAt time.Time) (*WebAuthnSession, error)
+	UseWebAuthnSession(ctx context.Context, sessionId, purpose string) *WebAuthnSession
+}
+
+func NewWebAuthnRepository(db *gorm.DB, timeouts Timeouts) WebAuthnRepositoryInterface {
+	return &WebAuthnRepository{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (wr *WebAuthnRepository) CreateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error {
+	return withContext(ctx, wr.db, wr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Create(credential)
+		if result.Error != nil {
+			return errors.New("Cannot save passkey!")
+		}
+		return nil
+	})
+}
+
+func (wr *WebAuthnRepository) FindWebAuthnCredential(ctx context.Context, credentialId string) *WebAuthnCredential {
+	credential := WebAuthnCredential{}
+
+	err := withContext(ctx, wr.db, wr.timeouts.Read, func(db *gorm.DB) error {
+		return db.First(&credential, "id = ?", credentialId).Error
+	})
+	if err != nil {
+		return nil
+	}
+	return &credential
+}
+
+func (wr *WebAuthnRepository) UserWebAuthnCredentials(ctx context.Context, userId string) ([]WebAuthnCredential, error) {
+	credentials := []WebAuthnCredential{}
+
+	err := withContext(ctx, wr.db, wr.timeouts.Read, func(db *gorm.DB) error {
+		if err := db.Where("user_id = ?", userId).Order("created_at").Find(&credentials).Error; err != nil {
+			return errors.New("Cannot fetch passkeys!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// UpdateWebAuthnSignCount stores the counter of an accepted assertion. The
+// update only applies while the stored counter is still oldCount, so of two
+// concurrent logins with the same counter only one goes through. That cannot
+// be told for authenticators that always report zero.
+func (wr *WebAuthnRepository) UpdateWebAuthnSignCount(ctx context.Context, credentialId string, oldCount, newCount uint32) error {
+	return withContext(ctx, wr.db, wr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Model(&WebAuthnCredential{}).
+			Where("id = ? AND sign_count = ?", credentialId, oldCount).
+			Updates(map[string]interface{}{"sign_count": newCount, "last_used_at": time.Now()})
+		if result.Error != nil {
+			return errors.New("Cannot update passkey!")
+		}
+		if result.RowsAffected == 0 && newCount != oldCount {
+			return conflict("Passkey was used concurrently!")
+		}
+		return nil
+	})
+}
+
+func (wr *WebAuthnRepository) DeleteWebAuthnCredential(ctx context.Context, userId, credentialId string) error {
+	return withContext(ctx, wr.db, wr.timeouts.Write, func(db *gorm.DB) error {
+		result := db.Where("id = ? AND user_id = ?", credentialId, userId).Delete(&WebAuthnCredential{})
+		if result.Error != nil {
+			return errors.New("Cannot delete passkey!")
+		}
+		if result.RowsAffected == 0 {
+			return notFound("Passkey not found!")
+		}
+		return nil
+	})
+}
+
+func (wr *WebAuthnRepository) CreateWebAuthnSession(ctx context.Context, userId, challenge, purpose string, expiresAt time.Time) (*WebAuthnSession, error) {
+	session := WebAuthnSession{
+		ID:        newUUID(),
+		UserID:    userId,
+		Challenge: challenge,
+		Purpose:   purpose,
+		ExpiresAt: expiresAt,
+	}
+
+	err := withContext(ctx, wr.db, wr.timeouts.Write, func(db *gorm.DB) error {
+		if err := db.Create(&session).Error; err != nil {
+			return errors.New("Cannot start passkey ceremony!")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UseWebAuthnSession returns the session and deletes it, so every challenge
+// is answered at most once. Expired sessions are not returned.
+func (wr *WebAuthnRepository) UseWebAuthnSession(ctx context.Context, sessionId, purpose string) *WebAuthnSession {
+	session := WebAuthnSession{}
+
+	used := errors.New("Passkey ceremony already used!")
+	err := withTransaction(ctx, wr.db, wr.timeouts.Write, func(tx *gorm.DB) error {
+		if err := tx.First(&session, "id = ? AND purpose = ?", sessionId, purpose).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", sessionId).Delete(&WebAuthnSession{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return used
+		}
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil
+	}
+	return &session
+}
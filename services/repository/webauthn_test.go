@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebAuthnRepository_UpdateWebAuthnSignCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := WebAuthnRepository{db: dbase}
+
+	query := "UPDATE `web_authn_credentials` SET `last_used_at` = ?, `sign_count` = ? WHERE (id = ? AND sign_count = ?)"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), uint32(8), "cred", uint32(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), uint32(8), "cred", uint32(7)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repos.UpdateWebAuthnSignCount(context.Background(), "cred", 7, 8))
+	assert.EqualError(t, repos.UpdateWebAuthnSignCount(context.Background(), "cred", 7, 8), "Passkey was used concurrently!")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebAuthnRepository_UseWebAuthnSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	dbase, _ := gorm.Open("mysql", db)
+	repos := WebAuthnRepository{db: dbase}
+
+	selectQuery := "SELECT * FROM `web_authn_sessions` WHERE (id = ? AND purpose = ?)"
+	deleteQuery := "DELETE FROM `web_authn_sessions` WHERE (id = ?)"
+	columns := []string{"id", "user_id", "challenge", "purpose", "expires_at"}
+
+	t.Run("single_use", func(t *testing.T) {
+		row := sqlmock.NewRows(columns).AddRow("session", "uuid", "challenge", "register", time.Now().Add(time.Minute))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs("session", "register").WillReturnRows(row)
+		mock.ExpectExec(regexp.QuoteMeta(deleteQuery)).WithArgs("session").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		session := repos.UseWebAuthnSession(context.Background(), "session", "register")
+
+		assert.Equal(t, "challenge", session.Challenge)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("used_concurrently", func(t *testing.T) {
+		row := sqlmock.NewRows(columns).AddRow("session", "uuid", "challenge", "register", time.Now().Add(time.Minute))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs("session", "register").WillReturnRows(row)
+		mock.ExpectExec(regexp.QuoteMeta(deleteQuery)).WithArgs("session").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		assert.Nil(t, repos.UseWebAuthnSession(context.Background(), "session", "register"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		row := sqlmock.NewRows(columns).AddRow("session", "uuid", "challenge", "register", time.Now().Add(-time.Minute))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs("session", "register").WillReturnRows(row)
+		mock.ExpectExec(regexp.QuoteMeta(deleteQuery)).WithArgs("session").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Nil(t, repos.UseWebAuthnSession(context.Background(), "session", "register"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
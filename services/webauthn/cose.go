@@ -0,0 +1,144 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE algorithm identifiers, see the IANA COSE registry.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+var supportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters.
+const (
+	coseKty = 1
+	coseAlg = 3
+	// EC2 and OKP keys
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	// RSA keys
+	coseN = -1
+	coseE = -2
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+// PublicKey is a credential public key decoded from its COSE form.
+type PublicKey struct {
+	Algorithm int
+	key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key as found in authenticator data.
+func ParsePublicKey(raw []byte) (*PublicKey, error) {
+	invalid := errors.New("Invalid credential public key!")
+
+	fields := map[int]interface{}{}
+	if err := cbor.Unmarshal(raw, &fields); err != nil {
+		return nil, invalid
+	}
+	kty, _ := coseInt(fields[coseKty])
+	alg, _ := coseInt(fields[coseAlg])
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := coseInt(fields[coseCrv])
+		x, _ := fields[coseX].([]byte)
+		y, _ := fields[coseY].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, invalid
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, invalid
+		}
+		return &PublicKey{Algorithm: alg, key: key}, nil
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := coseInt(fields[coseCrv])
+		x, _ := fields[coseX].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, invalid
+		}
+		return &PublicKey{Algorithm: alg, key: ed25519.PublicKey(x)}, nil
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := fields[coseN].([]byte)
+		e, _ := fields[coseE].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, invalid
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		return &PublicKey{Algorithm: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, nil
+	}
+	return nil, errors.New("Unsupported credential algorithm!")
+}
+
+// Verify checks a WebAuthn signature over data.
+func (k *PublicKey) Verify(data, signature []byte) error {
+	digest := sha256.Sum256(data)
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Algorithm == AlgES256 && ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if k.Algorithm == AlgEdDSA && ed25519.Verify(key, data, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if k.Algorithm == AlgRS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+	return errors.New("Invalid signature!")
+}
+
+// EncodePublicKey is the inverse of ParsePublicKey for the supported key
+// types, used by software authenticators.
+func EncodePublicKey(key crypto.PublicKey) ([]byte, error) {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		return cbor.Marshal(map[int]interface{}{coseKty: ktyEC2, coseAlg: AlgES256, coseCrv: crvP256, coseX: x, coseY: y})
+	case ed25519.PublicKey:
+		return cbor.Marshal(map[int]interface{}{coseKty: ktyOKP, coseAlg: AlgEdDSA, coseCrv: crvEd25519, coseX: []byte(key)})
+	case *rsa.PublicKey:
+		e := big.NewInt(int64(key.E)).Bytes()
+		return cbor.Marshal(map[int]interface{}{coseKty: ktyRSA, coseAlg: AlgRS256, coseN: key.N.Bytes(), coseE: e})
+	}
+	return nil, errors.New("Unsupported key type!")
+}
+
+func coseInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int64:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	}
+	return 0, false
+}
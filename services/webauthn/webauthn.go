@@ -0,0 +1,358 @@
+// Package webauthn verifies the registration and authentication ceremonies
+// of the W3C Web Authentication spec. It supports the "none" and "packed"
+// attestation formats without checking attestation trust, which is what a
+// relying party that only wants passkeys needs.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// RelyingParty is this server as WebAuthn sees it. ID is the domain
+// credentials are scoped to and Origins lists the web origins allowed to run
+// ceremonies for it.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+	Timeout time.Duration
+}
+
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+)
+
+const (
+	UserVerificationRequired  = "required"
+	UserVerificationPreferred = "preferred"
+)
+
+var encoding = base64.RawURLEncoding
+
+// Encode is the base64url encoding WebAuthn uses for binary values in JSON.
+func Encode(b []byte) string {
+	return encoding.EncodeToString(b)
+}
+
+// Decode accepts base64url with or without padding.
+func Decode(s string) ([]byte, error) {
+	return encoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// NewChallenge returns 32 random bytes in base64url.
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return Encode(b), nil
+}
+
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the publicKey argument of navigator.credentials.create
+// with binary values in base64url.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	Attestation            string                 `json:"attestation"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+}
+
+// RequestOptions is the publicKey argument of navigator.credentials.get. An
+// empty AllowCredentials asks for a discoverable credential.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// CreationOptions asks for a discoverable credential so it can be used
+// without typing an email.
+func (rp *RelyingParty) CreationOptions(challenge string, userHandle []byte, name, displayName string, exclude []CredentialDescriptor) *CreationOptions {
+	params := make([]CredentialParameter, len(supportedAlgorithms))
+	for i, alg := range supportedAlgorithms {
+		params[i] = CredentialParameter{Type: "public-key", Alg: alg}
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return &CreationOptions{
+		Challenge:        challenge,
+		RP:               RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User:             UserEntity{ID: Encode(userHandle), Name: name, DisplayName: displayName},
+		PubKeyCredParams: params,
+		Timeout:          rp.Timeout.Milliseconds(),
+		Attestation:      "none",
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: UserVerificationPreferred,
+		},
+		ExcludeCredentials: exclude,
+	}
+}
+
+func (rp *RelyingParty) RequestOptions(challenge string, allow []CredentialDescriptor, userVerification string) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// Credential is a verified new public key credential.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	Algorithm int
+	SignCount uint32
+	AAGUID    []byte
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony, challenge string) error {
+	data := clientData{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return errors.New("Invalid client data!")
+	}
+	if data.Type != ceremony {
+		return errors.New("Unexpected ceremony type!")
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimRight(data.Challenge, "=")), []byte(challenge)) != 1 {
+		return errors.New("Challenge mismatch!")
+	}
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return errors.New("Origin not allowed!")
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	invalid := errors.New("Invalid authenticator data!")
+	if len(raw) < 37 {
+		return nil, invalid
+	}
+	data := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	if data.flags&flagAttestedCredentialData == 0 {
+		return data, nil
+	}
+
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, invalid
+	}
+	data.aaguid = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+		return nil, invalid
+	}
+	data.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	// The key is followed by optional extensions, so its length is only
+	// known after decoding it.
+	var key cbor.RawMessage
+	decoder := cbor.NewDecoder(bytes.NewReader(rest))
+	if err := decoder.Decode(&key); err != nil {
+		return nil, invalid
+	}
+	data.publicKey = rest[:decoder.NumBytesRead()]
+	return data, nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(data *authenticatorData, requireUV bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(data.rpIDHash, rpIDHash[:]) != 1 {
+		return errors.New("Credential is for another relying party!")
+	}
+	if data.flags&flagUserPresent == 0 {
+		return errors.New("User presence is required!")
+	}
+	if requireUV && data.flags&flagUserVerified == 0 {
+		return errors.New("User verification is required!")
+	}
+	return nil
+}
+
+type attestationObject struct {
+	Format    string          `cbor:"fmt"`
+	Statement cbor.RawMessage `cbor:"attStmt"`
+	AuthData  []byte          `cbor:"authData"`
+}
+
+type packedStatement struct {
+	Alg int      `cbor:"alg"`
+	Sig []byte   `cbor:"sig"`
+	X5C [][]byte `cbor:"x5c"`
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create
+// against the challenge that was handed out for it.
+func (rp *RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestation []byte, requireUV bool) (*Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	object := attestationObject{}
+	if err := cbor.Unmarshal(attestation, &object); err != nil {
+		return nil, errors.New("Invalid attestation object!")
+	}
+	data, err := parseAuthenticatorData(object.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(data, requireUV); err != nil {
+		return nil, err
+	}
+	if data.credentialID == nil {
+		return nil, errors.New("Attested credential data is missing!")
+	}
+	key, err := ParsePublicKey(data.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, object.AuthData...), clientDataHash[:]...)
+	switch object.Format {
+	case "none":
+	case "packed":
+		statement := packedStatement{}
+		if err := cbor.Unmarshal(object.Statement, &statement); err != nil {
+			return nil, errors.New("Invalid attestation statement!")
+		}
+		if len(statement.X5C) == 0 {
+			// Self attestation, signed by the new credential itself.
+			if statement.Alg != key.Algorithm {
+				return nil, errors.New("Attestation algorithm mismatch!")
+			}
+			if err := key.Verify(signed, statement.Sig); err != nil {
+				return nil, errors.New("Invalid attestation signature!")
+			}
+			break
+		}
+		// The certificate chain is not checked against any trust anchors,
+		// only the signature over the new credential is.
+		certificate, err := x509.ParseCertificate(statement.X5C[0])
+		if err != nil {
+			return nil, errors.New("Invalid attestation certificate!")
+		}
+		attestationKey := &PublicKey{Algorithm: statement.Alg, key: certificate.PublicKey}
+		if err := attestationKey.Verify(signed, statement.Sig); err != nil {
+			return nil, errors.New("Invalid attestation signature!")
+		}
+	default:
+		return nil, errors.New("Unsupported attestation format " + object.Format + "!")
+	}
+
+	return &Credential{
+		ID:        data.credentialID,
+		PublicKey: data.publicKey,
+		Algorithm: key.Algorithm,
+		SignCount: data.signCount,
+		AAGUID:    data.aaguid,
+	}, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get with the
+// stored public key of the credential and returns the new signature counter.
+// A counter that did not move forward means the credential was cloned.
+func (rp *RelyingParty) VerifyAssertion(challenge string, clientDataJSON, authData, signature, publicKey []byte, storedSignCount uint32, requireUV bool) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	data, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(data, requireUV); err != nil {
+		return 0, err
+	}
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	if err := key.Verify(signed, signature); err != nil {
+		return 0, errors.New("Invalid assertion signature!")
+	}
+
+	// Authenticators that do not count report zero every time.
+	if (data.signCount != 0 || storedSignCount != 0) && data.signCount <= storedSignCount {
+		return 0, errors.New("Signature counter did not increase, the authenticator may be cloned!")
+	}
+	return data.signCount, nil
+}
@@ -0,0 +1,140 @@
+package webauthn_test
+
+import (
+	"api-auth/services/webauthn"
+	"api-auth/services/webauthn/webauthntest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var rp = &webauthn.RelyingParty{
+	ID:      "localhost",
+	Name:    "api-auth",
+	Origins: []string{"http://localhost:3000"},
+	Timeout: time.Minute,
+}
+
+func register(t *testing.T, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	challenge, _ := webauthn.NewChallenge()
+	response, err := authenticator.Create(rp.CreationOptions(challenge, []byte("uuid"), "kale@gmail.com", "kale", nil))
+	assert.NoError(t, err)
+
+	clientData, _ := webauthn.Decode(response.Response.ClientDataJSON)
+	attestation, _ := webauthn.Decode(response.Response.AttestationObject)
+	credential, err := rp.VerifyRegistration(challenge, clientData, attestation, false)
+	assert.NoError(t, err)
+	return credential
+}
+
+func login(authenticator *webauthntest.Authenticator, credential *webauthn.Credential, signCount uint32, requireUV bool) (uint32, error) {
+	challenge, _ := webauthn.NewChallenge()
+	allow := []webauthn.CredentialDescriptor{{Type: "public-key", ID: webauthn.Encode(credential.ID)}}
+	response, err := authenticator.Get(rp.RequestOptions(challenge, allow, webauthn.UserVerificationRequired))
+	if err != nil {
+		return 0, err
+	}
+
+	clientData, _ := webauthn.Decode(response.Response.ClientDataJSON)
+	authData, _ := webauthn.Decode(response.Response.AuthenticatorData)
+	signature, _ := webauthn.Decode(response.Response.Signature)
+	return rp.VerifyAssertion(challenge, clientData, authData, signature, credential.PublicKey, signCount, requireUV)
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm int
+		format    string
+	}{
+		{name: "es256_none", algorithm: webauthn.AlgES256, format: "none"},
+		{name: "es256_packed", algorithm: webauthn.AlgES256, format: "packed"},
+		{name: "eddsa_packed", algorithm: webauthn.AlgEdDSA, format: "packed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			authenticator := webauthntest.New("localhost", "http://localhost:3000")
+			authenticator.Algorithm = test.algorithm
+			authenticator.Format = test.format
+
+			credential := register(t, authenticator)
+			assert.Equal(t, test.algorithm, credential.Algorithm)
+			assert.Len(t, credential.ID, 16)
+
+			signCount, err := login(authenticator, credential, 0, true)
+			assert.NoError(t, err)
+			assert.Equal(t, uint32(0), signCount)
+		})
+	}
+}
+
+func TestVerifyRegistration_Rejects(t *testing.T) {
+	authenticator := webauthntest.New("localhost", "http://localhost:3000")
+	challenge, _ := webauthn.NewChallenge()
+	response, _ := authenticator.Create(rp.CreationOptions(challenge, []byte("uuid"), "kale@gmail.com", "kale", nil))
+	clientData, _ := webauthn.Decode(response.Response.ClientDataJSON)
+	attestation, _ := webauthn.Decode(response.Response.AttestationObject)
+
+	other, _ := webauthn.NewChallenge()
+	_, err := rp.VerifyRegistration(other, clientData, attestation, false)
+	assert.EqualError(t, err, "Challenge mismatch!")
+
+	otherRP := *rp
+	otherRP.ID = "evil.example"
+	otherRP.Origins = []string{"http://localhost:3000"}
+	_, err = otherRP.VerifyRegistration(challenge, clientData, attestation, false)
+	assert.EqualError(t, err, "Credential is for another relying party!")
+
+	otherOrigin := *rp
+	otherOrigin.Origins = []string{"https://example.com"}
+	_, err = otherOrigin.VerifyRegistration(challenge, clientData, attestation, false)
+	assert.EqualError(t, err, "Origin not allowed!")
+
+	_, err = rp.VerifyRegistration(challenge, clientData, attestation[:len(attestation)-8], false)
+	assert.Error(t, err)
+
+	unverified := webauthntest.New("localhost", "http://localhost:3000")
+	unverified.UserVerified = false
+	response, _ = unverified.Create(rp.CreationOptions(challenge, []byte("uuid"), "kale@gmail.com", "kale", nil))
+	clientData, _ = webauthn.Decode(response.Response.ClientDataJSON)
+	attestation, _ = webauthn.Decode(response.Response.AttestationObject)
+	_, err = rp.VerifyRegistration(challenge, clientData, attestation, true)
+	assert.EqualError(t, err, "User verification is required!")
+}
+
+func TestVerifyAssertion_Rejects(t *testing.T) {
+	authenticator := webauthntest.New("localhost", "http://localhost:3000")
+	authenticator.CountSignatures = true
+	credential := register(t, authenticator)
+
+	signCount, err := login(authenticator, credential, 0, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), signCount)
+
+	// The counter has to move past the stored value.
+	_, err = login(authenticator, credential, 5, true)
+	assert.EqualError(t, err, "Signature counter did not increase, the authenticator may be cloned!")
+
+	// A key of another credential does not verify the signature.
+	other := register(t, webauthntest.New("localhost", "http://localhost:3000"))
+	challenge, _ := webauthn.NewChallenge()
+	allow := []webauthn.CredentialDescriptor{{Type: "public-key", ID: webauthn.Encode(credential.ID)}}
+	response, _ := authenticator.Get(rp.RequestOptions(challenge, allow, webauthn.UserVerificationRequired))
+	clientData, _ := webauthn.Decode(response.Response.ClientDataJSON)
+	authData, _ := webauthn.Decode(response.Response.AuthenticatorData)
+	signature, _ := webauthn.Decode(response.Response.Signature)
+	_, err = rp.VerifyAssertion(challenge, clientData, authData, signature, other.PublicKey, 0, false)
+	assert.EqualError(t, err, "Invalid assertion signature!")
+
+	// A registration response is not an assertion.
+	_, err = rp.VerifyAssertion(challenge, []byte(`{"type":"webauthn.create","challenge":"`+challenge+`","origin":"http://localhost:3000"}`), authData, signature, credential.PublicKey, 0, false)
+	assert.EqualError(t, err, "Unexpected ceremony type!")
+
+	authenticator.UserVerified = false
+	_, err = login(authenticator, credential, 1, true)
+	assert.EqualError(t, err, "User verification is required!")
+	_, err = login(authenticator, credential, 1, false)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,223 @@
+// Package webauthntest provides a software authenticator that performs the
+// client side of WebAuthn ceremonies, for tests.
+package webauthntest
+
+import (
+	"api-auth/services/webauthn"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Authenticator holds discoverable credentials in memory. The exported
+// fields change how it behaves to exercise the failure paths of a relying
+// party.
+type Authenticator struct {
+	RPID   string
+	Origin string
+	// Algorithm of new credentials, webauthn.AlgES256 unless set.
+	Algorithm int
+	// Format is the attestation format, "none" unless set to "packed".
+	Format string
+	// UserVerified sets the UV flag, authenticators with a PIN or biometric
+	// do.
+	UserVerified bool
+	// CountSignatures makes the signature counter go up on every use,
+	// otherwise it stays at zero like most passkey providers.
+	CountSignatures bool
+
+	credentials map[string]*credential
+}
+
+type credential struct {
+	id         []byte
+	key        crypto.Signer
+	userHandle []byte
+	signCount  uint32
+}
+
+func New(rpID, origin string) *Authenticator {
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		Algorithm:    webauthn.AlgES256,
+		Format:       "none",
+		UserVerified: true,
+		credentials:  map[string]*credential{},
+	}
+}
+
+// Credential is the JSON form of a PublicKeyCredential as browsers
+// serialize it, with binary values in base64url.
+type Credential struct {
+	ID       string                `json:"id"`
+	RawID    string                `json:"rawId"`
+	Type     string                `json:"type"`
+	Response AuthenticatorResponse `json:"response"`
+}
+
+type AuthenticatorResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON"`
+	AttestationObject string   `json:"attestationObject,omitempty"`
+	AuthenticatorData string   `json:"authenticatorData,omitempty"`
+	Signature         string   `json:"signature,omitempty"`
+	UserHandle        string   `json:"userHandle,omitempty"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// Create runs navigator.credentials.create.
+func (a *Authenticator) Create(options *webauthn.CreationOptions) (*Credential, error) {
+	var key crypto.Signer
+	var err error
+	switch a.Algorithm {
+	case webauthn.AlgEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+	userHandle, err := webauthn.Decode(options.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	cred := &credential{id: make([]byte, 16), key: key, userHandle: userHandle}
+	if _, err := rand.Read(cred.id); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := webauthn.EncodePublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	attested := make([]byte, 18)
+	binary.BigEndian.PutUint16(attested[16:], uint16(len(cred.id)))
+	attested = append(append(attested, cred.id...), publicKey...)
+	authData := a.authenticatorData(0x40, 0)
+	authData = append(authData, attested...)
+
+	clientDataJSON, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	statement := map[string]interface{}{}
+	if a.Format == "packed" {
+		signature, err := sign(key, authData, clientDataJSON)
+		if err != nil {
+			return nil, err
+		}
+		statement = map[string]interface{}{"alg": a.algorithmOf(key), "sig": signature}
+	}
+	attestation, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      a.Format,
+		"attStmt":  statement,
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials[webauthn.Encode(cred.id)] = cred
+	return &Credential{
+		ID:    webauthn.Encode(cred.id),
+		RawID: webauthn.Encode(cred.id),
+		Type:  "public-key",
+		Response: AuthenticatorResponse{
+			ClientDataJSON:    webauthn.Encode(clientDataJSON),
+			AttestationObject: webauthn.Encode(attestation),
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Get runs navigator.credentials.get. With an empty allow list it picks any
+// credential of the relying party, like a discoverable login.
+func (a *Authenticator) Get(options *webauthn.RequestOptions) (*Credential, error) {
+	var cred *credential
+	for _, allowed := range options.AllowCredentials {
+		if c, ok := a.credentials[allowed.ID]; ok {
+			cred = c
+			break
+		}
+	}
+	if len(options.AllowCredentials) == 0 {
+		for _, c := range a.credentials {
+			cred = c
+			break
+		}
+	}
+	if cred == nil {
+		return nil, errors.New("no matching credential")
+	}
+
+	if a.CountSignatures {
+		cred.signCount++
+	}
+	authData := a.authenticatorData(0, cred.signCount)
+	clientDataJSON, err := a.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := sign(cred.key, authData, clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:    webauthn.Encode(cred.id),
+		RawID: webauthn.Encode(cred.id),
+		Type:  "public-key",
+		Response: AuthenticatorResponse{
+			ClientDataJSON:    webauthn.Encode(clientDataJSON),
+			AuthenticatorData: webauthn.Encode(authData),
+			Signature:         webauthn.Encode(signature),
+			UserHandle:        webauthn.Encode(cred.userHandle),
+		},
+	}, nil
+}
+
+func (a *Authenticator) authenticatorData(flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags |= 0x01
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	data := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], signCount)
+	return data
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) algorithmOf(key crypto.Signer) int {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return webauthn.AlgEdDSA
+	}
+	return webauthn.AlgES256
+}
+
+func sign(key crypto.Signer, authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return key.Sign(rand.Reader, signed, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(signed)
+	return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}